@@ -1,19 +1,34 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"expvar"
 	"log"
+	"net"
 	"net/http"
+	"os"
 	"time"
 
+	walletv1 "github.com/Hlompy/Wallet/api/wallet/v1"
 	"github.com/Hlompy/Wallet/internal/config"
 	"github.com/Hlompy/Wallet/internal/db"
+	"github.com/Hlompy/Wallet/internal/grpcapi"
 	"github.com/Hlompy/Wallet/internal/handler"
+	"github.com/Hlompy/Wallet/internal/model"
+	"github.com/Hlompy/Wallet/internal/outbox"
 	"github.com/Hlompy/Wallet/internal/repository"
+	"github.com/Hlompy/Wallet/internal/repository/memory"
+	"github.com/Hlompy/Wallet/internal/repository/sqlite"
 	"github.com/Hlompy/Wallet/internal/service"
+	"github.com/Hlompy/Wallet/internal/stream"
+	"github.com/Hlompy/Wallet/internal/webhook"
 
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
 )
 
 func main() {
@@ -21,13 +36,150 @@ func main() {
 		log.Println("config.env not found, using system env")
 	}
 
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("invalid configuration: ", err)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(cfg, os.Args[2:])
+		return
+	}
+
+	if _, ok := model.LookupCurrency(cfg.DefaultCurrency); !ok {
+		log.Fatal("unknown DEFAULT_CURRENCY: ", cfg.DefaultCurrency)
+	}
+
+	if cfg.ConversionSpreadBps < 0 || cfg.ConversionSpreadBps >= 10000 {
+		log.Fatal("CONVERSION_SPREAD_BPS must be in [0, 10000)")
+	}
+
+	hub := stream.NewHub()
+
+	var repo storage
+	switch cfg.Storage {
+	case "database":
+		repo = openDatabase(cfg, hub)
+	case "memory":
+		log.Println("using in-memory storage, data will be lost on exit")
+		repo = memory.New(memory.WithNotify(hub.Publish))
+	default:
+		log.Fatal("unknown STORAGE: ", cfg.Storage)
+	}
+
+	if cfg.HistoryDefaultPageSize <= 0 || cfg.HistoryDefaultPageSize > cfg.HistoryMaxPageSize {
+		log.Fatal("HISTORY_DEFAULT_PAGE_SIZE must be positive and at most HISTORY_MAX_PAGE_SIZE")
+	}
+
+	if cfg.WalletBatchMaxItems <= 0 {
+		log.Fatal("WALLET_BATCH_MAX_ITEMS must be positive")
+	}
+
+	if cfg.ImportMaxBytes <= 0 || cfg.ImportPollInterval <= 0 {
+		log.Fatal("IMPORT_MAX_BYTES and IMPORT_POLL_INTERVAL must be positive")
+	}
+
+	svcOpts := []service.Option{
+		service.WithDefaultCurrency(cfg.DefaultCurrency),
+		service.WithConversionSpread(cfg.ConversionSpreadBps),
+		service.WithPageSize(cfg.HistoryDefaultPageSize, cfg.HistoryMaxPageSize),
+		service.WithIdempotencyTTL(cfg.IdempotencyTTL),
+		service.WithHoldTTL(cfg.HoldDefaultTTL, cfg.HoldMaxTTL),
+		service.WithBatchLimit(cfg.WalletBatchMaxItems),
+	}
+	if cfg.BatchWindow > 0 {
+		if cfg.BatchMaxSize <= 0 {
+			log.Fatal("BATCH_MAX_SIZE must be positive")
+		}
+		svcOpts = append(svcOpts, service.WithBatching(cfg.BatchWindow, cfg.BatchMaxSize))
+	}
+
+	if cfg.StreamKeepAlive <= 0 {
+		log.Fatal("STREAM_KEEPALIVE must be positive")
+	}
+
+	svc := service.New(repo, svcOpts...)
+	h := handler.New(svc,
+		handler.WithImportLimit(cfg.ImportMaxBytes),
+		handler.WithEventStream(hub, cfg.StreamKeepAlive),
+	)
+
+	if cfg.RatesFile != "" {
+		if err := loadRates(svc, cfg.RatesFile); err != nil {
+			log.Fatal("load rates: ", err)
+		}
+	}
+
+	go purgeIdempotencyKeys(svc, time.Hour)
+	go expireHolds(svc, cfg.HoldExpiryInterval)
+	go runImports(svc, cfg.ImportPollInterval)
+
+	if relay := eventRelay(cfg, repo); relay != nil {
+		go relayEvents(relay, cfg.OutboxPollInterval)
+		go purgeWalletEvents(relay, time.Hour)
+	}
+
+	go dispatchWebhooks(webhookDispatcher(cfg, repo), cfg.WebhookPollInterval)
+
+	r := mux.NewRouter()
+	r.Use(handler.RequestID)
+	r.HandleFunc("/api/v1/wallet", h.PostWallet).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/wallet/batch", h.PostWalletBatch).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/transfers", h.PostTransfer).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/holds", h.PostHold).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/holds/{id}", h.GetHold).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/holds/{id}/capture", h.CaptureHold).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/holds/{id}/release", h.ReleaseHold).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/conversions", h.PostConversion).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/wallets/{id}", h.GetBalance).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/wallets/{id}/transactions", h.ListTransactions).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/wallets/{id}/events", h.GetWalletEvents).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/imports", h.PostImport).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/imports/{id}", h.GetImport).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/imports/{id}/errors", h.GetImportErrors).Methods(http.MethodGet)
+
+	admin := r.PathPrefix("/api/v1/admin").Subrouter()
+	admin.Use(func(next http.Handler) http.Handler { return handler.AdminOnly(cfg.AdminToken, next) })
+	admin.HandleFunc("/rates", h.PostRates).Methods(http.MethodPost)
+	admin.HandleFunc("/rates", h.GetRates).Methods(http.MethodGet)
+	admin.HandleFunc("/ledger/trial-balance", h.GetTrialBalance).Methods(http.MethodGet)
+	admin.HandleFunc("/webhooks", h.PostWebhook).Methods(http.MethodPost)
+	admin.HandleFunc("/webhooks/{id}", h.GetWebhook).Methods(http.MethodGet)
+	admin.HandleFunc("/webhooks/{id}", h.DeleteWebhook).Methods(http.MethodDelete)
+	admin.HandleFunc("/webhook-deliveries", h.ListWebhookDeliveries).Methods(http.MethodGet)
+	admin.HandleFunc("/wallets/{id}/freeze", h.PostFreeze).Methods(http.MethodPost)
+	admin.HandleFunc("/wallets/{id}/freeze", h.GetFreeze).Methods(http.MethodGet)
+	admin.HandleFunc("/wallets/{id}/freeze", h.DeleteFreeze).Methods(http.MethodDelete)
+	admin.HandleFunc("/webhook-deliveries/{id}/replay", h.ReplayWebhookDelivery).Methods(http.MethodPost)
+	admin.Handle("/metrics", expvar.Handler()).Methods(http.MethodGet)
+
+	go serveGRPC(cfg, svc, hub)
+
+	log.Println("server started on :" + cfg.AppPort)
+	log.Fatal(http.ListenAndServe(":"+cfg.AppPort, r))
+}
+
+// storage is what the app needs from its repository, whichever STORAGE
+// it is kept in.
+type storage interface {
+	service.WalletRepository
+	outbox.Store
+	webhook.Queue
+	webhook.Store
+}
+
+// openDatabase connects to the DB_DRIVER database, migrates it and
+// returns the repository for it.
+func openDatabase(cfg *config.Config, hub *stream.Hub) storage {
+	if cfg.DBDriver != "postgres" && cfg.DBDriver != "sqlite" {
+		log.Fatal("unknown DB_DRIVER: ", cfg.DBDriver)
+	}
 
 	var database *sql.DB
 	var err error
 
 	for i := 1; i <= 10; i++ {
-		database, err = db.New(cfg.DBDsn)
+		database, err = db.New(cfg.DBDriver, cfg.DBDsn)
 		if err == nil {
 			log.Println("connected to database")
 			break
@@ -41,18 +193,239 @@ func main() {
 		log.Fatal("could not connect to database:", err)
 	}
 
-	if err := db.Migrate(database); err != nil {
+	if err := db.Migrate(database, cfg.DBDriver); err != nil {
 		log.Fatal(err)
 	}
 
-	repo := repository.New(database)
-	svc := service.New(repo)
-	h := handler.New(svc)
+	if cfg.DBDriver == "sqlite" {
+		return sqlite.New(database, sqlite.WithNotify(hub.Publish))
+	}
 
-	r := mux.NewRouter()
-	r.HandleFunc("/api/v1/wallet", h.PostWallet).Methods(http.MethodPost)
-	r.HandleFunc("/api/v1/wallets/{id}", h.GetBalance).Methods(http.MethodGet)
+	go func() {
+		if err := hub.Listen(context.Background(), cfg.DBDsn, repository.EventChannel); err != nil {
+			log.Println("listen for wallet events:", err)
+		}
+	}()
 
-	log.Println("server started on :" + cfg.AppPort)
-	log.Fatal(http.ListenAndServe(":"+cfg.AppPort, r))
+	repo := postgresRepository(cfg, database)
+	go sequenceWalletEvents(cfg, repo)
+
+	return repo
+}
+
+// postgresRepository returns the Postgres repository with the options set
+// in the config.
+func postgresRepository(cfg *config.Config, database *sql.DB) *repository.WalletRepository {
+	repo, err := repository.NewFromConfig(cfg, database)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Wallets dropped from HOT_WALLETS get their shards back on the row.
+	folded, err := repo.FoldShards(context.Background())
+	if err != nil {
+		log.Fatal("fold wallet shards: ", err)
+	}
+	if folded > 0 {
+		log.Printf("folded shards of %d balances\n", folded)
+	}
+
+	return repo
+}
+
+// sequenceWalletEvents numbers the wallet events written to Postgres,
+// woken when they commit and, in case a notification was lost, every
+// second. Streams see events only once they are numbered.
+func sequenceWalletEvents(cfg *config.Config, repo outbox.SequenceStore) {
+	written := stream.NewHub()
+	wake, _ := written.Subscribe("")
+	go func() {
+		if err := written.Listen(context.Background(), cfg.DBDsn, repository.UnsequencedEventChannel); err != nil {
+			log.Println("listen for written wallet events:", err)
+		}
+	}()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		if err := outbox.Sequence(context.Background(), repo, 1000); err != nil {
+			log.Println("number wallet events:", err)
+		}
+
+		select {
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// serveGRPC serves the gRPC API on GRPC_PORT, next to the REST one.
+func serveGRPC(cfg *config.Config, svc *service.WalletService, hub *stream.Hub) {
+	lis, err := net.Listen("tcp", ":"+cfg.GRPCPort)
+	if err != nil {
+		log.Fatal("grpc listen: ", err)
+	}
+
+	srv := grpc.NewServer(grpc.KeepaliveParams(keepalive.ServerParameters{Time: cfg.StreamKeepAlive}))
+	walletv1.RegisterWalletServiceServer(srv, grpcapi.New(svc, grpcapi.WithEventStream(hub, cfg.StreamKeepAlive)))
+	reflection.Register(srv)
+
+	log.Println("grpc server started on :" + cfg.GRPCPort)
+	log.Fatal(srv.Serve(lis))
+}
+
+func loadRates(svc *service.WalletService, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	rates, err := service.ParseRatesCSV(f)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.UpdateRates(context.Background(), rates); err != nil {
+		return err
+	}
+
+	log.Printf("loaded %d exchange rates from %s\n", len(rates), path)
+	return nil
+}
+
+func purgeIdempotencyKeys(svc *service.WalletService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		n, err := svc.PurgeIdempotencyKeys(context.Background())
+		if err != nil {
+			log.Println("purge idempotency keys:", err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("purged %d expired idempotency keys\n", n)
+		}
+	}
+}
+
+func expireHolds(svc *service.WalletService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		n, err := svc.ExpireHolds(context.Background())
+		if err != nil {
+			log.Println("expire holds:", err)
+		}
+		if n > 0 {
+			log.Printf("expired %d holds\n", n)
+		}
+	}
+}
+
+// runImports runs queued import jobs as they arrive, and on every tick
+// resumes jobs left unfinished by a restart or an error.
+func runImports(svc *service.WalletService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := svc.RunImports(context.Background()); err != nil {
+			log.Println("run imports:", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-svc.ImportQueued():
+		}
+	}
+}
+
+// eventRelay builds the relay for the sinks named in OUTBOX_SINKS, or
+// returns nil when there are none and events stay in the outbox.
+func eventRelay(cfg *config.Config, repo storage) *outbox.Relay {
+	var sinks []outbox.Sink
+	for _, name := range cfg.OutboxSinks {
+		switch name {
+		case "none":
+		case "log":
+			sinks = append(sinks, outbox.NewLogSink(os.Stdout))
+		case "webhook":
+			sinks = append(sinks, webhook.NewSink(repo))
+		default:
+			log.Fatal("unknown sink in OUTBOX_SINKS: ", name)
+		}
+	}
+	if len(sinks) == 0 {
+		return nil
+	}
+
+	if cfg.OutboxBatchSize <= 0 || cfg.OutboxPollInterval <= 0 {
+		log.Fatal("OUTBOX_BATCH_SIZE and OUTBOX_POLL_INTERVAL must be positive")
+	}
+
+	return outbox.New(
+		repo,
+		outbox.Fanout(sinks...),
+		outbox.WithBatchSize(cfg.OutboxBatchSize),
+		outbox.WithRetryDelay(cfg.OutboxRetryBaseDelay, cfg.OutboxRetryMaxDelay),
+		outbox.WithRetention(cfg.OutboxRetention),
+	)
+}
+
+func relayEvents(relay *outbox.Relay, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := relay.Drain(context.Background()); err != nil {
+			log.Println("relay wallet events:", err)
+		}
+	}
+}
+
+func purgeWalletEvents(relay *outbox.Relay, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		n, err := relay.Purge(context.Background())
+		if err != nil {
+			log.Println("purge wallet events:", err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("purged %d delivered wallet events\n", n)
+		}
+	}
+}
+
+func webhookDispatcher(cfg *config.Config, repo storage) *webhook.Dispatcher {
+	if cfg.WebhookBatchSize <= 0 || cfg.WebhookPollInterval <= 0 || cfg.WebhookTimeout <= 0 || cfg.WebhookMaxAttempts <= 0 {
+		log.Fatal("WEBHOOK_BATCH_SIZE, WEBHOOK_POLL_INTERVAL, WEBHOOK_TIMEOUT and WEBHOOK_MAX_ATTEMPTS must be positive")
+	}
+
+	return webhook.NewDispatcher(
+		repo,
+		webhook.WithBatchSize(cfg.WebhookBatchSize),
+		webhook.WithTimeout(cfg.WebhookTimeout),
+		webhook.WithMaxAttempts(cfg.WebhookMaxAttempts),
+		webhook.WithRetryDelay(cfg.WebhookRetryBaseDelay, cfg.WebhookRetryMaxDelay),
+	)
+}
+
+// dispatchWebhooks sends due webhook deliveries, including replayed ones,
+// on every tick.
+func dispatchWebhooks(dispatcher *webhook.Dispatcher, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := dispatcher.Drain(context.Background()); err != nil {
+			log.Println("dispatch webhooks:", err)
+		}
+	}
 }
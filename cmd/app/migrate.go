@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/Hlompy/Wallet/internal/config"
+	"github.com/Hlompy/Wallet/internal/db"
+	"github.com/Hlompy/Wallet/migrations"
+)
+
+const migrateUsage = "usage: app migrate up | down [N] | status"
+
+// runMigrate handles "app migrate up|down [N]|status" against the
+// DB_DRIVER database. Down reverts one migration unless told otherwise.
+func runMigrate(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		log.Fatal(migrateUsage)
+	}
+	if cfg.Storage != "database" {
+		log.Fatal("migrations need STORAGE=database, not ", cfg.Storage)
+	}
+
+	database, err := db.New(cfg.DBDriver, cfg.DBDsn)
+	if err != nil {
+		log.Fatal("could not connect to database: ", err)
+	}
+	defer database.Close()
+
+	m, err := db.NewMigrator(database, cfg.DBDriver, migrations.FS)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, mig := range applied {
+			fmt.Println("applied", mig.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("nothing to apply")
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				log.Fatal("the number of migrations to revert must be positive")
+			}
+		}
+
+		reverted, err := m.Down(ctx, steps)
+		for _, mig := range reverted {
+			fmt.Println("reverted", mig.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(reverted) == 0 {
+			fmt.Println("nothing to revert")
+		}
+
+	case "status":
+		states, err := m.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printStatus(states)
+
+	default:
+		log.Fatal(migrateUsage)
+	}
+}
+
+func printStatus(states []db.MigrationState) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+
+	for _, s := range states {
+		status, appliedAt := "pending", ""
+		if s.Applied {
+			status = "applied"
+			appliedAt = s.AppliedAt.UTC().Format("2006-01-02 15:04:05")
+		}
+		switch {
+		case s.Modified:
+			status = "modified"
+		case s.Applied && s.Up == "":
+			status = "unknown"
+		}
+		fmt.Fprintf(w, "%03d\t%s\t%s\t%s\n", s.Version, s.Name, status, appliedAt)
+	}
+
+	w.Flush()
+}
@@ -0,0 +1,127 @@
+// Command wallet-import applies a CSV or JSONL file of wallet operations
+// as an import job, the same way POST /api/v1/imports does, and waits for
+// it to finish:
+//
+//	wallet-import [-format csv|jsonl] FILE
+//	wallet-import -resume JOB_ID
+//
+// An interrupted job (Ctrl-C, a crash, a database error) keeps its
+// progress and can be resumed with -resume; the server also resumes it on
+// its own.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/Hlompy/Wallet/internal/config"
+	"github.com/Hlompy/Wallet/internal/db"
+	"github.com/Hlompy/Wallet/internal/model"
+	"github.com/Hlompy/Wallet/internal/repository"
+	"github.com/Hlompy/Wallet/internal/repository/sqlite"
+	"github.com/Hlompy/Wallet/internal/service"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	format := flag.String("format", "", "file format, csv or jsonl (default: from the file extension)")
+	resume := flag.String("resume", "", "id of an interrupted import job to resume")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: wallet-import [-format csv|jsonl] FILE | wallet-import -resume JOB_ID")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *resume == "" && flag.NArg() != 1 || *resume != "" && flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := godotenv.Load("config.env"); err != nil {
+		log.Println("config.env not found, using system env")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("invalid configuration: ", err)
+	}
+
+	database, err := db.New(cfg.DBDriver, cfg.DBDsn)
+	if err != nil {
+		log.Fatal("could not connect to database: ", err)
+	}
+	defer database.Close()
+
+	if err := db.Migrate(database, cfg.DBDriver); err != nil {
+		log.Fatal(err)
+	}
+
+	var repo service.WalletRepository
+	if cfg.DBDriver == "sqlite" {
+		repo = sqlite.New(database)
+	} else if repo, err = repository.NewFromConfig(cfg, database); err != nil {
+		log.Fatal(err)
+	}
+	svc := service.New(repo, service.WithDefaultCurrency(cfg.DefaultCurrency))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	id := *resume
+	if id == "" {
+		id, err = createImport(ctx, svc, flag.Arg(0), *format)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Println("created import", id)
+	}
+
+	job, err := svc.RunImport(ctx, id)
+	if err != nil {
+		log.Fatalf("import %s stopped: %v; resume it with -resume %s", id, err, id)
+	}
+
+	fmt.Printf("import %s %s: %d succeeded, %d failed\n", id, strings.ToLower(job.Status), job.Succeeded, job.Failed)
+	if job.Error != "" {
+		fmt.Println(job.Error)
+	}
+
+	if job.Failed > 0 {
+		lineErrs, err := svc.ImportErrors(ctx, id)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, e := range lineErrs {
+			fmt.Fprintf(os.Stderr, "line %d: %s: %s\n", e.Line, e.Code, e.Detail)
+		}
+	}
+
+	if job.Status != model.ImportCompleted || job.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func createImport(ctx context.Context, svc *service.WalletService, path, format string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+
+	job, err := svc.CreateImport(ctx, format, data)
+	if err != nil {
+		return "", err
+	}
+
+	return job.ID.String(), nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Hlompy/Wallet/internal/config"
+	"github.com/Hlompy/Wallet/internal/db"
+	"github.com/Hlompy/Wallet/internal/model"
+	"github.com/Hlompy/Wallet/internal/repository"
+	"github.com/Hlompy/Wallet/internal/repository/sqlite"
+	"github.com/Hlompy/Wallet/internal/service"
+)
+
+// backend is where the commands take effect: the database or a server.
+type backend interface {
+	Balance(ctx context.Context, walletID, currency string) (model.Wallet, error)
+	Process(ctx context.Context, walletID, currency, op string, amount int64) (model.Wallet, error)
+	Transfer(ctx context.Context, fromID, toID, currency string, amount int64) (model.TransferResult, error)
+	History(ctx context.Context, walletID string, filter model.TransactionFilter, cursor string) ([]model.Transaction, string, error)
+	Freeze(ctx context.Context, walletID, reason string) (*model.WalletFreeze, error)
+	Unfreeze(ctx context.Context, walletID string) error
+	FreezeStatus(ctx context.Context, walletID string) (*model.WalletFreeze, error)
+}
+
+// openBackend returns the server backend with -server, the database one
+// otherwise, and a function releasing it.
+func openBackend(opts options) (backend, func(), error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	if opts.server != "" {
+		if opts.dryRun {
+			return nil, nil, errors.New("-dry-run needs the database and cannot be used with -server")
+		}
+
+		token := opts.token
+		if token == "" {
+			token = cfg.AdminToken
+		}
+		return newHTTPBackend(opts.server, token), func() {}, nil
+	}
+
+	if cfg.Storage != "database" {
+		return nil, nil, fmt.Errorf("STORAGE=%s keeps wallets inside the server; use -server", cfg.Storage)
+	}
+
+	if cfg.HistoryDefaultPageSize <= 0 || cfg.HistoryDefaultPageSize > cfg.HistoryMaxPageSize {
+		return nil, nil, errors.New("HISTORY_DEFAULT_PAGE_SIZE must be positive and at most HISTORY_MAX_PAGE_SIZE")
+	}
+
+	database, err := db.New(cfg.DBDriver, cfg.DBDsn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not connect to database: %w", err)
+	}
+
+	// Migrating is up to the server or "app migrate"; an outdated schema
+	// is refused rather than changed behind their back.
+	if err := db.CheckMigrated(database, cfg.DBDriver); err != nil {
+		database.Close()
+		return nil, nil, err
+	}
+
+	// The same repository options as the server, so that hot wallets,
+	// the isolation level and the update mode apply here too.
+	var repo service.WalletRepository
+	if cfg.DBDriver == "sqlite" {
+		repo = sqlite.New(database)
+	} else if repo, err = repository.NewFromConfig(cfg, database); err != nil {
+		database.Close()
+		return nil, nil, err
+	}
+
+	svc := service.New(
+		repo,
+		service.WithDefaultCurrency(cfg.DefaultCurrency),
+		service.WithPageSize(cfg.HistoryDefaultPageSize, cfg.HistoryMaxPageSize),
+	)
+
+	return newDirectBackend(svc, repo, opts.dryRun), func() { database.Close() }, nil
+}
+
+// errDryRun rolls back the transaction of a dry run.
+var errDryRun = errors.New("dry run")
+
+// directBackend runs the commands through the service, on the database.
+type directBackend struct {
+	svc    *service.WalletService
+	repo   service.WalletRepository
+	dryRun bool
+}
+
+func newDirectBackend(svc *service.WalletService, repo service.WalletRepository, dryRun bool) *directBackend {
+	return &directBackend{svc: svc, repo: repo, dryRun: dryRun}
+}
+
+// write runs fn, in a dry run within a transaction that is rolled back
+// once fn has read what it needs to report.
+func (d *directBackend) write(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !d.dryRun {
+		return fn(ctx)
+	}
+
+	err := d.repo.WithinTx(ctx, func(ctx context.Context) error {
+		if err := fn(ctx); err != nil {
+			return err
+		}
+		return errDryRun
+	})
+	if errors.Is(err, errDryRun) {
+		return nil
+	}
+	return err
+}
+
+func (d *directBackend) Balance(ctx context.Context, walletID, currency string) (model.Wallet, error) {
+	return d.svc.Balance(ctx, walletID, currency)
+}
+
+func (d *directBackend) Process(ctx context.Context, walletID, currency, op string, amount int64) (model.Wallet, error) {
+	var wallet model.Wallet
+	err := d.write(ctx, func(ctx context.Context) error {
+		if err := d.svc.Process(ctx, walletID, currency, op, amount, 0); err != nil {
+			return err
+		}
+
+		var err error
+		wallet, err = d.svc.Balance(ctx, walletID, currency)
+		return err
+	})
+
+	return wallet, err
+}
+
+func (d *directBackend) Transfer(ctx context.Context, fromID, toID, currency string, amount int64) (model.TransferResult, error) {
+	var res model.TransferResult
+	err := d.write(ctx, func(ctx context.Context) error {
+		var err error
+		res, err = d.svc.Transfer(ctx, fromID, toID, currency, amount)
+		return err
+	})
+
+	return res, err
+}
+
+func (d *directBackend) History(
+	ctx context.Context,
+	walletID string,
+	filter model.TransactionFilter,
+	cursor string,
+) ([]model.Transaction, string, error) {
+
+	return d.svc.History(ctx, walletID, filter, cursor)
+}
+
+func (d *directBackend) Freeze(ctx context.Context, walletID, reason string) (*model.WalletFreeze, error) {
+	var freeze model.WalletFreeze
+	err := d.write(ctx, func(ctx context.Context) error {
+		var err error
+		freeze, err = d.svc.FreezeWallet(ctx, walletID, reason)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &freeze, nil
+}
+
+func (d *directBackend) Unfreeze(ctx context.Context, walletID string) error {
+	return d.write(ctx, func(ctx context.Context) error {
+		return d.svc.UnfreezeWallet(ctx, walletID)
+	})
+}
+
+func (d *directBackend) FreezeStatus(ctx context.Context, walletID string) (*model.WalletFreeze, error) {
+	return d.svc.WalletFreeze(ctx, walletID)
+}
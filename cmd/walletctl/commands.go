@@ -0,0 +1,479 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+)
+
+type runFunc func(ctx context.Context, c *cli, args []string) error
+
+// command is a subcommand with its flags registered on the FlagSet it
+// was built with.
+type command struct {
+	usage string
+	nargs int
+	run   runFunc
+}
+
+var commands = map[string]func(fs *flag.FlagSet) command{
+	"balance":  balanceCommand,
+	"deposit":  func(fs *flag.FlagSet) command { return processCommand(fs, model.OpDeposit) },
+	"withdraw": func(fs *flag.FlagSet) command { return processCommand(fs, model.OpWithdraw) },
+	"transfer": transferCommand,
+	"history":  historyCommand,
+	"freeze":   freezeCommand,
+	"unfreeze": unfreezeCommand,
+	"export":   exportCommand,
+}
+
+func balanceCommand(fs *flag.FlagSet) command {
+	currency := fs.String("currency", "", "currency of the balance (default: the default currency)")
+
+	return command{
+		usage: "[-currency C] WALLET",
+		nargs: 1,
+		run: func(ctx context.Context, c *cli, args []string) error {
+			if err := checkWallet(args[0]); err != nil {
+				return err
+			}
+
+			wallet, err := c.backend.Balance(ctx, args[0], *currency)
+			if err != nil {
+				return err
+			}
+			return c.printWallet(wallet)
+		},
+	}
+}
+
+func processCommand(fs *flag.FlagSet, op string) command {
+	currency := fs.String("currency", "", "currency of the balance (default: the default currency)")
+
+	return command{
+		usage: "[-currency C] WALLET AMOUNT",
+		nargs: 2,
+		run: func(ctx context.Context, c *cli, args []string) error {
+			if err := checkWallet(args[0]); err != nil {
+				return err
+			}
+			amount, err := parseAmount(args[1])
+			if err != nil {
+				return err
+			}
+
+			wallet, err := c.backend.Process(ctx, args[0], *currency, op, amount)
+			if err != nil {
+				return err
+			}
+			return c.printWallet(wallet)
+		},
+	}
+}
+
+func transferCommand(fs *flag.FlagSet) command {
+	currency := fs.String("currency", "", "currency to move (default: the default currency)")
+
+	return command{
+		usage: "[-currency C] FROM TO AMOUNT",
+		nargs: 3,
+		run: func(ctx context.Context, c *cli, args []string) error {
+			for _, id := range args[:2] {
+				if err := checkWallet(id); err != nil {
+					return err
+				}
+			}
+			amount, err := parseAmount(args[2])
+			if err != nil {
+				return err
+			}
+
+			res, err := c.backend.Transfer(ctx, args[0], args[1], *currency, amount)
+			if err != nil {
+				return err
+			}
+			return c.printTransfer(res)
+		},
+	}
+}
+
+// filterFlags registers the history filters on fs.
+func filterFlags(fs *flag.FlagSet) func() (model.TransactionFilter, error) {
+	currency := fs.String("currency", "", "only operations in this currency")
+	typ := fs.String("type", "", "only operations of this type")
+	from := fs.String("from", "", "only operations at or after this RFC 3339 `time`")
+	to := fs.String("to", "", "only operations before this RFC 3339 `time`")
+
+	return func() (model.TransactionFilter, error) {
+		filter := model.TransactionFilter{Currency: *currency, Type: *typ}
+
+		var err error
+		if *from != "" {
+			if filter.From, err = time.Parse(time.RFC3339, *from); err != nil {
+				return filter, fmt.Errorf("invalid -from: %w", err)
+			}
+		}
+		if *to != "" {
+			if filter.To, err = time.Parse(time.RFC3339, *to); err != nil {
+				return filter, fmt.Errorf("invalid -to: %w", err)
+			}
+		}
+		return filter, nil
+	}
+}
+
+func historyCommand(fs *flag.FlagSet) command {
+	filter := filterFlags(fs)
+	limit := fs.Int("limit", 0, "operations per page (default: the server's page size)")
+	cursor := fs.String("cursor", "", "continue from the `cursor` printed by the previous page")
+
+	return command{
+		usage: "[-currency C] [-type T] [-from T] [-to T] [-limit N] [-cursor C] WALLET",
+		nargs: 1,
+		run: func(ctx context.Context, c *cli, args []string) error {
+			if err := checkWallet(args[0]); err != nil {
+				return err
+			}
+			f, err := filter()
+			if err != nil {
+				return err
+			}
+			if *limit < 0 {
+				return errors.New("invalid -limit")
+			}
+			f.Limit = *limit
+
+			txs, next, err := c.backend.History(ctx, args[0], f, *cursor)
+			if err != nil {
+				return err
+			}
+			return c.printHistory(txs, next)
+		},
+	}
+}
+
+func freezeCommand(fs *flag.FlagSet) command {
+	reason := fs.String("reason", "", "why the wallet is frozen")
+	status := fs.Bool("status", false, "show whether the wallet is frozen instead")
+
+	return command{
+		usage: "[-reason TEXT | -status] WALLET",
+		nargs: 1,
+		run: func(ctx context.Context, c *cli, args []string) error {
+			if err := checkWallet(args[0]); err != nil {
+				return err
+			}
+
+			var (
+				freeze *model.WalletFreeze
+				err    error
+			)
+			if *status {
+				freeze, err = c.backend.FreezeStatus(ctx, args[0])
+			} else {
+				freeze, err = c.backend.Freeze(ctx, args[0], *reason)
+			}
+			if err != nil {
+				return err
+			}
+			return c.printFreeze(args[0], freeze)
+		},
+	}
+}
+
+func unfreezeCommand(fs *flag.FlagSet) command {
+	return command{
+		usage: "WALLET",
+		nargs: 1,
+		run: func(ctx context.Context, c *cli, args []string) error {
+			if err := checkWallet(args[0]); err != nil {
+				return err
+			}
+			if err := c.backend.Unfreeze(ctx, args[0]); err != nil {
+				return err
+			}
+			return c.printFreeze(args[0], nil)
+		},
+	}
+}
+
+var exportColumns = []string{"id", "walletId", "currency", "type", "amount", "balanceAfter", "rate", "createdAt"}
+
+func exportCommand(fs *flag.FlagSet) command {
+	filter := filterFlags(fs)
+	format := fs.String("format", "csv", "`format` of the export, csv or jsonl")
+
+	return command{
+		usage: "[-format csv|jsonl] [-currency C] [-type T] [-from T] [-to T] WALLET",
+		nargs: 1,
+		run: func(ctx context.Context, c *cli, args []string) error {
+			if err := checkWallet(args[0]); err != nil {
+				return err
+			}
+			f, err := filter()
+			if err != nil {
+				return err
+			}
+
+			var (
+				write func(model.Transaction) error
+				flush = func() error { return nil }
+			)
+			switch *format {
+			case "csv":
+				w := csv.NewWriter(c.out)
+				flush = func() error {
+					w.Flush()
+					return w.Error()
+				}
+				if err := w.Write(exportColumns); err != nil {
+					return err
+				}
+				write = func(t model.Transaction) error {
+					return w.Write([]string{
+						strconv.FormatInt(t.ID, 10),
+						t.WalletID.String(),
+						t.Currency,
+						t.Type,
+						strconv.FormatInt(t.Amount, 10),
+						strconv.FormatInt(t.BalanceAfter, 10),
+						t.Rate,
+						t.CreatedAt.UTC().Format(time.RFC3339Nano),
+					})
+				}
+			case "jsonl":
+				enc := json.NewEncoder(c.out)
+				write = func(t model.Transaction) error {
+					return enc.Encode(newTransactionOutput(t))
+				}
+			default:
+				return fmt.Errorf("unknown export format %q", *format)
+			}
+
+			if err := exportHistory(ctx, c.backend, args[0], f, write); err != nil {
+				flush()
+				return err
+			}
+			return flush()
+		},
+	}
+}
+
+// exportHistory writes every operation matching filter, newest first,
+// following the cursor until the last page.
+func exportHistory(
+	ctx context.Context,
+	b backend,
+	walletID string,
+	filter model.TransactionFilter,
+	write func(model.Transaction) error,
+) error {
+
+	cursor := ""
+	for {
+		txs, next, err := b.History(ctx, walletID, filter, cursor)
+		if err != nil {
+			return err
+		}
+
+		for _, t := range txs {
+			if err := write(t); err != nil {
+				return err
+			}
+		}
+
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+func checkWallet(id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return fmt.Errorf("invalid wallet %q", id)
+	}
+	return nil
+}
+
+func parseAmount(s string) (int64, error) {
+	amount, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || amount <= 0 {
+		return 0, fmt.Errorf("invalid amount %q: want a positive number of minor units", s)
+	}
+	return amount, nil
+}
+
+// cli prints command results as a table or as JSON.
+type cli struct {
+	backend backend
+	out     io.Writer
+	json    bool
+}
+
+type walletOutput struct {
+	WalletID         uuid.UUID `json:"walletId"`
+	Currency         string    `json:"currency"`
+	Balance          int64     `json:"balance"`
+	AvailableBalance int64     `json:"availableBalance"`
+	Version          int64     `json:"version"`
+}
+
+type transferOutput struct {
+	FromWalletID string `json:"fromWalletId"`
+	FromBalance  int64  `json:"fromBalance"`
+	ToWalletID   string `json:"toWalletId"`
+	ToBalance    int64  `json:"toBalance"`
+	Currency     string `json:"currency"`
+}
+
+type transactionOutput struct {
+	ID           int64     `json:"id"`
+	WalletID     uuid.UUID `json:"walletId"`
+	Currency     string    `json:"currency"`
+	Type         string    `json:"type"`
+	Amount       int64     `json:"amount"`
+	BalanceAfter int64     `json:"balanceAfter"`
+	Rate         string    `json:"rate,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type historyOutput struct {
+	Transactions []transactionOutput `json:"transactions"`
+	NextCursor   string              `json:"nextCursor,omitempty"`
+}
+
+type freezeOutput struct {
+	WalletID string     `json:"walletId"`
+	Frozen   bool       `json:"frozen"`
+	Reason   string     `json:"reason,omitempty"`
+	FrozenAt *time.Time `json:"frozenAt,omitempty"`
+}
+
+func newTransactionOutput(t model.Transaction) transactionOutput {
+	return transactionOutput{
+		ID:           t.ID,
+		WalletID:     t.WalletID,
+		Currency:     t.Currency,
+		Type:         t.Type,
+		Amount:       t.Amount,
+		BalanceAfter: t.BalanceAfter,
+		Rate:         t.Rate,
+		CreatedAt:    t.CreatedAt,
+	}
+}
+
+func (c *cli) printWallet(w model.Wallet) error {
+	if c.json {
+		return c.printJSON(walletOutput{
+			WalletID:         w.ID,
+			Currency:         w.Currency,
+			Balance:          w.Balance,
+			AvailableBalance: w.Available(),
+			Version:          w.Version,
+		})
+	}
+
+	return c.table(func(tw io.Writer) {
+		fmt.Fprintln(tw, "WALLET\tCURRENCY\tBALANCE\tAVAILABLE\tVERSION")
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\n", w.ID, w.Currency, w.Balance, w.Available(), w.Version)
+	})
+}
+
+func (c *cli) printTransfer(r model.TransferResult) error {
+	if c.json {
+		return c.printJSON(transferOutput{
+			FromWalletID: r.FromWalletID,
+			FromBalance:  r.FromBalance,
+			ToWalletID:   r.ToWalletID,
+			ToBalance:    r.ToBalance,
+			Currency:     r.Currency,
+		})
+	}
+
+	return c.table(func(tw io.Writer) {
+		fmt.Fprintln(tw, "WALLET\tCURRENCY\tBALANCE")
+		fmt.Fprintf(tw, "%s\t%s\t%d\n", r.FromWalletID, r.Currency, r.FromBalance)
+		fmt.Fprintf(tw, "%s\t%s\t%d\n", r.ToWalletID, r.Currency, r.ToBalance)
+	})
+}
+
+func (c *cli) printHistory(txs []model.Transaction, next string) error {
+	if c.json {
+		out := historyOutput{Transactions: make([]transactionOutput, 0, len(txs)), NextCursor: next}
+		for _, t := range txs {
+			out.Transactions = append(out.Transactions, newTransactionOutput(t))
+		}
+		return c.printJSON(out)
+	}
+
+	err := c.table(func(tw io.Writer) {
+		fmt.Fprintln(tw, "ID\tTIME\tTYPE\tCURRENCY\tAMOUNT\tBALANCE\tRATE")
+		for _, t := range txs {
+			fmt.Fprintf(
+				tw,
+				"%d\t%s\t%s\t%s\t%d\t%d\t%s\n",
+				t.ID,
+				t.CreatedAt.UTC().Format(time.RFC3339),
+				t.Type,
+				t.Currency,
+				t.Amount,
+				t.BalanceAfter,
+				t.Rate,
+			)
+		}
+	})
+	if err != nil || next == "" {
+		return err
+	}
+
+	_, err = fmt.Fprintf(c.out, "\nmore: -cursor %s\n", next)
+	return err
+}
+
+// printFreeze prints the freeze of walletID, or that it is not frozen
+// when f is nil.
+func (c *cli) printFreeze(walletID string, f *model.WalletFreeze) error {
+	out := freezeOutput{WalletID: walletID}
+	if f != nil {
+		out.Frozen = true
+		out.Reason = f.Reason
+		out.FrozenAt = &f.FrozenAt
+	}
+
+	if c.json {
+		return c.printJSON(out)
+	}
+
+	return c.table(func(tw io.Writer) {
+		since := ""
+		if out.FrozenAt != nil {
+			since = out.FrozenAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintln(tw, "WALLET\tFROZEN\tREASON\tSINCE")
+		fmt.Fprintf(tw, "%s\t%t\t%s\t%s\n", out.WalletID, out.Frozen, out.Reason, since)
+	})
+}
+
+func (c *cli) printJSON(v any) error {
+	enc := json.NewEncoder(c.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (c *cli) table(fn func(tw io.Writer)) error {
+	tw := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fn(tw)
+	return tw.Flush()
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// httpBackend runs the commands on a server through its REST API.
+type httpBackend struct {
+	client *http.Client
+	server string
+	token  string
+}
+
+func newHTTPBackend(server, token string) *httpBackend {
+	return &httpBackend{
+		client: &http.Client{Timeout: 30 * time.Second},
+		server: strings.TrimRight(server, "/"),
+		token:  token,
+	}
+}
+
+type balanceBody struct {
+	WalletID         uuid.UUID `json:"walletId"`
+	Currency         string    `json:"currency"`
+	Balance          int64     `json:"balance"`
+	AvailableBalance int64     `json:"availableBalance"`
+	Version          int64     `json:"version"`
+}
+
+type transferBody struct {
+	FromWalletID string `json:"fromWalletId"`
+	FromBalance  int64  `json:"fromBalance"`
+	ToWalletID   string `json:"toWalletId"`
+	ToBalance    int64  `json:"toBalance"`
+	Currency     string `json:"currency"`
+}
+
+type transactionBody struct {
+	ID           int64     `json:"id"`
+	Currency     string    `json:"currency"`
+	Type         string    `json:"type"`
+	Amount       int64     `json:"amount"`
+	BalanceAfter int64     `json:"balanceAfter"`
+	Rate         string    `json:"rate"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type historyBody struct {
+	WalletID     uuid.UUID         `json:"walletId"`
+	Transactions []transactionBody `json:"transactions"`
+	NextCursor   string            `json:"nextCursor"`
+}
+
+type freezeBody struct {
+	WalletID uuid.UUID `json:"walletId"`
+	Frozen   bool      `json:"frozen"`
+	Reason   string    `json:"reason"`
+	FrozenAt time.Time `json:"frozenAt"`
+}
+
+type problemBody struct {
+	Status  int            `json:"status"`
+	Code    string         `json:"code"`
+	Detail  string         `json:"detail"`
+	Details map[string]any `json:"details"`
+}
+
+func (h *httpBackend) Balance(ctx context.Context, walletID, currency string) (model.Wallet, error) {
+	query := url.Values{}
+	if currency != "" {
+		query.Set("currency", currency)
+	}
+
+	var body balanceBody
+	if err := h.do(ctx, http.MethodGet, "/api/v1/wallets/"+url.PathEscape(walletID), query, nil, &body); err != nil {
+		return model.Wallet{}, err
+	}
+
+	return model.Wallet{
+		ID:       body.WalletID,
+		Currency: body.Currency,
+		Balance:  body.Balance,
+		Held:     body.Balance - body.AvailableBalance,
+		Version:  body.Version,
+	}, nil
+}
+
+// Process applies the operation, then reads the balance it left for the
+// held amount the operation's response lacks.
+func (h *httpBackend) Process(ctx context.Context, walletID, currency, op string, amount int64) (model.Wallet, error) {
+	req := map[string]any{
+		"walletId":      walletID,
+		"currency":      currency,
+		"operationType": op,
+		"amount":        amount,
+	}
+
+	var body balanceBody
+	if err := h.do(ctx, http.MethodPost, "/api/v1/wallet", nil, req, &body); err != nil {
+		return model.Wallet{}, err
+	}
+
+	return h.Balance(ctx, walletID, body.Currency)
+}
+
+func (h *httpBackend) Transfer(ctx context.Context, fromID, toID, currency string, amount int64) (model.TransferResult, error) {
+	req := map[string]any{
+		"fromWalletId": fromID,
+		"toWalletId":   toID,
+		"currency":     currency,
+		"amount":       amount,
+	}
+
+	var body transferBody
+	if err := h.do(ctx, http.MethodPost, "/api/v1/transfers", nil, req, &body); err != nil {
+		return model.TransferResult{}, err
+	}
+
+	return model.TransferResult{
+		FromWalletID: body.FromWalletID,
+		ToWalletID:   body.ToWalletID,
+		Currency:     body.Currency,
+		FromBalance:  body.FromBalance,
+		ToBalance:    body.ToBalance,
+	}, nil
+}
+
+func (h *httpBackend) History(
+	ctx context.Context,
+	walletID string,
+	filter model.TransactionFilter,
+	cursor string,
+) ([]model.Transaction, string, error) {
+
+	query := url.Values{}
+	if filter.Currency != "" {
+		query.Set("currency", filter.Currency)
+	}
+	if filter.Type != "" {
+		query.Set("type", filter.Type)
+	}
+	if !filter.From.IsZero() {
+		query.Set("from", filter.From.Format(time.RFC3339))
+	}
+	if !filter.To.IsZero() {
+		query.Set("to", filter.To.Format(time.RFC3339))
+	}
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+
+	var body historyBody
+	path := "/api/v1/wallets/" + url.PathEscape(walletID) + "/transactions"
+	if err := h.do(ctx, http.MethodGet, path, query, nil, &body); err != nil {
+		return nil, "", err
+	}
+
+	txs := make([]model.Transaction, 0, len(body.Transactions))
+	for _, t := range body.Transactions {
+		txs = append(txs, model.Transaction{
+			ID:           t.ID,
+			WalletID:     body.WalletID,
+			Currency:     t.Currency,
+			Type:         t.Type,
+			Amount:       t.Amount,
+			BalanceAfter: t.BalanceAfter,
+			Rate:         t.Rate,
+			CreatedAt:    t.CreatedAt,
+		})
+	}
+
+	return txs, body.NextCursor, nil
+}
+
+func (h *httpBackend) Freeze(ctx context.Context, walletID, reason string) (*model.WalletFreeze, error) {
+	var body freezeBody
+	err := h.do(ctx, http.MethodPost, freezePath(walletID), nil, map[string]string{"reason": reason}, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	return body.freeze(), nil
+}
+
+func (h *httpBackend) Unfreeze(ctx context.Context, walletID string) error {
+	return h.do(ctx, http.MethodDelete, freezePath(walletID), nil, nil, nil)
+}
+
+func (h *httpBackend) FreezeStatus(ctx context.Context, walletID string) (*model.WalletFreeze, error) {
+	var body freezeBody
+	if err := h.do(ctx, http.MethodGet, freezePath(walletID), nil, nil, &body); err != nil {
+		return nil, err
+	}
+
+	return body.freeze(), nil
+}
+
+func freezePath(walletID string) string {
+	return "/api/v1/admin/wallets/" + url.PathEscape(walletID) + "/freeze"
+}
+
+func (b freezeBody) freeze() *model.WalletFreeze {
+	if !b.Frozen {
+		return nil
+	}
+	return &model.WalletFreeze{WalletID: b.WalletID, Reason: b.Reason, FrozenAt: b.FrozenAt}
+}
+
+// do sends a request with req, if any, as its JSON body and decodes the
+// response into resp. A problem response becomes the domain error it
+// describes, so commands report server and database errors alike.
+func (h *httpBackend) do(ctx context.Context, method, path string, query url.Values, req, resp any) error {
+	target := h.server + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if req != nil {
+		data, err := json.Marshal(req)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	r, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	if req != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if h.token != "" {
+		r.Header.Set("Authorization", "Bearer "+h.token)
+	}
+
+	res, err := h.client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		var p problemBody
+		if err := json.NewDecoder(res.Body).Decode(&p); err != nil || p.Code == "" {
+			return fmt.Errorf("%s %s: %s", method, path, res.Status)
+		}
+		return appErr.New(p.Code, res.StatusCode, p.Detail).WithDetails(p.Details)
+	}
+
+	if resp == nil || res.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(resp)
+}
@@ -0,0 +1,206 @@
+// Command walletctl inspects and fixes wallets. It works on the database
+// named by config.env, through the same service as the server, or on a
+// running server over HTTP when given -server:
+//
+//	walletctl [flags] balance [-currency C] WALLET
+//	walletctl [flags] deposit [-currency C] WALLET AMOUNT
+//	walletctl [flags] withdraw [-currency C] WALLET AMOUNT
+//	walletctl [flags] transfer [-currency C] FROM TO AMOUNT
+//	walletctl [flags] history [-currency C] [-type T] [-from T] [-to T] [-limit N] [-cursor C] WALLET
+//	walletctl [flags] freeze [-reason TEXT | -status] WALLET
+//	walletctl [flags] unfreeze WALLET
+//	walletctl [flags] export [-format csv|jsonl] [-currency C] [-type T] [-from T] [-to T] WALLET
+//
+// Amounts are in minor units, as in the API. Flags may follow the
+// arguments. With -dry-run a command runs in a transaction that is rolled
+// back, showing what it would do without doing it; it needs the database.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+
+	"github.com/joho/godotenv"
+)
+
+const usage = `usage: walletctl [flags] COMMAND [command flags] ARGS
+
+commands:
+  balance WALLET              show a balance
+  deposit WALLET AMOUNT       deposit to a balance
+  withdraw WALLET AMOUNT      withdraw from a balance
+  transfer FROM TO AMOUNT     move money between wallets
+  history WALLET              list operations, newest first
+  freeze WALLET               stop all operations on a wallet
+  unfreeze WALLET             lift a freeze
+  export WALLET               write the whole history as CSV or JSONL
+
+flags:`
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("walletctl: ")
+
+	if err := godotenv.Load("config.env"); err != nil && !os.IsNotExist(err) {
+		log.Println("config.env:", err)
+	}
+
+	os.Exit(execute(os.Args[1:]))
+}
+
+// execute runs the command line and returns the exit code: 2 for a usage
+// error, 1 for a failed command.
+func execute(args []string) int {
+	inv, err := parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		return 2
+	}
+
+	b, closeBackend, err := openBackend(inv.opts)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	defer closeBackend()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	c := &cli{backend: b, out: os.Stdout, json: inv.opts.output == "json"}
+	if err := inv.run(ctx, c, inv.args); err != nil {
+		log.Println(describe(err))
+		return 1
+	}
+
+	if inv.opts.dryRun {
+		log.Println("dry run: nothing was changed")
+	}
+	return 0
+}
+
+// options are the flags every command takes.
+type options struct {
+	server string
+	token  string
+	output string
+	dryRun bool
+}
+
+func (o *options) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.server, "server", o.server, "`URL` of a running server to use instead of the database")
+	fs.StringVar(&o.token, "token", o.token, "admin `token` for freeze over -server (default: ADMIN_TOKEN)")
+	fs.StringVar(&o.output, "output", o.output, "output `format`, table or json")
+	fs.BoolVar(&o.dryRun, "dry-run", o.dryRun, "run the command in a transaction that is rolled back")
+}
+
+// invocation is a parsed command line.
+type invocation struct {
+	opts options
+	run  runFunc
+	args []string
+}
+
+// parse reads the command line: global flags, the command, then its flags
+// and arguments in any order. Parse errors are reported on stderr.
+func parse(args []string) (*invocation, error) {
+	inv := &invocation{opts: options{output: "table"}}
+
+	global := flag.NewFlagSet("walletctl", flag.ContinueOnError)
+	inv.opts.register(global)
+	global.Usage = func() {
+		fmt.Fprintln(global.Output(), usage)
+		global.PrintDefaults()
+	}
+	if err := global.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if global.NArg() == 0 {
+		global.Usage()
+		return nil, errors.New("no command")
+	}
+
+	name := global.Arg(0)
+	newCmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(global.Output(), "unknown command %q\n", name)
+		global.Usage()
+		return nil, errors.New("unknown command")
+	}
+
+	fs := flag.NewFlagSet("walletctl "+name, flag.ContinueOnError)
+	inv.opts.register(fs)
+	cmd := newCmd(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: walletctl %s %s\n", name, cmd.usage)
+		fs.PrintDefaults()
+	}
+
+	pos, err := parseArgs(fs, global.Args()[1:])
+	if err != nil {
+		return nil, err
+	}
+	if len(pos) != cmd.nargs {
+		fs.Usage()
+		return nil, errors.New("wrong number of arguments")
+	}
+
+	if inv.opts.output != "table" && inv.opts.output != "json" {
+		fmt.Fprintf(fs.Output(), "unknown output format %q\n", inv.opts.output)
+		return nil, errors.New("unknown output format")
+	}
+
+	inv.run = cmd.run
+	inv.args = pos
+	return inv, nil
+}
+
+// parseArgs parses fs from args, letting flags follow the positional
+// arguments, and returns the positional ones.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var pos []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+
+		args = fs.Args()
+		if len(args) == 0 {
+			return pos, nil
+		}
+		pos = append(pos, args[0])
+		args = args[1:]
+	}
+}
+
+// describe renders err for the operator: a domain error, local or from
+// the server, by its code, message and details.
+func describe(err error) string {
+	e, ok := appErr.As(err)
+	if !ok {
+		return err.Error()
+	}
+
+	msg := e.Code + ": " + e.Message
+	if len(e.Details) > 0 {
+		details := make([]string, 0, len(e.Details))
+		for k, v := range e.Details {
+			details = append(details, fmt.Sprintf("%s=%v", k, v))
+		}
+		sort.Strings(details)
+		msg += " (" + strings.Join(details, " ") + ")"
+	}
+	return msg
+}
@@ -1,22 +1,201 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	AppPort string
-	DBDsn   string
+	AppPort  string
+	GRPCPort string
+	Storage  string
+	DBDriver string
+	DBDsn    string
+
+	DefaultCurrency string
+
+	TxIsolation       string
+	TxMaxRetries      int
+	TxRetryBaseDelay  time.Duration
+	BalanceUpdateMode string
+	CASMaxRetries     int
+
+	HotWallets      []string
+	HotWalletShards int
+
+	BatchWindow  time.Duration
+	BatchMaxSize int
+
+	WalletBatchMaxItems int
+
+	ImportMaxBytes     int64
+	ImportPollInterval time.Duration
+
+	OutboxSinks          []string
+	OutboxPollInterval   time.Duration
+	OutboxBatchSize      int
+	OutboxRetryBaseDelay time.Duration
+	OutboxRetryMaxDelay  time.Duration
+	OutboxRetention      time.Duration
+
+	WebhookPollInterval   time.Duration
+	WebhookBatchSize      int
+	WebhookTimeout        time.Duration
+	WebhookMaxAttempts    int
+	WebhookRetryBaseDelay time.Duration
+	WebhookRetryMaxDelay  time.Duration
+
+	StreamKeepAlive time.Duration
+
+	ConversionSpreadBps int
+	RatesFile           string
+
+	AdminToken string
+
+	HistoryDefaultPageSize int
+	HistoryMaxPageSize     int
+
+	IdempotencyTTL time.Duration
+
+	HoldDefaultTTL     time.Duration
+	HoldMaxTTL         time.Duration
+	HoldExpiryInterval time.Duration
 }
 
-func Load() *Config {
-	return &Config{
-		AppPort: os.Getenv("APP_PORT"),
-		DBDsn: "host=" + os.Getenv("DB_HOST") +
-			" port=" + os.Getenv("DB_PORT") +
-			" user=" + os.Getenv("DB_USER") +
-			" password=" + os.Getenv("DB_PASSWORD") +
-			" dbname=" + os.Getenv("DB_NAME") +
-			" sslmode=" + os.Getenv("DB_SSLMODE"),
+// Load reads the configuration from the environment. A set but unparsable
+// numeric or duration value is an error rather than a silent default.
+func Load() (*Config, error) {
+	driver := getString("DB_DRIVER", "postgres")
+	var p parser
+
+	cfg := &Config{
+		AppPort:  os.Getenv("APP_PORT"),
+		GRPCPort: getString("GRPC_PORT", "9090"),
+		Storage:  getString("STORAGE", "database"),
+		DBDriver: driver,
+		DBDsn:    dbDsn(driver),
+
+		DefaultCurrency: getString("DEFAULT_CURRENCY", "RUB"),
+
+		TxIsolation:       getString("TX_ISOLATION", "read_committed"),
+		TxMaxRetries:      p.getInt("TX_MAX_RETRIES", 3),
+		TxRetryBaseDelay:  p.getDuration("TX_RETRY_BASE_DELAY", 5*time.Millisecond),
+		BalanceUpdateMode: getString("BALANCE_UPDATE_MODE", "lock"),
+		CASMaxRetries:     p.getInt("CAS_MAX_RETRIES", 10),
+
+		HotWallets:      getList("HOT_WALLETS", ""),
+		HotWalletShards: p.getInt("HOT_WALLET_SHARDS", 16),
+
+		BatchWindow:  p.getDuration("BATCH_WINDOW", 0),
+		BatchMaxSize: p.getInt("BATCH_MAX_SIZE", 100),
+
+		WalletBatchMaxItems: p.getInt("WALLET_BATCH_MAX_ITEMS", 1000),
+
+		ImportMaxBytes:     int64(p.getInt("IMPORT_MAX_BYTES", 64<<20)),
+		ImportPollInterval: p.getDuration("IMPORT_POLL_INTERVAL", 5*time.Second),
+
+		OutboxSinks:          getList("OUTBOX_SINKS", "webhook"),
+		OutboxPollInterval:   p.getDuration("OUTBOX_POLL_INTERVAL", time.Second),
+		OutboxBatchSize:      p.getInt("OUTBOX_BATCH_SIZE", 100),
+		OutboxRetryBaseDelay: p.getDuration("OUTBOX_RETRY_BASE_DELAY", time.Second),
+		OutboxRetryMaxDelay:  p.getDuration("OUTBOX_RETRY_MAX_DELAY", 5*time.Minute),
+		OutboxRetention:      p.getDuration("OUTBOX_RETENTION", 7*24*time.Hour),
+
+		WebhookPollInterval:   p.getDuration("WEBHOOK_POLL_INTERVAL", time.Second),
+		WebhookBatchSize:      p.getInt("WEBHOOK_BATCH_SIZE", 20),
+		WebhookTimeout:        p.getDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		WebhookMaxAttempts:    p.getInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		WebhookRetryBaseDelay: p.getDuration("WEBHOOK_RETRY_BASE_DELAY", 10*time.Second),
+		WebhookRetryMaxDelay:  p.getDuration("WEBHOOK_RETRY_MAX_DELAY", time.Hour),
+
+		StreamKeepAlive: p.getDuration("STREAM_KEEPALIVE", 15*time.Second),
+
+		ConversionSpreadBps: p.getInt("CONVERSION_SPREAD_BPS", 0),
+		RatesFile:           os.Getenv("RATES_FILE"),
+
+		AdminToken: os.Getenv("ADMIN_TOKEN"),
+
+		HistoryDefaultPageSize: p.getInt("HISTORY_DEFAULT_PAGE_SIZE", 20),
+		HistoryMaxPageSize:     p.getInt("HISTORY_MAX_PAGE_SIZE", 100),
+
+		IdempotencyTTL: p.getDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+
+		HoldDefaultTTL:     p.getDuration("HOLD_DEFAULT_TTL", 15*time.Minute),
+		HoldMaxTTL:         p.getDuration("HOLD_MAX_TTL", 7*24*time.Hour),
+		HoldExpiryInterval: p.getDuration("HOLD_EXPIRY_INTERVAL", 30*time.Second),
+	}
+
+	if err := errors.Join(p.errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// dbDsn returns what db.New needs to open the DB_DRIVER database: the
+// file path for SQLite, the connection string for Postgres.
+func dbDsn(driver string) string {
+	if driver == "sqlite" {
+		return getString("DB_PATH", "wallet.db")
+	}
+
+	return "host=" + os.Getenv("DB_HOST") +
+		" port=" + os.Getenv("DB_PORT") +
+		" user=" + os.Getenv("DB_USER") +
+		" password=" + os.Getenv("DB_PASSWORD") +
+		" dbname=" + os.Getenv("DB_NAME") +
+		" sslmode=" + os.Getenv("DB_SSLMODE")
+}
+
+func getString(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// getList splits a comma-separated value, dropping empty items.
+func getList(key, def string) []string {
+	var items []string
+	for _, item := range strings.Split(getString(key, def), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parser collects the errors of the values it fails to parse, so Load can
+// report every bad variable at once.
+type parser struct {
+	errs []error
+}
+
+func (p *parser) getInt(key string, def int) int {
+	s := os.Getenv(key)
+	if s == "" {
+		return def
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("%s: %q is not an integer", key, s))
+		return def
+	}
+	return v
+}
+
+func (p *parser) getDuration(key string, def time.Duration) time.Duration {
+	s := os.Getenv(key)
+	if s == "" {
+		return def
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("%s: %q is not a duration", key, s))
+		return def
 	}
+	return v
 }
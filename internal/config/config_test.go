@@ -0,0 +1,54 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad_Defaults(t *testing.T) {
+	t.Setenv("IMPORT_MAX_BYTES", "")
+	t.Setenv("HOLD_MAX_TTL", "")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ImportMaxBytes != 64<<20 {
+		t.Errorf("expected default ImportMaxBytes, got %d", cfg.ImportMaxBytes)
+	}
+	if cfg.HoldMaxTTL != 7*24*time.Hour {
+		t.Errorf("expected default HoldMaxTTL, got %v", cfg.HoldMaxTTL)
+	}
+}
+
+func TestLoad_Values(t *testing.T) {
+	t.Setenv("IMPORT_MAX_BYTES", "1024")
+	t.Setenv("HOLD_MAX_TTL", "2h")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ImportMaxBytes != 1024 {
+		t.Errorf("expected ImportMaxBytes 1024, got %d", cfg.ImportMaxBytes)
+	}
+	if cfg.HoldMaxTTL != 2*time.Hour {
+		t.Errorf("expected HoldMaxTTL 2h, got %v", cfg.HoldMaxTTL)
+	}
+}
+
+func TestLoad_Unparsable(t *testing.T) {
+	t.Setenv("IMPORT_MAX_BYTES", "64MB")
+	t.Setenv("HOLD_MAX_TTL", "7d")
+
+	_, err := Load()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, key := range []string{"IMPORT_MAX_BYTES", "HOLD_MAX_TTL"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("expected %s in error, got %v", key, err)
+		}
+	}
+}
@@ -2,13 +2,24 @@ package db
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
 	"time"
 
 	_ "github.com/lib/pq"
 )
 
-func New(dsn string) (*sql.DB, error) {
+// New opens the database of driver, "postgres" or "sqlite". For SQLite
+// dsn is the path of the database file.
+func New(driver, dsn string) (*sql.DB, error) {
+	switch driver {
+	case "postgres":
+	case "sqlite":
+		return openSQLite(dsn)
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", driver)
+	}
+
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return nil, err
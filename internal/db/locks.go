@@ -0,0 +1,17 @@
+package db
+
+// Postgres advisory lock keys. They share one key space per database, so
+// every key the application takes is declared here, each with its own
+// value.
+const (
+	// EventRelayLock is held by the one outbox relay allowed to deliver
+	// events at a time.
+	EventRelayLock = 7_349_201
+
+	// MigrationLock is held by the one migration run allowed at a time.
+	MigrationLock = 7_349_202
+
+	// EventSequencerLock is held by the one sequencer allowed to number
+	// events at a time.
+	EventSequencerLock = 7_349_203
+)
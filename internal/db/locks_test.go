@@ -0,0 +1,17 @@
+package db
+
+import "testing"
+
+func TestAdvisoryLocksAreDistinct(t *testing.T) {
+	keys := map[int]string{}
+	for name, key := range map[string]int{
+		"EventRelayLock":     EventRelayLock,
+		"MigrationLock":      MigrationLock,
+		"EventSequencerLock": EventSequencerLock,
+	} {
+		if other, ok := keys[key]; ok {
+			t.Errorf("%s and %s share key %d", name, other, key)
+		}
+		keys[key] = name
+	}
+}
@@ -1,16 +1,378 @@
 package db
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
-	"os"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Hlompy/Wallet/migrations"
 )
 
-func Migrate(db *sql.DB) error {
-	data, err := os.ReadFile("migrations/001_init.sql")
+var migrationFile = regexp.MustCompile(`^(\d+)_\w+\.(up|down)\.sql$`)
+
+var schemaMigrations = map[string]string{
+	"postgres": `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+	"sqlite": `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now'))
+	)`,
+}
+
+// Migration is a schema change. Down is empty when it cannot be reverted.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// MigrationState is a migration as the database knows it. A migration
+// applied by a newer binary has only its version and name.
+type MigrationState struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+
+	// Modified is set when the migration changed after it was applied.
+	Modified bool
+}
+
+// Migrator applies the migrations of one driver and records them, with
+// the checksum of each, in schema_migrations. Every migration runs in a
+// transaction of its own.
+type Migrator struct {
+	db         *sql.DB
+	driver     string
+	migrations []Migration
+}
+
+// NewMigrator reads the migrations of driver from fsys: the Postgres ones
+// from its root, the SQLite ones from sqlite/.
+func NewMigrator(db *sql.DB, driver string, fsys fs.FS) (*Migrator, error) {
+	if _, ok := schemaMigrations[driver]; !ok {
+		return nil, fmt.Errorf("unknown database driver %q", driver)
+	}
+
+	dir := "."
+	if driver == "sqlite" {
+		dir = "sqlite"
+	}
+
+	list, err := loadMigrations(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, driver: driver, migrations: list}, nil
+}
+
+// Migrate applies the pending embedded migrations of driver.
+func Migrate(db *sql.DB, driver string) error {
+	m, err := NewMigrator(db, driver, migrations.FS)
 	if err != nil {
 		return err
 	}
 
-	_, err = db.Exec(string(data))
+	applied, err := m.Up(context.Background())
+	for _, mig := range applied {
+		log.Println("applied migration", mig.Name)
+	}
 	return err
 }
+
+// CheckMigrated returns an error unless every embedded migration of
+// driver has been applied, unmodified. Tools that share the database with
+// the server use it to refuse to run on an outdated schema without
+// changing it themselves.
+func CheckMigrated(db *sql.DB, driver string) error {
+	m, err := NewMigrator(db, driver, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	states, err := m.Status(context.Background())
+	if err != nil {
+		return err
+	}
+
+	for _, s := range states {
+		switch {
+		case s.Modified:
+			return fmt.Errorf("migration %s was modified after it was applied", s.Name)
+		case !s.Applied:
+			return fmt.Errorf("migration %s is not applied; run \"app migrate up\" or start the server first", s.Name)
+		}
+	}
+	return nil
+}
+
+// Up applies the pending migrations in version order and returns them.
+// It refuses to run when an applied migration has been modified since.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.session(ctx, func(conn *sql.Conn) error {
+		states, err := m.states(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range states {
+			if s.Modified {
+				return fmt.Errorf("migration %s was modified after it was applied", s.Name)
+			}
+		}
+
+		for _, s := range states {
+			if s.Applied {
+				continue
+			}
+
+			applied, err := m.step(ctx, conn, s.Migration, false)
+			if err != nil {
+				return fmt.Errorf("migration %s: %w", s.Name, err)
+			}
+			if applied {
+				done = append(done, s.Migration)
+			}
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// Down reverts the last steps applied migrations, newest first, and
+// returns them.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.session(ctx, func(conn *sql.Conn) error {
+		states, err := m.states(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(states) - 1; i >= 0 && len(done) < steps; i-- {
+			s := states[i]
+			if !s.Applied {
+				continue
+			}
+			if s.Up == "" {
+				return fmt.Errorf("migration %s is not known to this binary", s.Name)
+			}
+			if s.Down == "" {
+				return fmt.Errorf("migration %s cannot be reverted", s.Name)
+			}
+
+			reverted, err := m.step(ctx, conn, s.Migration, true)
+			if err != nil {
+				return fmt.Errorf("revert migration %s: %w", s.Name, err)
+			}
+			if reverted {
+				done = append(done, s.Migration)
+			}
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// Status returns every migration, known or applied, in version order.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationState, error) {
+	var states []MigrationState
+	err := m.session(ctx, func(conn *sql.Conn) error {
+		var err error
+		states, err = m.states(ctx, conn)
+		return err
+	})
+
+	return states, err
+}
+
+// session runs fn on a connection of its own, with schema_migrations in
+// place. On Postgres it holds the migration lock meanwhile, so replicas
+// starting together migrate one after another; a SQLite database has a
+// single writer anyway, and step rechecks what it does under the write
+// lock.
+func (m *Migrator) session(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.driver == "postgres" {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, MigrationLock); err != nil {
+			return err
+		}
+		defer conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, MigrationLock)
+	}
+
+	if _, err := conn.ExecContext(ctx, schemaMigrations[m.driver]); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// states merges the known migrations with the applied ones.
+func (m *Migrator) states(ctx context.Context, conn *sql.Conn) ([]MigrationState, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]MigrationState)
+	for rows.Next() {
+		var s MigrationState
+		if err := rows.Scan(&s.Version, &s.Name, &s.Checksum, &s.AppliedAt); err != nil {
+			return nil, err
+		}
+		s.Applied = true
+		applied[s.Version] = s
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := MigrationState{Migration: mig}
+		if a, ok := applied[mig.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = a.AppliedAt
+			s.Modified = a.Checksum != mig.Checksum
+			delete(applied, mig.Version)
+		}
+		states = append(states, s)
+	}
+	for _, s := range applied {
+		s.Checksum = ""
+		states = append(states, s)
+	}
+
+	sort.Slice(states, func(i, j int) bool { return states[i].Version < states[j].Version })
+	return states, nil
+}
+
+// step applies mig, or reverts it when down is set, together with its
+// row in schema_migrations. It returns false when another runner got
+// there first.
+func (m *Migrator) step(ctx context.Context, conn *sql.Conn, mig Migration, down bool) (bool, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var applied bool
+	err = tx.QueryRowContext(
+		ctx,
+		`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`,
+		mig.Version,
+	).Scan(&applied)
+	if err != nil {
+		return false, err
+	}
+	if applied != down {
+		return false, nil
+	}
+
+	if down {
+		if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+			return false, err
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, mig.Version)
+	} else {
+		if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+			return false, err
+		}
+		_, err = tx.ExecContext(
+			ctx,
+			`INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+			mig.Version,
+			mig.Name,
+			mig.Checksum,
+		)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// loadMigrations reads the NNN_name.up.sql and NNN_name.down.sql files
+// in dir. Every version needs an up file.
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		file := entry.Name()
+		match := migrationFile.FindStringSubmatch(file)
+		if match == nil {
+			return nil, fmt.Errorf("migration %s is not named NNN_name.up.sql or NNN_name.down.sql", file)
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		data, err := fs.ReadFile(fsys, path.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version}
+			byVersion[version] = mig
+		}
+
+		if match[2] == "down" {
+			mig.Down = string(data)
+			continue
+		}
+		if mig.Up != "" {
+			return nil, fmt.Errorf("two migrations with version %d", version)
+		}
+		sum := sha256.Sum256(data)
+		mig.Name = file[:len(file)-len(".up.sql")]
+		mig.Up = string(data)
+		mig.Checksum = hex.EncodeToString(sum[:])
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %d has no up file", mig.Version)
+		}
+		list = append(list, *mig)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
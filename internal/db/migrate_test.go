@@ -0,0 +1,266 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Hlompy/Wallet/migrations"
+)
+
+func testSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+
+	database, err := New("sqlite", filepath.Join(t.TempDir(), "wallet.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	return database
+}
+
+func testMigrations() fstest.MapFS {
+	return fstest.MapFS{
+		"sqlite/001_accounts.up.sql":   {Data: []byte(`CREATE TABLE accounts (id TEXT PRIMARY KEY)`)},
+		"sqlite/001_accounts.down.sql": {Data: []byte(`DROP TABLE accounts`)},
+		"sqlite/002_notes.up.sql":      {Data: []byte(`CREATE TABLE notes (id TEXT PRIMARY KEY)`)},
+		"sqlite/002_notes.down.sql":    {Data: []byte(`DROP TABLE notes`)},
+	}
+}
+
+func tableExists(t *testing.T, database *sql.DB, name string) bool {
+	t.Helper()
+
+	var exists bool
+	err := database.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = $1)`,
+		name,
+	).Scan(&exists)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return exists
+}
+
+func TestMigrator_UpDown(t *testing.T) {
+	ctx := context.Background()
+	database := testSQLite(t)
+
+	m, err := NewMigrator(database, "sqlite", testMigrations())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if len(applied) != 2 || applied[0].Name != "001_accounts" || applied[1].Name != "002_notes" {
+		t.Fatalf("applied %+v", applied)
+	}
+
+	applied, err = m.Up(ctx)
+	if err != nil || len(applied) != 0 {
+		t.Fatalf("second up applied %d, err %v", len(applied), err)
+	}
+
+	reverted, err := m.Down(ctx, 1)
+	if err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if len(reverted) != 1 || reverted[0].Version != 2 {
+		t.Fatalf("reverted %+v", reverted)
+	}
+	if !tableExists(t, database, "accounts") || tableExists(t, database, "notes") {
+		t.Fatal("down reverted the wrong migration")
+	}
+
+	states, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if len(states) != 2 || !states[0].Applied || states[0].AppliedAt.IsZero() || states[1].Applied {
+		t.Fatalf("status %+v", states)
+	}
+}
+
+func TestMigrator_ModifiedMigration(t *testing.T) {
+	ctx := context.Background()
+	database := testSQLite(t)
+
+	fsys := testMigrations()
+	m, err := NewMigrator(database, "sqlite", fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+
+	fsys["sqlite/001_accounts.up.sql"] = &fstest.MapFile{
+		Data: []byte(`CREATE TABLE accounts (id TEXT PRIMARY KEY, name TEXT)`),
+	}
+	fsys["sqlite/003_tags.up.sql"] = &fstest.MapFile{
+		Data: []byte(`CREATE TABLE tags (id TEXT PRIMARY KEY)`),
+	}
+
+	m, err = NewMigrator(database, "sqlite", fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = m.Up(ctx)
+	if err == nil || !strings.Contains(err.Error(), "001_accounts was modified") {
+		t.Fatalf("expected modified migration error, got %v", err)
+	}
+	if tableExists(t, database, "tags") {
+		t.Fatal("up went on despite the modified migration")
+	}
+
+	states, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !states[0].Modified || states[1].Modified {
+		t.Fatalf("status %+v", states)
+	}
+}
+
+func TestMigrator_UnknownAppliedMigration(t *testing.T) {
+	ctx := context.Background()
+	database := testSQLite(t)
+
+	m, err := NewMigrator(database, "sqlite", testMigrations())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+
+	older := testMigrations()
+	delete(older, "sqlite/002_notes.up.sql")
+	delete(older, "sqlite/002_notes.down.sql")
+
+	m, err = NewMigrator(database, "sqlite", older)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	states, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 2 || !states[1].Applied || states[1].Name != "002_notes" || states[1].Up != "" {
+		t.Fatalf("status %+v", states)
+	}
+
+	if _, err := m.Down(ctx, 1); err == nil {
+		t.Fatal("expected an error reverting a migration the binary does not know")
+	}
+	if !tableExists(t, database, "notes") {
+		t.Fatal("unknown migration was reverted")
+	}
+}
+
+func TestLoadMigrations_Invalid(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"bad name": {
+			"001_init.sql": {Data: []byte(`SELECT 1`)},
+		},
+		"down without up": {
+			"001_init.down.sql": {Data: []byte(`SELECT 1`)},
+		},
+		"duplicate version": {
+			"001_init.up.sql":  {Data: []byte(`SELECT 1`)},
+			"001_other.up.sql": {Data: []byte(`SELECT 1`)},
+		},
+	}
+
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := loadMigrations(fsys, "."); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+// TestEmbeddedMigrations_SQLite applies the shipped SQLite migrations and
+// reverts them all.
+func TestEmbeddedMigrations_SQLite(t *testing.T) {
+	ctx := context.Background()
+	database := testSQLite(t)
+
+	m, err := NewMigrator(database, "sqlite", migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if !tableExists(t, database, "wallets") {
+		t.Fatal("wallets table is missing")
+	}
+
+	reverted, err := m.Down(ctx, len(applied))
+	if err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if len(reverted) != len(applied) || tableExists(t, database, "wallets") {
+		t.Fatalf("reverted %d of %d migrations", len(reverted), len(applied))
+	}
+}
+
+func TestCheckMigrated(t *testing.T) {
+	database := testSQLite(t)
+
+	if err := CheckMigrated(database, "sqlite"); err == nil {
+		t.Fatal("expected an error for a database without migrations")
+	}
+
+	if err := Migrate(database, "sqlite"); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := CheckMigrated(database, "sqlite"); err != nil {
+		t.Fatalf("expected a migrated database to pass, got %v", err)
+	}
+
+	m, err := NewMigrator(database, "sqlite", migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Down(context.Background(), 1); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if err := CheckMigrated(database, "sqlite"); err == nil || !strings.Contains(err.Error(), "not applied") {
+		t.Errorf("expected a pending migration error, got %v", err)
+	}
+}
+
+// TestEmbeddedMigrations_Postgres checks that every shipped Postgres
+// migration can be reverted.
+func TestEmbeddedMigrations_Postgres(t *testing.T) {
+	list, err := loadMigrations(migrations.FS, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) == 0 {
+		t.Fatal("no migrations found")
+	}
+
+	for i, mig := range list {
+		if mig.Version != i+1 {
+			t.Errorf("migration %s: expected version %d", mig.Name, i+1)
+		}
+		if mig.Down == "" {
+			t.Errorf("migration %s has no down file", mig.Name)
+		}
+	}
+}
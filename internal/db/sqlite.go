@@ -0,0 +1,28 @@
+package db
+
+import (
+	"database/sql"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteParams are set on every connection: a writer waits up to five
+// seconds for the write lock instead of failing at once, WAL mode lets
+// readers go on while it writes, and transactions take the write lock
+// when they begin rather than on their first write.
+const sqliteParams = "_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_txlock=immediate"
+
+// openSQLite opens the database file at path, creating it if needed.
+func openSQLite(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?"+sqliteParams)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
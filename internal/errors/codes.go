@@ -0,0 +1,86 @@
+package errors
+
+// Error codes returned to API clients. Clients match on them, so a code
+// must never change once released; add a new one instead.
+const (
+	CodeValidationFailed    = "VALIDATION_FAILED"
+	CodeInsufficientFunds   = "INSUFFICIENT_FUNDS"
+	CodeWalletNotFound      = "WALLET_NOT_FOUND"
+	CodeWalletFrozen        = "WALLET_FROZEN"
+	CodeInvalidOperation    = "INVALID_OPERATION"
+	CodeInvalidCursor       = "INVALID_CURSOR"
+	CodeInvalidDateRange    = "INVALID_DATE_RANGE"
+	CodeSameWallet          = "SAME_WALLET"
+	CodeInvalidTTL          = "INVALID_TTL"
+	CodeInvalidCurrency     = "INVALID_CURRENCY"
+	CodeCurrencyMismatch    = "CURRENCY_MISMATCH"
+	CodeSameCurrency        = "SAME_CURRENCY"
+	CodeInvalidRate         = "INVALID_RATE"
+	CodeRateNotFound        = "RATE_NOT_FOUND"
+	CodeAmountTooSmall      = "AMOUNT_TOO_SMALL"
+	CodeHoldNotFound        = "HOLD_NOT_FOUND"
+	CodeHoldNotActive       = "HOLD_NOT_ACTIVE"
+	CodeCaptureExceedsHold  = "CAPTURE_EXCEEDS_HOLD"
+	CodeIdempotencyConflict = "IDEMPOTENCY_CONFLICT"
+	CodeVersionMismatch     = "VERSION_MISMATCH"
+	CodeConcurrentUpdate    = "CONCURRENT_UPDATE"
+	CodeBatchTooLarge       = "BATCH_TOO_LARGE"
+	CodeImportNotFound      = "IMPORT_NOT_FOUND"
+	CodeInvalidImport       = "INVALID_IMPORT"
+	CodeImportTooLarge      = "IMPORT_TOO_LARGE"
+	CodeWebhookNotFound     = "WEBHOOK_NOT_FOUND"
+	CodeInvalidWebhook      = "INVALID_WEBHOOK"
+	CodeDeliveryNotFound    = "DELIVERY_NOT_FOUND"
+	CodeUnauthorized        = "UNAUTHORIZED"
+	CodeForbidden           = "FORBIDDEN"
+	CodeInternal            = "INTERNAL_ERROR"
+)
+
+var domainErrors = []*Error{
+	ErrInsufficientFunds,
+	ErrWalletNotFound,
+	ErrWalletFrozen,
+	ErrInvalidOperation,
+	ErrInvalidCursor,
+	ErrInvalidDateRange,
+	ErrSameWallet,
+	ErrInvalidTTL,
+	ErrInvalidCurrency,
+	ErrCurrencyMismatch,
+	ErrSameCurrency,
+	ErrInvalidRate,
+	ErrRateNotFound,
+	ErrAmountTooSmall,
+	ErrHoldNotFound,
+	ErrHoldNotActive,
+	ErrCaptureExceedsHold,
+	ErrIdempotencyConflict,
+	ErrVersionMismatch,
+	ErrConcurrentUpdate,
+	ErrBatchTooLarge,
+	ErrImportNotFound,
+	ErrInvalidImport,
+	ErrWebhookNotFound,
+	ErrInvalidWebhook,
+	ErrDeliveryNotFound,
+}
+
+// FromMessage returns the domain error with the given message.
+func FromMessage(msg string) (*Error, bool) {
+	for _, err := range domainErrors {
+		if err.Message == msg {
+			return err, true
+		}
+	}
+	return nil, false
+}
+
+// FromCode returns the domain error with the given code.
+func FromCode(code string) (*Error, bool) {
+	for _, err := range domainErrors {
+		if err.Code == code {
+			return err, true
+		}
+	}
+	return nil, false
+}
@@ -1,9 +1,101 @@
 package errors
 
-import "errors"
+import (
+	"errors"
+	"net/http"
+)
+
+// Error is a domain error: something the client did or asked for that the
+// service refuses, as opposed to a failure of the service itself. Code and
+// Status say how it is reported over HTTP, Details carries values that
+// help the client react (e.g. the available balance), Err the cause.
+//
+// Two Errors match under errors.Is when their codes match, so an Error
+// enriched with details or a cause still matches its sentinel below.
+type Error struct {
+	Code    string
+	Status  int
+	Message string
+	Details map[string]any
+	Err     error
+}
+
+func New(code string, status int, message string) *Error {
+	return &Error{Code: code, Status: status, Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// WithDetails returns a copy of e carrying details.
+func (e *Error) WithDetails(details map[string]any) *Error {
+	c := *e
+	c.Details = details
+	return &c
+}
+
+// Wrap returns a copy of e caused by err.
+func (e *Error) Wrap(err error) *Error {
+	c := *e
+	c.Err = err
+	return &c
+}
 
 var (
-	ErrInsufficientFunds = errors.New("insufficient funds")
-	ErrWalletNotFound    = errors.New("wallet not found")
-	ErrInvalidOperation  = errors.New("invalid operation type")
+	ErrInsufficientFunds = New(CodeInsufficientFunds, http.StatusBadRequest, "insufficient funds")
+	ErrWalletNotFound    = New(CodeWalletNotFound, http.StatusNotFound, "wallet not found")
+	ErrWalletFrozen      = New(CodeWalletFrozen, http.StatusConflict, "wallet is frozen")
+	ErrInvalidOperation  = New(CodeInvalidOperation, http.StatusBadRequest, "invalid operation type")
+	ErrInvalidCursor     = New(CodeInvalidCursor, http.StatusBadRequest, "invalid cursor")
+	ErrInvalidDateRange  = New(CodeInvalidDateRange, http.StatusBadRequest, "invalid date range")
+	ErrSameWallet        = New(CodeSameWallet, http.StatusBadRequest, "source and destination wallets must differ")
+	ErrInvalidTTL        = New(CodeInvalidTTL, http.StatusBadRequest, "invalid hold ttl")
+	ErrInvalidCurrency   = New(CodeInvalidCurrency, http.StatusBadRequest, "invalid currency")
+	ErrCurrencyMismatch  = New(CodeCurrencyMismatch, http.StatusBadRequest, "currency does not match wallet")
+	ErrSameCurrency      = New(CodeSameCurrency, http.StatusBadRequest, "source and target currencies must differ")
+	ErrInvalidRate       = New(CodeInvalidRate, http.StatusBadRequest, "invalid exchange rate")
+	ErrRateNotFound      = New(CodeRateNotFound, http.StatusNotFound, "exchange rate not found")
+	ErrAmountTooSmall    = New(CodeAmountTooSmall, http.StatusBadRequest, "amount too small to convert")
+
+	ErrHoldNotFound       = New(CodeHoldNotFound, http.StatusNotFound, "hold not found")
+	ErrHoldNotActive      = New(CodeHoldNotActive, http.StatusConflict, "hold is not active")
+	ErrCaptureExceedsHold = New(CodeCaptureExceedsHold, http.StatusBadRequest, "capture amount exceeds hold")
+
+	ErrIdempotencyConflict = New(CodeIdempotencyConflict, http.StatusConflict, "idempotency key already used for a different request")
+
+	ErrVersionMismatch  = New(CodeVersionMismatch, http.StatusPreconditionFailed, "wallet version does not match")
+	ErrConcurrentUpdate = New(CodeConcurrentUpdate, http.StatusConflict, "wallet is being updated concurrently, retry later")
+
+	ErrBatchTooLarge = New(CodeBatchTooLarge, http.StatusBadRequest, "too many operations in batch")
+
+	ErrImportNotFound = New(CodeImportNotFound, http.StatusNotFound, "import not found")
+	ErrInvalidImport  = New(CodeInvalidImport, http.StatusBadRequest, "invalid import file")
+
+	ErrWebhookNotFound  = New(CodeWebhookNotFound, http.StatusNotFound, "webhook not found")
+	ErrInvalidWebhook   = New(CodeInvalidWebhook, http.StatusBadRequest, "invalid webhook")
+	ErrDeliveryNotFound = New(CodeDeliveryNotFound, http.StatusNotFound, "webhook delivery not found")
+
+	// ErrUnbalancedJournal is a bug, not a domain error: clients only see
+	// an internal error.
+	ErrUnbalancedJournal = errors.New("journal entries do not balance")
 )
+
+// As returns the domain error in err's chain, if any.
+func As(err error) (*Error, bool) {
+	var e *Error
+	ok := errors.As(err, &e)
+	return e, ok
+}
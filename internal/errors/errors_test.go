@@ -0,0 +1,72 @@
+package errors
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestError_Is(t *testing.T) {
+	detailed := ErrInsufficientFunds.WithDetails(map[string]any{"available": int64(1)})
+	caused := ErrWalletNotFound.Wrap(sql.ErrNoRows)
+
+	tests := []struct {
+		name   string
+		err    error
+		target error
+		want   bool
+	}{
+		{"sentinel", ErrInsufficientFunds, ErrInsufficientFunds, true},
+		{"with details", detailed, ErrInsufficientFunds, true},
+		{"wrapped with details", fmt.Errorf("withdraw: %w", detailed), ErrInsufficientFunds, true},
+		{"other code", detailed, ErrWalletNotFound, false},
+		{"cause", caused, sql.ErrNoRows, true},
+		{"cause keeps code", caused, ErrWalletNotFound, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errors.Is(tt.err, tt.target); got != tt.want {
+				t.Errorf("errors.Is = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestError_CopiesDoNotTouchSentinel(t *testing.T) {
+	ErrInsufficientFunds.WithDetails(map[string]any{"available": int64(1)})
+	ErrWalletNotFound.Wrap(sql.ErrNoRows)
+
+	if ErrInsufficientFunds.Details != nil || ErrWalletNotFound.Err != nil {
+		t.Error("sentinels must stay unchanged")
+	}
+}
+
+func TestAs(t *testing.T) {
+	e, ok := As(fmt.Errorf("capture: %w", ErrCaptureExceedsHold))
+	if !ok || e.Code != CodeCaptureExceedsHold {
+		t.Errorf("expected %s, got %+v", CodeCaptureExceedsHold, e)
+	}
+
+	if _, ok := As(ErrUnbalancedJournal); ok {
+		t.Error("ErrUnbalancedJournal must not be a domain error")
+	}
+}
+
+func TestDomainErrors_UniqueCodes(t *testing.T) {
+	seen := map[string]bool{}
+	for _, err := range domainErrors {
+		if seen[err.Code] {
+			t.Errorf("duplicate code %s", err.Code)
+		}
+		seen[err.Code] = true
+
+		if got, ok := FromMessage(err.Message); !ok || got != err {
+			t.Errorf("FromMessage(%q) = %v", err.Message, got)
+		}
+		if got, ok := FromCode(err.Code); !ok || got != err {
+			t.Errorf("FromCode(%q) = %v", err.Code, got)
+		}
+	}
+}
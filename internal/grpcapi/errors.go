@@ -0,0 +1,81 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorDomain is the domain of the google.rpc.ErrorInfo attached to
+// refused requests; its reason is the domain error's code.
+const ErrorDomain = "wallet"
+
+// domainCodes overrides the code derived from the HTTP status where gRPC
+// has a more precise one.
+var domainCodes = map[string]codes.Code{
+	appErr.CodeInsufficientFunds:   codes.FailedPrecondition,
+	appErr.CodeHoldNotActive:       codes.FailedPrecondition,
+	appErr.CodeWalletFrozen:        codes.FailedPrecondition,
+	appErr.CodeConcurrentUpdate:    codes.Aborted,
+	appErr.CodeIdempotencyConflict: codes.AlreadyExists,
+}
+
+// statusError turns err into a gRPC status error. Domain errors keep their
+// message, code and details; anything else becomes a bare internal error.
+func statusError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+
+	e, ok := appErr.As(err)
+	if !ok {
+		return status.Error(codes.Internal, "internal error")
+	}
+
+	info := &errdetails.ErrorInfo{Reason: e.Code, Domain: ErrorDomain}
+	if len(e.Details) > 0 {
+		info.Metadata = make(map[string]string, len(e.Details))
+		for k, v := range e.Details {
+			info.Metadata[k] = fmt.Sprint(v)
+		}
+	}
+
+	st, detailErr := status.New(grpcCode(e), e.Message).WithDetails(info)
+	if detailErr != nil {
+		return status.Error(grpcCode(e), e.Message)
+	}
+	return st.Err()
+}
+
+func grpcCode(e *appErr.Error) codes.Code {
+	if code, ok := domainCodes[e.Code]; ok {
+		return code
+	}
+
+	switch e.Status {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	default:
+		return codes.Internal
+	}
+}
@@ -0,0 +1,192 @@
+// Package grpcapi serves the wallet over gRPC, next to the REST handler
+// and on top of the same service.
+package grpcapi
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	walletv1 "github.com/Hlompy/Wallet/api/wallet/v1"
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/handler"
+	"github.com/Hlompy/Wallet/internal/model"
+	"github.com/Hlompy/Wallet/internal/stream"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type Server struct {
+	walletv1.UnimplementedWalletServiceServer
+
+	service handler.WalletService
+
+	events   stream.Subscriber
+	interval time.Duration
+}
+
+type Option func(*Server)
+
+// WithEventStream wakes WatchBalance streams through events, and has them
+// check for events every interval in case a notification was lost.
+func WithEventStream(events stream.Subscriber, interval time.Duration) Option {
+	return func(s *Server) {
+		s.events = events
+		s.interval = interval
+	}
+}
+
+func New(service handler.WalletService, opts ...Option) *Server {
+	s := &Server{
+		service:  service,
+		interval: 15 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *Server) Process(ctx context.Context, req *walletv1.ProcessRequest) (*walletv1.ProcessResponse, error) {
+	if _, err := uuid.Parse(req.WalletId); err != nil {
+		return nil, validationError("invalid wallet_id")
+	}
+	if req.IfVersion < 0 {
+		return nil, validationError("invalid if_version")
+	}
+
+	err := s.service.Process(ctx, req.WalletId, req.Currency, req.OperationType, req.Amount, req.IfVersion)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	wallet, err := s.service.Balance(ctx, req.WalletId, req.Currency)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &walletv1.ProcessResponse{
+		WalletId: req.WalletId,
+		Currency: wallet.Currency,
+		Balance:  wallet.Balance,
+		Version:  wallet.Version,
+	}, nil
+}
+
+func (s *Server) Balance(ctx context.Context, req *walletv1.BalanceRequest) (*walletv1.BalanceResponse, error) {
+	if _, err := uuid.Parse(req.WalletId); err != nil {
+		return nil, validationError("invalid wallet_id")
+	}
+
+	wallet, err := s.service.Balance(ctx, req.WalletId, req.Currency)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	currency, _ := model.LookupCurrency(wallet.Currency)
+
+	return &walletv1.BalanceResponse{
+		WalletId:         req.WalletId,
+		Currency:         wallet.Currency,
+		MinorUnits:       int32(currency.MinorUnits),
+		Balance:          wallet.Balance,
+		AvailableBalance: wallet.Available(),
+		Version:          wallet.Version,
+	}, nil
+}
+
+func (s *Server) Transfer(ctx context.Context, req *walletv1.TransferRequest) (*walletv1.TransferResponse, error) {
+	from, err := uuid.Parse(req.FromWalletId)
+	if err != nil {
+		return nil, validationError("invalid from_wallet_id")
+	}
+
+	to, err := uuid.Parse(req.ToWalletId)
+	if err != nil {
+		return nil, validationError("invalid to_wallet_id")
+	}
+
+	res, err := s.service.Transfer(ctx, from.String(), to.String(), req.Currency, req.Amount)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &walletv1.TransferResponse{
+		FromWalletId: res.FromWalletID,
+		FromBalance:  res.FromBalance,
+		ToWalletId:   res.ToWalletID,
+		ToBalance:    res.ToBalance,
+		Currency:     res.Currency,
+	}, nil
+}
+
+// WatchBalance follows the wallet like GET /api/v1/wallets/{id}/events,
+// with after_sequence in place of Last-Event-ID.
+func (s *Server) WatchBalance(req *walletv1.WatchBalanceRequest, srv grpc.ServerStreamingServer[walletv1.WatchBalanceResponse]) error {
+	walletID, err := uuid.Parse(req.WalletId)
+	if err != nil {
+		return validationError("invalid wallet_id")
+	}
+	if req.GetAfterSequence() < 0 {
+		return validationError("invalid after_sequence")
+	}
+
+	// Notifications carry the canonical form of the id.
+	watcher := stream.NewWatcher(s.service, s.events, s.interval)
+	err = watcher.Watch(srv.Context(), walletID.String(), req.GetAfterSequence(), req.AfterSequence != nil, &watchSink{srv: srv})
+	if err != nil {
+		return statusError(err)
+	}
+	return nil
+}
+
+type watchSink struct {
+	srv grpc.ServerStreamingServer[walletv1.WatchBalanceResponse]
+}
+
+// Open sends the headers, so the client knows the wallet exists before
+// the first update.
+func (s *watchSink) Open() error {
+	return s.srv.SendHeader(metadata.MD{})
+}
+
+func (s *watchSink) Send(u stream.Update) error {
+	if u.Event == nil {
+		snapshot := &walletv1.BalanceSnapshot{Sequence: u.Sequence}
+		for _, wallet := range u.Balances {
+			snapshot.Balances = append(snapshot.Balances, &walletv1.CurrencyBalance{
+				Currency:         wallet.Currency,
+				Balance:          wallet.Balance,
+				AvailableBalance: wallet.Available(),
+			})
+		}
+		return s.srv.Send(&walletv1.WatchBalanceResponse{
+			Update: &walletv1.WatchBalanceResponse_Snapshot{Snapshot: snapshot},
+		})
+	}
+
+	e := u.Event
+	return s.srv.Send(&walletv1.WatchBalanceResponse{
+		Update: &walletv1.WatchBalanceResponse_Event{Event: &walletv1.BalanceEvent{
+			Sequence:     e.Sequence,
+			Type:         e.Type,
+			Currency:     e.Currency,
+			Amount:       e.Amount,
+			BalanceAfter: e.BalanceAfter,
+			CreatedAt:    timestamppb.New(e.CreatedAt),
+		}},
+	})
+}
+
+// Idle sends nothing: quiet connections are kept alive with HTTP/2 pings
+// (see keepalive.ServerParameters).
+func (s *watchSink) Idle() error {
+	return nil
+}
+
+func validationError(detail string) error {
+	return statusError(appErr.New(appErr.CodeValidationFailed, http.StatusBadRequest, detail))
+}
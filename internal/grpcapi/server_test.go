@@ -0,0 +1,292 @@
+package grpcapi
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	walletv1 "github.com/Hlompy/Wallet/api/wallet/v1"
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/handler"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	testWalletID = "550e8400-e29b-41d4-a716-446655440000"
+	testOtherID  = "11111111-1111-1111-1111-111111111111"
+)
+
+// MockWalletService implements the methods the gRPC API uses; the others
+// panic through the nil embedded interface.
+type MockWalletService struct {
+	handler.WalletService
+
+	ProcessFunc        func(ctx context.Context, walletID, currency, op string, amount, ifVersion int64) error
+	BalanceFunc        func(ctx context.Context, walletID, currency string) (model.Wallet, error)
+	TransferFunc       func(ctx context.Context, fromID, toID, currency string, amount int64) (model.TransferResult, error)
+	WalletSnapshotFunc func(ctx context.Context, walletID string) (int64, []model.Wallet, error)
+	WalletEventsFunc   func(ctx context.Context, walletID string, after int64) ([]model.WalletEvent, error)
+}
+
+func (m *MockWalletService) Process(ctx context.Context, walletID, currency, op string, amount, ifVersion int64) error {
+	if m.ProcessFunc != nil {
+		return m.ProcessFunc(ctx, walletID, currency, op, amount, ifVersion)
+	}
+	return nil
+}
+
+func (m *MockWalletService) Balance(ctx context.Context, walletID, currency string) (model.Wallet, error) {
+	if m.BalanceFunc != nil {
+		return m.BalanceFunc(ctx, walletID, currency)
+	}
+	return model.Wallet{}, nil
+}
+
+func (m *MockWalletService) Transfer(ctx context.Context, fromID, toID, currency string, amount int64) (model.TransferResult, error) {
+	if m.TransferFunc != nil {
+		return m.TransferFunc(ctx, fromID, toID, currency, amount)
+	}
+	return model.TransferResult{}, nil
+}
+
+func (m *MockWalletService) WalletSnapshot(ctx context.Context, walletID string) (int64, []model.Wallet, error) {
+	if m.WalletSnapshotFunc != nil {
+		return m.WalletSnapshotFunc(ctx, walletID)
+	}
+	return 0, nil, nil
+}
+
+func (m *MockWalletService) WalletEvents(ctx context.Context, walletID string, after int64) ([]model.WalletEvent, error) {
+	if m.WalletEventsFunc != nil {
+		return m.WalletEventsFunc(ctx, walletID, after)
+	}
+	return nil, nil
+}
+
+// dial serves the service over an in-memory connection.
+func dial(t *testing.T, service handler.WalletService) walletv1.WalletServiceClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	walletv1.RegisterWalletServiceServer(srv, New(service))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return walletv1.NewWalletServiceClient(conn)
+}
+
+func TestProcess_Success(t *testing.T) {
+	var gotOp string
+	var gotAmount, gotVersion int64
+	client := dial(t, &MockWalletService{
+		ProcessFunc: func(ctx context.Context, walletID, currency, op string, amount, ifVersion int64) error {
+			gotOp, gotAmount, gotVersion = op, amount, ifVersion
+			return nil
+		},
+		BalanceFunc: func(ctx context.Context, walletID, currency string) (model.Wallet, error) {
+			return model.Wallet{Currency: "USD", Balance: 1500, Version: 4}, nil
+		},
+	})
+
+	resp, err := client.Process(context.Background(), &walletv1.ProcessRequest{
+		WalletId:      testWalletID,
+		OperationType: model.OpDeposit,
+		Amount:        500,
+		IfVersion:     3,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if gotOp != model.OpDeposit || gotAmount != 500 || gotVersion != 3 {
+		t.Errorf("unexpected call: %s %d %d", gotOp, gotAmount, gotVersion)
+	}
+	want := &walletv1.ProcessResponse{WalletId: testWalletID, Currency: "USD", Balance: 1500, Version: 4}
+	if !proto.Equal(resp, want) {
+		t.Errorf("expected %v, got %v", want, resp)
+	}
+}
+
+func TestProcess_InsufficientFunds(t *testing.T) {
+	client := dial(t, &MockWalletService{
+		ProcessFunc: func(ctx context.Context, walletID, currency, op string, amount, ifVersion int64) error {
+			return appErr.ErrInsufficientFunds.WithDetails(map[string]any{"available": int64(100)})
+		},
+	})
+
+	_, err := client.Process(context.Background(), &walletv1.ProcessRequest{
+		WalletId:      testWalletID,
+		OperationType: model.OpWithdraw,
+		Amount:        500,
+	})
+
+	st := status.Convert(err)
+	if st.Code() != codes.FailedPrecondition || st.Message() != appErr.ErrInsufficientFunds.Message {
+		t.Fatalf("unexpected status: %v", st)
+	}
+
+	info := errorInfo(t, st)
+	if info.Reason != appErr.CodeInsufficientFunds || info.Domain != ErrorDomain || info.Metadata["available"] != "100" {
+		t.Errorf("unexpected error info: %v", info)
+	}
+}
+
+func TestBalance_InvalidWalletID(t *testing.T) {
+	client := dial(t, &MockWalletService{})
+
+	_, err := client.Balance(context.Background(), &walletv1.BalanceRequest{WalletId: "not-a-uuid"})
+
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", st)
+	}
+	if info := errorInfo(t, st); info.Reason != appErr.CodeValidationFailed {
+		t.Errorf("expected reason %s, got %s", appErr.CodeValidationFailed, info.Reason)
+	}
+}
+
+func TestTransfer_WalletNotFound(t *testing.T) {
+	client := dial(t, &MockWalletService{
+		TransferFunc: func(ctx context.Context, fromID, toID, currency string, amount int64) (model.TransferResult, error) {
+			return model.TransferResult{}, appErr.ErrWalletNotFound
+		},
+	})
+
+	_, err := client.Transfer(context.Background(), &walletv1.TransferRequest{
+		FromWalletId: testWalletID,
+		ToWalletId:   testOtherID,
+		Amount:       100,
+	})
+
+	if code := status.Code(err); code != codes.NotFound {
+		t.Errorf("expected NotFound, got %v", code)
+	}
+}
+
+func TestWatchBalance_ResumesWithEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := dial(t, &MockWalletService{
+		WalletSnapshotFunc: func(ctx context.Context, walletID string) (int64, []model.Wallet, error) {
+			return 9, []model.Wallet{{Currency: "USD", Balance: 500}}, nil
+		},
+		WalletEventsFunc: func(ctx context.Context, walletID string, after int64) ([]model.WalletEvent, error) {
+			if after == 7 {
+				return []model.WalletEvent{{Sequence: 8}, {Sequence: 9, BalanceAfter: 500}}, nil
+			}
+			return nil, nil
+		},
+	})
+
+	watch, err := client.WatchBalance(ctx, &walletv1.WatchBalanceRequest{WalletId: testWalletID, AfterSequence: proto.Int64(7)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []int64{8, 9} {
+		resp, err := watch.Recv()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if e := resp.GetEvent(); e == nil || e.Sequence != want {
+			t.Errorf("expected event %d, got %v", want, resp)
+		}
+	}
+}
+
+func TestWatchBalance_CanonicalID(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	read := make(chan string, 2)
+	client := dial(t, &MockWalletService{
+		WalletSnapshotFunc: func(ctx context.Context, walletID string) (int64, []model.Wallet, error) {
+			read <- walletID
+			return 0, nil, nil
+		},
+		WalletEventsFunc: func(ctx context.Context, walletID string, after int64) ([]model.WalletEvent, error) {
+			return nil, nil
+		},
+	})
+
+	watch, err := client.WatchBalance(ctx, &walletv1.WatchBalanceRequest{WalletId: strings.ToUpper(testWalletID)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := watch.Recv(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if id := <-read; id != testWalletID {
+		t.Errorf("expected the wallet watched under its canonical id, got %q", id)
+	}
+}
+
+func TestWatchBalance_SnapshotAndNotFound(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := dial(t, &MockWalletService{
+		WalletSnapshotFunc: func(ctx context.Context, walletID string) (int64, []model.Wallet, error) {
+			if walletID == testOtherID {
+				return 0, nil, appErr.ErrWalletNotFound
+			}
+			return 3, []model.Wallet{{Currency: "USD", Balance: 500, Held: 100}}, nil
+		},
+	})
+
+	watch, err := client.WatchBalance(ctx, &walletv1.WatchBalanceRequest{WalletId: testWalletID})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp, err := watch.Recv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := &walletv1.BalanceSnapshot{Sequence: 3, Balances: []*walletv1.CurrencyBalance{{Currency: "USD", Balance: 500, AvailableBalance: 400}}}
+	if !proto.Equal(resp.GetSnapshot(), want) {
+		t.Errorf("expected snapshot %v, got %v", want, resp)
+	}
+
+	watch, err = client.WatchBalance(ctx, &walletv1.WatchBalanceRequest{WalletId: testOtherID})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := watch.Recv(); err == io.EOF || status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound, got %v", err)
+	}
+}
+
+func errorInfo(t *testing.T, st *status.Status) *errdetails.ErrorInfo {
+	t.Helper()
+
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok {
+			return info
+		}
+	}
+	t.Fatalf("no ErrorInfo in %v", st)
+	return nil
+}
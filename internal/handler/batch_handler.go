@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+)
+
+type batchRequest struct {
+	Atomic     bool                    `json:"atomic"`
+	Operations []batchOperationRequest `json:"operations"`
+	RequestID  string                  `json:"requestId,omitempty"`
+}
+
+type batchOperationRequest struct {
+	WalletID string `json:"walletId"`
+	Currency string `json:"currency,omitempty"`
+	OpType   string `json:"operationType"`
+	Amount   int64  `json:"amount"`
+}
+
+type batchResponse struct {
+	Atomic  bool                `json:"atomic"`
+	Results []batchItemResponse `json:"results"`
+}
+
+// batchItemResponse carries the status a single POST /api/v1/wallet
+// would have answered with, and its body.
+type batchItemResponse struct {
+	Status int              `json:"status"`
+	Result *walletResponse  `json:"result,omitempty"`
+	Error  *problemResponse `json:"error,omitempty"`
+}
+
+func (h *Handler) PostWalletBatch(w http.ResponseWriter, r *http.Request) {
+	var req batchRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid json")
+		return
+	}
+
+	if len(req.Operations) == 0 {
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "operations must not be empty")
+		return
+	}
+
+	hashParts := []string{r.Method, r.URL.Path, strconv.FormatBool(req.Atomic)}
+	for i, op := range req.Operations {
+		id, err := uuid.Parse(op.WalletID)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, fmt.Sprintf("operations[%d]: invalid walletId", i))
+			return
+		}
+
+		// Canonical ids keep the lock order and the request hash stable.
+		req.Operations[i].WalletID = id.String()
+		hashParts = append(hashParts, req.Operations[i].WalletID, op.Currency, op.OpType, strconv.FormatInt(op.Amount, 10))
+	}
+
+	h.respond(w, r, req.RequestID, requestHash(hashParts...), func(ctx context.Context) (int, []byte, error) {
+		return h.processBatch(ctx, req)
+	})
+}
+
+func (h *Handler) processBatch(ctx context.Context, req batchRequest) (int, []byte, error) {
+	ops := make([]model.BatchOperation, len(req.Operations))
+	for i, op := range req.Operations {
+		ops[i] = model.BatchOperation{
+			WalletID: op.WalletID,
+			Currency: op.Currency,
+			Type:     op.OpType,
+			Amount:   op.Amount,
+		}
+	}
+
+	results, err := h.service.ProcessBatch(ctx, ops, req.Atomic)
+	if err != nil {
+		return errorResult(err)
+	}
+
+	resp := batchResponse{
+		Atomic:  req.Atomic,
+		Results: make([]batchItemResponse, len(results)),
+	}
+	for i, res := range results {
+		if res.Err != nil {
+			p, ok := domainProblem(res.Err)
+			if !ok {
+				p = newProblem(http.StatusInternalServerError, appErr.CodeInternal, "internal error")
+			}
+			resp.Results[i] = batchItemResponse{Status: p.Status, Error: &p}
+			continue
+		}
+
+		resp.Results[i] = batchItemResponse{
+			Status: http.StatusOK,
+			Result: &walletResponse{
+				WalletID: req.Operations[i].WalletID,
+				Currency: res.Wallet.Currency,
+				Balance:  res.Wallet.Balance,
+				Version:  res.Wallet.Version,
+			},
+		}
+	}
+
+	body, err := json.Marshal(resp)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, body, nil
+}
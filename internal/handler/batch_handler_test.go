@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+func TestPostWalletBatch_ReportsEachItem(t *testing.T) {
+	mockService := &MockWalletService{
+		ProcessBatchFunc: func(ctx context.Context, ops []model.BatchOperation, atomic bool) ([]model.BatchResult, error) {
+			if atomic {
+				t.Error("expected a non-atomic batch")
+			}
+			if len(ops) != 3 || ops[0].WalletID != "550e8400-e29b-41d4-a716-446655440000" {
+				t.Errorf("unexpected operations: %+v", ops)
+			}
+			return []model.BatchResult{
+				{Wallet: model.Wallet{Currency: "RUB", Balance: 1500, Version: 3}},
+				{Err: appErr.ErrInsufficientFunds},
+				{Err: errors.New("connection reset")},
+			}, nil
+		},
+	}
+
+	handler := New(mockService)
+
+	body := `{"operations":[
+		{"walletId":"550E8400-E29B-41D4-A716-446655440000","operationType":"DEPOSIT","amount":500},
+		{"walletId":"11111111-1111-1111-1111-111111111111","operationType":"WITHDRAW","amount":100},
+		{"walletId":"22222222-2222-2222-2222-222222222222","operationType":"DEPOSIT","amount":100}
+	]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/wallet/batch", bytes.NewReader([]byte(body)))
+	rec := httptest.NewRecorder()
+
+	handler.PostWalletBatch(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var resp batchResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+
+	if len(resp.Results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(resp.Results))
+	}
+
+	ok := resp.Results[0]
+	if ok.Status != http.StatusOK || ok.Result == nil || ok.Result.Balance != 1500 || ok.Result.WalletID != "550e8400-e29b-41d4-a716-446655440000" {
+		t.Errorf("unexpected first result: %+v", ok)
+	}
+
+	failed := resp.Results[1]
+	if failed.Status != http.StatusBadRequest || failed.Error == nil || failed.Error.Code != appErr.CodeInsufficientFunds {
+		t.Errorf("unexpected second result: %+v", failed)
+	}
+
+	internal := resp.Results[2]
+	if internal.Status != http.StatusInternalServerError || internal.Error == nil || internal.Error.Detail != "internal error" {
+		t.Errorf("unexpected third result: %+v", internal)
+	}
+}
+
+func TestPostWalletBatch_AtomicFailure(t *testing.T) {
+	mockService := &MockWalletService{
+		ProcessBatchFunc: func(ctx context.Context, ops []model.BatchOperation, atomic bool) ([]model.BatchResult, error) {
+			if !atomic {
+				t.Error("expected an atomic batch")
+			}
+			return nil, appErr.ErrInsufficientFunds.WithDetails(map[string]any{"index": 1})
+		},
+	}
+
+	handler := New(mockService)
+
+	body := `{"atomic":true,"operations":[
+		{"walletId":"550e8400-e29b-41d4-a716-446655440000","operationType":"DEPOSIT","amount":500},
+		{"walletId":"11111111-1111-1111-1111-111111111111","operationType":"WITHDRAW","amount":100}
+	]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/wallet/batch", bytes.NewReader([]byte(body)))
+	rec := httptest.NewRecorder()
+
+	handler.PostWalletBatch(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+
+	var p problemResponse
+	json.NewDecoder(rec.Body).Decode(&p)
+
+	if p.Code != appErr.CodeInsufficientFunds || p.Details["index"] != float64(1) {
+		t.Errorf("unexpected problem: %+v", p)
+	}
+}
+
+func TestPostWalletBatch_InvalidRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		detail string
+	}{
+		{"invalid json", "{", "invalid json"},
+		{"no operations", `{"operations":[]}`, "operations must not be empty"},
+		{"invalid wallet", `{"operations":[{"walletId":"550e8400-e29b-41d4-a716-446655440000","operationType":"DEPOSIT","amount":1},{"walletId":"nope","operationType":"DEPOSIT","amount":1}]}`, "operations[1]: invalid walletId"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := New(&MockWalletService{
+				ProcessBatchFunc: func(ctx context.Context, ops []model.BatchOperation, atomic bool) ([]model.BatchResult, error) {
+					t.Error("service must not be called")
+					return nil, nil
+				},
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/wallet/batch", bytes.NewReader([]byte(tt.body)))
+			rec := httptest.NewRecorder()
+
+			handler.PostWalletBatch(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d", rec.Code)
+			}
+
+			var p problemResponse
+			json.NewDecoder(rec.Body).Decode(&p)
+			if p.Detail != tt.detail {
+				t.Errorf("expected detail %q, got %q", tt.detail, p.Detail)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+
+	"github.com/google/uuid"
+)
+
+type conversionRequest struct {
+	WalletID     string `json:"walletId"`
+	FromCurrency string `json:"fromCurrency"`
+	ToCurrency   string `json:"toCurrency"`
+	Amount       int64  `json:"amount"`
+	RequestID    string `json:"requestId,omitempty"`
+}
+
+type conversionResponse struct {
+	WalletID     string `json:"walletId"`
+	FromCurrency string `json:"fromCurrency"`
+	FromAmount   int64  `json:"fromAmount"`
+	FromBalance  int64  `json:"fromBalance"`
+	ToCurrency   string `json:"toCurrency"`
+	ToAmount     int64  `json:"toAmount"`
+	ToBalance    int64  `json:"toBalance"`
+	Rate         string `json:"rate"`
+}
+
+func (h *Handler) PostConversion(w http.ResponseWriter, r *http.Request) {
+	var req conversionRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid json")
+		return
+	}
+
+	id, err := uuid.Parse(req.WalletID)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid walletId")
+		return
+	}
+	req.WalletID = id.String()
+
+	hash := requestHash(
+		r.Method,
+		r.URL.Path,
+		req.WalletID,
+		req.FromCurrency,
+		req.ToCurrency,
+		strconv.FormatInt(req.Amount, 10),
+	)
+
+	h.respond(w, r, req.RequestID, hash, func(ctx context.Context) (int, []byte, error) {
+		return h.processConversion(ctx, req)
+	})
+}
+
+func (h *Handler) processConversion(ctx context.Context, req conversionRequest) (int, []byte, error) {
+	res, err := h.service.Convert(ctx, req.WalletID, req.FromCurrency, req.ToCurrency, req.Amount)
+	if err != nil {
+		return errorResult(err)
+	}
+
+	body, err := json.Marshal(conversionResponse{
+		WalletID:     res.WalletID,
+		FromCurrency: res.FromCurrency,
+		FromAmount:   res.FromAmount,
+		FromBalance:  res.FromBalance,
+		ToCurrency:   res.ToCurrency,
+		ToAmount:     res.ToAmount,
+		ToBalance:    res.ToBalance,
+		Rate:         res.Rate,
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, body, nil
+}
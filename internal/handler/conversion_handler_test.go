@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+func TestPostConversion_Success(t *testing.T) {
+	mockService := &MockWalletService{
+		ConvertFunc: func(ctx context.Context, walletID, fromCurrency, toCurrency string, amount int64) (model.ConversionResult, error) {
+			if walletID != "550e8400-e29b-41d4-a716-446655440000" {
+				t.Errorf("expected canonical walletID, got %s", walletID)
+			}
+			return model.ConversionResult{
+				WalletID:     walletID,
+				FromCurrency: "USD",
+				ToCurrency:   "EUR",
+				FromAmount:   amount,
+				ToAmount:     270,
+				Rate:         "0.9",
+				FromBalance:  700,
+				ToBalance:    270,
+			}, nil
+		},
+	}
+
+	handler := New(mockService)
+
+	body, _ := json.Marshal(conversionRequest{
+		WalletID:     "550E8400-E29B-41D4-A716-446655440000",
+		FromCurrency: "USD",
+		ToCurrency:   "EUR",
+		Amount:       300,
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/conversions", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	handler.PostConversion(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var resp conversionResponse
+	json.NewDecoder(rec.Body).Decode(&resp)
+
+	if resp.FromAmount != 300 || resp.ToAmount != 270 || resp.Rate != "0.9" || resp.FromBalance != 700 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestPostConversion_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		err        error
+		wantStatus int
+	}{
+		{"invalid json", "{", nil, http.StatusBadRequest},
+		{"invalid wallet", `{"walletId":"nope","fromCurrency":"USD","toCurrency":"EUR","amount":1}`, nil, http.StatusBadRequest},
+		{"same currency", "", appErr.ErrSameCurrency, http.StatusBadRequest},
+		{"too small", "", appErr.ErrAmountTooSmall, http.StatusBadRequest},
+		{"insufficient funds", "", appErr.ErrInsufficientFunds, http.StatusBadRequest},
+		{"no rate", "", appErr.ErrRateNotFound, http.StatusNotFound},
+		{"no wallet", "", appErr.ErrWalletNotFound, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockWalletService{
+				ConvertFunc: func(ctx context.Context, walletID, fromCurrency, toCurrency string, amount int64) (model.ConversionResult, error) {
+					return model.ConversionResult{}, tt.err
+				},
+			}
+
+			body := tt.body
+			if body == "" {
+				body = `{"walletId":"550e8400-e29b-41d4-a716-446655440000","fromCurrency":"USD","toCurrency":"EUR","amount":1}`
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/conversions", strings.NewReader(body))
+			rec := httptest.NewRecorder()
+
+			New(mockService).PostConversion(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
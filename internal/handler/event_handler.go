@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/stream"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type snapshotBalance struct {
+	Currency         string `json:"currency"`
+	Balance          int64  `json:"balance"`
+	AvailableBalance int64  `json:"availableBalance"`
+}
+
+type snapshotEvent struct {
+	WalletID string            `json:"walletId"`
+	Sequence int64             `json:"sequence"`
+	Balances []snapshotBalance `json:"balances"`
+}
+
+type balanceEvent struct {
+	WalletID     string    `json:"walletId"`
+	Sequence     int64     `json:"sequence"`
+	Type         string    `json:"type"`
+	Currency     string    `json:"currency"`
+	Amount       int64     `json:"amount"`
+	BalanceAfter int64     `json:"balanceAfter"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// GetWalletEvents streams the wallet's balance changes as Server-Sent
+// Events, each with its sequence as the event id. A client reconnecting
+// with Last-Event-ID gets the events it missed; otherwise the stream
+// starts with a snapshot of the balances.
+func (h *Handler) GetWalletEvents(w http.ResponseWriter, r *http.Request) {
+	walletID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid walletId")
+		return
+	}
+	// Notifications carry the canonical form of the id.
+	id := walletID.String()
+
+	lastID := r.Header.Get("Last-Event-ID")
+	var after int64
+	if lastID != "" {
+		after, err = strconv.ParseInt(lastID, 10, 64)
+		if err != nil || after < 0 {
+			writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid Last-Event-ID")
+			return
+		}
+	}
+
+	sink := &eventSink{w: w, rc: http.NewResponseController(w), walletID: id}
+	watcher := stream.NewWatcher(h.service, h.events, h.keepAlive)
+
+	err = watcher.Watch(r.Context(), id, after, lastID != "", sink)
+	if err != nil && !sink.open {
+		writeError(w, r, err)
+	}
+}
+
+// eventSink writes a wallet's updates as Server-Sent Events.
+type eventSink struct {
+	w        http.ResponseWriter
+	rc       *http.ResponseController
+	walletID string
+	open     bool
+}
+
+func (s *eventSink) Open() error {
+	s.w.Header().Set("Content-Type", "text/event-stream")
+	s.w.Header().Set("Cache-Control", "no-cache")
+	s.w.Header().Set("X-Accel-Buffering", "no")
+	s.w.WriteHeader(http.StatusOK)
+	s.open = true
+	return s.rc.Flush()
+}
+
+func (s *eventSink) Send(u stream.Update) error {
+	if err := writeUpdate(s.w, s.walletID, u); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// Idle keeps proxies from closing a quiet stream.
+func (s *eventSink) Idle() error {
+	if _, err := io.WriteString(s.w, ": keep-alive\n\n"); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+func writeUpdate(w io.Writer, id string, u stream.Update) error {
+	if u.Event == nil {
+		snapshot := snapshotEvent{
+			WalletID: id,
+			Sequence: u.Sequence,
+			Balances: make([]snapshotBalance, 0, len(u.Balances)),
+		}
+		for _, wallet := range u.Balances {
+			snapshot.Balances = append(snapshot.Balances, snapshotBalance{
+				Currency:         wallet.Currency,
+				Balance:          wallet.Balance,
+				AvailableBalance: wallet.Available(),
+			})
+		}
+		return writeEvent(w, u.Sequence, "snapshot", snapshot)
+	}
+
+	e := u.Event
+	return writeEvent(w, u.Sequence, "balance", balanceEvent{
+		WalletID:     id,
+		Sequence:     e.Sequence,
+		Type:         e.Type,
+		Currency:     e.Currency,
+		Amount:       e.Amount,
+		BalanceAfter: e.BalanceAfter,
+		CreatedAt:    e.CreatedAt,
+	})
+}
+
+func writeEvent(w io.Writer, id int64, event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, event, data)
+	return err
+}
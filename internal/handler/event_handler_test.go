@@ -0,0 +1,250 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/gorilla/mux"
+)
+
+const testWalletID = "550e8400-e29b-41d4-a716-446655440000"
+
+// streamEvents serves each page in turn, then cancels the stream once the
+// pages run out.
+func streamEvents(cancel context.CancelFunc, afters *[]int64, pages ...[]model.WalletEvent) func(ctx context.Context, walletID string, after int64) ([]model.WalletEvent, error) {
+	return func(ctx context.Context, walletID string, after int64) ([]model.WalletEvent, error) {
+		*afters = append(*afters, after)
+		if len(pages) == 0 {
+			cancel()
+			return nil, nil
+		}
+		page := pages[0]
+		pages = pages[1:]
+		return page, nil
+	}
+}
+
+func eventsRequest(ctx context.Context, lastID string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/wallets/"+testWalletID+"/events", nil).WithContext(ctx)
+	req = mux.SetURLVars(req, map[string]string{"id": testWalletID})
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	return req
+}
+
+func TestGetWalletEvents_SnapshotThenEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var afters []int64
+	handler := New(&MockWalletService{
+		WalletSnapshotFunc: func(ctx context.Context, walletID string) (int64, []model.Wallet, error) {
+			return 3, []model.Wallet{{Currency: "USD", Balance: 500, Held: 100}}, nil
+		},
+		WalletEventsFunc: streamEvents(cancel, &afters,
+			[]model.WalletEvent{{Sequence: 4, Currency: "USD", Type: model.OpDeposit, Amount: 50, BalanceAfter: 550}},
+		),
+	})
+
+	rec := httptest.NewRecorder()
+	handler.GetWalletEvents(rec, eventsRequest(ctx, ""))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+
+	body := rec.Body.String()
+	snapshot := `id: 3
+event: snapshot
+data: {"walletId":"` + testWalletID + `","sequence":3,"balances":[{"currency":"USD","balance":500,"availableBalance":400}]}`
+	if !strings.HasPrefix(body, snapshot) {
+		t.Errorf("stream must start with the snapshot, got:\n%s", body)
+	}
+	if !strings.Contains(body, "id: 4\nevent: balance\n") || !strings.Contains(body, `"balanceAfter":550`) {
+		t.Errorf("missing balance event:\n%s", body)
+	}
+	if len(afters) == 0 || afters[0] != 3 {
+		t.Errorf("events must follow the snapshot sequence, got %v", afters)
+	}
+}
+
+func TestGetWalletEvents_ResumesFromLastEventID(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var afters []int64
+	handler := New(&MockWalletService{
+		WalletSnapshotFunc: func(ctx context.Context, walletID string) (int64, []model.Wallet, error) {
+			return 9, []model.Wallet{{Currency: "USD", Balance: 500}}, nil
+		},
+		WalletEventsFunc: streamEvents(cancel, &afters,
+			[]model.WalletEvent{{Sequence: 8}, {Sequence: 9}},
+		),
+	})
+
+	rec := httptest.NewRecorder()
+	handler.GetWalletEvents(rec, eventsRequest(ctx, "7"))
+
+	body := rec.Body.String()
+	if strings.Contains(body, "event: snapshot") {
+		t.Errorf("a resumed stream must not send a snapshot:\n%s", body)
+	}
+	if !strings.HasPrefix(body, "id: 8\n") || !strings.Contains(body, "id: 9\n") {
+		t.Errorf("expected the missed events 8 and 9:\n%s", body)
+	}
+	if len(afters) < 2 || afters[0] != 7 || afters[1] != 9 {
+		t.Errorf("unexpected reads %v", afters)
+	}
+}
+
+func TestGetWalletEvents_GapSendsSnapshot(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var afters []int64
+	handler := New(&MockWalletService{
+		WalletSnapshotFunc: func(ctx context.Context, walletID string) (int64, []model.Wallet, error) {
+			return 40, []model.Wallet{{Currency: "USD", Balance: 500}}, nil
+		},
+		WalletEventsFunc: streamEvents(cancel, &afters,
+			[]model.WalletEvent{{Sequence: 31}},
+		),
+	})
+
+	rec := httptest.NewRecorder()
+	handler.GetWalletEvents(rec, eventsRequest(ctx, "5"))
+
+	body := rec.Body.String()
+	if !strings.HasPrefix(body, "id: 40\nevent: snapshot\n") {
+		t.Errorf("purged events must be replaced by a snapshot:\n%s", body)
+	}
+	if strings.Contains(body, "id: 31\n") {
+		t.Errorf("events before the snapshot must not be sent:\n%s", body)
+	}
+	if len(afters) < 2 || afters[1] != 40 {
+		t.Errorf("unexpected reads %v", afters)
+	}
+}
+
+func TestGetWalletEvents_Wakes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	wake := make(chan struct{}, 1)
+	events := &mockSubscriber{ch: wake}
+
+	calls := 0
+	handler := New(&MockWalletService{
+		WalletEventsFunc: func(ctx context.Context, walletID string, after int64) ([]model.WalletEvent, error) {
+			calls++
+			switch calls {
+			case 1:
+				wake <- struct{}{}
+			case 2:
+				return []model.WalletEvent{{Sequence: 1}}, nil
+			default:
+				cancel()
+			}
+			return nil, nil
+		},
+	}, WithEventStream(events, time.Hour))
+
+	rec := httptest.NewRecorder()
+	handler.GetWalletEvents(rec, eventsRequest(ctx, ""))
+
+	if events.walletID != testWalletID || !events.cancelled {
+		t.Errorf("expected a subscription to the wallet, ended with the stream: %+v", events)
+	}
+	if !strings.Contains(rec.Body.String(), "id: 1\nevent: balance\n") {
+		t.Errorf("the wake-up must deliver the new event:\n%s", rec.Body.String())
+	}
+}
+
+func TestGetWalletEvents_CanonicalID(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := &mockSubscriber{ch: make(chan struct{})}
+	var read []string
+	handler := New(&MockWalletService{
+		WalletSnapshotFunc: func(ctx context.Context, walletID string) (int64, []model.Wallet, error) {
+			read = append(read, walletID)
+			return 0, nil, nil
+		},
+		WalletEventsFunc: func(ctx context.Context, walletID string, after int64) ([]model.WalletEvent, error) {
+			read = append(read, walletID)
+			cancel()
+			return nil, nil
+		},
+	}, WithEventStream(events, time.Hour))
+
+	upper := strings.ToUpper(testWalletID)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/wallets/"+upper+"/events", nil).WithContext(ctx)
+	req = mux.SetURLVars(req, map[string]string{"id": upper})
+
+	rec := httptest.NewRecorder()
+	handler.GetWalletEvents(rec, req)
+
+	if events.walletID != testWalletID {
+		t.Errorf("expected a subscription under the canonical id, got %q", events.walletID)
+	}
+	for _, id := range read {
+		if id != testWalletID {
+			t.Errorf("expected reads under the canonical id, got %q", id)
+		}
+	}
+	if !strings.Contains(rec.Body.String(), `"walletId":"`+testWalletID+`"`) {
+		t.Errorf("expected the canonical id in the stream:\n%s", rec.Body.String())
+	}
+}
+
+type mockSubscriber struct {
+	ch        chan struct{}
+	walletID  string
+	cancelled bool
+}
+
+func (m *mockSubscriber) Subscribe(walletID string) (<-chan struct{}, func()) {
+	m.walletID = walletID
+	return m.ch, func() { m.cancelled = true }
+}
+
+func TestGetWalletEvents_InvalidLastEventID(t *testing.T) {
+	handler := New(&MockWalletService{})
+
+	rec := httptest.NewRecorder()
+	handler.GetWalletEvents(rec, eventsRequest(context.Background(), "abc"))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rec.Code)
+	}
+}
+
+func TestGetWalletEvents_WalletNotFound(t *testing.T) {
+	handler := New(&MockWalletService{
+		WalletSnapshotFunc: func(ctx context.Context, walletID string) (int64, []model.Wallet, error) {
+			return 0, nil, appErr.ErrWalletNotFound
+		},
+	})
+
+	rec := httptest.NewRecorder()
+	handler.GetWalletEvents(rec, eventsRequest(context.Background(), ""))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+	if p := decodeProblem(t, rec); p.Code != appErr.CodeWalletNotFound {
+		t.Errorf("expected code %s, got %s", appErr.CodeWalletNotFound, p.Code)
+	}
+}
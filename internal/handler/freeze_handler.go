@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// maxFreezeReason bounds the note an operator leaves on a freeze.
+const maxFreezeReason = 1000
+
+type freezeRequest struct {
+	Reason string `json:"reason"`
+}
+
+type freezeResponse struct {
+	WalletID string     `json:"walletId"`
+	Frozen   bool       `json:"frozen"`
+	Reason   string     `json:"reason,omitempty"`
+	FrozenAt *time.Time `json:"frozenAt,omitempty"`
+}
+
+func newFreezeResponse(walletID string, f *model.WalletFreeze) freezeResponse {
+	if f == nil {
+		return freezeResponse{WalletID: walletID}
+	}
+	return freezeResponse{WalletID: walletID, Frozen: true, Reason: f.Reason, FrozenAt: &f.FrozenAt}
+}
+
+// PostFreeze freezes the wallet. The body, with the reason, is optional.
+func (h *Handler) PostFreeze(w http.ResponseWriter, r *http.Request) {
+	id, ok := freezeWalletID(w, r)
+	if !ok {
+		return
+	}
+
+	var req freezeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid json")
+		return
+	}
+	if len(req.Reason) > maxFreezeReason {
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "reason too long")
+		return
+	}
+
+	freeze, err := h.service.FreezeWallet(r.Context(), id, req.Reason)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newFreezeResponse(id, &freeze))
+}
+
+func (h *Handler) DeleteFreeze(w http.ResponseWriter, r *http.Request) {
+	id, ok := freezeWalletID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.UnfreezeWallet(r.Context(), id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) GetFreeze(w http.ResponseWriter, r *http.Request) {
+	id, ok := freezeWalletID(w, r)
+	if !ok {
+		return
+	}
+
+	freeze, err := h.service.WalletFreeze(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newFreezeResponse(id, freeze))
+}
+
+func freezeWalletID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := mux.Vars(r)["id"]
+	if _, err := uuid.Parse(id); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid walletId")
+		return "", false
+	}
+	return id, true
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+func TestPostFreeze(t *testing.T) {
+	frozenAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	mockService := &MockWalletService{
+		FreezeWalletFunc: func(ctx context.Context, walletID, reason string) (model.WalletFreeze, error) {
+			if reason != "chargeback" {
+				t.Errorf("unexpected reason %q", reason)
+			}
+			return model.WalletFreeze{WalletID: uuid.MustParse(walletID), Reason: reason, FrozenAt: frozenAt}, nil
+		},
+	}
+
+	handler := New(mockService)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/wallets/"+testWalletID+"/freeze", bytes.NewReader([]byte(`{"reason":"chargeback"}`)))
+	req = mux.SetURLVars(req, map[string]string{"id": testWalletID})
+	rec := httptest.NewRecorder()
+
+	handler.PostFreeze(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var resp freezeResponse
+	json.NewDecoder(rec.Body).Decode(&resp)
+
+	if !resp.Frozen || resp.WalletID != testWalletID || resp.Reason != "chargeback" || resp.FrozenAt == nil || !resp.FrozenAt.Equal(frozenAt) {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestPostFreeze_EmptyBody(t *testing.T) {
+	called := false
+	handler := New(&MockWalletService{
+		FreezeWalletFunc: func(ctx context.Context, walletID, reason string) (model.WalletFreeze, error) {
+			called = true
+			return model.WalletFreeze{WalletID: uuid.MustParse(walletID)}, nil
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/wallets/"+testWalletID+"/freeze", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": testWalletID})
+	rec := httptest.NewRecorder()
+
+	handler.PostFreeze(rec, req)
+
+	if rec.Code != http.StatusOK || !called {
+		t.Errorf("expected the wallet frozen with status 200, got %d", rec.Code)
+	}
+}
+
+func TestPostFreeze_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		body string
+	}{
+		{"wallet id", "nope", `{}`},
+		{"json", testWalletID, `{`},
+		{"reason too long", testWalletID, `{"reason":"` + strings.Repeat("x", maxFreezeReason+1) + `"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := New(&MockWalletService{
+				FreezeWalletFunc: func(ctx context.Context, walletID, reason string) (model.WalletFreeze, error) {
+					t.Error("service must not be called")
+					return model.WalletFreeze{}, nil
+				},
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/wallets/"+tt.id+"/freeze", strings.NewReader(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+			rec := httptest.NewRecorder()
+
+			handler.PostFreeze(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d", rec.Code)
+			}
+		})
+	}
+}
+
+func TestGetFreeze_NotFrozen(t *testing.T) {
+	handler := New(&MockWalletService{})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/wallets/"+testWalletID+"/freeze", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": testWalletID})
+	rec := httptest.NewRecorder()
+
+	handler.GetFreeze(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if body := strings.TrimSpace(rec.Body.String()); body != `{"walletId":"`+testWalletID+`","frozen":false}` {
+		t.Errorf("unexpected body: %s", body)
+	}
+}
+
+func TestDeleteFreeze_UnknownWallet(t *testing.T) {
+	handler := New(&MockWalletService{
+		UnfreezeWalletFunc: func(ctx context.Context, walletID string) error {
+			return appErr.ErrWalletNotFound
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/admin/wallets/"+testWalletID+"/freeze", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": testWalletID})
+	rec := httptest.NewRecorder()
+
+	handler.DeleteFreeze(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+}
+
+func TestPostWallet_Frozen(t *testing.T) {
+	handler := New(&MockWalletService{
+		ProcessFunc: func(ctx context.Context, walletID, currency, op string, amount, ifVersion int64) error {
+			return appErr.ErrWalletFrozen.WithDetails(map[string]any{"walletId": walletID})
+		},
+	})
+
+	body := `{"walletId":"` + testWalletID + `","operationType":"WITHDRAW","amount":100}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/wallet", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	handler.PostWallet(rec, req)
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected status 409, got %d", rec.Code)
+	}
+
+	var p problemResponse
+	json.NewDecoder(rec.Body).Decode(&p)
+	if p.Code != appErr.CodeWalletFrozen {
+		t.Errorf("expected code %s, got %+v", appErr.CodeWalletFrozen, p)
+	}
+}
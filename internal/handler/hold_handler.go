@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type holdRequest struct {
+	WalletID   string `json:"walletId"`
+	Currency   string `json:"currency,omitempty"`
+	Amount     int64  `json:"amount"`
+	TTLSeconds int64  `json:"ttlSeconds,omitempty"`
+	RequestID  string `json:"requestId,omitempty"`
+}
+
+type captureRequest struct {
+	Amount    int64  `json:"amount,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+type holdResponse struct {
+	HoldID    string    `json:"holdId"`
+	WalletID  string    `json:"walletId"`
+	Currency  string    `json:"currency"`
+	Amount    int64     `json:"amount"`
+	Captured  int64     `json:"captured"`
+	Status    string    `json:"status"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (h *Handler) PostHold(w http.ResponseWriter, r *http.Request) {
+	var req holdRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid json")
+		return
+	}
+
+	if _, err := uuid.Parse(req.WalletID); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid walletId")
+		return
+	}
+
+	hash := requestHash(
+		r.Method,
+		r.URL.Path,
+		req.WalletID,
+		req.Currency,
+		strconv.FormatInt(req.Amount, 10),
+		strconv.FormatInt(req.TTLSeconds, 10),
+	)
+
+	h.respond(w, r, req.RequestID, hash, func(ctx context.Context) (int, []byte, error) {
+		hold, err := h.service.PlaceHold(ctx, req.WalletID, req.Currency, req.Amount, time.Duration(req.TTLSeconds)*time.Second)
+		return holdResult(http.StatusCreated, hold, err)
+	})
+}
+
+func (h *Handler) CaptureHold(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if _, err := uuid.Parse(id); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid holdId")
+		return
+	}
+
+	var req captureRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid json")
+			return
+		}
+	}
+
+	hash := requestHash(r.Method, r.URL.Path, strconv.FormatInt(req.Amount, 10))
+
+	h.respond(w, r, req.RequestID, hash, func(ctx context.Context) (int, []byte, error) {
+		hold, err := h.service.CaptureHold(ctx, id, req.Amount)
+		return holdResult(http.StatusOK, hold, err)
+	})
+}
+
+func (h *Handler) ReleaseHold(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if _, err := uuid.Parse(id); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid holdId")
+		return
+	}
+
+	h.respond(w, r, "", requestHash(r.Method, r.URL.Path), func(ctx context.Context) (int, []byte, error) {
+		hold, err := h.service.ReleaseHold(ctx, id)
+		return holdResult(http.StatusOK, hold, err)
+	})
+}
+
+func (h *Handler) GetHold(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if _, err := uuid.Parse(id); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid holdId")
+		return
+	}
+
+	hold, err := h.service.Hold(r.Context(), id)
+	status, body, err := holdResult(http.StatusOK, hold, err)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeResult(w, r, status, body)
+}
+
+func holdResult(status int, hold model.Hold, err error) (int, []byte, error) {
+	if err != nil {
+		return errorResult(err)
+	}
+
+	body, err := json.Marshal(holdResponse{
+		HoldID:    hold.ID.String(),
+		WalletID:  hold.WalletID.String(),
+		Currency:  hold.Currency,
+		Amount:    hold.Amount,
+		Captured:  hold.Captured,
+		Status:    hold.Status,
+		ExpiresAt: hold.ExpiresAt,
+		CreatedAt: hold.CreatedAt,
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return status, body, nil
+}
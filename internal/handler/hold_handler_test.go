@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const testHoldID = "9b2f6a3c-1d4e-4f5a-8b6c-7d8e9f0a1b2c"
+
+func TestPostHold_Success(t *testing.T) {
+	mockService := &MockWalletService{
+		PlaceHoldFunc: func(ctx context.Context, walletID, currency string, amount int64, ttl time.Duration) (model.Hold, error) {
+			if ttl != 60*time.Second {
+				t.Errorf("expected ttl 60s, got %s", ttl)
+			}
+			return model.Hold{
+				ID:       uuid.MustParse(testHoldID),
+				WalletID: uuid.MustParse(walletID),
+				Amount:   amount,
+				Status:   model.HoldActive,
+			}, nil
+		},
+	}
+
+	handler := New(mockService)
+
+	body, _ := json.Marshal(holdRequest{
+		WalletID:   "550e8400-e29b-41d4-a716-446655440000",
+		Amount:     500,
+		TTLSeconds: 60,
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/holds", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	handler.PostHold(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", rec.Code)
+	}
+
+	var resp holdResponse
+	json.NewDecoder(rec.Body).Decode(&resp)
+
+	if resp.HoldID != testHoldID || resp.Amount != 500 || resp.Status != model.HoldActive {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestPostHold_InvalidWalletID(t *testing.T) {
+	handler := New(&MockWalletService{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/holds", bytes.NewReader([]byte(`{"walletId":"nope","amount":1}`)))
+	rec := httptest.NewRecorder()
+
+	handler.PostHold(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rec.Code)
+	}
+}
+
+func TestCaptureHold_EmptyBodyCapturesAll(t *testing.T) {
+	mockService := &MockWalletService{
+		CaptureHoldFunc: func(ctx context.Context, holdID string, amount int64) (model.Hold, error) {
+			if amount != 0 {
+				t.Errorf("expected amount 0, got %d", amount)
+			}
+			return model.Hold{ID: uuid.MustParse(holdID), Amount: 500, Captured: 500, Status: model.HoldCaptured}, nil
+		},
+	}
+
+	handler := New(mockService)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/holds/"+testHoldID+"/capture", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": testHoldID})
+	rec := httptest.NewRecorder()
+
+	handler.CaptureHold(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var resp holdResponse
+	json.NewDecoder(rec.Body).Decode(&resp)
+
+	if resp.Captured != 500 || resp.Status != model.HoldCaptured {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestCaptureHold_ServiceErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"not active", appErr.ErrHoldNotActive, http.StatusConflict},
+		{"not found", appErr.ErrHoldNotFound, http.StatusNotFound},
+		{"exceeds hold", appErr.ErrCaptureExceedsHold, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := New(&MockWalletService{
+				CaptureHoldFunc: func(ctx context.Context, holdID string, amount int64) (model.Hold, error) {
+					return model.Hold{}, tt.err
+				},
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/holds/"+testHoldID+"/capture", bytes.NewReader([]byte(`{"amount":100}`)))
+			req = mux.SetURLVars(req, map[string]string{"id": testHoldID})
+			rec := httptest.NewRecorder()
+
+			handler.CaptureHold(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestReleaseHold_InvalidID(t *testing.T) {
+	handler := New(&MockWalletService{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/holds/nope/release", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "nope"})
+	rec := httptest.NewRecorder()
+
+	handler.ReleaseHold(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rec.Code)
+	}
+}
+
+func TestGetHold_NotFound(t *testing.T) {
+	handler := New(&MockWalletService{
+		HoldFunc: func(ctx context.Context, holdID string) (model.Hold, error) {
+			return model.Hold{}, appErr.ErrHoldNotFound
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/holds/"+testHoldID, nil)
+	req = mux.SetURLVars(req, map[string]string{"id": testHoldID})
+	rec := httptest.NewRecorder()
+
+	handler.GetHold(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+)
+
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+type resultFunc func(ctx context.Context) (statusCode int, body []byte, err error)
+
+// respond executes fn and writes its result. When the request carries an
+// idempotency key (the header wins over the body field) the execution goes
+// through the service so that retries replay the first response.
+func (h *Handler) respond(w http.ResponseWriter, r *http.Request, bodyKey, requestHash string, fn resultFunc) {
+	key := r.Header.Get(idempotencyKeyHeader)
+	if key == "" {
+		key = bodyKey
+	}
+
+	if key == "" {
+		status, body, err := fn(r.Context())
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeResult(w, r, status, body)
+		return
+	}
+
+	if len(key) > maxIdempotencyKeyLength {
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid idempotency key")
+		return
+	}
+
+	status, body, replayed, err := h.service.Idempotent(r.Context(), key, requestHash, fn)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if replayed {
+		w.Header().Set(idempotentReplayedHeader, "true")
+	}
+	writeResult(w, r, status, body)
+}
+
+// writeResult writes a result produced by a resultFunc. Error bodies are
+// problem documents and get the current request's id.
+func writeResult(w http.ResponseWriter, r *http.Request, status int, body []byte) {
+	if status >= http.StatusBadRequest {
+		sendProblem(w, r, storedProblem(status, body))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func requestHash(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
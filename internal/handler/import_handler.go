@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type importResponse struct {
+	ImportID  string    `json:"importId"`
+	Format    string    `json:"format"`
+	Status    string    `json:"status"`
+	LastLine  int       `json:"lastLine"`
+	Succeeded int       `json:"succeeded"`
+	Failed    int       `json:"failed"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// PostImport queues the request body as an import file. The format comes
+// from the format query parameter or, failing that, the Content-Type.
+func (h *Handler) PostImport(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = importFormat(r.Header.Get("Content-Type"))
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxImportBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeProblem(w, r, http.StatusRequestEntityTooLarge, appErr.CodeImportTooLarge, "import file too large")
+		return
+	}
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid body")
+		return
+	}
+
+	hash := requestHash(r.Method, r.URL.Path, format, string(data))
+
+	h.respond(w, r, "", hash, func(ctx context.Context) (int, []byte, error) {
+		job, err := h.service.CreateImport(ctx, format, data)
+		return importResult(http.StatusAccepted, job, err)
+	})
+}
+
+func (h *Handler) GetImport(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if _, err := uuid.Parse(id); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid importId")
+		return
+	}
+
+	job, err := h.service.Import(r.Context(), id)
+	status, body, err := importResult(http.StatusOK, job, err)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeResult(w, r, status, body)
+}
+
+// GetImportErrors serves the failed lines of an import as a CSV file.
+func (h *Handler) GetImportErrors(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if _, err := uuid.Parse(id); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid importId")
+		return
+	}
+
+	lineErrs, err := h.service.ImportErrors(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="import-`+id+`-errors.csv"`)
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"line", "code", "detail"})
+	for _, e := range lineErrs {
+		cw.Write([]string{strconv.Itoa(e.Line), e.Code, e.Detail})
+	}
+	cw.Flush()
+}
+
+func importFormat(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv":
+		return model.ImportCSV
+	case "application/jsonl", "application/x-ndjson", "application/x-jsonlines":
+		return model.ImportJSONL
+	default:
+		return ""
+	}
+}
+
+func importResult(status int, job model.ImportJob, err error) (int, []byte, error) {
+	if err != nil {
+		return errorResult(err)
+	}
+
+	body, err := json.Marshal(importResponse{
+		ImportID:  job.ID.String(),
+		Format:    job.Format,
+		Status:    job.Status,
+		LastLine:  job.LastLine,
+		Succeeded: job.Succeeded,
+		Failed:    job.Failed,
+		Error:     job.Error,
+		CreatedAt: job.CreatedAt,
+		UpdatedAt: job.UpdatedAt,
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return status, body, nil
+}
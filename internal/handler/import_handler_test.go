@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const testImportID = "3f1c2b7a-8e4d-4c5b-9a6f-0e1d2c3b4a59"
+
+func TestPostImport_FormatFromContentType(t *testing.T) {
+	var gotFormat, gotData string
+	handler := New(&MockWalletService{
+		CreateImportFunc: func(ctx context.Context, format string, data []byte) (model.ImportJob, error) {
+			gotFormat, gotData = format, string(data)
+			return model.ImportJob{ID: uuid.MustParse(testImportID), Format: format, Status: model.ImportPending}, nil
+		},
+	})
+
+	body := `{"walletId":"550e8400-e29b-41d4-a716-446655440000","operationType":"DEPOSIT","amount":1}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/imports", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-ndjson; charset=utf-8")
+	rec := httptest.NewRecorder()
+
+	handler.PostImport(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if gotFormat != model.ImportJSONL || gotData != body {
+		t.Errorf("unexpected import: %q %q", gotFormat, gotData)
+	}
+
+	var resp importResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.ImportID != testImportID || resp.Status != model.ImportPending {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestPostImport_TooLarge(t *testing.T) {
+	handler := New(&MockWalletService{
+		CreateImportFunc: func(ctx context.Context, format string, data []byte) (model.ImportJob, error) {
+			t.Fatal("an oversized file must not be stored")
+			return model.ImportJob{}, nil
+		},
+	}, WithImportLimit(8))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/imports?format=csv", strings.NewReader("walletId,operationType,amount\n"))
+	rec := httptest.NewRecorder()
+
+	handler.PostImport(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status 413, got %d", rec.Code)
+	}
+	if p := decodeProblem(t, rec); p.Code != appErr.CodeImportTooLarge {
+		t.Errorf("expected code %s, got %s", appErr.CodeImportTooLarge, p.Code)
+	}
+}
+
+func TestGetImportErrors_CSVReport(t *testing.T) {
+	handler := New(&MockWalletService{
+		ImportErrorsFunc: func(ctx context.Context, id string) ([]model.ImportLineError, error) {
+			return []model.ImportLineError{
+				{Line: 3, Code: appErr.CodeInsufficientFunds, Detail: "insufficient funds"},
+				{Line: 7, Code: appErr.CodeValidationFailed, Detail: "invalid walletId"},
+			}, nil
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/imports/"+testImportID+"/errors", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": testImportID})
+	rec := httptest.NewRecorder()
+
+	handler.GetImportErrors(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/csv" {
+		t.Errorf("unexpected Content-Type %q", ct)
+	}
+
+	want := "line,code,detail\n3,INSUFFICIENT_FUNDS,insufficient funds\n7,VALIDATION_FAILED,invalid walletId\n"
+	if rec.Body.String() != want {
+		t.Errorf("unexpected report:\n%s", rec.Body.String())
+	}
+}
+
+func TestGetImport_NotFound(t *testing.T) {
+	handler := New(&MockWalletService{
+		ImportFunc: func(ctx context.Context, id string) (model.ImportJob, error) {
+			return model.ImportJob{}, appErr.ErrImportNotFound
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/imports/"+testImportID, nil)
+	req = mux.SetURLVars(req, map[string]string{"id": testImportID})
+	rec := httptest.NewRecorder()
+
+	handler.GetImport(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+type accountBalanceResponse struct {
+	Account  string `json:"account"`
+	Currency string `json:"currency"`
+	Debit    int64  `json:"debit"`
+	Credit   int64  `json:"credit"`
+	Balance  int64  `json:"balance"`
+}
+
+type currencyTotalResponse struct {
+	Currency    string `json:"currency"`
+	TotalDebit  int64  `json:"totalDebit"`
+	TotalCredit int64  `json:"totalCredit"`
+}
+
+// Totals are per currency: amounts in different currencies do not add up.
+type trialBalanceResponse struct {
+	Accounts []accountBalanceResponse `json:"accounts"`
+	Totals   []currencyTotalResponse  `json:"totals"`
+}
+
+func (h *Handler) GetTrialBalance(w http.ResponseWriter, r *http.Request) {
+	balances, err := h.service.TrialBalance(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	resp := trialBalanceResponse{
+		Accounts: make([]accountBalanceResponse, 0, len(balances)),
+		Totals:   []currencyTotalResponse{},
+	}
+	totals := make(map[string]int)
+	for _, b := range balances {
+		resp.Accounts = append(resp.Accounts, accountBalanceResponse{
+			Account:  b.Account,
+			Currency: b.Currency,
+			Debit:    b.Debit,
+			Credit:   b.Credit,
+			Balance:  b.Balance(),
+		})
+
+		i, ok := totals[b.Currency]
+		if !ok {
+			i = len(resp.Totals)
+			totals[b.Currency] = i
+			resp.Totals = append(resp.Totals, currencyTotalResponse{Currency: b.Currency})
+		}
+		resp.Totals[i].TotalDebit += b.Debit
+		resp.Totals[i].TotalCredit += b.Credit
+	}
+	sort.Slice(resp.Totals, func(i, j int) bool {
+		return resp.Totals[i].Currency < resp.Totals[j].Currency
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+func TestGetTrialBalance_Success(t *testing.T) {
+	mockService := &MockWalletService{
+		TrialBalanceFunc: func(ctx context.Context) ([]model.AccountBalance, error) {
+			return []model.AccountBalance{
+				{Account: "system:cash_in", Currency: "USD", Debit: 1000},
+				{Account: "system:cash_in", Currency: "EUR", Debit: 50},
+				{Account: "system:cash_out", Currency: "USD", Credit: 300},
+				{Account: model.AccountWallets, Currency: "EUR", Credit: 50},
+				{Account: model.AccountWallets, Currency: "USD", Debit: 300, Credit: 1000},
+			}, nil
+		},
+	}
+
+	handler := New(mockService)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/ledger/trial-balance", nil)
+	rec := httptest.NewRecorder()
+
+	handler.GetTrialBalance(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var resp trialBalanceResponse
+	json.NewDecoder(rec.Body).Decode(&resp)
+
+	if len(resp.Accounts) != 5 {
+		t.Fatalf("expected 5 accounts, got %d", len(resp.Accounts))
+	}
+	if resp.Accounts[4].Balance != -700 || resp.Accounts[4].Currency != "USD" {
+		t.Errorf("expected USD wallet balance -700, got %+v", resp.Accounts[4])
+	}
+
+	want := []currencyTotalResponse{
+		{Currency: "EUR", TotalDebit: 50, TotalCredit: 50},
+		{Currency: "USD", TotalDebit: 1300, TotalCredit: 1300},
+	}
+	if len(resp.Totals) != len(want) {
+		t.Fatalf("expected %d totals, got %+v", len(want), resp.Totals)
+	}
+	for i := range want {
+		if resp.Totals[i] != want[i] {
+			t.Errorf("expected totals %+v, got %+v", want[i], resp.Totals[i])
+		}
+	}
+}
+
+func TestGetTrialBalance_Error(t *testing.T) {
+	mockService := &MockWalletService{
+		TrialBalanceFunc: func(ctx context.Context) ([]model.AccountBalance, error) {
+			return nil, errors.New("db down")
+		},
+	}
+
+	handler := New(mockService)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/ledger/trial-balance", nil)
+	rec := httptest.NewRecorder()
+
+	handler.GetTrialBalance(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", rec.Code)
+	}
+}
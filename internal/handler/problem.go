@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+)
+
+const problemContentType = "application/problem+json"
+
+// problemResponse is an RFC 7807 problem document. Code is the stable
+// identifier clients should match on; Detail is for humans.
+type problemResponse struct {
+	Type      string         `json:"type"`
+	Title     string         `json:"title"`
+	Status    int            `json:"status"`
+	Code      string         `json:"code"`
+	Detail    string         `json:"detail,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+	Instance  string         `json:"instance,omitempty"`
+	RequestID string         `json:"requestId,omitempty"`
+}
+
+func newProblem(status int, code, detail string) problemResponse {
+	return problemResponse{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Code:   code,
+		Detail: detail,
+	}
+}
+
+// writeProblem answers with a problem for errors detected by the handler
+// itself, such as malformed input.
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	sendProblem(w, r, newProblem(status, code, detail))
+}
+
+// domainProblem describes the domain error in err's chain. Only the
+// domain error's own message is exposed, never the context it was wrapped
+// in or its cause.
+func domainProblem(err error) (problemResponse, bool) {
+	e, ok := appErr.As(err)
+	if !ok {
+		return problemResponse{}, false
+	}
+
+	p := newProblem(e.Status, e.Code, e.Message)
+	p.Details = e.Details
+	return p, true
+}
+
+// writeError answers with the problem for err, or a bare internal error
+// when err is not a domain error.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	p, ok := domainProblem(err)
+	if !ok {
+		p = newProblem(http.StatusInternalServerError, appErr.CodeInternal, "internal error")
+	}
+	sendProblem(w, r, p)
+}
+
+// errorResult turns a domain error into a result for respond. Other errors
+// are passed through and end up as internal errors. So is contention:
+// it is transient, and an idempotency key must not keep it as its
+// response, or retries with the key would never run the request again.
+func errorResult(err error) (int, []byte, error) {
+	p, ok := domainProblem(err)
+	if !ok || errors.Is(err, appErr.ErrConcurrentUpdate) {
+		return 0, nil, err
+	}
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		return 0, nil, err
+	}
+	return p.Status, body, nil
+}
+
+// storedProblem decodes a problem produced by errorResult. Idempotent
+// responses stored before problem documents were introduced hold the bare
+// error message instead.
+func storedProblem(status int, body []byte) problemResponse {
+	var p problemResponse
+	if err := json.Unmarshal(body, &p); err == nil && p.Code != "" {
+		return p
+	}
+
+	msg := string(bytes.TrimSpace(body))
+	if e, ok := appErr.FromMessage(msg); ok {
+		return newProblem(status, e.Code, msg)
+	}
+	return newProblem(status, appErr.CodeValidationFailed, msg)
+}
+
+func sendProblem(w http.ResponseWriter, r *http.Request, p problemResponse) {
+	p.Instance = r.URL.Path
+	p.RequestID = requestIDFrom(r.Context())
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+)
+
+func decodeProblem(t *testing.T, rec *httptest.ResponseRecorder) problemResponse {
+	t.Helper()
+
+	if ct := rec.Header().Get("Content-Type"); ct != problemContentType {
+		t.Errorf("expected content type %s, got %s", problemContentType, ct)
+	}
+
+	var p problemResponse
+	if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+		t.Fatalf("invalid problem body: %v", err)
+	}
+	return p
+}
+
+func TestWriteError_Internal(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/ledger/trial-balance", nil)
+	rec := httptest.NewRecorder()
+
+	writeError(rec, req, appErr.ErrUnbalancedJournal)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", rec.Code)
+	}
+
+	p := decodeProblem(t, rec)
+	if p.Code != appErr.CodeInternal || p.Detail != "internal error" {
+		t.Errorf("internal details must not leak: %+v", p)
+	}
+}
+
+func TestWriteResult_ReplayedProblem(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     []byte
+		wantCode string
+	}{
+		{"problem document", mustErrorBody(t, appErr.ErrInsufficientFunds), appErr.CodeInsufficientFunds},
+		{"legacy message", []byte("insufficient funds\n"), appErr.CodeInsufficientFunds},
+		{"legacy unknown message", []byte("invalid json"), appErr.CodeValidationFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/wallet", nil)
+			req = req.WithContext(context.WithValue(req.Context(), requestIDKey{}, "replay-1"))
+			rec := httptest.NewRecorder()
+
+			writeResult(rec, req, http.StatusBadRequest, tt.body)
+
+			p := decodeProblem(t, rec)
+			if p.Code != tt.wantCode || p.Status != http.StatusBadRequest {
+				t.Errorf("unexpected problem: %+v", p)
+			}
+			if p.RequestID != "replay-1" {
+				t.Errorf("expected the replaying request id, got %q", p.RequestID)
+			}
+		})
+	}
+}
+
+func mustErrorBody(t *testing.T, err error) []byte {
+	t.Helper()
+
+	_, body, err := errorResult(err)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return body
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{"from client", "abc-123", true},
+		{"generated", "", false},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+		{"control characters", "abc\x01", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = requestIDFrom(r.Context())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("X-Request-ID", tt.header)
+			}
+			rec := httptest.NewRecorder()
+
+			RequestID(next).ServeHTTP(rec, req)
+
+			if seen == "" || rec.Header().Get("X-Request-ID") != seen {
+				t.Errorf("expected the request id %q in the response, got %q", seen, rec.Header().Get("X-Request-ID"))
+			}
+			if (seen == tt.header) != tt.keep {
+				t.Errorf("unexpected request id %q", seen)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+type rateRequest struct {
+	Base          string      `json:"base"`
+	Quote         string      `json:"quote"`
+	Rate          json.Number `json:"rate"`
+	EffectiveFrom *time.Time  `json:"effectiveFrom,omitempty"`
+}
+
+type ratesRequest struct {
+	Rates []rateRequest `json:"rates"`
+}
+
+type rateResponse struct {
+	Base          string    `json:"base"`
+	Quote         string    `json:"quote"`
+	Rate          string    `json:"rate"`
+	EffectiveFrom time.Time `json:"effectiveFrom"`
+}
+
+type ratesResponse struct {
+	Rates []rateResponse `json:"rates"`
+}
+
+// AdminOnly lets through requests carrying "Authorization: Bearer <token>".
+// With an empty token the wrapped routes are disabled altogether.
+func AdminOnly(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			writeProblem(w, r, http.StatusForbidden, appErr.CodeForbidden, "admin api disabled")
+			return
+		}
+
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			writeProblem(w, r, http.StatusUnauthorized, appErr.CodeUnauthorized, "unauthorized")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *Handler) PostRates(w http.ResponseWriter, r *http.Request) {
+	var req ratesRequest
+
+	dec := json.NewDecoder(r.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid json")
+		return
+	}
+
+	if len(req.Rates) == 0 {
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "no rates")
+		return
+	}
+
+	rates := make([]model.ExchangeRate, 0, len(req.Rates))
+	for _, rr := range req.Rates {
+		rate := model.ExchangeRate{
+			Base:  rr.Base,
+			Quote: rr.Quote,
+			Rate:  rr.Rate.String(),
+		}
+		if rr.EffectiveFrom != nil {
+			rate.EffectiveFrom = *rr.EffectiveFrom
+		}
+		rates = append(rates, rate)
+	}
+
+	if err := h.service.UpdateRates(r.Context(), rates); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) GetRates(w http.ResponseWriter, r *http.Request) {
+	rates, err := h.service.Rates(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	resp := ratesResponse{Rates: make([]rateResponse, 0, len(rates))}
+	for _, rate := range rates {
+		resp.Rates = append(resp.Rates, rateResponse{
+			Base:          rate.Base,
+			Quote:         rate.Quote,
+			Rate:          rate.Rate,
+			EffectiveFrom: rate.EffectiveFrom,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
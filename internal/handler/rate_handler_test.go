@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+func TestAdminOnly(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		header     string
+		wantStatus int
+	}{
+		{"disabled", "", "Bearer ", http.StatusForbidden},
+		{"missing header", "secret", "", http.StatusUnauthorized},
+		{"wrong token", "secret", "Bearer nope", http.StatusUnauthorized},
+		{"not bearer", "secret", "secret", http.StatusUnauthorized},
+		{"ok", "secret", "Bearer secret", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/rates", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+
+			AdminOnly(tt.token, next).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestPostRates_Success(t *testing.T) {
+	var got []model.ExchangeRate
+	mockService := &MockWalletService{
+		UpdateRatesFunc: func(ctx context.Context, rates []model.ExchangeRate) error {
+			got = rates
+			return nil
+		},
+	}
+
+	body := `{"rates":[
+		{"base":"USD","quote":"EUR","rate":0.90,"effectiveFrom":"2026-01-01T00:00:00Z"},
+		{"base":"EUR","quote":"USD","rate":"1.1"}
+	]}`
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/rates", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	New(mockService).PostRates(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", rec.Code)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("expected 2 rates, got %d", len(got))
+	}
+	// The number is passed through as written, never through a float.
+	if got[0].Rate != "0.90" || !got[0].EffectiveFrom.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected first rate: %+v", got[0])
+	}
+	if got[1].Rate != "1.1" || !got[1].EffectiveFrom.IsZero() {
+		t.Errorf("unexpected second rate: %+v", got[1])
+	}
+}
+
+func TestPostRates_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		err        error
+		wantStatus int
+	}{
+		{"invalid json", "{", nil, http.StatusBadRequest},
+		{"no rates", `{"rates":[]}`, nil, http.StatusBadRequest},
+		{"invalid rate", `{"rates":[{"base":"USD","quote":"EUR","rate":-1}]}`, appErr.ErrInvalidRate, http.StatusBadRequest},
+		{"invalid currency", `{"rates":[{"base":"USD","quote":"XXX","rate":1}]}`, appErr.ErrInvalidCurrency, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockWalletService{
+				UpdateRatesFunc: func(ctx context.Context, rates []model.ExchangeRate) error {
+					return tt.err
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/rates", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+
+			New(mockService).PostRates(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestGetRates(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockService := &MockWalletService{
+		RatesFunc: func(ctx context.Context) ([]model.ExchangeRate, error) {
+			return []model.ExchangeRate{{Base: "USD", Quote: "EUR", Rate: "0.9", EffectiveFrom: from}}, nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/rates", nil)
+	rec := httptest.NewRecorder()
+
+	New(mockService).GetRates(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var resp ratesResponse
+	json.NewDecoder(rec.Body).Decode(&resp)
+
+	if len(resp.Rates) != 1 || resp.Rates[0].Rate != "0.9" || !resp.Rates[0].EffectiveFrom.Equal(from) {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+
+	"github.com/google/uuid"
+)
+
+type transferRequest struct {
+	FromWalletID string `json:"fromWalletId"`
+	ToWalletID   string `json:"toWalletId"`
+	Currency     string `json:"currency,omitempty"`
+	Amount       int64  `json:"amount"`
+	RequestID    string `json:"requestId,omitempty"`
+}
+
+type transferResponse struct {
+	FromWalletID string `json:"fromWalletId"`
+	FromBalance  int64  `json:"fromBalance"`
+	ToWalletID   string `json:"toWalletId"`
+	ToBalance    int64  `json:"toBalance"`
+	Currency     string `json:"currency"`
+}
+
+func (h *Handler) PostTransfer(w http.ResponseWriter, r *http.Request) {
+	var req transferRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid json")
+		return
+	}
+
+	from, err := uuid.Parse(req.FromWalletID)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid fromWalletId")
+		return
+	}
+
+	to, err := uuid.Parse(req.ToWalletID)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid toWalletId")
+		return
+	}
+
+	// Canonical form keeps lock ordering and request hashing stable
+	// regardless of how the client spelled the ids.
+	req.FromWalletID = from.String()
+	req.ToWalletID = to.String()
+
+	hash := requestHash(
+		r.Method,
+		r.URL.Path,
+		req.FromWalletID,
+		req.ToWalletID,
+		req.Currency,
+		strconv.FormatInt(req.Amount, 10),
+	)
+
+	h.respond(w, r, req.RequestID, hash, func(ctx context.Context) (int, []byte, error) {
+		return h.processTransfer(ctx, req)
+	})
+}
+
+func (h *Handler) processTransfer(ctx context.Context, req transferRequest) (int, []byte, error) {
+	res, err := h.service.Transfer(ctx, req.FromWalletID, req.ToWalletID, req.Currency, req.Amount)
+	if err != nil {
+		return errorResult(err)
+	}
+
+	body, err := json.Marshal(transferResponse{
+		FromWalletID: res.FromWalletID,
+		FromBalance:  res.FromBalance,
+		ToWalletID:   res.ToWalletID,
+		ToBalance:    res.ToBalance,
+		Currency:     res.Currency,
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, body, nil
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+func TestPostTransfer_Success(t *testing.T) {
+	mockService := &MockWalletService{
+		TransferFunc: func(ctx context.Context, fromID, toID, currency string, amount int64) (model.TransferResult, error) {
+			if fromID != "550e8400-e29b-41d4-a716-446655440000" {
+				t.Errorf("expected canonical fromID, got %s", fromID)
+			}
+			return model.TransferResult{FromWalletID: fromID, ToWalletID: toID, FromBalance: 700, ToBalance: 1300}, nil
+		},
+	}
+
+	handler := New(mockService)
+
+	body, _ := json.Marshal(transferRequest{
+		FromWalletID: "550E8400-E29B-41D4-A716-446655440000",
+		ToWalletID:   "11111111-1111-1111-1111-111111111111",
+		Amount:       300,
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/transfers", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	handler.PostTransfer(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var resp transferResponse
+	json.NewDecoder(rec.Body).Decode(&resp)
+
+	if resp.FromBalance != 700 || resp.ToBalance != 1300 {
+		t.Errorf("unexpected balances: %+v", resp)
+	}
+	if resp.ToWalletID != "11111111-1111-1111-1111-111111111111" {
+		t.Errorf("unexpected toWalletId %s", resp.ToWalletID)
+	}
+}
+
+func TestPostTransfer_InvalidRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid json", "{"},
+		{"invalid from", `{"fromWalletId":"nope","toWalletId":"11111111-1111-1111-1111-111111111111","amount":1}`},
+		{"invalid to", `{"fromWalletId":"11111111-1111-1111-1111-111111111111","toWalletId":"nope","amount":1}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := New(&MockWalletService{})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/transfers", bytes.NewReader([]byte(tt.body)))
+			rec := httptest.NewRecorder()
+
+			handler.PostTransfer(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d", rec.Code)
+			}
+		})
+	}
+}
+
+func TestPostTransfer_ServiceErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"insufficient funds", appErr.ErrInsufficientFunds, http.StatusBadRequest},
+		{"same wallet", appErr.ErrSameWallet, http.StatusBadRequest},
+		{"wallet not found", appErr.ErrWalletNotFound, http.StatusNotFound},
+		{"internal", context.DeadlineExceeded, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := New(&MockWalletService{
+				TransferFunc: func(ctx context.Context, fromID, toID, currency string, amount int64) (model.TransferResult, error) {
+					return model.TransferResult{}, tt.err
+				},
+			})
+
+			body, _ := json.Marshal(transferRequest{
+				FromWalletID: "550e8400-e29b-41d4-a716-446655440000",
+				ToWalletID:   "11111111-1111-1111-1111-111111111111",
+				Amount:       300,
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/transfers", bytes.NewReader(body))
+			rec := httptest.NewRecorder()
+
+			handler.PostTransfer(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, rec.Code)
+			}
+		})
+	}
+}
+
+func TestPostTransfer_IdempotencyKey(t *testing.T) {
+	var gotKey string
+
+	mockService := &MockWalletService{
+		IdempotentFunc: func(ctx context.Context, key, requestHash string, fn func(ctx context.Context) (int, []byte, error)) (int, []byte, bool, error) {
+			gotKey = key
+			status, body, err := fn(ctx)
+			return status, body, false, err
+		},
+	}
+
+	handler := New(mockService)
+
+	body, _ := json.Marshal(transferRequest{
+		FromWalletID: "550e8400-e29b-41d4-a716-446655440000",
+		ToWalletID:   "11111111-1111-1111-1111-111111111111",
+		Amount:       300,
+		RequestID:    "transfer-1",
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/transfers", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	handler.PostTransfer(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rec.Code)
+	}
+	if gotKey != "transfer-1" {
+		t.Errorf("expected key transfer-1, got %s", gotKey)
+	}
+}
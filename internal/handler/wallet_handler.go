@@ -4,107 +4,315 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+	"github.com/Hlompy/Wallet/internal/stream"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
 type WalletService interface {
-	Process(ctx context.Context, walletID, op string, amount int64) error
-	Balance(ctx context.Context, walletID string) (int64, error)
+	Process(ctx context.Context, walletID, currency, op string, amount, ifVersion int64) error
+	ProcessBatch(ctx context.Context, ops []model.BatchOperation, atomic bool) ([]model.BatchResult, error)
+	Balance(ctx context.Context, walletID, currency string) (model.Wallet, error)
+	Transfer(ctx context.Context, fromID, toID, currency string, amount int64) (model.TransferResult, error)
+	TrialBalance(ctx context.Context) ([]model.AccountBalance, error)
+	FreezeWallet(ctx context.Context, walletID, reason string) (model.WalletFreeze, error)
+	UnfreezeWallet(ctx context.Context, walletID string) error
+	WalletFreeze(ctx context.Context, walletID string) (*model.WalletFreeze, error)
+	PlaceHold(ctx context.Context, walletID, currency string, amount int64, ttl time.Duration) (model.Hold, error)
+	CaptureHold(ctx context.Context, holdID string, amount int64) (model.Hold, error)
+	ReleaseHold(ctx context.Context, holdID string) (model.Hold, error)
+	Hold(ctx context.Context, holdID string) (model.Hold, error)
+	Convert(ctx context.Context, walletID, fromCurrency, toCurrency string, amount int64) (model.ConversionResult, error)
+	UpdateRates(ctx context.Context, rates []model.ExchangeRate) error
+	Rates(ctx context.Context) ([]model.ExchangeRate, error)
+	History(ctx context.Context, walletID string, filter model.TransactionFilter, cursor string) ([]model.Transaction, string, error)
+	Idempotent(ctx context.Context, key, requestHash string, fn func(ctx context.Context) (int, []byte, error)) (int, []byte, bool, error)
+
+	CreateImport(ctx context.Context, format string, data []byte) (model.ImportJob, error)
+	Import(ctx context.Context, id string) (model.ImportJob, error)
+	ImportErrors(ctx context.Context, id string) ([]model.ImportLineError, error)
+
+	CreateWebhook(ctx context.Context, url string, events []string) (model.Webhook, error)
+	Webhook(ctx context.Context, id string) (model.Webhook, error)
+	DeleteWebhook(ctx context.Context, id string) error
+	WebhookDeliveries(ctx context.Context, status string, limit int) ([]model.WebhookDelivery, error)
+	ReplayWebhookDelivery(ctx context.Context, id int64) (model.WebhookDelivery, error)
+
+	WalletSnapshot(ctx context.Context, walletID string) (int64, []model.Wallet, error)
+	WalletEvents(ctx context.Context, walletID string, after int64) ([]model.WalletEvent, error)
 }
 
 type Handler struct {
 	service WalletService
+
+	maxImportBytes int64
+
+	events    stream.Subscriber
+	keepAlive time.Duration
+}
+
+type Option func(*Handler)
+
+// WithImportLimit caps the size of an uploaded import file in bytes.
+func WithImportLimit(maxBytes int64) Option {
+	return func(h *Handler) {
+		h.maxImportBytes = maxBytes
+	}
+}
+
+// WithEventStream wakes wallet event streams through events and sets how
+// often an idle stream sends a keep-alive comment.
+func WithEventStream(events stream.Subscriber, keepAlive time.Duration) Option {
+	return func(h *Handler) {
+		h.events = events
+		h.keepAlive = keepAlive
+	}
 }
 
-func New(service WalletService) *Handler {
-	return &Handler{service: service}
+func New(service WalletService, opts ...Option) *Handler {
+	h := &Handler{
+		service:        service,
+		maxImportBytes: 64 << 20,
+		keepAlive:      15 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 type walletRequest struct {
-	WalletID string `json:"walletId"`
-	OpType   string `json:"operationType"`
-	Amount   int64  `json:"amount"`
+	WalletID  string `json:"walletId"`
+	Currency  string `json:"currency,omitempty"`
+	OpType    string `json:"operationType"`
+	Amount    int64  `json:"amount"`
+	RequestID string `json:"requestId,omitempty"`
 }
 
 type walletResponse struct {
 	WalletID string `json:"walletId"`
+	Currency string `json:"currency"`
 	Balance  int64  `json:"balance"`
+	Version  int64  `json:"version"`
+}
+
+type balanceResponse struct {
+	WalletID         string `json:"walletId"`
+	Currency         string `json:"currency"`
+	MinorUnits       int    `json:"minorUnits"`
+	Balance          int64  `json:"balance"`
+	AvailableBalance int64  `json:"availableBalance"`
+	Version          int64  `json:"version"`
+}
+
+type transactionResponse struct {
+	ID           int64     `json:"id"`
+	Currency     string    `json:"currency"`
+	Type         string    `json:"type"`
+	Amount       int64     `json:"amount"`
+	BalanceAfter int64     `json:"balanceAfter"`
+	Rate         string    `json:"rate,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type historyResponse struct {
+	WalletID     string                `json:"walletId"`
+	Transactions []transactionResponse `json:"transactions"`
+	NextCursor   string                `json:"nextCursor,omitempty"`
 }
 
 func (h *Handler) PostWallet(w http.ResponseWriter, r *http.Request) {
 	var req walletRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid json", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid json")
 		return
 	}
 
 	if _, err := uuid.Parse(req.WalletID); err != nil {
-		http.Error(w, "invalid walletId", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid walletId")
 		return
 	}
 
+	ifVersion, ok := parseIfMatch(r.Header.Get("If-Match"))
+	if !ok {
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid If-Match")
+		return
+	}
+
+	hash := requestHash(
+		r.Method,
+		r.URL.Path,
+		req.WalletID,
+		req.Currency,
+		req.OpType,
+		strconv.FormatInt(req.Amount, 10),
+		strconv.FormatInt(ifVersion, 10),
+	)
+
+	h.respond(w, r, req.RequestID, hash, func(ctx context.Context) (int, []byte, error) {
+		return h.processWallet(ctx, req, ifVersion)
+	})
+}
+
+func (h *Handler) processWallet(ctx context.Context, req walletRequest, ifVersion int64) (int, []byte, error) {
 	err := h.service.Process(
-		r.Context(),
+		ctx,
 		req.WalletID,
+		req.Currency,
 		req.OpType,
 		req.Amount,
+		ifVersion,
 	)
 
 	if err != nil {
-		switch err {
-		case appErr.ErrInsufficientFunds:
-			http.Error(w, err.Error(), http.StatusBadRequest)
-		case appErr.ErrInvalidOperation:
-			http.Error(w, err.Error(), http.StatusBadRequest)
-		case appErr.ErrWalletNotFound:
-			http.Error(w, err.Error(), http.StatusNotFound)
-		default:
-			http.Error(w, "internal error", http.StatusInternalServerError)
-		}
-		return
+		return errorResult(err)
 	}
 
-	balance, err := h.service.Balance(r.Context(), req.WalletID)
+	wallet, err := h.service.Balance(ctx, req.WalletID, req.Currency)
 	if err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
-		return
+		return 0, nil, err
 	}
 
-	resp := walletResponse{
+	body, err := json.Marshal(walletResponse{
 		WalletID: req.WalletID,
-		Balance:  balance,
+		Currency: wallet.Currency,
+		Balance:  wallet.Balance,
+		Version:  wallet.Version,
+	})
+	if err != nil {
+		return 0, nil, err
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(resp)
+	return http.StatusOK, body, nil
 }
 
 func (h *Handler) GetBalance(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
 	if _, err := uuid.Parse(id); err != nil {
-		http.Error(w, "invalid walletId", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid walletId")
 		return
 	}
 
-	balance, err := h.service.Balance(r.Context(), id)
+	wallet, err := h.service.Balance(r.Context(), id, r.URL.Query().Get("currency"))
 	if err != nil {
-		switch err {
-		case appErr.ErrWalletNotFound:
-			http.Error(w, err.Error(), http.StatusNotFound)
-		default:
-			http.Error(w, "internal error", http.StatusInternalServerError)
-		}
+		writeError(w, r, err)
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]int64{
-		"balance": balance,
+	currency, _ := model.LookupCurrency(wallet.Currency)
+
+	w.Header().Set("ETag", etag(wallet.Version))
+	json.NewEncoder(w).Encode(balanceResponse{
+		WalletID:         id,
+		Currency:         wallet.Currency,
+		MinorUnits:       currency.MinorUnits,
+		Balance:          wallet.Balance,
+		AvailableBalance: wallet.Available(),
+		Version:          wallet.Version,
 	})
 }
+
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// parseIfMatch returns the wallet version an If-Match header requires, or
+// 0 when there is no header or it is "*". Only a single strong ETag as
+// sent by GetBalance is understood.
+func parseIfMatch(header string) (int64, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, true
+	}
+
+	v, ok := strings.CutPrefix(header, `"`)
+	if ok {
+		v, ok = strings.CutSuffix(v, `"`)
+	}
+	if !ok {
+		return 0, false
+	}
+
+	version, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+func (h *Handler) ListTransactions(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if _, err := uuid.Parse(id); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid walletId")
+		return
+	}
+
+	q := r.URL.Query()
+	filter := model.TransactionFilter{
+		Currency: q.Get("currency"),
+		Type:     q.Get("type"),
+	}
+
+	if v := q.Get("from"); v != "" {
+		from, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid from")
+			return
+		}
+		filter.From = from
+	}
+
+	if v := q.Get("to"); v != "" {
+		to, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid to")
+			return
+		}
+		filter.To = to
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid limit")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	txs, next, err := h.service.History(r.Context(), id, filter, q.Get("cursor"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	resp := historyResponse{
+		WalletID:     id,
+		Transactions: make([]transactionResponse, 0, len(txs)),
+		NextCursor:   next,
+	}
+	for _, t := range txs {
+		resp.Transactions = append(resp.Transactions, transactionResponse{
+			ID:           t.ID,
+			Currency:     t.Currency,
+			Type:         t.Type,
+			Amount:       t.Amount,
+			BalanceAfter: t.BalanceAfter,
+			Rate:         t.Rate,
+			CreatedAt:    t.CreatedAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
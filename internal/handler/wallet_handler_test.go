@@ -3,41 +3,254 @@ package handler
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
 type MockWalletService struct {
-	ProcessFunc func(ctx context.Context, walletID, op string, amount int64) error
-	BalanceFunc func(ctx context.Context, walletID string) (int64, error)
+	ProcessFunc      func(ctx context.Context, walletID, currency, op string, amount, ifVersion int64) error
+	ProcessBatchFunc func(ctx context.Context, ops []model.BatchOperation, atomic bool) ([]model.BatchResult, error)
+	BalanceFunc      func(ctx context.Context, walletID, currency string) (model.Wallet, error)
+	TransferFunc     func(ctx context.Context, fromID, toID, currency string, amount int64) (model.TransferResult, error)
+	TrialBalanceFunc func(ctx context.Context) ([]model.AccountBalance, error)
+
+	FreezeWalletFunc   func(ctx context.Context, walletID, reason string) (model.WalletFreeze, error)
+	UnfreezeWalletFunc func(ctx context.Context, walletID string) error
+	WalletFreezeFunc   func(ctx context.Context, walletID string) (*model.WalletFreeze, error)
+
+	PlaceHoldFunc   func(ctx context.Context, walletID, currency string, amount int64, ttl time.Duration) (model.Hold, error)
+	CaptureHoldFunc func(ctx context.Context, holdID string, amount int64) (model.Hold, error)
+	ReleaseHoldFunc func(ctx context.Context, holdID string) (model.Hold, error)
+	HoldFunc        func(ctx context.Context, holdID string) (model.Hold, error)
+	ConvertFunc     func(ctx context.Context, walletID, fromCurrency, toCurrency string, amount int64) (model.ConversionResult, error)
+	UpdateRatesFunc func(ctx context.Context, rates []model.ExchangeRate) error
+	RatesFunc       func(ctx context.Context) ([]model.ExchangeRate, error)
+	HistoryFunc     func(ctx context.Context, walletID string, filter model.TransactionFilter, cursor string) ([]model.Transaction, string, error)
+	IdempotentFunc  func(ctx context.Context, key, requestHash string, fn func(ctx context.Context) (int, []byte, error)) (int, []byte, bool, error)
+
+	CreateImportFunc func(ctx context.Context, format string, data []byte) (model.ImportJob, error)
+	ImportFunc       func(ctx context.Context, id string) (model.ImportJob, error)
+	ImportErrorsFunc func(ctx context.Context, id string) ([]model.ImportLineError, error)
+
+	CreateWebhookFunc         func(ctx context.Context, url string, events []string) (model.Webhook, error)
+	WebhookFunc               func(ctx context.Context, id string) (model.Webhook, error)
+	DeleteWebhookFunc         func(ctx context.Context, id string) error
+	WebhookDeliveriesFunc     func(ctx context.Context, status string, limit int) ([]model.WebhookDelivery, error)
+	ReplayWebhookDeliveryFunc func(ctx context.Context, id int64) (model.WebhookDelivery, error)
+
+	WalletSnapshotFunc func(ctx context.Context, walletID string) (int64, []model.Wallet, error)
+	WalletEventsFunc   func(ctx context.Context, walletID string, after int64) ([]model.WalletEvent, error)
 }
 
-func (m *MockWalletService) Process(ctx context.Context, walletID, op string, amount int64) error {
+func (m *MockWalletService) Process(ctx context.Context, walletID, currency, op string, amount, ifVersion int64) error {
 	if m.ProcessFunc != nil {
-		return m.ProcessFunc(ctx, walletID, op, amount)
+		return m.ProcessFunc(ctx, walletID, currency, op, amount, ifVersion)
 	}
 	return nil
 }
 
-func (m *MockWalletService) Balance(ctx context.Context, walletID string) (int64, error) {
+func (m *MockWalletService) ProcessBatch(ctx context.Context, ops []model.BatchOperation, atomic bool) ([]model.BatchResult, error) {
+	if m.ProcessBatchFunc != nil {
+		return m.ProcessBatchFunc(ctx, ops, atomic)
+	}
+	return make([]model.BatchResult, len(ops)), nil
+}
+
+func (m *MockWalletService) Balance(ctx context.Context, walletID, currency string) (model.Wallet, error) {
 	if m.BalanceFunc != nil {
-		return m.BalanceFunc(ctx, walletID)
+		return m.BalanceFunc(ctx, walletID, currency)
+	}
+	return model.Wallet{}, nil
+}
+
+func (m *MockWalletService) Transfer(ctx context.Context, fromID, toID, currency string, amount int64) (model.TransferResult, error) {
+	if m.TransferFunc != nil {
+		return m.TransferFunc(ctx, fromID, toID, currency, amount)
+	}
+	return model.TransferResult{FromWalletID: fromID, ToWalletID: toID, Currency: currency}, nil
+}
+
+func (m *MockWalletService) TrialBalance(ctx context.Context) ([]model.AccountBalance, error) {
+	if m.TrialBalanceFunc != nil {
+		return m.TrialBalanceFunc(ctx)
+	}
+	return nil, nil
+}
+
+func (m *MockWalletService) PlaceHold(ctx context.Context, walletID, currency string, amount int64, ttl time.Duration) (model.Hold, error) {
+	if m.PlaceHoldFunc != nil {
+		return m.PlaceHoldFunc(ctx, walletID, currency, amount, ttl)
+	}
+	return model.Hold{}, nil
+}
+
+func (m *MockWalletService) CaptureHold(ctx context.Context, holdID string, amount int64) (model.Hold, error) {
+	if m.CaptureHoldFunc != nil {
+		return m.CaptureHoldFunc(ctx, holdID, amount)
+	}
+	return model.Hold{}, nil
+}
+
+func (m *MockWalletService) ReleaseHold(ctx context.Context, holdID string) (model.Hold, error) {
+	if m.ReleaseHoldFunc != nil {
+		return m.ReleaseHoldFunc(ctx, holdID)
+	}
+	return model.Hold{}, nil
+}
+
+func (m *MockWalletService) Hold(ctx context.Context, holdID string) (model.Hold, error) {
+	if m.HoldFunc != nil {
+		return m.HoldFunc(ctx, holdID)
+	}
+	return model.Hold{}, nil
+}
+
+func (m *MockWalletService) Convert(ctx context.Context, walletID, fromCurrency, toCurrency string, amount int64) (model.ConversionResult, error) {
+	if m.ConvertFunc != nil {
+		return m.ConvertFunc(ctx, walletID, fromCurrency, toCurrency, amount)
 	}
-	return 0, nil
+	return model.ConversionResult{}, nil
+}
+
+func (m *MockWalletService) UpdateRates(ctx context.Context, rates []model.ExchangeRate) error {
+	if m.UpdateRatesFunc != nil {
+		return m.UpdateRatesFunc(ctx, rates)
+	}
+	return nil
+}
+
+func (m *MockWalletService) FreezeWallet(ctx context.Context, walletID, reason string) (model.WalletFreeze, error) {
+	if m.FreezeWalletFunc != nil {
+		return m.FreezeWalletFunc(ctx, walletID, reason)
+	}
+	return model.WalletFreeze{}, nil
+}
+
+func (m *MockWalletService) UnfreezeWallet(ctx context.Context, walletID string) error {
+	if m.UnfreezeWalletFunc != nil {
+		return m.UnfreezeWalletFunc(ctx, walletID)
+	}
+	return nil
+}
+
+func (m *MockWalletService) WalletFreeze(ctx context.Context, walletID string) (*model.WalletFreeze, error) {
+	if m.WalletFreezeFunc != nil {
+		return m.WalletFreezeFunc(ctx, walletID)
+	}
+	return nil, nil
+}
+
+func (m *MockWalletService) Rates(ctx context.Context) ([]model.ExchangeRate, error) {
+	if m.RatesFunc != nil {
+		return m.RatesFunc(ctx)
+	}
+	return nil, nil
+}
+
+func (m *MockWalletService) History(ctx context.Context, walletID string, filter model.TransactionFilter, cursor string) ([]model.Transaction, string, error) {
+	if m.HistoryFunc != nil {
+		return m.HistoryFunc(ctx, walletID, filter, cursor)
+	}
+	return nil, "", nil
+}
+
+func (m *MockWalletService) Idempotent(ctx context.Context, key, requestHash string, fn func(ctx context.Context) (int, []byte, error)) (int, []byte, bool, error) {
+	if m.IdempotentFunc != nil {
+		return m.IdempotentFunc(ctx, key, requestHash, fn)
+	}
+	status, body, err := fn(ctx)
+	return status, body, false, err
+}
+
+func (m *MockWalletService) CreateImport(ctx context.Context, format string, data []byte) (model.ImportJob, error) {
+	if m.CreateImportFunc != nil {
+		return m.CreateImportFunc(ctx, format, data)
+	}
+	return model.ImportJob{Format: format, Status: model.ImportPending}, nil
+}
+
+func (m *MockWalletService) Import(ctx context.Context, id string) (model.ImportJob, error) {
+	if m.ImportFunc != nil {
+		return m.ImportFunc(ctx, id)
+	}
+	return model.ImportJob{}, nil
+}
+
+func (m *MockWalletService) ImportErrors(ctx context.Context, id string) ([]model.ImportLineError, error) {
+	if m.ImportErrorsFunc != nil {
+		return m.ImportErrorsFunc(ctx, id)
+	}
+	return nil, nil
+}
+
+func (m *MockWalletService) CreateWebhook(ctx context.Context, url string, events []string) (model.Webhook, error) {
+	if m.CreateWebhookFunc != nil {
+		return m.CreateWebhookFunc(ctx, url, events)
+	}
+	return model.Webhook{}, nil
+}
+
+func (m *MockWalletService) Webhook(ctx context.Context, id string) (model.Webhook, error) {
+	if m.WebhookFunc != nil {
+		return m.WebhookFunc(ctx, id)
+	}
+	return model.Webhook{}, nil
+}
+
+func (m *MockWalletService) DeleteWebhook(ctx context.Context, id string) error {
+	if m.DeleteWebhookFunc != nil {
+		return m.DeleteWebhookFunc(ctx, id)
+	}
+	return nil
+}
+
+func (m *MockWalletService) WebhookDeliveries(ctx context.Context, status string, limit int) ([]model.WebhookDelivery, error) {
+	if m.WebhookDeliveriesFunc != nil {
+		return m.WebhookDeliveriesFunc(ctx, status, limit)
+	}
+	return nil, nil
+}
+
+func (m *MockWalletService) ReplayWebhookDelivery(ctx context.Context, id int64) (model.WebhookDelivery, error) {
+	if m.ReplayWebhookDeliveryFunc != nil {
+		return m.ReplayWebhookDeliveryFunc(ctx, id)
+	}
+	return model.WebhookDelivery{}, nil
+}
+
+func (m *MockWalletService) WalletSnapshot(ctx context.Context, walletID string) (int64, []model.Wallet, error) {
+	if m.WalletSnapshotFunc != nil {
+		return m.WalletSnapshotFunc(ctx, walletID)
+	}
+	return 0, nil, nil
+}
+
+func (m *MockWalletService) WalletEvents(ctx context.Context, walletID string, after int64) ([]model.WalletEvent, error) {
+	if m.WalletEventsFunc != nil {
+		return m.WalletEventsFunc(ctx, walletID, after)
+	}
+	return nil, nil
 }
 
 func TestPostWallet_Success(t *testing.T) {
 	mockService := &MockWalletService{
-		ProcessFunc: func(ctx context.Context, walletID, op string, amount int64) error {
+		ProcessFunc: func(ctx context.Context, walletID, currency, op string, amount, ifVersion int64) error {
 			return nil
 		},
-		BalanceFunc: func(ctx context.Context, walletID string) (int64, error) {
-			return 1000, nil
+		BalanceFunc: func(ctx context.Context, walletID, currency string) (model.Wallet, error) {
+			return model.Wallet{Currency: "USD", Balance: 1000}, nil
 		},
 	}
 
@@ -45,6 +258,7 @@ func TestPostWallet_Success(t *testing.T) {
 
 	reqBody := walletRequest{
 		WalletID: "11111111-1111-1111-1111-111111111123",
+		Currency: "USD",
 		OpType:   "DEPOSIT",
 		Amount:   1000,
 	}
@@ -68,6 +282,9 @@ func TestPostWallet_Success(t *testing.T) {
 	if resp.Balance != 1000 {
 		t.Errorf("expected balance 1000, got %d", resp.Balance)
 	}
+	if resp.Currency != "USD" {
+		t.Errorf("expected currency USD, got %s", resp.Currency)
+	}
 }
 
 func TestPostWallet_InvalidJSON(t *testing.T) {
@@ -101,11 +318,14 @@ func TestPostWallet_InvalidWalletID(t *testing.T) {
 	if rec.Code != http.StatusBadRequest {
 		t.Errorf("expected status 400, got %d", rec.Code)
 	}
+	if problem := decodeProblem(t, rec); problem.Code != appErr.CodeValidationFailed {
+		t.Errorf("expected code %s, got %s", appErr.CodeValidationFailed, problem.Code)
+	}
 }
 
 func TestPostWallet_InsufficientFunds(t *testing.T) {
 	mockService := &MockWalletService{
-		ProcessFunc: func(ctx context.Context, walletID, op string, amount int64) error {
+		ProcessFunc: func(ctx context.Context, walletID, currency, op string, amount, ifVersion int64) error {
 			return appErr.ErrInsufficientFunds
 		},
 	}
@@ -120,18 +340,27 @@ func TestPostWallet_InsufficientFunds(t *testing.T) {
 	body, _ := json.Marshal(reqBody)
 
 	req := httptest.NewRequest(http.MethodPost, "/api/v1/wallet", bytes.NewReader(body))
+	req.Header.Set("X-Request-ID", "req-1")
 	rec := httptest.NewRecorder()
 
-	handler.PostWallet(rec, req)
+	RequestID(http.HandlerFunc(handler.PostWallet)).ServeHTTP(rec, req)
 
 	if rec.Code != http.StatusBadRequest {
 		t.Errorf("expected status 400, got %d", rec.Code)
 	}
+
+	problem := decodeProblem(t, rec)
+	if problem.Code != appErr.CodeInsufficientFunds || problem.Detail != "insufficient funds" {
+		t.Errorf("unexpected problem: %+v", problem)
+	}
+	if problem.RequestID != "req-1" || problem.Instance != "/api/v1/wallet" || problem.Status != http.StatusBadRequest {
+		t.Errorf("unexpected problem: %+v", problem)
+	}
 }
 
 func TestPostWallet_WalletNotFound(t *testing.T) {
 	mockService := &MockWalletService{
-		ProcessFunc: func(ctx context.Context, walletID, op string, amount int64) error {
+		ProcessFunc: func(ctx context.Context, walletID, currency, op string, amount, ifVersion int64) error {
 			return appErr.ErrWalletNotFound
 		},
 	}
@@ -155,16 +384,413 @@ func TestPostWallet_WalletNotFound(t *testing.T) {
 	}
 }
 
+func TestPostWallet_CurrencyErrors(t *testing.T) {
+	for _, svcErr := range []error{appErr.ErrInvalidCurrency, appErr.ErrCurrencyMismatch} {
+		t.Run(svcErr.Error(), func(t *testing.T) {
+			handler := New(&MockWalletService{
+				ProcessFunc: func(ctx context.Context, walletID, currency, op string, amount, ifVersion int64) error {
+					return svcErr
+				},
+			})
+
+			body := []byte(`{"walletId":"550e8400-e29b-41d4-a716-446655440000","currency":"EUR","operationType":"WITHDRAW","amount":100}`)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/wallet", bytes.NewReader(body))
+			rec := httptest.NewRecorder()
+
+			handler.PostWallet(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d", rec.Code)
+			}
+		})
+	}
+}
+
+func TestPostWallet_WrappedErrors(t *testing.T) {
+	details := map[string]any{"available": int64(100), "requested": int64(500)}
+
+	tests := []struct {
+		name        string
+		err         error
+		wantStatus  int
+		wantCode    string
+		wantDetails bool
+	}{
+		{
+			"wrapped with context",
+			fmt.Errorf("withdraw 500: %w", appErr.ErrInsufficientFunds),
+			http.StatusBadRequest, appErr.CodeInsufficientFunds, false,
+		},
+		{
+			"with details",
+			fmt.Errorf("withdraw 500: %w", appErr.ErrInsufficientFunds.WithDetails(details)),
+			http.StatusBadRequest, appErr.CodeInsufficientFunds, true,
+		},
+		{
+			"with cause",
+			appErr.ErrWalletNotFound.Wrap(sql.ErrNoRows),
+			http.StatusNotFound, appErr.CodeWalletNotFound, false,
+		},
+		{
+			"joined",
+			errors.Join(errors.New("audit failed"), appErr.ErrInvalidOperation),
+			http.StatusBadRequest, appErr.CodeInvalidOperation, false,
+		},
+		{
+			"not a domain error",
+			fmt.Errorf("update balance: %w", sql.ErrConnDone),
+			http.StatusInternalServerError, appErr.CodeInternal, false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := New(&MockWalletService{
+				ProcessFunc: func(ctx context.Context, walletID, currency, op string, amount, ifVersion int64) error {
+					return tt.err
+				},
+			})
+
+			body := []byte(`{"walletId":"550e8400-e29b-41d4-a716-446655440000","operationType":"WITHDRAW","amount":500}`)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/wallet", bytes.NewReader(body))
+			rec := httptest.NewRecorder()
+
+			handler.PostWallet(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+
+			problem := decodeProblem(t, rec)
+			if problem.Code != tt.wantCode {
+				t.Errorf("expected code %s, got %s", tt.wantCode, problem.Code)
+			}
+			// Neither the wrapping context nor the cause may leak.
+			if strings.Contains(problem.Detail, "withdraw") || strings.Contains(problem.Detail, "sql") {
+				t.Errorf("unexpected detail %q", problem.Detail)
+			}
+			if tt.wantDetails {
+				if problem.Details["available"] != float64(100) || problem.Details["requested"] != float64(500) {
+					t.Errorf("unexpected details: %+v", problem.Details)
+				}
+			} else if problem.Details != nil {
+				t.Errorf("expected no details, got %+v", problem.Details)
+			}
+		})
+	}
+}
+
+func TestGetBalance_WrappedNotFound(t *testing.T) {
+	handler := New(&MockWalletService{
+		BalanceFunc: func(ctx context.Context, walletID, currency string) (model.Wallet, error) {
+			return model.Wallet{}, fmt.Errorf("balance of %s: %w", walletID, appErr.ErrWalletNotFound)
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/wallets/550e8400-e29b-41d4-a716-446655440000", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "550e8400-e29b-41d4-a716-446655440000"})
+	rec := httptest.NewRecorder()
+
+	handler.GetBalance(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+	if problem := decodeProblem(t, rec); problem.Code != appErr.CodeWalletNotFound || problem.Detail != "wallet not found" {
+		t.Errorf("unexpected problem: %+v", problem)
+	}
+}
+
+func TestPostWallet_IfMatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		ifMatch    string
+		svcErr     error
+		wantStatus int
+		wantArg    int64
+	}{
+		{"no header", "", nil, http.StatusOK, 0},
+		{"any", "*", nil, http.StatusOK, 0},
+		{"current version", `"12"`, nil, http.StatusOK, 12},
+		{"stale version", `"11"`, appErr.ErrVersionMismatch, http.StatusPreconditionFailed, 11},
+		{"weak etag", `W/"12"`, nil, http.StatusBadRequest, 0},
+		{"unquoted", `12`, nil, http.StatusBadRequest, 0},
+		{"not a version", `"abc"`, nil, http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := New(&MockWalletService{
+				ProcessFunc: func(ctx context.Context, walletID, currency, op string, amount, ifVersion int64) error {
+					if ifVersion != tt.wantArg {
+						t.Errorf("expected ifVersion %d, got %d", tt.wantArg, ifVersion)
+					}
+					return tt.svcErr
+				},
+				BalanceFunc: func(ctx context.Context, walletID, currency string) (model.Wallet, error) {
+					return model.Wallet{Currency: "USD", Balance: 900, Version: 13}, nil
+				},
+			})
+
+			body := []byte(`{"walletId":"550e8400-e29b-41d4-a716-446655440000","operationType":"WITHDRAW","amount":100}`)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/wallet", bytes.NewReader(body))
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.PostWallet(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+
+			switch rec.Code {
+			case http.StatusOK:
+				var resp walletResponse
+				json.NewDecoder(rec.Body).Decode(&resp)
+				if resp.Version != 13 {
+					t.Errorf("expected version 13, got %d", resp.Version)
+				}
+			case http.StatusPreconditionFailed:
+				if problem := decodeProblem(t, rec); problem.Code != appErr.CodeVersionMismatch {
+					t.Errorf("expected code %s, got %s", appErr.CodeVersionMismatch, problem.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestPostWallet_IdempotencyKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		requestID string
+		key       string
+	}{
+		{"header", "key-header", "", "key-header"},
+		{"body field", "", "key-body", "key-body"},
+		{"header wins", "key-header", "key-body", "key-header"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotKey string
+
+			mockService := &MockWalletService{
+				BalanceFunc: func(ctx context.Context, walletID, currency string) (model.Wallet, error) {
+					return model.Wallet{Balance: 1000}, nil
+				},
+				IdempotentFunc: func(ctx context.Context, key, requestHash string, fn func(ctx context.Context) (int, []byte, error)) (int, []byte, bool, error) {
+					gotKey = key
+					status, body, err := fn(ctx)
+					return status, body, false, err
+				},
+			}
+
+			handler := New(mockService)
+
+			body, _ := json.Marshal(walletRequest{
+				WalletID:  "550e8400-e29b-41d4-a716-446655440000",
+				OpType:    "DEPOSIT",
+				Amount:    1000,
+				RequestID: tt.requestID,
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/wallet", bytes.NewReader(body))
+			if tt.header != "" {
+				req.Header.Set("Idempotency-Key", tt.header)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.PostWallet(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Errorf("expected status 200, got %d", rec.Code)
+			}
+			if gotKey != tt.key {
+				t.Errorf("expected key %s, got %s", tt.key, gotKey)
+			}
+		})
+	}
+}
+
+func TestPostWallet_IdempotentReplay(t *testing.T) {
+	stored := []byte(`{"walletId":"550e8400-e29b-41d4-a716-446655440000","balance":700}`)
+
+	mockService := &MockWalletService{
+		ProcessFunc: func(ctx context.Context, walletID, currency, op string, amount, ifVersion int64) error {
+			t.Error("replay must not process the operation")
+			return nil
+		},
+		IdempotentFunc: func(ctx context.Context, key, requestHash string, fn func(ctx context.Context) (int, []byte, error)) (int, []byte, bool, error) {
+			return http.StatusOK, stored, true, nil
+		},
+	}
+
+	handler := New(mockService)
+
+	body, _ := json.Marshal(walletRequest{
+		WalletID: "550e8400-e29b-41d4-a716-446655440000",
+		OpType:   "WITHDRAW",
+		Amount:   300,
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/wallet", bytes.NewReader(body))
+	req.Header.Set("Idempotency-Key", "key-1")
+	rec := httptest.NewRecorder()
+
+	handler.PostWallet(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rec.Code)
+	}
+	if rec.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("expected Idempotent-Replayed header")
+	}
+	if rec.Body.String() != string(stored) {
+		t.Errorf("expected stored body, got %s", rec.Body.String())
+	}
+}
+
+func TestPostWallet_IdempotencyConflict(t *testing.T) {
+	mockService := &MockWalletService{
+		IdempotentFunc: func(ctx context.Context, key, requestHash string, fn func(ctx context.Context) (int, []byte, error)) (int, []byte, bool, error) {
+			return 0, nil, false, appErr.ErrIdempotencyConflict
+		},
+	}
+
+	handler := New(mockService)
+
+	body, _ := json.Marshal(walletRequest{
+		WalletID: "550e8400-e29b-41d4-a716-446655440000",
+		OpType:   "DEPOSIT",
+		Amount:   1000,
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/wallet", bytes.NewReader(body))
+	req.Header.Set("Idempotency-Key", "key-1")
+	rec := httptest.NewRecorder()
+
+	handler.PostWallet(rec, req)
+
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409, got %d", rec.Code)
+	}
+	if problem := decodeProblem(t, rec); problem.Code != appErr.CodeIdempotencyConflict {
+		t.Errorf("expected code %s, got %s", appErr.CodeIdempotencyConflict, problem.Code)
+	}
+}
+
+func TestPostWallet_ConcurrentUpdateNotStored(t *testing.T) {
+	stored := map[string][]byte{}
+	calls := 0
+
+	mockService := &MockWalletService{
+		ProcessFunc: func(ctx context.Context, walletID, currency, op string, amount, ifVersion int64) error {
+			calls++
+			if calls == 1 {
+				return appErr.ErrConcurrentUpdate
+			}
+			return nil
+		},
+		BalanceFunc: func(ctx context.Context, walletID, currency string) (model.Wallet, error) {
+			return model.Wallet{Currency: "RUB", Balance: 1000, Version: 1}, nil
+		},
+		// Like the service, keep the result only when fn succeeds;
+		// an error rolls the key back.
+		IdempotentFunc: func(ctx context.Context, key, requestHash string, fn func(ctx context.Context) (int, []byte, error)) (int, []byte, bool, error) {
+			if body, ok := stored[key]; ok {
+				return http.StatusConflict, body, true, nil
+			}
+			status, body, err := fn(ctx)
+			if err != nil {
+				return 0, nil, false, err
+			}
+			stored[key] = body
+			return status, body, false, nil
+		},
+	}
+
+	handler := New(mockService)
+
+	body, _ := json.Marshal(walletRequest{
+		WalletID: "550e8400-e29b-41d4-a716-446655440000",
+		OpType:   "DEPOSIT",
+		Amount:   1000,
+	})
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/wallet", bytes.NewReader(body))
+		req.Header.Set("Idempotency-Key", "key-1")
+		rec := httptest.NewRecorder()
+		handler.PostWallet(rec, req)
+		return rec
+	}
+
+	rec := send()
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected status 409, got %d", rec.Code)
+	}
+	if problem := decodeProblem(t, rec); problem.Code != appErr.CodeConcurrentUpdate {
+		t.Errorf("expected code %s, got %s", appErr.CodeConcurrentUpdate, problem.Code)
+	}
+	if len(stored) != 0 {
+		t.Fatal("CONCURRENT_UPDATE must not be stored under the key")
+	}
+
+	rec = send()
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected the retry to run and succeed, got %d", rec.Code)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 calls to Process, got %d", calls)
+	}
+}
+
+func TestPostWallet_RequestHashDependsOnBody(t *testing.T) {
+	hashes := map[string]bool{}
+
+	mockService := &MockWalletService{
+		IdempotentFunc: func(ctx context.Context, key, requestHash string, fn func(ctx context.Context) (int, []byte, error)) (int, []byte, bool, error) {
+			hashes[requestHash] = true
+			return http.StatusOK, []byte("{}"), false, nil
+		},
+	}
+
+	handler := New(mockService)
+
+	for _, amount := range []int64{100, 100, 200} {
+		body, _ := json.Marshal(walletRequest{
+			WalletID: "550e8400-e29b-41d4-a716-446655440000",
+			OpType:   "DEPOSIT",
+			Amount:   amount,
+		})
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/wallet", bytes.NewReader(body))
+		req.Header.Set("Idempotency-Key", "key-1")
+
+		handler.PostWallet(httptest.NewRecorder(), req)
+	}
+
+	if len(hashes) != 2 {
+		t.Errorf("expected 2 distinct request hashes, got %d", len(hashes))
+	}
+}
+
 func TestGetBalance_Success(t *testing.T) {
 	mockService := &MockWalletService{
-		BalanceFunc: func(ctx context.Context, walletID string) (int64, error) {
-			return 5000, nil
+		BalanceFunc: func(ctx context.Context, walletID, currency string) (model.Wallet, error) {
+			if currency != "KWD" {
+				t.Errorf("expected currency KWD, got %q", currency)
+			}
+			return model.Wallet{Currency: "KWD", Balance: 5000, Held: 1500, Version: 12}, nil
 		},
 	}
 
 	handler := New(mockService)
 
-	req := httptest.NewRequest(http.MethodGet, "/api/v1/wallets/550e8400-e29b-41d4-a716-446655440000", nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/wallets/550e8400-e29b-41d4-a716-446655440000?currency=KWD", nil)
 	rec := httptest.NewRecorder()
 
 	// Имитируем mux.Vars
@@ -178,11 +804,20 @@ func TestGetBalance_Success(t *testing.T) {
 		t.Errorf("expected status 200, got %d", rec.Code)
 	}
 
-	var resp map[string]int64
+	var resp balanceResponse
 	json.NewDecoder(rec.Body).Decode(&resp)
 
-	if resp["balance"] != 5000 {
-		t.Errorf("expected balance 5000, got %d", resp["balance"])
+	if resp.Balance != 5000 {
+		t.Errorf("expected balance 5000, got %d", resp.Balance)
+	}
+	if resp.AvailableBalance != 3500 {
+		t.Errorf("expected availableBalance 3500, got %d", resp.AvailableBalance)
+	}
+	if resp.Currency != "KWD" || resp.MinorUnits != 3 {
+		t.Errorf("expected KWD with 3 minor units, got %s/%d", resp.Currency, resp.MinorUnits)
+	}
+	if resp.Version != 12 || rec.Header().Get("ETag") != `"12"` {
+		t.Errorf("expected version 12 and ETag \"12\", got %d and %s", resp.Version, rec.Header().Get("ETag"))
 	}
 }
 
@@ -205,8 +840,8 @@ func TestGetBalance_InvalidWalletID(t *testing.T) {
 
 func TestGetBalance_WalletNotFound(t *testing.T) {
 	mockService := &MockWalletService{
-		BalanceFunc: func(ctx context.Context, walletID string) (int64, error) {
-			return 0, appErr.ErrWalletNotFound
+		BalanceFunc: func(ctx context.Context, walletID, currency string) (model.Wallet, error) {
+			return model.Wallet{}, appErr.ErrWalletNotFound
 		},
 	}
 
@@ -224,4 +859,120 @@ func TestGetBalance_WalletNotFound(t *testing.T) {
 	if rec.Code != http.StatusNotFound {
 		t.Errorf("expected status 404, got %d", rec.Code)
 	}
+	if problem := decodeProblem(t, rec); problem.Code != appErr.CodeWalletNotFound {
+		t.Errorf("expected code %s, got %s", appErr.CodeWalletNotFound, problem.Code)
+	}
+}
+
+func TestListTransactions_Success(t *testing.T) {
+	walletID := "550e8400-e29b-41d4-a716-446655440000"
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mockService := &MockWalletService{
+		HistoryFunc: func(ctx context.Context, id string, filter model.TransactionFilter, cursor string) ([]model.Transaction, string, error) {
+			if filter.Type != "DEPOSIT" || !filter.From.Equal(from) || filter.Limit != 2 {
+				t.Errorf("unexpected filter: %+v", filter)
+			}
+			if cursor != "abc" {
+				t.Errorf("expected cursor abc, got %s", cursor)
+			}
+			return []model.Transaction{
+				{ID: 5, WalletID: uuid.MustParse(id), Type: "DEPOSIT", Amount: 300, BalanceAfter: 1300},
+				{ID: 4, WalletID: uuid.MustParse(id), Type: "DEPOSIT", Amount: 1000, BalanceAfter: 1000},
+			}, "next", nil
+		},
+	}
+
+	handler := New(mockService)
+
+	req := httptest.NewRequest(http.MethodGet,
+		"/api/v1/wallets/"+walletID+"/transactions?type=DEPOSIT&from=2024-01-01T00:00:00Z&limit=2&cursor=abc", nil)
+	rec := httptest.NewRecorder()
+
+	req = mux.SetURLVars(req, map[string]string{"id": walletID})
+
+	handler.ListTransactions(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var resp historyResponse
+	json.NewDecoder(rec.Body).Decode(&resp)
+
+	if len(resp.Transactions) != 2 {
+		t.Fatalf("expected 2 transactions, got %d", len(resp.Transactions))
+	}
+	if resp.Transactions[0].ID != 5 || resp.Transactions[0].BalanceAfter != 1300 {
+		t.Errorf("unexpected first transaction: %+v", resp.Transactions[0])
+	}
+	if resp.NextCursor != "next" {
+		t.Errorf("expected nextCursor next, got %s", resp.NextCursor)
+	}
+}
+
+func TestListTransactions_InvalidParams(t *testing.T) {
+	walletID := "550e8400-e29b-41d4-a716-446655440000"
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"invalid from", "?from=yesterday"},
+		{"invalid to", "?to=2024-13-01"},
+		{"invalid limit", "?limit=abc"},
+		{"zero limit", "?limit=0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := New(&MockWalletService{})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/wallets/"+walletID+"/transactions"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			req = mux.SetURLVars(req, map[string]string{"id": walletID})
+
+			handler.ListTransactions(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d", rec.Code)
+			}
+		})
+	}
+}
+
+func TestListTransactions_ServiceErrors(t *testing.T) {
+	walletID := "550e8400-e29b-41d4-a716-446655440000"
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"invalid cursor", appErr.ErrInvalidCursor, http.StatusBadRequest},
+		{"invalid type", appErr.ErrInvalidOperation, http.StatusBadRequest},
+		{"wallet not found", appErr.ErrWalletNotFound, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := New(&MockWalletService{
+				HistoryFunc: func(ctx context.Context, id string, filter model.TransactionFilter, cursor string) ([]model.Transaction, string, error) {
+					return nil, "", tt.err
+				},
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/wallets/"+walletID+"/transactions", nil)
+			rec := httptest.NewRecorder()
+
+			req = mux.SetURLVars(req, map[string]string{"id": walletID})
+
+			handler.ListTransactions(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, rec.Code)
+			}
+		})
+	}
 }
@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events,omitempty"`
+}
+
+type webhookResponse struct {
+	WebhookID string    `json:"webhookId"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type deliveryResponse struct {
+	DeliveryID    int64           `json:"deliveryId"`
+	WebhookID     string          `json:"webhookId"`
+	Event         string          `json:"event"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"nextAttemptAt"`
+	LastError     string          `json:"lastError,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	UpdatedAt     time.Time       `json:"updatedAt"`
+}
+
+type deliveriesResponse struct {
+	Deliveries []deliveryResponse `json:"deliveries"`
+}
+
+// PostWebhook registers a webhook. Its secret is in this response only.
+func (h *Handler) PostWebhook(w http.ResponseWriter, r *http.Request) {
+	var req webhookRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid json")
+		return
+	}
+
+	webhook, err := h.service.CreateWebhook(r.Context(), req.URL, req.Events)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newWebhookResponse(webhook, true))
+}
+
+func (h *Handler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+
+	webhook, err := h.service.Webhook(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newWebhookResponse(webhook, false))
+}
+
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteWebhook(r.Context(), id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries lists the newest deliveries, optionally only those
+// in the status query parameter (e.g. DEAD for the dead-letter queue).
+func (h *Handler) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	status := strings.ToUpper(q.Get("status"))
+	switch status {
+	case "", model.DeliveryPending, model.DeliveryDelivered, model.DeliveryDead:
+	default:
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid status")
+		return
+	}
+
+	var limit int
+	if v := q.Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid limit")
+			return
+		}
+	}
+
+	deliveries, err := h.service.WebhookDeliveries(r.Context(), status, limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	resp := deliveriesResponse{Deliveries: make([]deliveryResponse, 0, len(deliveries))}
+	for _, d := range deliveries {
+		resp.Deliveries = append(resp.Deliveries, newDeliveryResponse(d))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ReplayWebhookDelivery queues a delivery, usually a dead one, again.
+func (h *Handler) ReplayWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil || id <= 0 {
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid deliveryId")
+		return
+	}
+
+	delivery, err := h.service.ReplayWebhookDelivery(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newDeliveryResponse(delivery))
+}
+
+func webhookID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := mux.Vars(r)["id"]
+
+	if _, err := uuid.Parse(id); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, appErr.CodeValidationFailed, "invalid webhookId")
+		return "", false
+	}
+
+	return id, true
+}
+
+func newWebhookResponse(webhook model.Webhook, withSecret bool) webhookResponse {
+	resp := webhookResponse{
+		WebhookID: webhook.ID.String(),
+		URL:       webhook.URL,
+		Events:    webhook.Events,
+		Active:    webhook.Active,
+		CreatedAt: webhook.CreatedAt,
+	}
+	if withSecret {
+		resp.Secret = webhook.Secret
+	}
+	return resp
+}
+
+func newDeliveryResponse(d model.WebhookDelivery) deliveryResponse {
+	return deliveryResponse{
+		DeliveryID:    d.ID,
+		WebhookID:     d.WebhookID.String(),
+		Event:         d.EventType,
+		Status:        d.Status,
+		Attempts:      d.Attempts,
+		NextAttemptAt: d.NextAttemptAt,
+		LastError:     d.LastError,
+		Payload:       d.Payload,
+		CreatedAt:     d.CreatedAt,
+		UpdatedAt:     d.UpdatedAt,
+	}
+}
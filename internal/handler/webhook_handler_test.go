@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const testWebhookID = "6a1e4c2d-3b5f-4e7a-9c8d-0f1e2d3c4b5a"
+
+func TestPostWebhook_ReturnsSecret(t *testing.T) {
+	var gotURL string
+	var gotEvents []string
+	handler := New(&MockWalletService{
+		CreateWebhookFunc: func(ctx context.Context, url string, events []string) (model.Webhook, error) {
+			gotURL, gotEvents = url, events
+			return model.Webhook{ID: uuid.MustParse(testWebhookID), URL: url, Secret: "whsec_test", Events: events, Active: true}, nil
+		},
+	})
+
+	body := `{"url":"https://example.com/hooks","events":["hold.expired"]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/webhooks", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	handler.PostWebhook(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if gotURL != "https://example.com/hooks" || len(gotEvents) != 1 || gotEvents[0] != model.WebhookHoldExpired {
+		t.Errorf("unexpected webhook: %q %v", gotURL, gotEvents)
+	}
+
+	var resp webhookResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.WebhookID != testWebhookID || resp.Secret != "whsec_test" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestGetWebhook_HidesSecret(t *testing.T) {
+	handler := New(&MockWalletService{
+		WebhookFunc: func(ctx context.Context, id string) (model.Webhook, error) {
+			return model.Webhook{ID: uuid.MustParse(id), URL: "https://example.com/hooks", Secret: "whsec_test"}, nil
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/webhooks/"+testWebhookID, nil)
+	req = mux.SetURLVars(req, map[string]string{"id": testWebhookID})
+	rec := httptest.NewRecorder()
+
+	handler.GetWebhook(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "whsec_test") {
+		t.Errorf("secret leaked: %s", rec.Body.String())
+	}
+}
+
+func TestPostWebhook_Invalid(t *testing.T) {
+	handler := New(&MockWalletService{
+		CreateWebhookFunc: func(ctx context.Context, url string, events []string) (model.Webhook, error) {
+			return model.Webhook{}, appErr.ErrInvalidWebhook
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/webhooks", strings.NewReader(`{"url":"ftp://example.com"}`))
+	rec := httptest.NewRecorder()
+
+	handler.PostWebhook(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rec.Code)
+	}
+	if p := decodeProblem(t, rec); p.Code != appErr.CodeInvalidWebhook {
+		t.Errorf("expected code %s, got %s", appErr.CodeInvalidWebhook, p.Code)
+	}
+}
+
+func TestListWebhookDeliveries_Filter(t *testing.T) {
+	var gotStatus string
+	var gotLimit int
+	handler := New(&MockWalletService{
+		WebhookDeliveriesFunc: func(ctx context.Context, status string, limit int) ([]model.WebhookDelivery, error) {
+			gotStatus, gotLimit = status, limit
+			return []model.WebhookDelivery{{ID: 5, Status: model.DeliveryDead, Payload: []byte(`{"eventId":1}`)}}, nil
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/webhook-deliveries?status=dead&limit=10", nil)
+	rec := httptest.NewRecorder()
+
+	handler.ListWebhookDeliveries(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if gotStatus != model.DeliveryDead || gotLimit != 10 {
+		t.Errorf("unexpected filter: %q %d", gotStatus, gotLimit)
+	}
+
+	var resp deliveriesResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Deliveries) != 1 || string(resp.Deliveries[0].Payload) != `{"eventId":1}` {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/admin/webhook-deliveries?status=LOST", nil)
+	rec = httptest.NewRecorder()
+
+	handler.ListWebhookDeliveries(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rec.Code)
+	}
+}
+
+func TestReplayWebhookDelivery_NotFound(t *testing.T) {
+	handler := New(&MockWalletService{
+		ReplayWebhookDeliveryFunc: func(ctx context.Context, id int64) (model.WebhookDelivery, error) {
+			return model.WebhookDelivery{}, appErr.ErrDeliveryNotFound
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/webhook-deliveries/42/replay", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "42"})
+	rec := httptest.NewRecorder()
+
+	handler.ReplayWebhookDelivery(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,16 @@
+package model
+
+// BatchOperation is one deposit or withdrawal of a batch request.
+type BatchOperation struct {
+	WalletID string
+	Currency string
+	Type     string
+	Amount   int64
+}
+
+// BatchResult is the outcome of a BatchOperation: the wallet balance it
+// left behind, or the error that stopped it.
+type BatchResult struct {
+	Wallet Wallet
+	Err    error
+}
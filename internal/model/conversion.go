@@ -0,0 +1,15 @@
+package model
+
+// ConversionResult describes a CONVERT: FromAmount left the FromCurrency
+// balance and ToAmount arrived on the ToCurrency balance at Rate, the
+// exchange rate after the spread.
+type ConversionResult struct {
+	WalletID     string
+	FromCurrency string
+	ToCurrency   string
+	FromAmount   int64
+	ToAmount     int64
+	Rate         string
+	FromBalance  int64
+	ToBalance    int64
+}
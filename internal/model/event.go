@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventHoldExpired is the type of the event written when a hold expires;
+// every other event type is that of a ledger row.
+const EventHoldExpired = "HOLD_EXPIRED"
+
+// WalletEvent tells downstream systems that a balance changed. Type and
+// Amount are those of the ledger row written with it. Reference is the
+// other wallet of a transfer or the hold of a capture or expiry. Sequence
+// numbers a wallet's events from 1 without gaps, across all its
+// currencies.
+type WalletEvent struct {
+	ID           int64
+	WalletID     uuid.UUID
+	Sequence     int64
+	Currency     string
+	Type         string
+	Amount       int64
+	BalanceAfter int64
+	Reference    string
+	Attempts     int
+	CreatedAt    time.Time
+}
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	HoldActive   = "ACTIVE"
+	HoldCaptured = "CAPTURED"
+	HoldReleased = "RELEASED"
+	HoldExpired  = "EXPIRED"
+)
+
+// Hold reserves part of a wallet's balance. While ACTIVE its Amount is
+// counted in Wallet.Held; Captured is how much of it was finally withdrawn.
+type Hold struct {
+	ID        uuid.UUID
+	WalletID  uuid.UUID
+	Currency  string
+	Amount    int64
+	Captured  int64
+	Status    string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,11 @@
+package model
+
+// IdempotencyRecord is the stored outcome of a request made with an
+// idempotency key. StatusCode is zero while the original request is still
+// in flight.
+type IdempotencyRecord struct {
+	Key         string
+	RequestHash string
+	StatusCode  int
+	Response    []byte
+}
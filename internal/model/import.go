@@ -0,0 +1,41 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ImportCSV   = "csv"
+	ImportJSONL = "jsonl"
+)
+
+const (
+	ImportPending   = "PENDING"
+	ImportRunning   = "RUNNING"
+	ImportCompleted = "COMPLETED"
+	ImportFailed    = "FAILED"
+)
+
+// ImportJob is a file of wallet operations applied line by line. Lines up
+// to LastLine are done, each counted in Succeeded or Failed, so a job
+// interrupted midway resumes after LastLine.
+type ImportJob struct {
+	ID        uuid.UUID
+	Format    string
+	Status    string
+	LastLine  int
+	Succeeded int
+	Failed    int
+	Error     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ImportLineError is why a line of an import was not applied.
+type ImportLineError struct {
+	Line   int
+	Code   string
+	Detail string
+}
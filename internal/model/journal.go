@@ -0,0 +1,47 @@
+package model
+
+import "strings"
+
+const (
+	AccountCashIn         = "system:cash_in"
+	AccountCashOut        = "system:cash_out"
+	AccountFees           = "system:fees"
+	AccountOpeningBalance = "system:opening_balance"
+	AccountFX             = "system:fx"
+
+	// AccountWallets stands for all wallet accounts taken together, as
+	// the trial balance reports them.
+	AccountWallets = "wallet:*"
+)
+
+const walletAccountPrefix = "wallet:"
+
+func WalletAccount(walletID string) string {
+	return walletAccountPrefix + walletID
+}
+
+// IsWalletAccount reports whether account is the account of a wallet.
+func IsWalletAccount(account string) bool {
+	return strings.HasPrefix(account, walletAccountPrefix)
+}
+
+// JournalEntry is one leg of a double-entry journal. Debits are positive
+// and credits negative, so the entries of a journal always sum to zero in
+// each currency. User wallets are liabilities: money paid in credits the
+// wallet account.
+type JournalEntry struct {
+	Account  string
+	Currency string
+	Amount   int64
+}
+
+type AccountBalance struct {
+	Account  string
+	Currency string
+	Debit    int64
+	Credit   int64
+}
+
+func (b AccountBalance) Balance() int64 {
+	return b.Debit - b.Credit
+}
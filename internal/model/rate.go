@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// ExchangeRate is the price of one major unit of Base in Quote, as a
+// decimal string, valid from EffectiveFrom until a newer rate for the
+// same pair takes over. Rates are directional: USD/EUR says nothing
+// about EUR/USD.
+type ExchangeRate struct {
+	Base          string
+	Quote         string
+	Rate          string
+	EffectiveFrom time.Time
+}
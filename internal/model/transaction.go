@@ -0,0 +1,45 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	OpDeposit     = "DEPOSIT"
+	OpWithdraw    = "WITHDRAW"
+	OpTransferOut = "TRANSFER_OUT"
+	OpTransferIn  = "TRANSFER_IN"
+	OpCapture     = "CAPTURE"
+	OpConvertOut  = "CONVERT_OUT"
+	OpConvertIn   = "CONVERT_IN"
+
+	OpTransfer = "TRANSFER"
+	OpConvert  = "CONVERT"
+)
+
+// Transaction is an immutable ledger entry. Amount is signed: positive
+// for credits, negative for debits, so BalanceAfter-Amount is the balance
+// the operation started from. Rate is only set on CONVERT legs.
+type Transaction struct {
+	ID           int64
+	WalletID     uuid.UUID
+	Currency     string
+	Type         string
+	Amount       int64
+	BalanceAfter int64
+	Rate         string
+	CreatedAt    time.Time
+}
+
+// TransactionFilter narrows a ledger listing. Zero values mean "no
+// restriction"; BeforeID pages backwards from a previously seen entry.
+type TransactionFilter struct {
+	Currency string
+	Type     string
+	From     time.Time
+	To       time.Time
+	BeforeID int64
+	Limit    int
+}
@@ -0,0 +1,9 @@
+package model
+
+type TransferResult struct {
+	FromWalletID string
+	ToWalletID   string
+	Currency     string
+	FromBalance  int64
+	ToBalance    int64
+}
@@ -1,8 +1,29 @@
 package model
 
-import "github.com/google/uuid"
+import (
+	"time"
 
+	"github.com/google/uuid"
+)
+
+// Version grows with every change to Balance or Held.
 type Wallet struct {
-	ID      uuid.UUID
-	Balance int64
+	ID       uuid.UUID
+	Currency string
+	Balance  int64
+	Held     int64
+	Version  int64
+}
+
+// Available is the part of the balance not reserved by active holds.
+func (w Wallet) Available() int64 {
+	return w.Balance - w.Held
+}
+
+// WalletFreeze stops operations that move money in or out of the wallet,
+// in all its currencies, until it is lifted.
+type WalletFreeze struct {
+	WalletID uuid.UUID
+	Reason   string
+	FrozenAt time.Time
 }
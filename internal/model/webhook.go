@@ -0,0 +1,52 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Webhook event types integrators subscribe to.
+const (
+	WebhookBalanceChanged    = "balance.changed"
+	WebhookTransferCompleted = "transfer.completed"
+	WebhookHoldExpired       = "hold.expired"
+)
+
+var WebhookEvents = []string{WebhookBalanceChanged, WebhookTransferCompleted, WebhookHoldExpired}
+
+const (
+	DeliveryPending   = "PENDING"
+	DeliveryDelivered = "DELIVERED"
+	DeliveryDead      = "DEAD"
+)
+
+// Webhook is an integrator's endpoint. Deliveries to it are signed with
+// Secret, which is only shown when the webhook is created.
+type Webhook struct {
+	ID        uuid.UUID
+	URL       string
+	Secret    string
+	Events    []string
+	Active    bool
+	CreatedAt time.Time
+}
+
+// WebhookDelivery is one event on its way to one webhook. It is retried
+// until it is DELIVERED or, after too many failures, DEAD. URL and Secret
+// are those of the webhook, filled in for the deliveries being sent.
+type WebhookDelivery struct {
+	ID            int64
+	WebhookID     uuid.UUID
+	EventType     string
+	Payload       []byte
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+
+	URL    string
+	Secret string
+}
@@ -0,0 +1,148 @@
+// Package outbox delivers the wallet events written by the repository
+// (the wallet_events outbox) to downstream sinks.
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+)
+
+type Store interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+	LockEventRelay(ctx context.Context) (bool, error)
+	PendingEvents(ctx context.Context, now time.Time, limit int) ([]model.WalletEvent, error)
+	MarkEventsDelivered(ctx context.Context, ids []int64) error
+	RetryEvent(ctx context.Context, id int64, at time.Time, msg string) error
+	DeleteDeliveredEvents(ctx context.Context, deliveredBefore time.Time) (int64, error)
+}
+
+// Sink receives events. Deliver may see an event more than once, so sinks
+// (or their consumers) deduplicate by wallet id and sequence.
+type Sink interface {
+	Deliver(ctx context.Context, event model.WalletEvent) error
+}
+
+type Relay struct {
+	store Store
+	sink  Sink
+
+	batchSize     int
+	retryDelay    time.Duration
+	maxRetryDelay time.Duration
+	retention     time.Duration
+}
+
+type Option func(*Relay)
+
+func WithBatchSize(n int) Option {
+	return func(r *Relay) {
+		r.batchSize = n
+	}
+}
+
+// WithRetryDelay sets the wait before redelivering a failed event; it
+// doubles with every failure up to max.
+func WithRetryDelay(base, max time.Duration) Option {
+	return func(r *Relay) {
+		r.retryDelay = base
+		r.maxRetryDelay = max
+	}
+}
+
+// WithRetention sets how long delivered events are kept.
+func WithRetention(d time.Duration) Option {
+	return func(r *Relay) {
+		r.retention = d
+	}
+}
+
+func New(store Store, sink Sink, opts ...Option) *Relay {
+	r := &Relay{
+		store:         store,
+		sink:          sink,
+		batchSize:     100,
+		retryDelay:    time.Second,
+		maxRetryDelay: 5 * time.Minute,
+		retention:     7 * 24 * time.Hour,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// RelayBatch delivers up to one batch of pending events and returns how
+// many it handled, delivered or not. Events are delivered at least once:
+// one that reached the sink is marked delivered only when the batch
+// commits. Only one relay runs at a time, and after a failed event the
+// wallet's later events wait for it, so each wallet's events reach the
+// sink in sequence order.
+func (r *Relay) RelayBatch(ctx context.Context) (int, error) {
+	var handled int
+	err := r.store.WithinTx(ctx, func(ctx context.Context) error {
+		handled = 0
+
+		locked, err := r.store.LockEventRelay(ctx)
+		if err != nil || !locked {
+			return err
+		}
+
+		events, err := r.store.PendingEvents(ctx, time.Now(), r.batchSize)
+		if err != nil {
+			return err
+		}
+
+		blocked := make(map[uuid.UUID]bool)
+		var delivered []int64
+		for _, event := range events {
+			if blocked[event.WalletID] {
+				continue
+			}
+
+			if err := r.sink.Deliver(ctx, event); err != nil {
+				blocked[event.WalletID] = true
+				if err := r.store.RetryEvent(ctx, event.ID, time.Now().Add(r.backoff(event.Attempts)), err.Error()); err != nil {
+					return err
+				}
+				continue
+			}
+
+			delivered = append(delivered, event.ID)
+		}
+
+		handled = len(events)
+		return r.store.MarkEventsDelivered(ctx, delivered)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return handled, nil
+}
+
+// Drain relays batches until the outbox has no more due events.
+func (r *Relay) Drain(ctx context.Context) error {
+	for {
+		n, err := r.RelayBatch(ctx)
+		if err != nil || n < r.batchSize {
+			return err
+		}
+	}
+}
+
+// Purge deletes events delivered longer ago than the retention period.
+func (r *Relay) Purge(ctx context.Context) (int64, error) {
+	return r.store.DeleteDeliveredEvents(ctx, time.Now().Add(-r.retention))
+}
+
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := r.retryDelay
+	for i := 0; i < attempts && delay < r.maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, r.maxRetryDelay)
+}
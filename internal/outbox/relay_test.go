@@ -0,0 +1,192 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+)
+
+var (
+	testWalletA = uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	testWalletB = uuid.MustParse("22222222-2222-2222-2222-222222222222")
+)
+
+type MockStore struct {
+	LockEventRelayFunc        func(ctx context.Context) (bool, error)
+	PendingEventsFunc         func(ctx context.Context, now time.Time, limit int) ([]model.WalletEvent, error)
+	MarkEventsDeliveredFunc   func(ctx context.Context, ids []int64) error
+	RetryEventFunc            func(ctx context.Context, id int64, at time.Time, msg string) error
+	DeleteDeliveredEventsFunc func(ctx context.Context, deliveredBefore time.Time) (int64, error)
+}
+
+func (m *MockStore) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func (m *MockStore) LockEventRelay(ctx context.Context) (bool, error) {
+	if m.LockEventRelayFunc != nil {
+		return m.LockEventRelayFunc(ctx)
+	}
+	return true, nil
+}
+
+func (m *MockStore) PendingEvents(ctx context.Context, now time.Time, limit int) ([]model.WalletEvent, error) {
+	if m.PendingEventsFunc != nil {
+		return m.PendingEventsFunc(ctx, now, limit)
+	}
+	return nil, nil
+}
+
+func (m *MockStore) MarkEventsDelivered(ctx context.Context, ids []int64) error {
+	if m.MarkEventsDeliveredFunc != nil {
+		return m.MarkEventsDeliveredFunc(ctx, ids)
+	}
+	return nil
+}
+
+func (m *MockStore) RetryEvent(ctx context.Context, id int64, at time.Time, msg string) error {
+	if m.RetryEventFunc != nil {
+		return m.RetryEventFunc(ctx, id, at, msg)
+	}
+	return nil
+}
+
+func (m *MockStore) DeleteDeliveredEvents(ctx context.Context, deliveredBefore time.Time) (int64, error) {
+	if m.DeleteDeliveredEventsFunc != nil {
+		return m.DeleteDeliveredEventsFunc(ctx, deliveredBefore)
+	}
+	return 0, nil
+}
+
+type sinkFunc func(ctx context.Context, event model.WalletEvent) error
+
+func (f sinkFunc) Deliver(ctx context.Context, event model.WalletEvent) error {
+	return f(ctx, event)
+}
+
+func pending(events ...model.WalletEvent) func(ctx context.Context, now time.Time, limit int) ([]model.WalletEvent, error) {
+	return func(ctx context.Context, now time.Time, limit int) ([]model.WalletEvent, error) {
+		return events, nil
+	}
+}
+
+func TestRelayBatch_DeliversAndMarks(t *testing.T) {
+	var marked []int64
+	store := &MockStore{
+		PendingEventsFunc: pending(
+			model.WalletEvent{ID: 1, WalletID: testWalletA, Sequence: 1},
+			model.WalletEvent{ID: 2, WalletID: testWalletB, Sequence: 1},
+			model.WalletEvent{ID: 3, WalletID: testWalletA, Sequence: 2},
+		),
+		MarkEventsDeliveredFunc: func(ctx context.Context, ids []int64) error {
+			marked = ids
+			return nil
+		},
+	}
+
+	var seen []string
+	relay := New(store, sinkFunc(func(ctx context.Context, event model.WalletEvent) error {
+		seen = append(seen, fmt.Sprintf("%d", event.ID))
+		return nil
+	}))
+
+	n, err := relay.RelayBatch(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if n != 3 || fmt.Sprint(seen) != "[1 2 3]" || fmt.Sprint(marked) != "[1 2 3]" {
+		t.Errorf("handled %d, delivered %v, marked %v", n, seen, marked)
+	}
+}
+
+func TestRelayBatch_FailureHoldsBackWallet(t *testing.T) {
+	var marked []int64
+	var retried int64
+	var retryAt time.Time
+	var retryMsg string
+
+	store := &MockStore{
+		PendingEventsFunc: pending(
+			model.WalletEvent{ID: 1, WalletID: testWalletA, Sequence: 1, Attempts: 2},
+			model.WalletEvent{ID: 2, WalletID: testWalletB, Sequence: 1},
+			model.WalletEvent{ID: 3, WalletID: testWalletA, Sequence: 2},
+		),
+		MarkEventsDeliveredFunc: func(ctx context.Context, ids []int64) error {
+			marked = ids
+			return nil
+		},
+		RetryEventFunc: func(ctx context.Context, id int64, at time.Time, msg string) error {
+			retried, retryAt, retryMsg = id, at, msg
+			return nil
+		},
+	}
+
+	relay := New(store, sinkFunc(func(ctx context.Context, event model.WalletEvent) error {
+		if event.ID == 1 {
+			return errors.New("sink unavailable")
+		}
+		return nil
+	}), WithRetryDelay(time.Second, time.Minute))
+
+	start := time.Now()
+	if _, err := relay.RelayBatch(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Event 3 must not overtake event 1 of the same wallet.
+	if fmt.Sprint(marked) != "[2]" {
+		t.Errorf("expected only event 2 delivered, got %v", marked)
+	}
+
+	if retried != 1 || retryMsg != "sink unavailable" {
+		t.Errorf("unexpected retry of event %d: %q", retried, retryMsg)
+	}
+	if d := retryAt.Sub(start); d < 4*time.Second || d > 5*time.Second {
+		t.Errorf("expected a 4s backoff after two attempts, got %v", d)
+	}
+}
+
+func TestRelayBatch_AnotherRelayActive(t *testing.T) {
+	store := &MockStore{
+		LockEventRelayFunc: func(ctx context.Context) (bool, error) {
+			return false, nil
+		},
+		PendingEventsFunc: func(ctx context.Context, now time.Time, limit int) ([]model.WalletEvent, error) {
+			t.Fatal("events read without the relay lock")
+			return nil, nil
+		},
+	}
+
+	n, err := New(store, NewLogSink(nil)).RelayBatch(context.Background())
+	if err != nil || n != 0 {
+		t.Errorf("expected nothing relayed, got %d, %v", n, err)
+	}
+}
+
+func TestRelay_Backoff(t *testing.T) {
+	relay := New(&MockStore{}, nil, WithRetryDelay(time.Second, time.Minute))
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, time.Second},
+		{1, 2 * time.Second},
+		{5, 32 * time.Second},
+		{6, time.Minute},
+		{100, time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := relay.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package outbox
+
+import "context"
+
+// SequenceStore numbers committed events, giving each wallet's events
+// their sequences; SequenceEvents returns how many it numbered.
+type SequenceStore interface {
+	SequenceEvents(ctx context.Context, limit int) (int, error)
+}
+
+// Sequence numbers events in batches of batchSize until none is left.
+func Sequence(ctx context.Context, store SequenceStore, batchSize int) error {
+	for {
+		n, err := store.SequenceEvents(ctx, batchSize)
+		if err != nil || n < batchSize {
+			return err
+		}
+	}
+}
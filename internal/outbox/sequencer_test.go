@@ -0,0 +1,48 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type sequenceFunc func(ctx context.Context, limit int) (int, error)
+
+func (f sequenceFunc) SequenceEvents(ctx context.Context, limit int) (int, error) {
+	return f(ctx, limit)
+}
+
+func TestSequence_UntilShortBatch(t *testing.T) {
+	batches := []int{10, 10, 3}
+	var calls int
+
+	err := Sequence(context.Background(), sequenceFunc(func(ctx context.Context, limit int) (int, error) {
+		if limit != 10 {
+			t.Errorf("expected a limit of 10, got %d", limit)
+		}
+		n := batches[calls]
+		calls++
+		return n, nil
+	}), 10)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 batches, got %d", calls)
+	}
+}
+
+func TestSequence_Error(t *testing.T) {
+	boom := errors.New("boom")
+	var calls int
+
+	err := Sequence(context.Background(), sequenceFunc(func(ctx context.Context, limit int) (int, error) {
+		calls++
+		return 0, boom
+	}), 10)
+
+	if !errors.Is(err, boom) || calls != 1 {
+		t.Errorf("expected to stop on the error, got %v after %d calls", err, calls)
+	}
+}
@@ -0,0 +1,83 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+// Message is the JSON form of an event handed to sinks.
+type Message struct {
+	EventID      int64     `json:"eventId"`
+	WalletID     string    `json:"walletId"`
+	Sequence     int64     `json:"sequence"`
+	Type         string    `json:"type"`
+	Currency     string    `json:"currency"`
+	Amount       int64     `json:"amount"`
+	BalanceAfter int64     `json:"balanceAfter"`
+	Reference    string    `json:"reference,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+func NewMessage(event model.WalletEvent) Message {
+	return Message{
+		EventID:      event.ID,
+		WalletID:     event.WalletID.String(),
+		Sequence:     event.Sequence,
+		Type:         event.Type,
+		Currency:     event.Currency,
+		Amount:       event.Amount,
+		BalanceAfter: event.BalanceAfter,
+		Reference:    event.Reference,
+		CreatedAt:    event.CreatedAt,
+	}
+}
+
+func Encode(event model.WalletEvent) ([]byte, error) {
+	return json.Marshal(NewMessage(event))
+}
+
+type logSink struct {
+	logger *log.Logger
+}
+
+// NewLogSink writes every event to w as a JSON line.
+func NewLogSink(w io.Writer) Sink {
+	return &logSink{logger: log.New(w, "wallet event ", log.LstdFlags)}
+}
+
+func (s *logSink) Deliver(ctx context.Context, event model.WalletEvent) error {
+	msg, err := Encode(event)
+	if err != nil {
+		return err
+	}
+
+	s.logger.Println(string(msg))
+	return nil
+}
+
+type fanout []Sink
+
+// Fanout delivers each event to every sink. An event that fails on one
+// sink is redelivered to all of them.
+func Fanout(sinks ...Sink) Sink {
+	if len(sinks) == 1 {
+		return sinks[0]
+	}
+	return fanout(sinks)
+}
+
+func (f fanout) Deliver(ctx context.Context, event model.WalletEvent) error {
+	var errs []error
+	for _, sink := range f {
+		if err := sink.Deliver(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,60 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+func TestLogSink_WritesJSON(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewLogSink(&buf)
+
+	err := sink.Deliver(context.Background(), model.WalletEvent{
+		ID:           7,
+		WalletID:     testWalletA,
+		Sequence:     3,
+		Currency:     "USD",
+		Type:         model.OpDeposit,
+		Amount:       500,
+		BalanceAfter: 1500,
+		CreatedAt:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := `{"eventId":7,"walletId":"11111111-1111-1111-1111-111111111111","sequence":3,"type":"DEPOSIT","currency":"USD","amount":500,"balanceAfter":1500,"createdAt":"2024-01-01T00:00:00Z"}`
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("unexpected log line: %s", buf.String())
+	}
+}
+
+func TestFanout_DeliversToEverySink(t *testing.T) {
+	failed := errors.New("sink unavailable")
+	calls := 0
+
+	sink := Fanout(
+		sinkFunc(func(ctx context.Context, event model.WalletEvent) error {
+			calls++
+			return failed
+		}),
+		sinkFunc(func(ctx context.Context, event model.WalletEvent) error {
+			calls++
+			return nil
+		}),
+	)
+
+	err := sink.Deliver(context.Background(), model.WalletEvent{ID: 1})
+	if !errors.Is(err, failed) {
+		t.Errorf("expected the sink error, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected both sinks called, got %d", calls)
+	}
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// BenchmarkUpdateBalance compares row locks, compare-and-set and sharded
+// balance rows on a single hot wallet. Run with WALLET_TEST_DSN set, e.g.
+//
+//	WALLET_TEST_DSN="host=localhost user=postgres password=postgres dbname=wallets sslmode=disable" \
+//	    go test ./internal/repository -run '^$' -bench UpdateBalance -cpu 1,8,32
+func BenchmarkUpdateBalance(b *testing.B) {
+	db := testDB(b)
+
+	modes := []struct {
+		name string
+		repo func(db *sql.DB, walletID string) *WalletRepository
+	}{
+		{"lock", func(db *sql.DB, _ string) *WalletRepository { return New(db) }},
+		{"cas", func(db *sql.DB, _ string) *WalletRepository { return New(db, WithCompareAndSet(1000)) }},
+		{"sharded", func(db *sql.DB, walletID string) *WalletRepository {
+			return New(db, WithShardedWallets(16, walletID))
+		}},
+	}
+
+	for _, mode := range modes {
+		b.Run(mode.name, func(b *testing.B) {
+			ctx := context.Background()
+			walletID := uuid.NewString()
+			repo := mode.repo(db, walletID)
+
+			if err := repo.UpdateBalance(ctx, walletID, testCurrency, 1, 0); err != nil {
+				b.Fatal(err)
+			}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if err := repo.UpdateBalance(ctx, walletID, testCurrency, 1, 0); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
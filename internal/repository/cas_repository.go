@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+// errStaleVersion reports that another writer changed the row between our
+// read and our write.
+var errStaleVersion = errors.New("stale wallet version")
+
+// updateBalanceCAS is UpdateBalance without FOR UPDATE: the new balance is
+// written only if the row still has the version it was computed from.
+// Every statement in READ COMMITTED sees the latest committed data, so a
+// retry rereads the row even inside a caller's transaction.
+func (r *WalletRepository) updateBalanceCAS(
+	ctx context.Context,
+	walletID string,
+	currency string,
+	amount int64,
+	ifVersion int64,
+) error {
+
+	for attempt := 0; attempt < r.casRetries; attempt++ {
+		err := r.inTx(ctx, func(tx *sql.Tx) error {
+			return compareAndSetBalance(ctx, tx, walletID, currency, amount, ifVersion)
+		})
+		if err != errStaleVersion {
+			return err
+		}
+	}
+
+	return appErr.ErrConcurrentUpdate
+}
+
+func compareAndSetBalance(
+	ctx context.Context,
+	tx *sql.Tx,
+	walletID string,
+	currency string,
+	amount int64,
+	ifVersion int64,
+) error {
+
+	// FOR KEY SHARE does not hold back other writers, only FreezeWallet.
+	var wallet model.Wallet
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT `+walletColumns+` FROM wallets WHERE id = $1 AND currency = $2 FOR KEY SHARE`,
+		walletID,
+		currency,
+	).Scan(&wallet.ID, &wallet.Currency, &wallet.Balance, &wallet.Held, &wallet.Version)
+	missing := err == sql.ErrNoRows
+	if err != nil && !missing {
+		return err
+	}
+
+	if err := checkNotFrozen(ctx, tx, walletID); err != nil {
+		return err
+	}
+
+	if missing {
+		if ifVersion != 0 {
+			return appErr.ErrVersionMismatch
+		}
+		if amount < 0 {
+			return walletMissing(ctx, tx, walletID)
+		}
+
+		res, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO wallets (id, currency, balance) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
+			walletID,
+			currency,
+			amount,
+		)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			// A concurrent deposit opened the balance first.
+			return errStaleVersion
+		}
+
+		return recordOperation(ctx, tx, walletID, currency, amount, amount)
+	}
+
+	if ifVersion != 0 && wallet.Version != ifVersion {
+		return appErr.ErrVersionMismatch
+	}
+
+	newBalance := wallet.Balance + amount
+	if newBalance < wallet.Held {
+		return insufficientFunds(wallet, -amount)
+	}
+
+	res, err := tx.ExecContext(
+		ctx,
+		`UPDATE wallets SET balance = $1 WHERE id = $2 AND currency = $3 AND version = $4`,
+		newBalance,
+		walletID,
+		currency,
+		wallet.Version,
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errStaleVersion
+	}
+
+	return recordOperation(ctx, tx, walletID, currency, amount, newBalance)
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+const testCASWalletID = "550e8400-e29b-41d4-a716-446655440000"
+
+func expectReadWallet(mock sqlmock.Sqlmock, balance, version int64) {
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR KEY SHARE`).
+		WithArgs(testCASWalletID, testCurrency).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "currency", "balance", "held", "version"}).
+			AddRow(testCASWalletID, testCurrency, balance, int64(0), version))
+	expectNotFrozen(mock, testCASWalletID)
+}
+
+func expectCASUpdate(mock sqlmock.Sqlmock, balance, version, affected int64) {
+	mock.ExpectExec(`UPDATE wallets SET balance = \$1 WHERE id = \$2 AND currency = \$3 AND version = \$4`).
+		WithArgs(balance, testCASWalletID, testCurrency, version).
+		WillReturnResult(sqlmock.NewResult(0, affected))
+}
+
+func expectDepositRecorded(mock sqlmock.Sqlmock, amount, balance int64) {
+	expectJournal(mock, "DEPOSIT", 1,
+		model.JournalEntry{Account: "system:cash_in", Currency: testCurrency, Amount: amount},
+		model.JournalEntry{Account: "wallet:" + testCASWalletID, Currency: testCurrency, Amount: -amount},
+	)
+	mock.ExpectExec(`INSERT INTO transactions`).
+		WithArgs(int64(1), testCASWalletID, testCurrency, "DEPOSIT", amount, balance).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectEvent(mock, testCASWalletID, testCurrency, "DEPOSIT", amount, balance, "")
+}
+
+func TestUpdateBalanceCAS_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db, WithCompareAndSet(3))
+
+	mock.ExpectBegin()
+	expectReadWallet(mock, 1000, 4)
+	expectCASUpdate(mock, 1500, 4, 1)
+	expectDepositRecorded(mock, 500, 1500)
+	mock.ExpectCommit()
+
+	if err := repo.UpdateBalance(context.Background(), testCASWalletID, testCurrency, 500, 0); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestUpdateBalanceCAS_RetriesOnStaleVersion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db, WithCompareAndSet(3))
+
+	// Another deposit of 200 lands between the first read and write.
+	mock.ExpectBegin()
+	expectReadWallet(mock, 1000, 4)
+	expectCASUpdate(mock, 1500, 4, 0)
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	expectReadWallet(mock, 1200, 5)
+	expectCASUpdate(mock, 1700, 5, 1)
+	expectDepositRecorded(mock, 500, 1700)
+	mock.ExpectCommit()
+
+	if err := repo.UpdateBalance(context.Background(), testCASWalletID, testCurrency, 500, 0); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestUpdateBalanceCAS_GivesUp(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db, WithCompareAndSet(2))
+
+	for v := int64(4); v < 6; v++ {
+		mock.ExpectBegin()
+		expectReadWallet(mock, 1000, v)
+		expectCASUpdate(mock, 1500, v, 0)
+		mock.ExpectRollback()
+	}
+
+	err = repo.UpdateBalance(context.Background(), testCASWalletID, testCurrency, 500, 0)
+	if !errors.Is(err, appErr.ErrConcurrentUpdate) {
+		t.Errorf("expected ErrConcurrentUpdate, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestUpdateBalanceCAS_IfVersionMismatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db, WithCompareAndSet(3))
+
+	mock.ExpectBegin()
+	expectReadWallet(mock, 1000, 5)
+	mock.ExpectRollback()
+
+	err = repo.UpdateBalance(context.Background(), testCASWalletID, testCurrency, -100, 4)
+	if !errors.Is(err, appErr.ErrVersionMismatch) {
+		t.Errorf("expected ErrVersionMismatch, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestUpdateBalanceCAS_OpenBalanceRace(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db, WithCompareAndSet(3))
+
+	// A concurrent deposit opens the balance before our insert does.
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets`).
+		WillReturnError(sql.ErrNoRows)
+	expectNotFrozen(mock, testCASWalletID)
+	mock.ExpectExec(`INSERT INTO wallets \(id, currency, balance\) VALUES \(\$1, \$2, \$3\) ON CONFLICT DO NOTHING`).
+		WithArgs(testCASWalletID, testCurrency, int64(500)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	expectReadWallet(mock, 300, 1)
+	expectCASUpdate(mock, 800, 1, 1)
+	expectDepositRecorded(mock, 500, 800)
+	mock.ExpectCommit()
+
+	if err := repo.UpdateBalance(context.Background(), testCASWalletID, testCurrency, 500, 0); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
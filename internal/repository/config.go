@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Hlompy/Wallet/internal/config"
+
+	"github.com/google/uuid"
+)
+
+// NewFromConfig returns the repository with the options set in cfg:
+// TX_ISOLATION, TX_MAX_RETRIES, BALANCE_UPDATE_MODE and HOT_WALLETS. The
+// server and the command-line tools share it so that they update balances
+// the same way.
+func NewFromConfig(cfg *config.Config, db *sql.DB) (*WalletRepository, error) {
+	if cfg.TxMaxRetries < 0 {
+		return nil, errors.New("TX_MAX_RETRIES must not be negative")
+	}
+
+	opts := []Option{
+		WithRetry(cfg.TxMaxRetries, cfg.TxRetryBaseDelay),
+	}
+	switch cfg.TxIsolation {
+	case "read_committed":
+	case "serializable":
+		opts = append(opts, WithIsolation(sql.LevelSerializable))
+	default:
+		return nil, fmt.Errorf("unknown TX_ISOLATION: %s", cfg.TxIsolation)
+	}
+
+	switch cfg.BalanceUpdateMode {
+	case "lock":
+	case "cas":
+		if cfg.CASMaxRetries <= 0 {
+			return nil, errors.New("CAS_MAX_RETRIES must be positive")
+		}
+		// A reread inside a caller's serializable transaction sees the
+		// same snapshot, so compare-and-set could never catch up.
+		if cfg.TxIsolation != "read_committed" {
+			return nil, errors.New("BALANCE_UPDATE_MODE=cas needs TX_ISOLATION=read_committed")
+		}
+		opts = append(opts, WithCompareAndSet(cfg.CASMaxRetries))
+	default:
+		return nil, fmt.Errorf("unknown BALANCE_UPDATE_MODE: %s", cfg.BalanceUpdateMode)
+	}
+
+	if len(cfg.HotWallets) > 0 {
+		if cfg.HotWalletShards <= 0 {
+			return nil, errors.New("HOT_WALLET_SHARDS must be positive")
+		}
+
+		hot := make([]string, 0, len(cfg.HotWallets))
+		for _, id := range cfg.HotWallets {
+			walletID, err := uuid.Parse(id)
+			if err != nil {
+				return nil, fmt.Errorf("invalid wallet id in HOT_WALLETS: %s", id)
+			}
+			hot = append(hot, walletID.String())
+		}
+		opts = append(opts, WithShardedWallets(cfg.HotWalletShards, hot...))
+	}
+
+	return New(db, opts...), nil
+}
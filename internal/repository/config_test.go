@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/Hlompy/Wallet/internal/config"
+)
+
+func testConfig() *config.Config {
+	return &config.Config{
+		TxIsolation:       "read_committed",
+		TxMaxRetries:      3,
+		TxRetryBaseDelay:  time.Millisecond,
+		BalanceUpdateMode: "lock",
+		CASMaxRetries:     10,
+		HotWalletShards:   4,
+	}
+}
+
+func TestNewFromConfig(t *testing.T) {
+	cfg := testConfig()
+	cfg.TxIsolation = "serializable"
+	cfg.HotWallets = []string{"550E8400-E29B-41D4-A716-446655440000"}
+
+	r, err := NewFromConfig(cfg, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if r.isolation != sql.LevelSerializable || r.maxRetries != 3 || r.casRetries != 0 {
+		t.Errorf("options not applied: %+v", r)
+	}
+	if r.shards != 4 || !r.isSharded("550e8400-e29b-41d4-a716-446655440000") {
+		t.Errorf("expected the hot wallet sharded in canonical form, got %v", r.hotWallets)
+	}
+}
+
+func TestNewFromConfig_CompareAndSet(t *testing.T) {
+	cfg := testConfig()
+	cfg.BalanceUpdateMode = "cas"
+
+	r, err := NewFromConfig(cfg, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.casRetries != 10 || r.isolation != sql.LevelReadCommitted {
+		t.Errorf("options not applied: %+v", r)
+	}
+}
+
+func TestNewFromConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(cfg *config.Config)
+	}{
+		{"negative retries", func(cfg *config.Config) { cfg.TxMaxRetries = -1 }},
+		{"unknown isolation", func(cfg *config.Config) { cfg.TxIsolation = "snapshot" }},
+		{"unknown mode", func(cfg *config.Config) { cfg.BalanceUpdateMode = "optimistic" }},
+		{"cas without retries", func(cfg *config.Config) { cfg.BalanceUpdateMode, cfg.CASMaxRetries = "cas", 0 }},
+		{"serializable cas", func(cfg *config.Config) { cfg.BalanceUpdateMode, cfg.TxIsolation = "cas", "serializable" }},
+		{"invalid hot wallet", func(cfg *config.Config) { cfg.HotWallets = []string{"nope"} }},
+		{"no shards", func(cfg *config.Config) {
+			cfg.HotWallets = []string{"550e8400-e29b-41d4-a716-446655440000"}
+			cfg.HotWalletShards = 0
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			tt.edit(cfg)
+			if _, err := NewFromConfig(cfg, nil); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/Hlompy/Wallet/internal/repository/repotest"
+)
+
+// TestConformance runs the shared repository suite against Postgres, on
+// emptied tables for every test.
+func TestConformance(t *testing.T) {
+	db := testDB(t)
+
+	repotest.Run(t, func(t *testing.T) repotest.Repository {
+		_, err := db.Exec(`TRUNCATE wallets, wallet_shards, transactions, journals, journal_entries, holds,
+			exchange_rates, idempotency_keys, import_jobs, import_errors, wallet_event_sequences, wallet_events,
+			webhooks, webhook_deliveries, wallet_freezes RESTART IDENTITY CASCADE`)
+		if err != nil {
+			t.Fatalf("truncate tables: %v", err)
+		}
+		return New(db)
+	})
+}
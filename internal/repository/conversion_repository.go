@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+// Convert exchanges fromAmount of the wallet's fromCurrency balance for
+// toAmount of its toCurrency balance. The target balance is opened when
+// the wallet does not hold that currency yet. The amounts are computed by
+// the caller; rate is only recorded on both ledger legs.
+func (r *WalletRepository) Convert(
+	ctx context.Context,
+	walletID string,
+	fromCurrency string,
+	toCurrency string,
+	fromAmount int64,
+	toAmount int64,
+	rate string,
+) (model.ConversionResult, error) {
+
+	result := model.ConversionResult{
+		WalletID:     walletID,
+		FromCurrency: fromCurrency,
+		ToCurrency:   toCurrency,
+		FromAmount:   fromAmount,
+		ToAmount:     toAmount,
+		Rate:         rate,
+	}
+
+	walletID = canonicalID(walletID)
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		// Both rows are locked in currency order, the same order any
+		// concurrent conversion on this wallet uses.
+		lockOrder := []string{fromCurrency, toCurrency}
+		if toCurrency < fromCurrency {
+			lockOrder = []string{toCurrency, fromCurrency}
+		}
+
+		wallets := make(map[string]model.Wallet, 2)
+		for _, currency := range lockOrder {
+			wallet, err := r.lockWallet(ctx, tx, walletID, currency)
+			if err == sql.ErrNoRows {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			wallets[currency] = wallet
+		}
+
+		if err := checkNotFrozen(ctx, tx, walletID); err != nil {
+			return err
+		}
+
+		from, ok := wallets[fromCurrency]
+		if !ok {
+			return walletMissing(ctx, tx, walletID)
+		}
+
+		result.FromBalance = from.Balance - fromAmount
+		if result.FromBalance < from.Held {
+			return insufficientFunds(from, fromAmount)
+		}
+
+		to, ok := wallets[toCurrency]
+		if !ok {
+			// A brand-new row cannot take part in a lock cycle. The upsert
+			// covers a deposit opening the same balance concurrently.
+			_, err := tx.ExecContext(
+				ctx,
+				`INSERT INTO wallets (id, currency, balance) VALUES ($1, $2, 0) ON CONFLICT DO NOTHING`,
+				walletID,
+				toCurrency,
+			)
+			if err != nil {
+				return err
+			}
+
+			to, err = r.lockWallet(ctx, tx, walletID, toCurrency)
+			if err != nil {
+				return err
+			}
+		}
+		result.ToBalance = to.Balance + toAmount
+
+		account := model.WalletAccount(walletID)
+		journalID, err := postJournal(ctx, tx, model.OpConvert, []model.JournalEntry{
+			{Account: account, Currency: fromCurrency, Amount: fromAmount},
+			{Account: model.AccountFX, Currency: fromCurrency, Amount: -fromAmount},
+			{Account: model.AccountFX, Currency: toCurrency, Amount: toAmount},
+			{Account: account, Currency: toCurrency, Amount: -toAmount},
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, leg := range []struct {
+			currency string
+			op       string
+			amount   int64
+			balance  int64
+		}{
+			{fromCurrency, model.OpConvertOut, -fromAmount, result.FromBalance},
+			{toCurrency, model.OpConvertIn, toAmount, result.ToBalance},
+		} {
+			_, err := tx.ExecContext(
+				ctx,
+				`UPDATE wallets SET balance = $1 WHERE id = $2 AND currency = $3`,
+				leg.balance,
+				walletID,
+				leg.currency,
+			)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(
+				ctx,
+				`INSERT INTO transactions (journal_id, wallet_id, currency, type, amount, balance_after, rate) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+				journalID,
+				walletID,
+				leg.currency,
+				leg.op,
+				leg.amount,
+				leg.balance,
+				rate,
+			)
+			if err != nil {
+				return err
+			}
+
+			if err := recordEvent(ctx, tx, walletID, leg.currency, leg.op, leg.amount, leg.balance, ""); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return model.ConversionResult{}, err
+	}
+
+	return result, nil
+}
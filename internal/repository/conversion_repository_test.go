@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+const testConversionWalletID = "550e8400-e29b-41d4-a716-446655440000"
+
+func expectLockWallet(mock sqlmock.Sqlmock, currency string, balance, held int64) {
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+		WithArgs(testConversionWalletID, currency).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "currency", "balance", "held", "version"}).
+			AddRow(testConversionWalletID, currency, balance, held, testVersion))
+}
+
+func TestConvert_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	mock.ExpectBegin()
+	// EUR sorts before USD, so it is locked first.
+	expectLockWallet(mock, "EUR", 50, 0)
+	expectLockWallet(mock, "USD", 1000, 0)
+	expectNotFrozen(mock, testConversionWalletID)
+	expectJournal(mock, "CONVERT", 4,
+		model.JournalEntry{Account: "wallet:" + testConversionWalletID, Currency: "USD", Amount: 300},
+		model.JournalEntry{Account: "system:fx", Currency: "USD", Amount: -300},
+		model.JournalEntry{Account: "system:fx", Currency: "EUR", Amount: 270},
+		model.JournalEntry{Account: "wallet:" + testConversionWalletID, Currency: "EUR", Amount: -270},
+	)
+	mock.ExpectExec(`UPDATE wallets SET balance = \$1 WHERE id = \$2 AND currency = \$3`).
+		WithArgs(int64(700), testConversionWalletID, "USD").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO transactions \(journal_id, wallet_id, currency, type, amount, balance_after, rate\)`).
+		WithArgs(int64(4), testConversionWalletID, "USD", "CONVERT_OUT", int64(-300), int64(700), "0.9").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectEvent(mock, testConversionWalletID, "USD", "CONVERT_OUT", int64(-300), int64(700), "")
+	mock.ExpectExec(`UPDATE wallets SET balance = \$1 WHERE id = \$2 AND currency = \$3`).
+		WithArgs(int64(320), testConversionWalletID, "EUR").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO transactions \(journal_id, wallet_id, currency, type, amount, balance_after, rate\)`).
+		WithArgs(int64(4), testConversionWalletID, "EUR", "CONVERT_IN", int64(270), int64(320), "0.9").
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	expectEvent(mock, testConversionWalletID, "EUR", "CONVERT_IN", int64(270), int64(320), "")
+	mock.ExpectCommit()
+
+	res, err := repo.Convert(context.Background(), testConversionWalletID, "USD", "EUR", 300, 270, "0.9")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if res.FromBalance != 700 || res.ToBalance != 320 || res.Rate != "0.9" {
+		t.Errorf("unexpected result: %+v", res)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestConvert_OpensTargetBalance(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+		WithArgs(testConversionWalletID, "JPY").
+		WillReturnError(sql.ErrNoRows)
+	expectLockWallet(mock, "USD", 1000, 0)
+	expectNotFrozen(mock, testConversionWalletID)
+	mock.ExpectExec(`INSERT INTO wallets \(id, currency, balance\) VALUES \(\$1, \$2, 0\) ON CONFLICT DO NOTHING`).
+		WithArgs(testConversionWalletID, "JPY").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectLockWallet(mock, "JPY", 0, 0)
+	expectJournal(mock, "CONVERT", 5,
+		model.JournalEntry{Account: "wallet:" + testConversionWalletID, Currency: "USD", Amount: 100},
+		model.JournalEntry{Account: "system:fx", Currency: "USD", Amount: -100},
+		model.JournalEntry{Account: "system:fx", Currency: "JPY", Amount: 150},
+		model.JournalEntry{Account: "wallet:" + testConversionWalletID, Currency: "JPY", Amount: -150},
+	)
+	mock.ExpectExec(`UPDATE wallets`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO transactions`).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectEvent(mock)
+	mock.ExpectExec(`UPDATE wallets SET balance = \$1 WHERE id = \$2 AND currency = \$3`).
+		WithArgs(int64(150), testConversionWalletID, "JPY").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO transactions`).WillReturnResult(sqlmock.NewResult(2, 1))
+	expectEvent(mock)
+	mock.ExpectCommit()
+
+	res, err := repo.Convert(context.Background(), testConversionWalletID, "USD", "JPY", 100, 150, "150")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if res.FromBalance != 900 || res.ToBalance != 150 {
+		t.Errorf("unexpected result: %+v", res)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestConvert_RespectsHolds(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	mock.ExpectBegin()
+	expectLockWallet(mock, "EUR", 0, 0)
+	expectLockWallet(mock, "USD", 1000, 800)
+	expectNotFrozen(mock, testConversionWalletID)
+	mock.ExpectRollback()
+
+	_, err = repo.Convert(context.Background(), testConversionWalletID, "USD", "EUR", 300, 270, "0.9")
+	if !errors.Is(err, appErr.ErrInsufficientFunds) {
+		t.Errorf("expected ErrInsufficientFunds, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestConvert_SourceMissing(t *testing.T) {
+	tests := []struct {
+		name   string
+		exists bool
+		want   error
+	}{
+		{"unknown wallet", false, appErr.ErrWalletNotFound},
+		{"no balance in currency", true, appErr.ErrCurrencyMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to create mock: %v", err)
+			}
+			defer db.Close()
+
+			repo := New(db)
+
+			mock.ExpectBegin()
+			expectLockWallet(mock, "EUR", 0, 0)
+			mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+				WithArgs(testConversionWalletID, "USD").
+				WillReturnError(sql.ErrNoRows)
+			expectNotFrozen(mock, testConversionWalletID)
+			mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM wallets WHERE id = \$1\)`).
+				WithArgs(testConversionWalletID).
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(tt.exists))
+			mock.ExpectRollback()
+
+			_, err = repo.Convert(context.Background(), testConversionWalletID, "USD", "EUR", 300, 270, "0.9")
+			if err != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
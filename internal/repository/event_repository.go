@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Hlompy/Wallet/internal/db"
+	"github.com/Hlompy/Wallet/internal/model"
+	"github.com/lib/pq"
+)
+
+// EventChannel is the channel notified, with the wallet id as payload,
+// when events of the wallet are numbered.
+const EventChannel = "wallet_events"
+
+// UnsequencedEventChannel is the channel notified, with an empty payload,
+// when a transaction writing events commits.
+const UnsequencedEventChannel = "wallet_events_unsequenced"
+
+// recordEvent writes an outbox event, normally that of a ledger row;
+// reference links it to the other wallet of a transfer or to a hold, and
+// queues a notification on UnsequencedEventChannel, sent by Postgres on
+// commit. The event gets its sequence later, from SequenceEvents: numbering
+// it here would lock a per-wallet counter until commit, and so serialize
+// the writers of a hot wallet that its shards let run side by side.
+func recordEvent(
+	ctx context.Context,
+	tx *sql.Tx,
+	walletID string,
+	currency string,
+	eventType string,
+	amount int64,
+	balanceAfter int64,
+	reference string,
+) error {
+
+	_, err := tx.ExecContext(
+		ctx,
+		`WITH event AS (
+			INSERT INTO wallet_events (wallet_id, currency, type, amount, balance_after, reference)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id
+		)
+		SELECT pg_notify($7, '') FROM event`,
+		walletID,
+		currency,
+		eventType,
+		amount,
+		balanceAfter,
+		reference,
+		UnsequencedEventChannel,
+	)
+	return err
+}
+
+// SequenceEvents numbers up to limit committed events that have no
+// sequence yet and returns how many it numbered. Each wallet's events get
+// the next sequences of the wallet in the order they were written, and
+// its streams are notified on EventChannel when this commits. Only one
+// sequencer runs at a time: it returns 0 when another holds the lock.
+//
+// Events are numbered once they are visible, so a wallet's sequence
+// follows the order its events committed in, whatever order they took
+// their ids in.
+func (r *WalletRepository) SequenceEvents(ctx context.Context, limit int) (int, error) {
+	var n int
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		n = 0
+
+		var locked bool
+		err := tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock($1)`, db.EventSequencerLock).Scan(&locked)
+		if err != nil || !locked {
+			return err
+		}
+
+		rows, err := tx.QueryContext(
+			ctx,
+			`WITH batch AS (
+				SELECT id, wallet_id,
+					row_number() OVER (PARTITION BY wallet_id ORDER BY id) AS n,
+					count(*) OVER (PARTITION BY wallet_id) AS total
+				FROM (
+					SELECT id, wallet_id FROM wallet_events WHERE sequence IS NULL ORDER BY id LIMIT $1
+				) e
+			), last AS (
+				INSERT INTO wallet_event_sequences (wallet_id, sequence)
+				SELECT DISTINCT wallet_id, total FROM batch
+				ON CONFLICT (wallet_id) DO UPDATE SET sequence = wallet_event_sequences.sequence + excluded.sequence
+				RETURNING wallet_id, sequence
+			), numbered AS (
+				UPDATE wallet_events e SET sequence = last.sequence - batch.total + batch.n
+				FROM batch JOIN last ON last.wallet_id = batch.wallet_id
+				WHERE e.id = batch.id
+				RETURNING e.wallet_id
+			)
+			SELECT pg_notify($2, wallet_id::text) FROM numbered`,
+			limit,
+			EventChannel,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			n++
+		}
+		return rows.Err()
+	})
+
+	return n, err
+}
+
+// LockEventRelay takes the relay lock for the current transaction. It
+// returns false when another relay holds it.
+func (r *WalletRepository) LockEventRelay(ctx context.Context) (bool, error) {
+	var locked bool
+	err := r.conn(ctx).QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock($1)`, db.EventRelayLock).Scan(&locked)
+	return locked, err
+}
+
+// PendingEvents returns up to limit undelivered, numbered events in
+// sequence order per wallet, leaving out wallets whose oldest event waits
+// for a retry after now. Events are taken in id order, which is sequence
+// order except for an event numbered ahead of an older id; such an event
+// waits until the ones before it are delivered.
+func (r *WalletRepository) PendingEvents(ctx context.Context, now time.Time, limit int) ([]model.WalletEvent, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`SELECT id, wallet_id, sequence, currency, type, amount, balance_after, reference, attempts, created_at
+		FROM wallet_events e
+		WHERE delivered_at IS NULL AND sequence IS NOT NULL AND NOT EXISTS (
+			SELECT 1 FROM wallet_events b
+			WHERE b.wallet_id = e.wallet_id AND b.delivered_at IS NULL AND b.next_attempt_at > $1
+		) AND NOT EXISTS (
+			SELECT 1 FROM wallet_events b
+			WHERE b.wallet_id = e.wallet_id AND b.delivered_at IS NULL AND b.sequence < e.sequence AND b.id > e.id
+		)
+		ORDER BY id
+		LIMIT $2`,
+		now,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanEvents(rows)
+}
+
+// WalletEventsSince returns up to limit events of the wallet with a
+// sequence after the given one, in sequence order. Events deleted after
+// delivery are not returned, which leaves a gap before the first one.
+func (r *WalletRepository) WalletEventsSince(ctx context.Context, walletID string, after int64, limit int) ([]model.WalletEvent, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`SELECT id, wallet_id, sequence, currency, type, amount, balance_after, reference, attempts, created_at
+		FROM wallet_events
+		WHERE wallet_id = $1 AND sequence > $2
+		ORDER BY sequence
+		LIMIT $3`,
+		walletID,
+		after,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanEvents(rows)
+}
+
+// LastEventSequence returns the sequence of the wallet's latest event, 0
+// if it has none.
+func (r *WalletRepository) LastEventSequence(ctx context.Context, walletID string) (int64, error) {
+	var sequence int64
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		`SELECT sequence FROM wallet_event_sequences WHERE wallet_id = $1`,
+		walletID,
+	).Scan(&sequence)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+
+	return sequence, err
+}
+
+func (r *WalletRepository) MarkEventsDelivered(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		`UPDATE wallet_events SET delivered_at = now() WHERE id = ANY($1)`,
+		pq.Array(ids),
+	)
+	return err
+}
+
+// RetryEvent records a failed delivery; the event is due again at at.
+func (r *WalletRepository) RetryEvent(ctx context.Context, id int64, at time.Time, msg string) error {
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		`UPDATE wallet_events SET attempts = attempts + 1, next_attempt_at = $1, last_error = $2 WHERE id = $3`,
+		at,
+		msg,
+		id,
+	)
+	return err
+}
+
+func (r *WalletRepository) DeleteDeliveredEvents(ctx context.Context, deliveredBefore time.Time) (int64, error) {
+	res, err := r.conn(ctx).ExecContext(
+		ctx,
+		`DELETE FROM wallet_events WHERE delivered_at < $1`,
+		deliveredBefore,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func scanEvents(rows *sql.Rows) ([]model.WalletEvent, error) {
+	defer rows.Close()
+
+	var events []model.WalletEvent
+	for rows.Next() {
+		var e model.WalletEvent
+		err := rows.Scan(&e.ID, &e.WalletID, &e.Sequence, &e.Currency, &e.Type, &e.Amount, &e.BalanceAfter, &e.Reference, &e.Attempts, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
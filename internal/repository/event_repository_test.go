@@ -0,0 +1,284 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// expectEvent expects the outbox event written with a ledger row; args,
+// when given, are its wallet id, currency, type, amount, balance and
+// reference.
+func expectEvent(mock sqlmock.Sqlmock, args ...driver.Value) {
+	e := mock.ExpectExec(`INSERT INTO wallet_events \(wallet_id, currency, type, amount, balance_after, reference\).* pg_notify`)
+	if len(args) > 0 {
+		e.WithArgs(append(args, UnsequencedEventChannel)...)
+	}
+	e.WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+func TestSequenceEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT pg_try_advisory_xact_lock\(\$1\)`).
+		WithArgs(7_349_203).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(true))
+	mock.ExpectQuery(`WITH batch AS .* WHERE sequence IS NULL ORDER BY id LIMIT \$1 .* INSERT INTO wallet_event_sequences .* UPDATE wallet_events e SET sequence = .* pg_notify\(\$2, wallet_id::text\)`).
+		WithArgs(100, EventChannel).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_notify"}).AddRow("").AddRow("").AddRow(""))
+	mock.ExpectCommit()
+
+	n, err := repo.SequenceEvents(context.Background(), 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 events numbered, got %d", n)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestSequenceEvents_AnotherSequencerRuns(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT pg_try_advisory_xact_lock\(\$1\)`).
+		WithArgs(7_349_203).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(false))
+	mock.ExpectCommit()
+
+	n, err := repo.SequenceEvents(context.Background(), 100)
+	if err != nil || n != 0 {
+		t.Errorf("expected nothing numbered, got %d (%v)", n, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+// TestSequenceEvents_HotWalletShards writes events on two shards of a hot
+// wallet at once: the second must not wait for the first to commit, and
+// the sequences follow the order they committed in.
+func TestSequenceEvents_HotWalletShards(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	walletID := uuid.NewString()
+	repo := New(db, WithShardedWallets(2, walletID))
+
+	// The wallet row and both shards exist, so neither deposit inserts a
+	// row the other would wait on.
+	for shard := 0; shard < 2; shard++ {
+		err := repo.inTx(ctx, func(tx *sql.Tx) error {
+			return depositToShard(ctx, tx, walletID, testCurrency, shard, 1)
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	first, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer first.Rollback()
+	if err := depositToShard(ctx, first, walletID, testCurrency, 0, 100); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	err = repo.inTx(timeout, func(tx *sql.Tx) error {
+		return depositToShard(timeout, tx, walletID, testCurrency, 1, 200)
+	})
+	if err != nil {
+		t.Fatalf("the second shard waited for the first: %v", err)
+	}
+
+	if err := first.Commit(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for {
+		n, err := repo.SequenceEvents(ctx, 100)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if n == 0 {
+			break
+		}
+	}
+
+	events, err := repo.WalletEventsSince(ctx, walletID, 0, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(events) != 4 || events[2].Amount != 200 || events[3].Amount != 100 {
+		t.Fatalf("expected the events in commit order, got %+v", events)
+	}
+	for i, e := range events {
+		if e.Sequence != int64(i+1) {
+			t.Errorf("expected sequence %d, got %+v", i+1, e)
+		}
+	}
+	if seq, err := repo.LastEventSequence(ctx, walletID); err != nil || seq != 4 {
+		t.Errorf("expected sequence 4, got %d (%v)", seq, err)
+	}
+}
+
+func TestPendingEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT id, wallet_id, sequence, currency, type, amount, balance_after, reference, attempts, created_at\s+FROM wallet_events e\s+WHERE delivered_at IS NULL AND sequence IS NOT NULL AND NOT EXISTS .* b.sequence < e.sequence AND b.id > e.id`).
+		WithArgs(now, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "wallet_id", "sequence", "currency", "type", "amount", "balance_after", "reference", "attempts", "created_at"}).
+			AddRow(int64(11), testWalletID, int64(3), testCurrency, "DEPOSIT", int64(500), int64(1500), "", 0, now).
+			AddRow(int64(12), testWalletID, int64(4), testCurrency, "WITHDRAW", int64(-200), int64(1300), "", 2, now))
+
+	events, err := repo.PendingEvents(context.Background(), now, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(events) != 2 || events[0].Sequence != 3 || events[1].BalanceAfter != 1300 || events[1].Attempts != 2 {
+		t.Errorf("unexpected events: %+v", events)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestMarkEventsDelivered(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	mock.ExpectExec(`UPDATE wallet_events SET delivered_at = now\(\) WHERE id = ANY\(\$1\)`).
+		WithArgs(pq.Array([]int64{11, 12})).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	if err := repo.MarkEventsDelivered(context.Background(), []int64{11, 12}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Nothing delivered, nothing to update.
+	if err := repo.MarkEventsDelivered(context.Background(), nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestRetryEvent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+	at := time.Now().Add(time.Minute)
+
+	mock.ExpectExec(`UPDATE wallet_events SET attempts = attempts \+ 1, next_attempt_at = \$1, last_error = \$2 WHERE id = \$3`).
+		WithArgs(at, "sink unavailable", int64(11)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := repo.RetryEvent(context.Background(), 11, at, "sink unavailable"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestWalletEventsSince(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT id, wallet_id, sequence, .* FROM wallet_events\s+WHERE wallet_id = \$1 AND sequence > \$2\s+ORDER BY sequence`).
+		WithArgs(testWalletID, int64(3), 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "wallet_id", "sequence", "currency", "type", "amount", "balance_after", "reference", "attempts", "created_at"}).
+			AddRow(int64(12), testWalletID, int64(4), testCurrency, "WITHDRAW", int64(-200), int64(1300), "", 0, now))
+
+	events, err := repo.WalletEventsSince(context.Background(), testWalletID, 3, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(events) != 1 || events[0].Sequence != 4 || events[0].BalanceAfter != 1300 {
+		t.Errorf("unexpected events: %+v", events)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestLastEventSequence_NoEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	mock.ExpectQuery(`SELECT sequence FROM wallet_event_sequences WHERE wallet_id = \$1`).
+		WithArgs(testWalletID).
+		WillReturnRows(sqlmock.NewRows([]string{"sequence"}))
+
+	sequence, err := repo.LastEventSequence(context.Background(), testWalletID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sequence != 0 {
+		t.Errorf("expected 0, got %d", sequence)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
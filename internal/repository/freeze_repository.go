@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+const freezeColumns = `wallet_id, reason, frozen_at`
+
+// FreezeWallet freezes the wallet. Freezing a frozen wallet again only
+// replaces the reason.
+//
+// The freeze locks the wallet's balances first, shards before rows as
+// foldShards does. It so waits for the operations holding them, and the
+// operations after it, which lock a balance before checking for a freeze,
+// wait for it and then see it.
+func (r *WalletRepository) FreezeWallet(ctx context.Context, walletID, reason string) (model.WalletFreeze, error) {
+	var f model.WalletFreeze
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(
+			ctx,
+			`SELECT 1 FROM wallet_shards WHERE wallet_id = $1 ORDER BY currency, shard FOR UPDATE`,
+			walletID,
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `SELECT 1 FROM wallets WHERE id = $1 ORDER BY currency FOR UPDATE`, walletID)
+		if err != nil {
+			return err
+		}
+
+		return tx.QueryRowContext(
+			ctx,
+			`INSERT INTO wallet_freezes (wallet_id, reason) VALUES ($1, $2)
+			ON CONFLICT (wallet_id) DO UPDATE SET reason = EXCLUDED.reason
+			RETURNING `+freezeColumns,
+			walletID,
+			reason,
+		).Scan(&f.WalletID, &f.Reason, &f.FrozenAt)
+	})
+	if err != nil {
+		return model.WalletFreeze{}, err
+	}
+
+	return f, nil
+}
+
+// UnfreezeWallet lifts the wallet's freeze, if it has one.
+func (r *WalletRepository) UnfreezeWallet(ctx context.Context, walletID string) error {
+	_, err := r.conn(ctx).ExecContext(ctx, `DELETE FROM wallet_freezes WHERE wallet_id = $1`, walletID)
+	return err
+}
+
+// GetWalletFreeze returns the wallet's freeze, or nil when it is not
+// frozen.
+func (r *WalletRepository) GetWalletFreeze(ctx context.Context, walletID string) (*model.WalletFreeze, error) {
+	var f model.WalletFreeze
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		`SELECT `+freezeColumns+` FROM wallet_freezes WHERE wallet_id = $1`,
+		walletID,
+	).Scan(&f.WalletID, &f.Reason, &f.FrozenAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &f, nil
+}
+
+// checkNotFrozen refuses an operation on a frozen wallet. It runs once the
+// transaction has locked the balance it changes, see FreezeWallet, and
+// before it writes anything, so a caller sharing the transaction may carry
+// on after the error. The reason of the freeze is kept from the client.
+func checkNotFrozen(ctx context.Context, tx *sql.Tx, walletID string) error {
+	var frozen bool
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT EXISTS (SELECT 1 FROM wallet_freezes WHERE wallet_id = $1)`,
+		walletID,
+	).Scan(&frozen)
+	if err != nil {
+		return err
+	}
+
+	if frozen {
+		return appErr.ErrWalletFrozen.WithDetails(map[string]any{"walletId": walletID})
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestFreezeWallet(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+	frozenAt := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT 1 FROM wallet_shards WHERE wallet_id = \$1 ORDER BY currency, shard FOR UPDATE`).
+		WithArgs(testWalletID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SELECT 1 FROM wallets WHERE id = \$1 ORDER BY currency FOR UPDATE`).
+		WithArgs(testWalletID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery(`INSERT INTO wallet_freezes \(wallet_id, reason\) VALUES \(\$1, \$2\)\s+ON CONFLICT \(wallet_id\) DO UPDATE SET reason = EXCLUDED.reason\s+RETURNING wallet_id, reason, frozen_at`).
+		WithArgs(testWalletID, "chargeback").
+		WillReturnRows(sqlmock.NewRows([]string{"wallet_id", "reason", "frozen_at"}).
+			AddRow(testWalletID, "chargeback", frozenAt))
+	mock.ExpectCommit()
+
+	freeze, err := repo.FreezeWallet(context.Background(), testWalletID, "chargeback")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if freeze.WalletID.String() != testWalletID || freeze.Reason != "chargeback" || !freeze.FrozenAt.Equal(frozenAt) {
+		t.Errorf("unexpected freeze: %+v", freeze)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGetWalletFreeze_NotFrozen(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	mock.ExpectQuery(`SELECT wallet_id, reason, frozen_at FROM wallet_freezes WHERE wallet_id = \$1`).
+		WithArgs(testWalletID).
+		WillReturnRows(sqlmock.NewRows([]string{"wallet_id", "reason", "frozen_at"}))
+
+	freeze, err := repo.GetWalletFreeze(context.Background(), testWalletID)
+	if err != nil || freeze != nil {
+		t.Errorf("expected no freeze, got %+v (%v)", freeze, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestUnfreezeWallet(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	mock.ExpectExec(`DELETE FROM wallet_freezes WHERE wallet_id = \$1`).
+		WithArgs(testWalletID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := repo.UnfreezeWallet(context.Background(), testWalletID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func expectNotFrozen(mock sqlmock.Sqlmock, walletID string) {
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM wallet_freezes WHERE wallet_id = \$1\)`).
+		WithArgs(walletID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+}
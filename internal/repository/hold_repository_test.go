@@ -0,0 +1,285 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+const (
+	testHoldID   = "9b2f6a3c-1d4e-4f5a-8b6c-7d8e9f0a1b2c"
+	testWalletID = "550e8400-e29b-41d4-a716-446655440000"
+)
+
+func holdRow(amount, captured int64, status string, expiresAt time.Time) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "wallet_id", "currency", "amount", "captured", "status", "expires_at", "created_at"}).
+		AddRow(testHoldID, testWalletID, testCurrency, amount, captured, status, expiresAt, expiresAt.Add(-time.Hour))
+}
+
+func expectLockHold(mock sqlmock.Sqlmock, balance, held, amount int64, status string, expiresAt time.Time) {
+	mock.ExpectQuery(`SELECT wallet_id, currency FROM holds WHERE id = \$1`).
+		WithArgs(testHoldID).
+		WillReturnRows(sqlmock.NewRows([]string{"wallet_id", "currency"}).AddRow(testWalletID, testCurrency))
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+		WithArgs(testWalletID, testCurrency).
+		WillReturnRows(walletRow(testWalletID, balance, held))
+	mock.ExpectQuery(`SELECT id, wallet_id, currency, amount, captured, status, expires_at, created_at FROM holds WHERE id = \$1 FOR UPDATE`).
+		WithArgs(testHoldID).
+		WillReturnRows(holdRow(amount, 0, status, expiresAt))
+}
+
+func TestPlaceHold_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+	expiresAt := time.Now().Add(15 * time.Minute)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+		WithArgs(testWalletID, testCurrency).
+		WillReturnRows(walletRow(testWalletID, 1000, 200))
+	expectNotFrozen(mock, testWalletID)
+	mock.ExpectExec(`UPDATE wallets SET held = held \+ \$1 WHERE id = \$2 AND currency = \$3`).
+		WithArgs(int64(500), testWalletID, testCurrency).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO holds \(wallet_id, currency, amount, status, expires_at\)`).
+		WithArgs(testWalletID, testCurrency, int64(500), model.HoldActive, expiresAt).
+		WillReturnRows(holdRow(500, 0, model.HoldActive, expiresAt))
+	mock.ExpectCommit()
+
+	hold, err := repo.PlaceHold(context.Background(), testWalletID, testCurrency, 500, expiresAt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if hold.ID.String() != testHoldID || hold.Amount != 500 || hold.Status != model.HoldActive {
+		t.Errorf("unexpected hold: %+v", hold)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestPlaceHold_InsufficientAvailable(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+		WithArgs(testWalletID, testCurrency).
+		WillReturnRows(walletRow(testWalletID, 1000, 800))
+	expectNotFrozen(mock, testWalletID)
+	mock.ExpectRollback()
+
+	_, err = repo.PlaceHold(context.Background(), testWalletID, testCurrency, 500, time.Now().Add(time.Minute))
+	if !errors.Is(err, appErr.ErrInsufficientFunds) {
+		t.Errorf("expected ErrInsufficientFunds, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestCaptureHold_Partial(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+	now := time.Now()
+	expiresAt := now.Add(time.Minute)
+
+	mock.ExpectBegin()
+	expectLockHold(mock, 1000, 500, 500, model.HoldActive, expiresAt)
+	expectNotFrozen(mock, testWalletID)
+	mock.ExpectExec(`UPDATE wallets SET balance = \$1, held = held - \$2 WHERE id = \$3 AND currency = \$4`).
+		WithArgs(int64(700), int64(500), testWalletID, testCurrency).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectJournal(mock, model.OpCapture, 7,
+		model.JournalEntry{Account: model.WalletAccount(testWalletID), Currency: testCurrency, Amount: 300},
+		model.JournalEntry{Account: model.AccountCashOut, Currency: testCurrency, Amount: -300},
+	)
+	mock.ExpectExec(`INSERT INTO transactions`).
+		WithArgs(int64(7), testWalletID, testCurrency, model.OpCapture, int64(-300), int64(700)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectEvent(mock, testWalletID, testCurrency, model.OpCapture, int64(-300), int64(700), testHoldID)
+	mock.ExpectQuery(`UPDATE holds SET status = \$1, captured = \$2`).
+		WithArgs(model.HoldCaptured, int64(300), testHoldID).
+		WillReturnRows(holdRow(500, 300, model.HoldCaptured, expiresAt))
+	mock.ExpectCommit()
+
+	hold, err := repo.CaptureHold(context.Background(), testHoldID, 300, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if hold.Status != model.HoldCaptured || hold.Captured != 300 {
+		t.Errorf("unexpected hold: %+v", hold)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestCaptureHold_NotCapturable(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		status    string
+		expiresAt time.Time
+		amount    int64
+		wantErr   error
+	}{
+		{"expired", model.HoldActive, now.Add(-time.Second), 0, appErr.ErrHoldNotActive},
+		{"already released", model.HoldReleased, now.Add(time.Minute), 0, appErr.ErrHoldNotActive},
+		{"exceeds hold", model.HoldActive, now.Add(time.Minute), 600, appErr.ErrCaptureExceedsHold},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to create mock: %v", err)
+			}
+			defer db.Close()
+
+			repo := New(db)
+
+			mock.ExpectBegin()
+			expectLockHold(mock, 1000, 500, 500, tt.status, tt.expiresAt)
+			expectNotFrozen(mock, testWalletID)
+			mock.ExpectRollback()
+
+			_, err = repo.CaptureHold(context.Background(), testHoldID, tt.amount, now)
+			if err != tt.wantErr {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestReleaseHold_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+	expiresAt := time.Now().Add(time.Minute)
+
+	mock.ExpectBegin()
+	expectLockHold(mock, 1000, 500, 500, model.HoldActive, expiresAt)
+	mock.ExpectExec(`UPDATE wallets SET held = held - \$1 WHERE id = \$2 AND currency = \$3`).
+		WithArgs(int64(500), testWalletID, testCurrency).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`UPDATE holds SET status = \$1, captured = \$2`).
+		WithArgs(model.HoldReleased, int64(0), testHoldID).
+		WillReturnRows(holdRow(500, 0, model.HoldReleased, expiresAt))
+	mock.ExpectCommit()
+
+	hold, err := repo.ReleaseHold(context.Background(), testHoldID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if hold.Status != model.HoldReleased {
+		t.Errorf("expected status %s, got %s", model.HoldReleased, hold.Status)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestExpireHolds_SkipsInactive(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+	now := time.Now()
+	expiresAt := now.Add(-time.Minute)
+
+	mock.ExpectQuery(`SELECT id FROM holds WHERE status = \$1 AND expires_at <= \$2`).
+		WithArgs(model.HoldActive, now, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testHoldID).AddRow(testHoldID))
+
+	// First hold was captured between the scan and the lock.
+	mock.ExpectBegin()
+	expectLockHold(mock, 1000, 0, 500, model.HoldCaptured, expiresAt)
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	expectLockHold(mock, 1000, 500, 500, model.HoldActive, expiresAt)
+	mock.ExpectExec(`UPDATE wallets SET held = held - \$1 WHERE id = \$2 AND currency = \$3`).
+		WithArgs(int64(500), testWalletID, testCurrency).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEvent(mock, testWalletID, testCurrency, model.EventHoldExpired, int64(500), int64(1000), testHoldID)
+	mock.ExpectQuery(`UPDATE holds SET status = \$1, captured = \$2`).
+		WithArgs(model.HoldExpired, int64(0), testHoldID).
+		WillReturnRows(holdRow(500, 0, model.HoldExpired, expiresAt))
+	mock.ExpectCommit()
+
+	expired, err := repo.ExpireHolds(context.Background(), now, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(expired) != 1 || expired[0].Status != model.HoldExpired {
+		t.Errorf("expected one expired hold, got %+v", expired)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGetHold_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	mock.ExpectQuery(`SELECT id, wallet_id, currency, amount, captured, status, expires_at, created_at FROM holds WHERE id = \$1`).
+		WithArgs(testHoldID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "wallet_id", "currency", "amount", "captured", "status", "expires_at", "created_at"}))
+
+	_, err = repo.GetHold(context.Background(), testHoldID)
+	if err != appErr.ErrHoldNotFound {
+		t.Errorf("expected ErrHoldNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
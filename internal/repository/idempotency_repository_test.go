@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+func TestReserveIdempotencyKey_Fresh(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	expiredBefore := time.Now().Add(-time.Hour)
+
+	mock.ExpectExec(`DELETE FROM idempotency_keys WHERE key = \$1 AND created_at < \$2`).
+		WithArgs("key-1", expiredBefore).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO idempotency_keys \(key, request_hash\) VALUES \(\$1, \$2\) ON CONFLICT \(key\) DO NOTHING`).
+		WithArgs("key-1", "hash-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rec, err := repo.ReserveIdempotencyKey(context.Background(), "key-1", "hash-1", expiredBefore)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rec != nil {
+		t.Errorf("expected nil record for fresh key, got %+v", rec)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestReserveIdempotencyKey_Existing(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	mock.ExpectExec(`DELETE FROM idempotency_keys`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO idempotency_keys`).
+		WithArgs("key-1", "hash-2").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT request_hash, status_code, response FROM idempotency_keys WHERE key = \$1`).
+		WithArgs("key-1").
+		WillReturnRows(sqlmock.NewRows([]string{"request_hash", "status_code", "response"}).
+			AddRow("hash-1", 200, []byte(`{"balance":100}`)))
+
+	rec, err := repo.ReserveIdempotencyKey(context.Background(), "key-1", "hash-2", time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rec == nil {
+		t.Fatal("expected stored record")
+	}
+	if rec.RequestHash != "hash-1" || rec.StatusCode != 200 || string(rec.Response) != `{"balance":100}` {
+		t.Errorf("unexpected record: %+v", rec)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestIdempotentOperation_SharesTransaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	walletID := "550e8400-e29b-41d4-a716-446655440000"
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM idempotency_keys`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO idempotency_keys`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+		WithArgs(walletID, testCurrency).
+		WillReturnRows(walletRow(walletID, int64(100), 0))
+	expectNotFrozen(mock, walletID)
+	mock.ExpectExec(`UPDATE wallets SET balance = \$1 WHERE id = \$2 AND currency = \$3`).
+		WithArgs(int64(150), walletID, testCurrency).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectJournal(mock, "DEPOSIT", 3,
+		model.JournalEntry{Account: "system:cash_in", Currency: testCurrency, Amount: 50},
+		model.JournalEntry{Account: "wallet:" + walletID, Currency: testCurrency, Amount: -50},
+	)
+	mock.ExpectExec(`INSERT INTO transactions`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectEvent(mock)
+	mock.ExpectExec(`UPDATE idempotency_keys SET status_code = \$1, response = \$2 WHERE key = \$3`).
+		WithArgs(200, []byte("ok"), "key-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.WithinTx(context.Background(), func(ctx context.Context) error {
+		if _, err := repo.ReserveIdempotencyKey(ctx, "key-1", "hash-1", time.Now()); err != nil {
+			return err
+		}
+		if err := repo.UpdateBalance(ctx, walletID, testCurrency, 50, 0); err != nil {
+			return err
+		}
+		return repo.SaveIdempotencyResponse(ctx, "key-1", 200, []byte("ok"))
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestIdempotentOperation_RollbackOnError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM idempotency_keys`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO idempotency_keys`).
+		WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	err = repo.WithinTx(context.Background(), func(ctx context.Context) error {
+		_, err := repo.ReserveIdempotencyKey(ctx, "key-1", "hash-1", time.Now())
+		return err
+	})
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDeleteExpiredIdempotencyKeys(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	expiredBefore := time.Now().Add(-24 * time.Hour)
+
+	mock.ExpectExec(`DELETE FROM idempotency_keys WHERE created_at < \$1`).
+		WithArgs(expiredBefore).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+
+	n, err := repo.DeleteExpiredIdempotencyKeys(context.Background(), expiredBefore)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if n != 4 {
+		t.Errorf("expected 4 deleted keys, got %d", n)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+const testImportID = "3f1c2b7a-8e4d-4c5b-9a6f-0e1d2c3b4a59"
+
+func expectLockImport(mock sqlmock.Sqlmock, lastLine int) {
+	mock.ExpectQuery(`SELECT last_line FROM import_jobs WHERE id = \$1 FOR UPDATE`).
+		WithArgs(testImportID).
+		WillReturnRows(sqlmock.NewRows([]string{"last_line"}).AddRow(lastLine))
+}
+
+func TestCreateImport(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+	now := time.Now()
+
+	mock.ExpectQuery(`INSERT INTO import_jobs \(format, data, status\) VALUES \(\$1, \$2, \$3\)`).
+		WithArgs(model.ImportCSV, []byte("walletId,operationType,amount\n"), model.ImportPending).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "format", "status", "last_line", "succeeded", "failed", "error", "created_at", "updated_at"}).
+			AddRow(testImportID, model.ImportCSV, model.ImportPending, 0, 0, 0, "", now, now))
+
+	job, err := repo.CreateImport(context.Background(), model.ImportCSV, []byte("walletId,operationType,amount\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if job.ID.String() != testImportID || job.Status != model.ImportPending {
+		t.Errorf("unexpected job: %+v", job)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdvanceImport_Succeeded(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	mock.ExpectBegin()
+	expectLockImport(mock, 4)
+	mock.ExpectExec(`UPDATE import_jobs SET last_line = \$1, succeeded = succeeded \+ 1, updated_at = now\(\) WHERE id = \$2`).
+		WithArgs(5, testImportID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	advanced, err := repo.AdvanceImport(context.Background(), testImportID, 5, nil)
+	if err != nil || !advanced {
+		t.Fatalf("expected the line to advance, got %v, %v", advanced, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdvanceImport_Failed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	mock.ExpectBegin()
+	expectLockImport(mock, 4)
+	mock.ExpectExec(`INSERT INTO import_errors \(job_id, line, code, detail\) VALUES \(\$1, \$2, \$3, \$4\)`).
+		WithArgs(testImportID, 5, appErr.CodeInsufficientFunds, "insufficient funds").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE import_jobs SET last_line = \$1, failed = failed \+ 1, updated_at = now\(\) WHERE id = \$2`).
+		WithArgs(5, testImportID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	advanced, err := repo.AdvanceImport(context.Background(), testImportID, 5, &model.ImportLineError{
+		Line:   5,
+		Code:   appErr.CodeInsufficientFunds,
+		Detail: "insufficient funds",
+	})
+	if err != nil || !advanced {
+		t.Fatalf("expected the line to advance, got %v, %v", advanced, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdvanceImport_LineAlreadyDone(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	mock.ExpectBegin()
+	expectLockImport(mock, 5)
+	mock.ExpectCommit()
+
+	advanced, err := repo.AdvanceImport(context.Background(), testImportID, 5, nil)
+	if err != nil || advanced {
+		t.Fatalf("expected the line to be skipped, got %v, %v", advanced, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGetImport_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	mock.ExpectQuery(`SELECT id, format, status, last_line, succeeded, failed, error, created_at, updated_at FROM import_jobs WHERE id = \$1`).
+		WithArgs(testImportID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err = repo.GetImport(context.Background(), testImportID)
+	if !errors.Is(err, appErr.ErrImportNotFound) {
+		t.Errorf("expected ErrImportNotFound, got %v", err)
+	}
+}
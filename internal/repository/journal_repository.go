@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+// postJournal records a balanced set of entries and returns the journal id.
+// The database re-checks the balance at commit, this check just fails
+// early with a domain error instead of a constraint violation.
+func postJournal(
+	ctx context.Context,
+	tx *sql.Tx,
+	journalType string,
+	entries []model.JournalEntry,
+) (int64, error) {
+
+	if len(entries) < 2 {
+		return 0, appErr.ErrUnbalancedJournal
+	}
+
+	sums := make(map[string]int64)
+	for _, e := range entries {
+		if e.Amount == 0 || e.Currency == "" {
+			return 0, appErr.ErrUnbalancedJournal
+		}
+		sums[e.Currency] += e.Amount
+	}
+	for _, sum := range sums {
+		if sum != 0 {
+			return 0, appErr.ErrUnbalancedJournal
+		}
+	}
+
+	var journalID int64
+	err := tx.QueryRowContext(
+		ctx,
+		`INSERT INTO journals (type) VALUES ($1) RETURNING id`,
+		journalType,
+	).Scan(&journalID)
+	if err != nil {
+		return 0, err
+	}
+
+	values := make([]string, 0, len(entries))
+	args := make([]any, 0, len(entries)*4)
+	for _, e := range entries {
+		n := len(args)
+		values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4))
+		args = append(args, journalID, e.Account, e.Currency, e.Amount)
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO journal_entries (journal_id, account, currency, amount) VALUES `+strings.Join(values, ", "),
+		args...,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return journalID, nil
+}
+
+// TrialBalance returns the debits and credits of each system account, and
+// of all wallet accounts together as model.AccountWallets, per currency.
+func (r *WalletRepository) TrialBalance(ctx context.Context) ([]model.AccountBalance, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`SELECT CASE WHEN account LIKE 'wallet:%' THEN $1 ELSE account END AS acct, currency,
+			COALESCE(SUM(amount) FILTER (WHERE amount > 0), 0),
+			COALESCE(-SUM(amount) FILTER (WHERE amount < 0), 0)
+		FROM journal_entries GROUP BY acct, currency ORDER BY acct, currency`,
+		model.AccountWallets,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var balances []model.AccountBalance
+	for rows.Next() {
+		var b model.AccountBalance
+		if err := rows.Scan(&b.Account, &b.Currency, &b.Debit, &b.Credit); err != nil {
+			return nil, err
+		}
+		balances = append(balances, b)
+	}
+
+	return balances, rows.Err()
+}
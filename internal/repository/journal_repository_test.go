@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+func expectJournal(mock sqlmock.Sqlmock, journalType string, journalID int64, entries ...model.JournalEntry) {
+	mock.ExpectQuery(`INSERT INTO journals \(type\) VALUES \(\$1\) RETURNING id`).
+		WithArgs(journalType).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(journalID))
+
+	args := make([]driver.Value, 0, len(entries)*4)
+	for _, e := range entries {
+		args = append(args, journalID, e.Account, e.Currency, e.Amount)
+	}
+
+	mock.ExpectExec(`INSERT INTO journal_entries \(journal_id, account, currency, amount\) VALUES`).
+		WithArgs(args...).
+		WillReturnResult(sqlmock.NewResult(0, int64(len(entries))))
+}
+
+func TestPostJournal_Unbalanced(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		name    string
+		entries []model.JournalEntry
+	}{
+		{"does not sum to zero", []model.JournalEntry{{Account: "system:cash_in", Currency: testCurrency, Amount: 100}, {Account: "wallet:a", Currency: testCurrency, Amount: -90}}},
+		{"single entry", []model.JournalEntry{{Account: "system:cash_in", Currency: testCurrency, Amount: 0}}},
+		{"zero amount leg", []model.JournalEntry{{Account: "system:cash_in", Currency: testCurrency, Amount: 0}, {Account: "wallet:a", Currency: testCurrency, Amount: 0}}},
+		{"offset across currencies", []model.JournalEntry{{Account: "system:cash_in", Currency: "USD", Amount: 100}, {Account: "wallet:a", Currency: "EUR", Amount: -100}}},
+		{"missing currency", []model.JournalEntry{{Account: "system:cash_in", Amount: 100}, {Account: "wallet:a", Amount: -100}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock.ExpectBegin()
+			mock.ExpectRollback()
+
+			tx, err := db.Begin()
+			if err != nil {
+				t.Fatalf("begin: %v", err)
+			}
+
+			_, err = postJournal(context.Background(), tx, "DEPOSIT", tt.entries)
+			if err != appErr.ErrUnbalancedJournal {
+				t.Errorf("expected ErrUnbalancedJournal, got %v", err)
+			}
+
+			tx.Rollback()
+		})
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestPostJournal_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	entries := []model.JournalEntry{
+		{Account: "wallet:a", Currency: testCurrency, Amount: 250},
+		{Account: "system:cash_out", Currency: testCurrency, Amount: -200},
+		{Account: "system:fees", Currency: testCurrency, Amount: -50},
+	}
+
+	mock.ExpectBegin()
+	expectJournal(mock, "WITHDRAW", 11, entries...)
+	mock.ExpectCommit()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+
+	id, err := postJournal(context.Background(), tx, "WITHDRAW", entries)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id != 11 {
+		t.Errorf("expected journal id 11, got %d", id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestTrialBalance(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	mock.ExpectQuery(`SELECT CASE WHEN account LIKE 'wallet:%' THEN \$1 ELSE account END AS acct, currency,.+FROM journal_entries GROUP BY acct, currency ORDER BY acct, currency`).
+		WithArgs(model.AccountWallets).
+		WillReturnRows(sqlmock.NewRows([]string{"acct", "currency", "debit", "credit"}).
+			AddRow("system:cash_in", testCurrency, int64(1000), int64(0)).
+			AddRow("system:cash_out", testCurrency, int64(0), int64(300)).
+			AddRow(model.AccountWallets, testCurrency, int64(300), int64(1000)))
+
+	balances, err := repo.TrialBalance(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(balances) != 3 {
+		t.Fatalf("expected 3 accounts, got %d", len(balances))
+	}
+	if balances[2].Account != model.AccountWallets || balances[2].Currency != testCurrency || balances[2].Balance() != -700 {
+		t.Errorf("unexpected wallet balance: %+v", balances[2])
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestTrialBalance_QueryError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	mock.ExpectQuery(`FROM journal_entries`).WillReturnError(sql.ErrConnDone)
+
+	if _, err := repo.TrialBalance(context.Background()); err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+// Convert exchanges fromAmount of the wallet's fromCurrency balance for
+// toAmount of its toCurrency balance. The target balance is opened when
+// the wallet does not hold that currency yet. The amounts are computed by
+// the caller; rate is only recorded on both ledger legs.
+func (r *Repository) Convert(
+	ctx context.Context,
+	walletID string,
+	fromCurrency string,
+	toCurrency string,
+	fromAmount int64,
+	toAmount int64,
+	rate string,
+) (model.ConversionResult, error) {
+
+	id, err := parseID(walletID)
+	if err != nil {
+		return model.ConversionResult{}, err
+	}
+
+	result := model.ConversionResult{
+		WalletID:     walletID,
+		FromCurrency: fromCurrency,
+		ToCurrency:   toCurrency,
+		FromAmount:   fromAmount,
+		ToAmount:     toAmount,
+		Rate:         rate,
+	}
+
+	err = r.run(ctx, func(t *txn) error {
+		if err := r.checkNotFrozen(id); err != nil {
+			return err
+		}
+
+		from, ok := r.wallet(id, fromCurrency)
+		if !ok {
+			return r.walletMissing(id)
+		}
+
+		result.FromBalance = from.Balance - fromAmount
+		if result.FromBalance < from.Held {
+			return insufficientFunds(from, fromAmount)
+		}
+
+		to, ok := r.wallet(id, toCurrency)
+		if !ok {
+			to = model.Wallet{ID: id, Currency: toCurrency}
+			r.putWallet(t, to)
+		}
+		result.ToBalance = to.Balance + toAmount
+
+		account := model.WalletAccount(id.String())
+		err := r.postJournal(t, []model.JournalEntry{
+			{Account: account, Currency: fromCurrency, Amount: fromAmount},
+			{Account: model.AccountFX, Currency: fromCurrency, Amount: -fromAmount},
+			{Account: model.AccountFX, Currency: toCurrency, Amount: toAmount},
+			{Account: account, Currency: toCurrency, Amount: -toAmount},
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, leg := range []struct {
+			currency string
+			op       string
+			amount   int64
+			balance  int64
+		}{
+			{fromCurrency, model.OpConvertOut, -fromAmount, result.FromBalance},
+			{toCurrency, model.OpConvertIn, toAmount, result.ToBalance},
+		} {
+			wallet, _ := r.wallet(id, leg.currency)
+			wallet.Balance = leg.balance
+			r.putWallet(t, wallet)
+
+			r.insertTransaction(t, id, leg.currency, leg.op, leg.amount, leg.balance, rate, "")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return model.ConversionResult{}, err
+	}
+
+	return result, nil
+}
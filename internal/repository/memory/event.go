@@ -0,0 +1,199 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// event is an outbox row: the event and the state of its delivery.
+type event struct {
+	model.WalletEvent
+	deliveredAt   time.Time
+	nextAttemptAt time.Time
+	lastError     string
+}
+
+func (e event) delivered() bool {
+	return !e.deliveredAt.IsZero()
+}
+
+// recordEvent writes an outbox event, normally that of a ledger row;
+// reference links it to the other wallet of a transfer or to a hold. The
+// wallet is notified once the transaction commits.
+func (r *Repository) recordEvent(
+	t *txn,
+	id uuid.UUID,
+	currency string,
+	eventType string,
+	amount int64,
+	balanceAfter int64,
+	reference string,
+) {
+
+	sequence := r.sequences[id] + 1
+	set(t, r.sequences, id, sequence)
+
+	r.lastEventID++
+	now := time.Now()
+	set(t, r.events, r.lastEventID, event{
+		WalletEvent: model.WalletEvent{
+			ID:           r.lastEventID,
+			WalletID:     id,
+			Sequence:     sequence,
+			Currency:     currency,
+			Type:         eventType,
+			Amount:       amount,
+			BalanceAfter: balanceAfter,
+			Reference:    reference,
+			CreatedAt:    now,
+		},
+		nextAttemptAt: now,
+	})
+
+	t.notified = append(t.notified, id.String())
+}
+
+// SequenceEvents has nothing to number: transactions run one at a time,
+// so recordEvent numbers events as they are written without holding up
+// anyone.
+func (r *Repository) SequenceEvents(ctx context.Context, limit int) (int, error) {
+	return 0, nil
+}
+
+// LockEventRelay always succeeds: the relay calls it in WithinTx, which
+// already keeps every other relay waiting.
+func (r *Repository) LockEventRelay(ctx context.Context) (bool, error) {
+	return true, nil
+}
+
+// PendingEvents returns up to limit undelivered events in commit order
+// per wallet, leaving out wallets whose oldest event waits for a retry
+// after now.
+func (r *Repository) PendingEvents(ctx context.Context, now time.Time, limit int) ([]model.WalletEvent, error) {
+	var events []model.WalletEvent
+	err := r.run(ctx, func(t *txn) error {
+		waiting := make(map[uuid.UUID]bool)
+		for _, e := range r.events {
+			if !e.delivered() && e.nextAttemptAt.After(now) {
+				waiting[e.WalletID] = true
+			}
+		}
+
+		for _, e := range r.events {
+			if !e.delivered() && !waiting[e.WalletID] {
+				events = append(events, e.WalletEvent)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].ID < events[j].ID
+	})
+	return limited(events, limit), nil
+}
+
+// WalletEventsSince returns up to limit events of the wallet with a
+// sequence after the given one, in sequence order. Events deleted after
+// delivery are not returned, which leaves a gap before the first one.
+func (r *Repository) WalletEventsSince(ctx context.Context, walletID string, after int64, limit int) ([]model.WalletEvent, error) {
+	id, err := parseID(walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []model.WalletEvent
+	err = r.run(ctx, func(t *txn) error {
+		for _, e := range r.events {
+			if e.WalletID == id && e.Sequence > after {
+				events = append(events, e.WalletEvent)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Sequence < events[j].Sequence
+	})
+	return limited(events, limit), nil
+}
+
+// LastEventSequence returns the sequence of the wallet's latest event, 0
+// if it has none.
+func (r *Repository) LastEventSequence(ctx context.Context, walletID string) (int64, error) {
+	id, err := parseID(walletID)
+	if err != nil {
+		return 0, err
+	}
+
+	var sequence int64
+	err = r.run(ctx, func(t *txn) error {
+		sequence = r.sequences[id]
+		return nil
+	})
+
+	return sequence, err
+}
+
+func (r *Repository) MarkEventsDelivered(ctx context.Context, ids []int64) error {
+	return r.run(ctx, func(t *txn) error {
+		now := time.Now()
+		for _, id := range ids {
+			if e, ok := r.events[id]; ok {
+				e.deliveredAt = now
+				set(t, r.events, id, e)
+			}
+		}
+		return nil
+	})
+}
+
+// RetryEvent records a failed delivery; the event is due again at at.
+func (r *Repository) RetryEvent(ctx context.Context, id int64, at time.Time, msg string) error {
+	return r.run(ctx, func(t *txn) error {
+		if e, ok := r.events[id]; ok {
+			e.Attempts++
+			e.nextAttemptAt = at
+			e.lastError = msg
+			set(t, r.events, id, e)
+		}
+		return nil
+	})
+}
+
+func (r *Repository) DeleteDeliveredEvents(ctx context.Context, deliveredBefore time.Time) (int64, error) {
+	var n int64
+	err := r.run(ctx, func(t *txn) error {
+		for id, e := range r.events {
+			if e.delivered() && e.deliveredAt.Before(deliveredBefore) {
+				del(t, r.events, id)
+				n++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// limited cuts items down to at most limit, like LIMIT.
+func limited[T any](items []T, limit int) []T {
+	if limit >= 0 && len(items) > limit {
+		return items[:limit]
+	}
+	return items
+}
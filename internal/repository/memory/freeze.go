@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// FreezeWallet freezes the wallet. Freezing a frozen wallet again only
+// replaces the reason.
+func (r *Repository) FreezeWallet(ctx context.Context, walletID, reason string) (model.WalletFreeze, error) {
+	id, err := parseID(walletID)
+	if err != nil {
+		return model.WalletFreeze{}, err
+	}
+
+	var f model.WalletFreeze
+	err = r.run(ctx, func(t *txn) error {
+		var ok bool
+		if f, ok = r.freezes[id]; !ok {
+			f = model.WalletFreeze{WalletID: id, FrozenAt: time.Now()}
+		}
+		f.Reason = reason
+		set(t, r.freezes, id, f)
+		return nil
+	})
+	if err != nil {
+		return model.WalletFreeze{}, err
+	}
+
+	return f, nil
+}
+
+// UnfreezeWallet lifts the wallet's freeze, if it has one.
+func (r *Repository) UnfreezeWallet(ctx context.Context, walletID string) error {
+	id, err := parseID(walletID)
+	if err != nil {
+		return err
+	}
+
+	return r.run(ctx, func(t *txn) error {
+		del(t, r.freezes, id)
+		return nil
+	})
+}
+
+// GetWalletFreeze returns the wallet's freeze, or nil when it is not
+// frozen.
+func (r *Repository) GetWalletFreeze(ctx context.Context, walletID string) (*model.WalletFreeze, error) {
+	id, err := parseID(walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	var freeze *model.WalletFreeze
+	err = r.run(ctx, func(t *txn) error {
+		if f, ok := r.freezes[id]; ok {
+			freeze = &f
+		}
+		return nil
+	})
+
+	return freeze, err
+}
+
+// checkNotFrozen refuses an operation on a frozen wallet. The reason of
+// the freeze is kept from the client.
+func (r *Repository) checkNotFrozen(id uuid.UUID) error {
+	if _, ok := r.freezes[id]; ok {
+		return appErr.ErrWalletFrozen.WithDetails(map[string]any{"walletId": id.String()})
+	}
+	return nil
+}
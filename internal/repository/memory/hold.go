@@ -0,0 +1,236 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// PlaceHold reserves amount of the wallet's currency balance until
+// expiresAt. The balance is untouched; only the available balance shrinks.
+func (r *Repository) PlaceHold(
+	ctx context.Context,
+	walletID string,
+	currency string,
+	amount int64,
+	expiresAt time.Time,
+) (model.Hold, error) {
+
+	id, err := parseID(walletID)
+	if err != nil {
+		return model.Hold{}, err
+	}
+
+	var hold model.Hold
+	err = r.run(ctx, func(t *txn) error {
+		wallet, ok := r.wallet(id, currency)
+		if !ok {
+			return r.walletMissing(id)
+		}
+
+		if err := r.checkNotFrozen(id); err != nil {
+			return err
+		}
+
+		if wallet.Available() < amount {
+			return insufficientFunds(wallet, amount)
+		}
+
+		wallet.Held += amount
+		r.putWallet(t, wallet)
+
+		hold = model.Hold{
+			ID:        uuid.New(),
+			WalletID:  id,
+			Currency:  currency,
+			Amount:    amount,
+			Status:    model.HoldActive,
+			ExpiresAt: expiresAt,
+			CreatedAt: time.Now(),
+		}
+		set(t, r.holds, hold.ID, hold)
+		return nil
+	})
+	if err != nil {
+		return model.Hold{}, err
+	}
+
+	return hold, nil
+}
+
+// CaptureHold withdraws amount (the whole hold when amount is 0) from the
+// wallet and releases whatever part of the hold was not captured.
+func (r *Repository) CaptureHold(
+	ctx context.Context,
+	holdID string,
+	amount int64,
+	now time.Time,
+) (model.Hold, error) {
+
+	var hold model.Hold
+	err := r.run(ctx, func(t *txn) error {
+		wallet, h, err := r.lookupHold(holdID)
+		if err != nil {
+			return err
+		}
+
+		if err := r.checkNotFrozen(h.WalletID); err != nil {
+			return err
+		}
+
+		if h.Status != model.HoldActive || !h.ExpiresAt.After(now) {
+			return appErr.ErrHoldNotActive
+		}
+
+		if amount == 0 {
+			amount = h.Amount
+		}
+		if amount > h.Amount {
+			return appErr.ErrCaptureExceedsHold
+		}
+
+		walletID := wallet.ID.String()
+		wallet.Balance -= amount
+		wallet.Held -= h.Amount
+		r.putWallet(t, wallet)
+
+		err = r.postJournal(t, []model.JournalEntry{
+			{Account: model.WalletAccount(walletID), Currency: h.Currency, Amount: amount},
+			{Account: model.AccountCashOut, Currency: h.Currency, Amount: -amount},
+		})
+		if err != nil {
+			return err
+		}
+
+		r.insertTransaction(t, wallet.ID, h.Currency, model.OpCapture, -amount, wallet.Balance, "", holdID)
+
+		hold = r.finishHold(t, h, model.HoldCaptured, amount)
+		return nil
+	})
+	if err != nil {
+		return model.Hold{}, err
+	}
+
+	return hold, nil
+}
+
+func (r *Repository) ReleaseHold(ctx context.Context, holdID string) (model.Hold, error) {
+	var hold model.Hold
+	err := r.run(ctx, func(t *txn) error {
+		var err error
+		hold, err = r.releaseHold(t, holdID, model.HoldReleased)
+		return err
+	})
+	if err != nil {
+		return model.Hold{}, err
+	}
+
+	return hold, nil
+}
+
+// ExpireHolds releases up to limit active holds whose expiry is not after
+// now, each in its own transaction, and returns the holds it expired.
+// Holds captured or released concurrently are skipped.
+func (r *Repository) ExpireHolds(ctx context.Context, now time.Time, limit int) ([]model.Hold, error) {
+	var due []model.Hold
+	err := r.run(ctx, func(t *txn) error {
+		for _, h := range r.holds {
+			if h.Status == model.HoldActive && !h.ExpiresAt.After(now) {
+				due = append(due, h)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].ExpiresAt.Before(due[j].ExpiresAt)
+	})
+	due = limited(due, limit)
+
+	var expired []model.Hold
+	for _, h := range due {
+		var hold model.Hold
+		err := r.run(ctx, func(t *txn) error {
+			var err error
+			hold, err = r.releaseHold(t, h.ID.String(), model.HoldExpired)
+			return err
+		})
+		if errors.Is(err, appErr.ErrHoldNotActive) {
+			continue
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired = append(expired, hold)
+	}
+
+	return expired, nil
+}
+
+func (r *Repository) GetHold(ctx context.Context, holdID string) (model.Hold, error) {
+	var hold model.Hold
+	err := r.run(ctx, func(t *txn) error {
+		var err error
+		_, hold, err = r.lookupHold(holdID)
+		return err
+	})
+	if err != nil {
+		return model.Hold{}, err
+	}
+
+	return hold, nil
+}
+
+func (r *Repository) releaseHold(t *txn, holdID, status string) (model.Hold, error) {
+	wallet, h, err := r.lookupHold(holdID)
+	if err != nil {
+		return model.Hold{}, err
+	}
+
+	if h.Status != model.HoldActive {
+		return model.Hold{}, appErr.ErrHoldNotActive
+	}
+
+	wallet.Held -= h.Amount
+	r.putWallet(t, wallet)
+
+	// An expiry leaves the balance alone but still gets an event, for
+	// integrators to learn that the reserved amount is free again.
+	if status == model.HoldExpired {
+		r.recordEvent(t, wallet.ID, h.Currency, model.EventHoldExpired, h.Amount, wallet.Balance, holdID)
+	}
+
+	return r.finishHold(t, h, status, 0), nil
+}
+
+// lookupHold returns the hold with the balance it reserves.
+func (r *Repository) lookupHold(holdID string) (model.Wallet, model.Hold, error) {
+	id, err := parseID(holdID)
+	if err != nil {
+		return model.Wallet{}, model.Hold{}, err
+	}
+
+	h, ok := r.holds[id]
+	if !ok {
+		return model.Wallet{}, model.Hold{}, appErr.ErrHoldNotFound
+	}
+
+	wallet, _ := r.wallet(h.WalletID, h.Currency)
+	return wallet, h, nil
+}
+
+func (r *Repository) finishHold(t *txn, h model.Hold, status string, captured int64) model.Hold {
+	h.Status = status
+	h.Captured = captured
+	set(t, r.holds, h.ID, h)
+	return h
+}
@@ -0,0 +1,99 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+type idempotencyKey struct {
+	record    model.IdempotencyRecord
+	createdAt time.Time
+}
+
+// ReserveIdempotencyKey claims key for the current request. It returns nil
+// when the key is fresh and the caller should go on to execute the request,
+// or the previously stored record when the key has already been used.
+// Keys created before expiredBefore are discarded and treated as fresh.
+//
+// Run inside WithinTx together with the operation itself, it keeps a
+// concurrent request with the same key waiting until the first one
+// commits and its response is stored.
+func (r *Repository) ReserveIdempotencyKey(
+	ctx context.Context,
+	key string,
+	requestHash string,
+	expiredBefore time.Time,
+) (*model.IdempotencyRecord, error) {
+
+	var rec *model.IdempotencyRecord
+	err := r.run(ctx, func(t *txn) error {
+		k, ok := r.idempotency[key]
+		if ok && k.createdAt.Before(expiredBefore) {
+			del(t, r.idempotency, key)
+			ok = false
+		}
+
+		if !ok {
+			set(t, r.idempotency, key, idempotencyKey{
+				record:    model.IdempotencyRecord{Key: key, RequestHash: requestHash},
+				createdAt: time.Now(),
+			})
+			return nil
+		}
+
+		stored := k.record
+		stored.Response = bytes.Clone(stored.Response)
+		rec = &stored
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rec, nil
+}
+
+func (r *Repository) SaveIdempotencyResponse(
+	ctx context.Context,
+	key string,
+	statusCode int,
+	response []byte,
+) error {
+
+	return r.run(ctx, func(t *txn) error {
+		k, ok := r.idempotency[key]
+		if !ok {
+			return nil
+		}
+
+		k.record.StatusCode = statusCode
+		k.record.Response = bytes.Clone(response)
+		set(t, r.idempotency, key, k)
+		return nil
+	})
+}
+
+func (r *Repository) DeleteExpiredIdempotencyKeys(
+	ctx context.Context,
+	expiredBefore time.Time,
+) (int64, error) {
+
+	var n int64
+	err := r.run(ctx, func(t *txn) error {
+		for key, k := range r.idempotency {
+			if k.createdAt.Before(expiredBefore) {
+				del(t, r.idempotency, key)
+				n++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
@@ -0,0 +1,184 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+)
+
+type importJob struct {
+	model.ImportJob
+	data []byte
+}
+
+func (r *Repository) CreateImport(ctx context.Context, format string, data []byte) (model.ImportJob, error) {
+	now := time.Now()
+	job := importJob{
+		ImportJob: model.ImportJob{
+			ID:        uuid.New(),
+			Format:    format,
+			Status:    model.ImportPending,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		data: bytes.Clone(data),
+	}
+
+	err := r.run(ctx, func(t *txn) error {
+		set(t, r.imports, job.ID, job)
+		return nil
+	})
+	if err != nil {
+		return model.ImportJob{}, err
+	}
+
+	return job.ImportJob, nil
+}
+
+func (r *Repository) GetImport(ctx context.Context, id string) (model.ImportJob, error) {
+	var job importJob
+	err := r.run(ctx, func(t *txn) error {
+		var err error
+		job, err = r.lookupImport(id)
+		return err
+	})
+	if err != nil {
+		return model.ImportJob{}, err
+	}
+
+	return job.ImportJob, nil
+}
+
+func (r *Repository) ImportData(ctx context.Context, id string) ([]byte, error) {
+	var job importJob
+	err := r.run(ctx, func(t *txn) error {
+		var err error
+		job, err = r.lookupImport(id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.Clone(job.data), nil
+}
+
+// UnfinishedImports returns the jobs that are queued or were interrupted,
+// oldest first.
+func (r *Repository) UnfinishedImports(ctx context.Context) ([]model.ImportJob, error) {
+	var jobs []model.ImportJob
+	err := r.run(ctx, func(t *txn) error {
+		for _, job := range r.imports {
+			if job.Status == model.ImportPending || job.Status == model.ImportRunning {
+				jobs = append(jobs, job.ImportJob)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	return jobs, nil
+}
+
+// AdvanceImport marks line of the job as done, failed with lineErr when it
+// is not nil. It returns false when the line was already done.
+func (r *Repository) AdvanceImport(
+	ctx context.Context,
+	id string,
+	line int,
+	lineErr *model.ImportLineError,
+) (bool, error) {
+
+	var advanced bool
+	err := r.run(ctx, func(t *txn) error {
+		job, err := r.lookupImport(id)
+		if err != nil {
+			return err
+		}
+
+		advanced = job.LastLine < line
+		if !advanced {
+			return nil
+		}
+
+		job.LastLine = line
+		job.UpdatedAt = time.Now()
+		if lineErr == nil {
+			job.Succeeded++
+		} else {
+			job.Failed++
+			e := *lineErr
+			e.Line = line
+			set(t, r.importErrors, job.ID, append(r.importErrors[job.ID], e))
+		}
+
+		set(t, r.imports, job.ID, job)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return advanced, nil
+}
+
+// SetImportStatus moves the job to status; msg says why a job failed.
+func (r *Repository) SetImportStatus(ctx context.Context, id, status, msg string) error {
+	return r.run(ctx, func(t *txn) error {
+		job, err := r.lookupImport(id)
+		if errors.Is(err, appErr.ErrImportNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		job.Status = status
+		job.Error = msg
+		job.UpdatedAt = time.Now()
+		set(t, r.imports, job.ID, job)
+		return nil
+	})
+}
+
+func (r *Repository) ImportErrors(ctx context.Context, id string) ([]model.ImportLineError, error) {
+	jobID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var lineErrs []model.ImportLineError
+	err = r.run(ctx, func(t *txn) error {
+		// Lines advance in order, so the errors already are.
+		lineErrs = slices.Clone(r.importErrors[jobID])
+		return nil
+	})
+
+	return lineErrs, err
+}
+
+func (r *Repository) lookupImport(id string) (importJob, error) {
+	jobID, err := parseID(id)
+	if err != nil {
+		return importJob{}, err
+	}
+
+	job, ok := r.imports[jobID]
+	if !ok {
+		return importJob{}, appErr.ErrImportNotFound
+	}
+	return job, nil
+}
@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+// accountKey identifies a row of the trial balance.
+type accountKey struct {
+	account  string
+	currency string
+}
+
+// postJournal checks that the entries balance within every currency, as
+// the constraint trigger on journal_entries does, and adds them to their
+// accounts. Only the account totals are kept.
+func (r *Repository) postJournal(t *txn, entries []model.JournalEntry) error {
+	if len(entries) < 2 {
+		return appErr.ErrUnbalancedJournal
+	}
+
+	sums := make(map[string]int64)
+	for _, e := range entries {
+		if e.Amount == 0 || e.Currency == "" {
+			return appErr.ErrUnbalancedJournal
+		}
+		sums[e.Currency] += e.Amount
+	}
+	for _, sum := range sums {
+		if sum != 0 {
+			return appErr.ErrUnbalancedJournal
+		}
+	}
+
+	for _, e := range entries {
+		key := accountKey{e.Account, e.Currency}
+		b := r.accounts[key]
+		b.Account, b.Currency = e.Account, e.Currency
+		if e.Amount > 0 {
+			b.Debit += e.Amount
+		} else {
+			b.Credit -= e.Amount
+		}
+		set(t, r.accounts, key, b)
+	}
+
+	return nil
+}
+
+// TrialBalance returns the debits and credits of each system account, and
+// of all wallet accounts together as model.AccountWallets, per currency.
+func (r *Repository) TrialBalance(ctx context.Context) ([]model.AccountBalance, error) {
+	var balances []model.AccountBalance
+	err := r.run(ctx, func(t *txn) error {
+		wallets := make(map[string]int)
+		for _, b := range r.accounts {
+			if !model.IsWalletAccount(b.Account) {
+				balances = append(balances, b)
+				continue
+			}
+
+			i, ok := wallets[b.Currency]
+			if !ok {
+				i = len(balances)
+				wallets[b.Currency] = i
+				balances = append(balances, model.AccountBalance{Account: model.AccountWallets, Currency: b.Currency})
+			}
+			balances[i].Debit += b.Debit
+			balances[i].Credit += b.Credit
+		}
+		return nil
+	})
+
+	sort.Slice(balances, func(i, j int) bool {
+		if balances[i].Account != balances[j].Account {
+			return balances[i].Account < balances[j].Account
+		}
+		return balances[i].Currency < balances[j].Currency
+	})
+	return balances, err
+}
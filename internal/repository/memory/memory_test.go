@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Hlompy/Wallet/internal/model"
+	"github.com/Hlompy/Wallet/internal/repository/repotest"
+
+	"github.com/google/uuid"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repository {
+		return New()
+	})
+}
+
+func TestWithNotify_OnCommitOnly(t *testing.T) {
+	ctx := context.Background()
+	walletID := uuid.NewString()
+
+	var notified []string
+	repo := New(WithNotify(func(id string) { notified = append(notified, id) }))
+
+	err := repo.WithinTx(ctx, func(ctx context.Context) error {
+		if err := repo.UpdateBalance(ctx, walletID, "USD", 100, 0); err != nil {
+			return err
+		}
+		if len(notified) != 0 {
+			t.Errorf("expected no notification before commit, got %v", notified)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(notified) != 1 || notified[0] != walletID {
+		t.Errorf("expected %s notified once, got %v", walletID, notified)
+	}
+
+	notified = nil
+	_ = repo.WithinTx(ctx, func(ctx context.Context) error {
+		if err := repo.UpdateBalance(ctx, walletID, "USD", 100, 0); err != nil {
+			return err
+		}
+		return errors.New("boom")
+	})
+	if len(notified) != 0 {
+		t.Errorf("expected no notification after a rollback, got %v", notified)
+	}
+}
+
+func TestUpdateBalance_InvalidID(t *testing.T) {
+	if err := New().UpdateBalance(context.Background(), "not-a-uuid", "USD", 100, 0); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestTransfer_CanonicalAccounts(t *testing.T) {
+	ctx := context.Background()
+	repo := New()
+	from, to := uuid.New(), uuid.New()
+
+	for _, id := range []uuid.UUID{from, to} {
+		if err := repo.UpdateBalance(ctx, id.String(), "USD", 100, 0); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if _, err := repo.Transfer(ctx, "{"+from.String()+"}", strings.ToUpper(to.String()), "USD", 30); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for id, want := range map[uuid.UUID]int64{from: -70, to: -130} {
+		b := repo.accounts[accountKey{model.WalletAccount(id.String()), "USD"}]
+		if b.Balance() != want {
+			t.Errorf("expected account of %s at %d, got %+v", id, want, b)
+		}
+	}
+	if len(repo.accounts) != 3 {
+		t.Errorf("expected the cash-in account and one account per wallet, got %v", repo.accounts)
+	}
+}
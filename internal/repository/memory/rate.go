@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+// rateKey identifies a rate like the primary key of exchange_rates, to the
+// microsecond Postgres keeps.
+type rateKey struct {
+	base          string
+	quote         string
+	effectiveFrom int64
+}
+
+// SaveRates stores rates all at once. A rate for a pair and effective-from
+// time that already exists is overwritten.
+func (r *Repository) SaveRates(ctx context.Context, rates []model.ExchangeRate) error {
+	return r.run(ctx, func(t *txn) error {
+		for _, rate := range rates {
+			set(t, r.rates, rateKey{rate.Base, rate.Quote, rate.EffectiveFrom.UnixMicro()}, rate)
+		}
+		return nil
+	})
+}
+
+// GetRate returns the base/quote rate in effect at the given time.
+func (r *Repository) GetRate(ctx context.Context, base, quote string, at time.Time) (model.ExchangeRate, error) {
+	var rate model.ExchangeRate
+	found := false
+	err := r.run(ctx, func(t *txn) error {
+		for _, candidate := range r.rates {
+			if candidate.Base != base || candidate.Quote != quote || candidate.EffectiveFrom.After(at) {
+				continue
+			}
+			if !found || candidate.EffectiveFrom.After(rate.EffectiveFrom) {
+				rate, found = candidate, true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return model.ExchangeRate{}, err
+	}
+	if !found {
+		return model.ExchangeRate{}, appErr.ErrRateNotFound
+	}
+
+	return rate, nil
+}
+
+// ListRates returns, for every pair, the rate in effect at the given time.
+func (r *Repository) ListRates(ctx context.Context, at time.Time) ([]model.ExchangeRate, error) {
+	type pair struct{ base, quote string }
+
+	latest := make(map[pair]model.ExchangeRate)
+	err := r.run(ctx, func(t *txn) error {
+		for _, rate := range r.rates {
+			if rate.EffectiveFrom.After(at) {
+				continue
+			}
+			p := pair{rate.Base, rate.Quote}
+			if cur, ok := latest[p]; !ok || rate.EffectiveFrom.After(cur.EffectiveFrom) {
+				latest[p] = rate
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rates []model.ExchangeRate
+	for _, rate := range latest {
+		rates = append(rates, rate)
+	}
+	sort.Slice(rates, func(i, j int) bool {
+		if rates[i].Base != rates[j].Base {
+			return rates[i].Base < rates[j].Base
+		}
+		return rates[i].Quote < rates[j].Quote
+	})
+
+	return rates, nil
+}
@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// Transfer moves amount from one wallet to another in a single
+// transaction. Both wallets must hold currency; they are checked in
+// ascending id order, the order Postgres locks them in.
+func (r *Repository) Transfer(
+	ctx context.Context,
+	fromID string,
+	toID string,
+	currency string,
+	amount int64,
+) (model.TransferResult, error) {
+
+	from, err := parseID(fromID)
+	if err != nil {
+		return model.TransferResult{}, err
+	}
+	to, err := parseID(toID)
+	if err != nil {
+		return model.TransferResult{}, err
+	}
+
+	result := model.TransferResult{
+		FromWalletID: fromID,
+		ToWalletID:   toID,
+		Currency:     currency,
+	}
+
+	err = r.run(ctx, func(t *txn) error {
+		lockOrder := []uuid.UUID{from, to}
+		if toID < fromID {
+			lockOrder = []uuid.UUID{to, from}
+		}
+
+		wallets := make(map[uuid.UUID]model.Wallet, 2)
+		for _, id := range lockOrder {
+			wallet, ok := r.wallet(id, currency)
+			if !ok {
+				return r.walletMissing(id)
+			}
+			wallets[id] = wallet
+		}
+
+		for _, id := range []uuid.UUID{from, to} {
+			if err := r.checkNotFrozen(id); err != nil {
+				return err
+			}
+		}
+
+		result.FromBalance = wallets[from].Balance - amount
+		result.ToBalance = wallets[to].Balance + amount
+
+		if result.FromBalance < wallets[from].Held {
+			return insufficientFunds(wallets[from], amount)
+		}
+
+		err := r.postJournal(t, []model.JournalEntry{
+			{Account: model.WalletAccount(from.String()), Currency: currency, Amount: amount},
+			{Account: model.WalletAccount(to.String()), Currency: currency, Amount: -amount},
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, leg := range []struct {
+			id           uuid.UUID
+			counterparty string
+			op           string
+			amount       int64
+			balance      int64
+		}{
+			{from, toID, model.OpTransferOut, -amount, result.FromBalance},
+			{to, fromID, model.OpTransferIn, amount, result.ToBalance},
+		} {
+			wallet, _ := r.wallet(leg.id, currency)
+			wallet.Balance = leg.balance
+			r.putWallet(t, wallet)
+
+			r.insertTransaction(t, leg.id, currency, leg.op, leg.amount, leg.balance, "", leg.counterparty)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return model.TransferResult{}, err
+	}
+
+	return result, nil
+}
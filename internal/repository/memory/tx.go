@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"context"
+)
+
+type txKey struct{}
+
+// txn is a transaction of the repository: the changes to undo if it rolls
+// back, and the wallets to notify once it commits.
+type txn struct {
+	repo     *Repository
+	undo     []func()
+	notified []string
+}
+
+// WithinTx runs fn in a single transaction. Repository calls made with the
+// context passed to fn join that transaction instead of opening their own,
+// so several operations commit or roll back together. Other calls wait
+// until it ends.
+func (r *Repository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := r.joined(ctx); ok {
+		return fn(ctx)
+	}
+
+	return r.run(ctx, func(t *txn) error {
+		return fn(context.WithValue(ctx, txKey{}, t))
+	})
+}
+
+// run calls fn in the transaction carried by ctx or, failing that, in a
+// new one holding the repository lock. The changes of a failed call are
+// undone even within a larger transaction, so it can go on like after a
+// refused operation in Postgres.
+func (r *Repository) run(ctx context.Context, fn func(t *txn) error) error {
+	if t, ok := r.joined(ctx); ok {
+		mark := len(t.undo)
+		err := fn(t)
+		if err != nil {
+			t.rollback(mark)
+		}
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	t := &txn{repo: r}
+	if err := r.commit(t, fn); err != nil {
+		return err
+	}
+
+	if r.notify != nil {
+		for _, walletID := range t.notified {
+			r.notify(walletID)
+		}
+	}
+	return nil
+}
+
+func (r *Repository) commit(t *txn, fn func(t *txn) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	committed := false
+	defer func() {
+		if !committed {
+			t.rollback(0)
+		}
+	}()
+
+	if err := fn(t); err != nil {
+		return err
+	}
+
+	committed = true
+	return nil
+}
+
+func (r *Repository) joined(ctx context.Context) (*txn, bool) {
+	t, ok := ctx.Value(txKey{}).(*txn)
+	return t, ok && t.repo == r
+}
+
+// rollback undoes the changes made since the first mark of them.
+func (t *txn) rollback(mark int) {
+	for i := len(t.undo) - 1; i >= mark; i-- {
+		t.undo[i]()
+	}
+	t.undo = t.undo[:mark]
+}
+
+// set stores v under k, to be restored to the previous entry on rollback.
+func set[K comparable, V any](t *txn, m map[K]V, k K, v V) {
+	old, ok := m[k]
+	t.undo = append(t.undo, func() {
+		if ok {
+			m[k] = old
+		} else {
+			delete(m, k)
+		}
+	})
+	m[k] = v
+}
+
+// del deletes the entry under k, to be restored on rollback.
+func del[K comparable, V any](t *txn, m map[K]V, k K) {
+	old, ok := m[k]
+	if !ok {
+		return
+	}
+	t.undo = append(t.undo, func() { m[k] = old })
+	delete(m, k)
+}
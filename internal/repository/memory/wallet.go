@@ -0,0 +1,302 @@
+// Package memory keeps wallets in process memory, with the semantics of
+// the Postgres repository but none of its durability: everything is lost
+// on exit. It is meant for tests and local development.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// Repository is safe for concurrent use. A single lock serializes its
+// transactions, which makes every isolation level beside the point.
+type Repository struct {
+	mu     sync.Mutex
+	notify func(walletID string)
+
+	wallets      map[uuid.UUID]map[string]model.Wallet
+	freezes      map[uuid.UUID]model.WalletFreeze
+	transactions map[uuid.UUID][]model.Transaction
+	accounts     map[accountKey]model.AccountBalance
+	holds        map[uuid.UUID]model.Hold
+	rates        map[rateKey]model.ExchangeRate
+	idempotency  map[string]idempotencyKey
+	sequences    map[uuid.UUID]int64
+	events       map[int64]event
+	imports      map[uuid.UUID]importJob
+	importErrors map[uuid.UUID][]model.ImportLineError
+	webhooks     map[uuid.UUID]model.Webhook
+	deliveries   map[int64]model.WebhookDelivery
+
+	// Like Postgres sequences, the ids are not given back on rollback.
+	lastTransactionID int64
+	lastEventID       int64
+	lastDeliveryID    int64
+}
+
+type Option func(*Repository)
+
+// WithNotify calls fn with the wallet id once a transaction writing events
+// of the wallet commits, as Postgres notifies repository.EventChannel.
+func WithNotify(fn func(walletID string)) Option {
+	return func(r *Repository) {
+		r.notify = fn
+	}
+}
+
+func New(opts ...Option) *Repository {
+	r := &Repository{
+		wallets:      make(map[uuid.UUID]map[string]model.Wallet),
+		freezes:      make(map[uuid.UUID]model.WalletFreeze),
+		transactions: make(map[uuid.UUID][]model.Transaction),
+		accounts:     make(map[accountKey]model.AccountBalance),
+		holds:        make(map[uuid.UUID]model.Hold),
+		rates:        make(map[rateKey]model.ExchangeRate),
+		idempotency:  make(map[string]idempotencyKey),
+		sequences:    make(map[uuid.UUID]int64),
+		events:       make(map[int64]event),
+		imports:      make(map[uuid.UUID]importJob),
+		importErrors: make(map[uuid.UUID][]model.ImportLineError),
+		webhooks:     make(map[uuid.UUID]model.Webhook),
+		deliveries:   make(map[int64]model.WebhookDelivery),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// UpdateBalance applies a deposit (amount > 0) or withdrawal (amount < 0)
+// to the wallet's balance in currency. A deposit in a currency the wallet
+// does not hold yet opens a new balance for it. A non-zero ifVersion is
+// the version the balance must still have.
+func (r *Repository) UpdateBalance(
+	ctx context.Context,
+	walletID string,
+	currency string,
+	amount int64,
+	ifVersion int64,
+) error {
+
+	id, err := parseID(walletID)
+	if err != nil {
+		return err
+	}
+
+	return r.run(ctx, func(t *txn) error {
+		if err := r.checkNotFrozen(id); err != nil {
+			return err
+		}
+
+		wallet, ok := r.wallet(id, currency)
+		if !ok {
+			if ifVersion != 0 {
+				return appErr.ErrVersionMismatch
+			}
+			if amount < 0 {
+				return r.walletMissing(id)
+			}
+
+			r.putWallet(t, model.Wallet{ID: id, Currency: currency, Balance: amount})
+			return r.recordOperation(t, id, currency, amount, amount)
+		}
+
+		if ifVersion != 0 && wallet.Version != ifVersion {
+			return appErr.ErrVersionMismatch
+		}
+
+		// Held funds stay on the balance but cannot be withdrawn.
+		newBalance := wallet.Balance + amount
+		if newBalance < wallet.Held {
+			return insufficientFunds(wallet, -amount)
+		}
+
+		wallet.Balance = newBalance
+		r.putWallet(t, wallet)
+		return r.recordOperation(t, id, currency, amount, newBalance)
+	})
+}
+
+func (r *Repository) GetWallet(ctx context.Context, walletID, currency string) (model.Wallet, error) {
+	id, err := parseID(walletID)
+	if err != nil {
+		return model.Wallet{}, err
+	}
+
+	var wallet model.Wallet
+	err = r.run(ctx, func(t *txn) error {
+		var ok bool
+		if wallet, ok = r.wallet(id, currency); !ok {
+			return appErr.ErrWalletNotFound
+		}
+		return nil
+	})
+
+	return wallet, err
+}
+
+// ListWallets returns every currency balance of the wallet.
+func (r *Repository) ListWallets(ctx context.Context, walletID string) ([]model.Wallet, error) {
+	id, err := parseID(walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	var wallets []model.Wallet
+	err = r.run(ctx, func(t *txn) error {
+		for _, wallet := range r.wallets[id] {
+			wallets = append(wallets, wallet)
+		}
+		return nil
+	})
+
+	sort.Slice(wallets, func(i, j int) bool {
+		return wallets[i].Currency < wallets[j].Currency
+	})
+	return wallets, err
+}
+
+func (r *Repository) ListTransactions(
+	ctx context.Context,
+	walletID string,
+	filter model.TransactionFilter,
+) ([]model.Transaction, error) {
+
+	id, err := parseID(walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	var txs []model.Transaction
+	err = r.run(ctx, func(t *txn) error {
+		ledger := r.transactions[id]
+		for i := len(ledger) - 1; i >= 0 && len(txs) < filter.Limit; i-- {
+			tx := ledger[i]
+			switch {
+			case filter.Currency != "" && tx.Currency != filter.Currency,
+				filter.Type != "" && tx.Type != filter.Type,
+				!filter.From.IsZero() && tx.CreatedAt.Before(filter.From),
+				!filter.To.IsZero() && !tx.CreatedAt.Before(filter.To),
+				filter.BeforeID > 0 && tx.ID >= filter.BeforeID:
+				continue
+			}
+			txs = append(txs, tx)
+		}
+		return nil
+	})
+
+	return txs, err
+}
+
+func (r *Repository) wallet(id uuid.UUID, currency string) (model.Wallet, bool) {
+	wallet, ok := r.wallets[id][currency]
+	return wallet, ok
+}
+
+// putWallet stores the balance, bumping its version on every change to
+// Balance or Held as the trigger on the wallets table does.
+func (r *Repository) putWallet(t *txn, wallet model.Wallet) {
+	balances, ok := r.wallets[wallet.ID]
+	if !ok {
+		balances = make(map[string]model.Wallet)
+		set(t, r.wallets, wallet.ID, balances)
+	}
+
+	wallet.Version = 1
+	if old, ok := balances[wallet.Currency]; ok {
+		wallet.Version = old.Version
+		if old.Balance != wallet.Balance || old.Held != wallet.Held {
+			wallet.Version++
+		}
+	}
+
+	set(t, balances, wallet.Currency, wallet)
+}
+
+// walletMissing explains a missing balance: either the wallet does not
+// exist at all or it only holds other currencies.
+func (r *Repository) walletMissing(id uuid.UUID) error {
+	if len(r.wallets[id]) > 0 {
+		return appErr.ErrCurrencyMismatch
+	}
+	return appErr.ErrWalletNotFound
+}
+
+// insufficientFunds tells the client how much it could have taken.
+func insufficientFunds(wallet model.Wallet, requested int64) error {
+	return appErr.ErrInsufficientFunds.WithDetails(map[string]any{
+		"currency":  wallet.Currency,
+		"balance":   wallet.Balance,
+		"available": wallet.Available(),
+		"requested": requested,
+	})
+}
+
+// recordOperation posts the journal for a deposit (amount > 0) or
+// withdrawal (amount < 0) and the matching ledger row.
+func (r *Repository) recordOperation(t *txn, id uuid.UUID, currency string, amount, balanceAfter int64) error {
+	op := model.OpDeposit
+	entries := []model.JournalEntry{
+		{Account: model.AccountCashIn, Currency: currency, Amount: amount},
+		{Account: model.WalletAccount(id.String()), Currency: currency, Amount: -amount},
+	}
+	if amount < 0 {
+		op = model.OpWithdraw
+		entries = []model.JournalEntry{
+			{Account: model.WalletAccount(id.String()), Currency: currency, Amount: -amount},
+			{Account: model.AccountCashOut, Currency: currency, Amount: amount},
+		}
+	}
+
+	if err := r.postJournal(t, entries); err != nil {
+		return err
+	}
+
+	r.insertTransaction(t, id, currency, op, amount, balanceAfter, "", "")
+	return nil
+}
+
+// insertTransaction writes a ledger row together with its outbox event.
+// rate is only set on the legs of a conversion.
+func (r *Repository) insertTransaction(
+	t *txn,
+	id uuid.UUID,
+	currency string,
+	opType string,
+	amount int64,
+	balanceAfter int64,
+	rate string,
+	reference string,
+) {
+
+	r.lastTransactionID++
+	set(t, r.transactions, id, append(r.transactions[id], model.Transaction{
+		ID:           r.lastTransactionID,
+		WalletID:     id,
+		Currency:     currency,
+		Type:         opType,
+		Amount:       amount,
+		BalanceAfter: balanceAfter,
+		Rate:         rate,
+		CreatedAt:    time.Now(),
+	}))
+
+	r.recordEvent(t, id, currency, opType, amount, balanceAfter, reference)
+}
+
+// parseID reads an id the way Postgres reads a UUID column.
+func parseID(s string) (uuid.UUID, error) {
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("invalid input syntax for type uuid: %q", s)
+	}
+	return id, nil
+}
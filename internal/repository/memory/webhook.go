@@ -0,0 +1,227 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+)
+
+func (r *Repository) CreateWebhook(ctx context.Context, url, secret string, events []string) (model.Webhook, error) {
+	webhook := model.Webhook{
+		ID:        uuid.New(),
+		URL:       url,
+		Secret:    secret,
+		Events:    slices.Clone(events),
+		Active:    true,
+		CreatedAt: time.Now(),
+	}
+
+	err := r.run(ctx, func(t *txn) error {
+		set(t, r.webhooks, webhook.ID, webhook)
+		return nil
+	})
+	if err != nil {
+		return model.Webhook{}, err
+	}
+
+	webhook.Events = slices.Clone(events)
+	return webhook, nil
+}
+
+func (r *Repository) GetWebhook(ctx context.Context, id string) (model.Webhook, error) {
+	var webhook model.Webhook
+	err := r.run(ctx, func(t *txn) error {
+		var err error
+		webhook, err = r.lookupWebhook(id)
+		return err
+	})
+	if err != nil {
+		return model.Webhook{}, err
+	}
+
+	webhook.Events = slices.Clone(webhook.Events)
+	return webhook, nil
+}
+
+// DeactivateWebhook stops new and pending deliveries to the webhook; the
+// deliveries already made are kept.
+func (r *Repository) DeactivateWebhook(ctx context.Context, id string) error {
+	return r.run(ctx, func(t *txn) error {
+		webhook, err := r.lookupWebhook(id)
+		if err != nil {
+			return err
+		}
+
+		webhook.Active = false
+		set(t, r.webhooks, webhook.ID, webhook)
+		return nil
+	})
+}
+
+// EnqueueWebhookDeliveries queues payload for every active webhook
+// subscribed to eventType and returns how many deliveries it queued.
+func (r *Repository) EnqueueWebhookDeliveries(ctx context.Context, eventType string, payload []byte) (int64, error) {
+	var n int64
+	err := r.run(ctx, func(t *txn) error {
+		now := time.Now()
+		for _, webhook := range r.webhooks {
+			if !webhook.Active || !slices.Contains(webhook.Events, eventType) {
+				continue
+			}
+
+			r.lastDeliveryID++
+			set(t, r.deliveries, r.lastDeliveryID, model.WebhookDelivery{
+				ID:            r.lastDeliveryID,
+				WebhookID:     webhook.ID,
+				EventType:     eventType,
+				Payload:       bytes.Clone(payload),
+				Status:        model.DeliveryPending,
+				NextAttemptAt: now,
+				CreatedAt:     now,
+				UpdatedAt:     now,
+			})
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// ClaimWebhookDeliveries takes up to limit pending deliveries due by now,
+// with the URL and secret of their webhook, and holds them until until:
+// they become due again then, unless marked delivered or failed first.
+func (r *Repository) ClaimWebhookDeliveries(ctx context.Context, now, until time.Time, limit int) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	err := r.run(ctx, func(t *txn) error {
+		for _, d := range r.deliveries {
+			if d.Status == model.DeliveryPending && !d.NextAttemptAt.After(now) && r.webhooks[d.WebhookID].Active {
+				deliveries = append(deliveries, d)
+			}
+		}
+
+		sort.Slice(deliveries, func(i, j int) bool {
+			a, b := deliveries[i], deliveries[j]
+			if !a.NextAttemptAt.Equal(b.NextAttemptAt) {
+				return a.NextAttemptAt.Before(b.NextAttemptAt)
+			}
+			return a.ID < b.ID
+		})
+		deliveries = limited(deliveries, limit)
+
+		for i := range deliveries {
+			deliveries[i].NextAttemptAt = until
+			set(t, r.deliveries, deliveries[i].ID, deliveries[i])
+
+			webhook := r.webhooks[deliveries[i].WebhookID]
+			deliveries[i].URL = webhook.URL
+			deliveries[i].Secret = webhook.Secret
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (r *Repository) MarkWebhookDelivered(ctx context.Context, id int64) error {
+	return r.run(ctx, func(t *txn) error {
+		if d, ok := r.deliveries[id]; ok {
+			d.Status = model.DeliveryDelivered
+			d.Attempts++
+			d.LastError = ""
+			d.UpdatedAt = time.Now()
+			set(t, r.deliveries, id, d)
+		}
+		return nil
+	})
+}
+
+// FailWebhookDelivery records a failed attempt. The delivery moves to
+// status: PENDING to be tried again at at, or DEAD to stop.
+func (r *Repository) FailWebhookDelivery(ctx context.Context, id int64, status string, at time.Time, msg string) error {
+	return r.run(ctx, func(t *txn) error {
+		if d, ok := r.deliveries[id]; ok {
+			d.Status = status
+			d.Attempts++
+			d.NextAttemptAt = at
+			d.LastError = msg
+			d.UpdatedAt = time.Now()
+			set(t, r.deliveries, id, d)
+		}
+		return nil
+	})
+}
+
+// WebhookDeliveries returns up to limit deliveries in status, or in any
+// status when it is empty, newest first.
+func (r *Repository) WebhookDeliveries(ctx context.Context, status string, limit int) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	err := r.run(ctx, func(t *txn) error {
+		for _, d := range r.deliveries {
+			if status == "" || d.Status == status {
+				deliveries = append(deliveries, d)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].ID > deliveries[j].ID
+	})
+	return limited(deliveries, limit), nil
+}
+
+// ReplayWebhookDelivery queues the delivery again from scratch, whatever
+// its status.
+func (r *Repository) ReplayWebhookDelivery(ctx context.Context, id int64) (model.WebhookDelivery, error) {
+	var d model.WebhookDelivery
+	err := r.run(ctx, func(t *txn) error {
+		var ok bool
+		if d, ok = r.deliveries[id]; !ok {
+			return appErr.ErrDeliveryNotFound
+		}
+
+		now := time.Now()
+		d.Status = model.DeliveryPending
+		d.Attempts = 0
+		d.NextAttemptAt = now
+		d.LastError = ""
+		d.UpdatedAt = now
+		set(t, r.deliveries, id, d)
+		return nil
+	})
+	if err != nil {
+		return model.WebhookDelivery{}, err
+	}
+
+	return d, nil
+}
+
+func (r *Repository) lookupWebhook(id string) (model.Webhook, error) {
+	webhookID, err := parseID(id)
+	if err != nil {
+		return model.Webhook{}, err
+	}
+
+	webhook, ok := r.webhooks[webhookID]
+	if !ok {
+		return model.Webhook{}, appErr.ErrWebhookNotFound
+	}
+	return webhook, nil
+}
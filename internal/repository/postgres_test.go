@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/Hlompy/Wallet/internal/db"
+
+	_ "github.com/lib/pq"
+)
+
+// testDB connects to the Postgres database named by WALLET_TEST_DSN and
+// applies the migrations. Tests and benchmarks that need a real database
+// are skipped without it.
+func testDB(tb testing.TB) *sql.DB {
+	tb.Helper()
+
+	dsn := os.Getenv("WALLET_TEST_DSN")
+	if dsn == "" {
+		tb.Skip("WALLET_TEST_DSN is not set")
+	}
+
+	database, err := sql.Open("postgres", dsn)
+	if err != nil {
+		tb.Fatalf("open database: %v", err)
+	}
+	tb.Cleanup(func() { database.Close() })
+
+	if err := db.Migrate(database, "postgres"); err != nil {
+		tb.Fatalf("migrate: %v", err)
+	}
+
+	return database
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+func TestSaveRates(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec(`INSERT INTO exchange_rates \(base, quote, rate, effective_from\) VALUES \(\$1, \$2, \$3, \$4\), \(\$5, \$6, \$7, \$8\)\s+ON CONFLICT \(base, quote, effective_from\) DO UPDATE SET rate = EXCLUDED.rate`).
+		WithArgs("USD", "EUR", "0.9", from, "EUR", "USD", "1.1", from).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	err = repo.SaveRates(context.Background(), []model.ExchangeRate{
+		{Base: "USD", Quote: "EUR", Rate: "0.9", EffectiveFrom: from},
+		{Base: "EUR", Quote: "USD", Rate: "1.1", EffectiveFrom: from},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGetRate_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	at := time.Now()
+	from := at.Add(-time.Hour)
+
+	mock.ExpectQuery(`SELECT rate::text, effective_from FROM exchange_rates\s+WHERE base = \$1 AND quote = \$2 AND effective_from <= \$3\s+ORDER BY effective_from DESC LIMIT 1`).
+		WithArgs("USD", "EUR", at).
+		WillReturnRows(sqlmock.NewRows([]string{"rate", "effective_from"}).AddRow("0.9", from))
+
+	rate, err := repo.GetRate(context.Background(), "USD", "EUR", at)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rate.Base != "USD" || rate.Quote != "EUR" || rate.Rate != "0.9" || !rate.EffectiveFrom.Equal(from) {
+		t.Errorf("unexpected rate: %+v", rate)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGetRate_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	mock.ExpectQuery(`SELECT rate::text, effective_from FROM exchange_rates`).
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = repo.GetRate(context.Background(), "USD", "EUR", time.Now())
+	if err != appErr.ErrRateNotFound {
+		t.Errorf("expected ErrRateNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestListRates(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	at := time.Now()
+
+	mock.ExpectQuery(`SELECT DISTINCT ON \(base, quote\) base, quote, rate::text, effective_from\s+FROM exchange_rates WHERE effective_from <= \$1`).
+		WithArgs(at).
+		WillReturnRows(sqlmock.NewRows([]string{"base", "quote", "rate", "effective_from"}).
+			AddRow("EUR", "USD", "1.1", at).
+			AddRow("USD", "EUR", "0.9", at))
+
+	rates, err := repo.ListRates(context.Background(), at)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(rates) != 2 || rates[1].Base != "USD" || rates[1].Rate != "0.9" {
+		t.Errorf("unexpected rates: %+v", rates)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
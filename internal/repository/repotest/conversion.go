@@ -0,0 +1,111 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+)
+
+func testConvert(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	walletID := uuid.NewString()
+
+	deposit(t, repo, walletID, "USD", 1000)
+
+	res, err := repo.Convert(ctx, walletID, "USD", "EUR", 500, 460, "0.92")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.FromBalance != 500 || res.ToBalance != 460 || res.Rate != "0.92" {
+		t.Errorf("unexpected result: %+v", res)
+	}
+
+	// Opened at 0, the new balance is at version 2 after the credit.
+	if wallet := getWallet(t, repo, walletID, "EUR"); wallet.Balance != 460 || wallet.Version != 2 {
+		t.Errorf("expected 460 EUR at version 2, got %+v", wallet)
+	}
+	expectBalance(t, repo, walletID, "USD", 500, 0)
+
+	txs := listTransactions(t, repo, walletID)
+	if len(txs) != 3 ||
+		txs[0].Type != model.OpConvertIn || txs[0].Currency != "EUR" || txs[0].Amount != 460 || txs[0].Rate != "0.92" ||
+		txs[1].Type != model.OpConvertOut || txs[1].Currency != "USD" || txs[1].Amount != -500 || txs[1].Rate != "0.92" ||
+		txs[2].Rate != "" {
+		t.Errorf("unexpected transactions: %+v", txs)
+	}
+
+	_, err = repo.Convert(ctx, walletID, "USD", "EUR", 501, 461, "0.92")
+	expectErr(t, err, appErr.ErrInsufficientFunds)
+
+	_, err = repo.Convert(ctx, walletID, "GBP", "USD", 10, 12, "1.2")
+	expectErr(t, err, appErr.ErrCurrencyMismatch)
+
+	_, err = repo.Convert(ctx, uuid.NewString(), "USD", "EUR", 10, 9, "0.92")
+	expectErr(t, err, appErr.ErrWalletNotFound)
+
+	balances, err := repo.TrialBalance(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, b := range balances {
+		if b.Account == model.AccountFX && b.Balance() != map[string]int64{"USD": -500, "EUR": 460}[b.Currency] {
+			t.Errorf("unexpected fx account: %+v", b)
+		}
+	}
+}
+
+func testRates(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	t0 := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(24 * time.Hour)
+
+	err := repo.SaveRates(ctx, []model.ExchangeRate{
+		{Base: "USD", Quote: "EUR", Rate: "0.90", EffectiveFrom: t0},
+		{Base: "USD", Quote: "EUR", Rate: "0.92", EffectiveFrom: t1},
+		{Base: "EUR", Quote: "USD", Rate: "1.08", EffectiveFrom: t0},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		at   time.Time
+		rate string
+	}{
+		{t0, "0.90"},
+		{t1.Add(-time.Second), "0.90"},
+		{t1, "0.92"},
+	}
+	for _, tt := range tests {
+		rate, err := repo.GetRate(ctx, "USD", "EUR", tt.at)
+		if err != nil || rate.Rate != tt.rate {
+			t.Errorf("at %v: expected %s, got %+v (%v)", tt.at, tt.rate, rate, err)
+		}
+	}
+
+	_, err = repo.GetRate(ctx, "USD", "EUR", t0.Add(-time.Second))
+	expectErr(t, err, appErr.ErrRateNotFound)
+
+	_, err = repo.GetRate(ctx, "USD", "GBP", t1)
+	expectErr(t, err, appErr.ErrRateNotFound)
+
+	// Saving a rate again for the same time overwrites it.
+	if err := repo.SaveRates(ctx, []model.ExchangeRate{{Base: "USD", Quote: "EUR", Rate: "0.93", EffectiveFrom: t1}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rates, err := repo.ListRates(ctx, t1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rates) != 2 ||
+		rates[0].Base != "EUR" || rates[0].Rate != "1.08" ||
+		rates[1].Base != "USD" || rates[1].Rate != "0.93" || !rates[1].EffectiveFrom.Equal(t1) {
+		t.Errorf("unexpected rates: %+v", rates)
+	}
+}
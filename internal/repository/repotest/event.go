@@ -0,0 +1,131 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Hlompy/Wallet/internal/model"
+	"github.com/Hlompy/Wallet/internal/outbox"
+
+	"github.com/google/uuid"
+)
+
+func testWalletEvents(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	a, b := uuid.NewString(), uuid.NewString()
+
+	deposit(t, repo, a, "USD", 100)
+	deposit(t, repo, b, "USD", 10)
+	deposit(t, repo, a, "EUR", 30)
+	deposit(t, repo, a, "USD", -40)
+
+	sequenceEvents(t, repo)
+	events, err := repo.WalletEventsSince(ctx, a, 0, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []model.WalletEvent{
+		{Sequence: 1, Currency: "USD", Type: model.OpDeposit, Amount: 100, BalanceAfter: 100},
+		{Sequence: 2, Currency: "EUR", Type: model.OpDeposit, Amount: 30, BalanceAfter: 30},
+		{Sequence: 3, Currency: "USD", Type: model.OpWithdraw, Amount: -40, BalanceAfter: 60},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), events)
+	}
+	for i, e := range events {
+		w := want[i]
+		if e.Sequence != w.Sequence || e.Currency != w.Currency || e.Type != w.Type || e.Amount != w.Amount ||
+			e.BalanceAfter != w.BalanceAfter || e.WalletID.String() != a {
+			t.Errorf("event %d: expected %+v, got %+v", i, w, e)
+		}
+	}
+
+	if events, _ := repo.WalletEventsSince(ctx, a, 1, 1); len(events) != 1 || events[0].Sequence != 2 {
+		t.Errorf("expected event 2 alone, got %+v", events)
+	}
+
+	for walletID, want := range map[string]int64{a: 3, b: 1, uuid.NewString(): 0} {
+		if seq, err := repo.LastEventSequence(ctx, walletID); err != nil || seq != want {
+			t.Errorf("expected sequence %d, got %d (%v)", want, seq, err)
+		}
+	}
+}
+
+func testOutbox(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	a, b := uuid.NewString(), uuid.NewString()
+
+	deposit(t, repo, a, "USD", 100)
+	deposit(t, repo, a, "USD", 50)
+	deposit(t, repo, b, "USD", 10)
+
+	now := time.Now().Add(time.Minute)
+	pending := pendingEvents(t, repo, now)
+	if len(pending) != 3 || pending[0].ID >= pending[1].ID || pending[1].ID >= pending[2].ID {
+		t.Fatalf("expected 3 events in commit order, got %+v", pending)
+	}
+
+	// A failed event holds back the rest of its wallet until it is due.
+	if err := repo.RetryEvent(ctx, pending[0].ID, now.Add(time.Hour), "boom"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := pendingEvents(t, repo, now); len(got) != 1 || got[0].WalletID.String() != b {
+		t.Errorf("expected only the event of the other wallet, got %+v", got)
+	}
+
+	retried := pendingEvents(t, repo, now.Add(2*time.Hour))
+	if len(retried) != 3 || retried[0].Attempts != 1 {
+		t.Fatalf("expected the retried event back with an attempt, got %+v", retried)
+	}
+
+	err := repo.WithinTx(ctx, func(ctx context.Context) error {
+		locked, err := repo.LockEventRelay(ctx)
+		if err != nil || !locked {
+			t.Errorf("expected the relay lock, got %v (%v)", locked, err)
+		}
+		return repo.MarkEventsDelivered(ctx, []int64{retried[0].ID, retried[1].ID, retried[2].ID})
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := pendingEvents(t, repo, now.Add(2*time.Hour)); len(got) != 0 {
+		t.Errorf("expected no pending events, got %+v", got)
+	}
+
+	if n, err := repo.DeleteDeliveredEvents(ctx, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("expected nothing to delete, got %d (%v)", n, err)
+	}
+	if n, err := repo.DeleteDeliveredEvents(ctx, time.Now().Add(time.Hour)); err != nil || n != 3 {
+		t.Errorf("expected 3 events deleted, got %d (%v)", n, err)
+	}
+
+	// Sequences go on after the events are gone.
+	deposit(t, repo, a, "USD", 1)
+	sequenceEvents(t, repo)
+	if events, _ := repo.WalletEventsSince(ctx, a, 0, 10); len(events) != 1 || events[0].Sequence != 3 {
+		t.Errorf("expected only event 3, got %+v", events)
+	}
+}
+
+// sequenceEvents numbers the events written so far, which the Postgres
+// repository leaves to a sequencer running after the commit.
+func sequenceEvents(t *testing.T, repo Repository) {
+	t.Helper()
+
+	if err := outbox.Sequence(context.Background(), repo, 100); err != nil {
+		t.Fatalf("failed to number events: %v", err)
+	}
+}
+
+func pendingEvents(t *testing.T, repo Repository, now time.Time) []model.WalletEvent {
+	t.Helper()
+
+	sequenceEvents(t, repo)
+	events, err := repo.PendingEvents(context.Background(), now, 10)
+	if err != nil {
+		t.Fatalf("failed to get pending events: %v", err)
+	}
+	return events
+}
@@ -0,0 +1,205 @@
+package repotest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+
+	"github.com/google/uuid"
+)
+
+func testFreezes(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	walletID := uuid.NewString()
+	otherID := uuid.NewString()
+	deposit(t, repo, walletID, "RUB", 100)
+	deposit(t, repo, otherID, "RUB", 100)
+
+	f, err := repo.GetWalletFreeze(ctx, walletID)
+	if err != nil || f != nil {
+		t.Fatalf("expected no freeze, got %+v (%v)", f, err)
+	}
+
+	frozen, err := repo.FreezeWallet(ctx, walletID, "chargeback")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if frozen.WalletID.String() != walletID || frozen.Reason != "chargeback" || frozen.FrozenAt.IsZero() {
+		t.Errorf("unexpected freeze: %+v", frozen)
+	}
+
+	again, err := repo.FreezeWallet(ctx, walletID, "fraud")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if again.Reason != "fraud" || !again.FrozenAt.Equal(frozen.FrozenAt) {
+		t.Errorf("refreezing must only replace the reason: %+v", again)
+	}
+
+	f, err = repo.GetWalletFreeze(ctx, walletID)
+	if err != nil || f == nil || f.Reason != "fraud" || !f.FrozenAt.Equal(frozen.FrozenAt) {
+		t.Errorf("unexpected freeze: %+v (%v)", f, err)
+	}
+	if f, _ := repo.GetWalletFreeze(ctx, otherID); f != nil {
+		t.Errorf("other wallet must stay unfrozen, got %+v", f)
+	}
+
+	errRollback := errors.New("rollback")
+	err = repo.WithinTx(ctx, func(ctx context.Context) error {
+		if err := repo.UnfreezeWallet(ctx, walletID); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("expected rollback, got %v", err)
+	}
+	if f, _ := repo.GetWalletFreeze(ctx, walletID); f == nil {
+		t.Error("rolled back unfreeze must keep the freeze")
+	}
+
+	for range 2 {
+		if err := repo.UnfreezeWallet(ctx, walletID); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if f, err := repo.GetWalletFreeze(ctx, walletID); err != nil || f != nil {
+		t.Errorf("expected no freeze, got %+v (%v)", f, err)
+	}
+}
+
+func testFrozenWallet(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	walletID := uuid.NewString()
+	otherID := uuid.NewString()
+	deposit(t, repo, walletID, "USD", 1000)
+	deposit(t, repo, otherID, "USD", 1000)
+
+	hold, err := repo.PlaceHold(ctx, walletID, "USD", 100, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := repo.FreezeWallet(ctx, walletID, "fraud"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ops := map[string]func() error{
+		"deposit": func() error {
+			return repo.UpdateBalance(ctx, walletID, "USD", 100, 0)
+		},
+		"deposit in a new currency": func() error {
+			return repo.UpdateBalance(ctx, walletID, "EUR", 100, 0)
+		},
+		"withdraw": func() error {
+			return repo.UpdateBalance(ctx, walletID, "USD", -100, 0)
+		},
+		"transfer out": func() error {
+			_, err := repo.Transfer(ctx, walletID, otherID, "USD", 100)
+			return err
+		},
+		"transfer in": func() error {
+			_, err := repo.Transfer(ctx, otherID, walletID, "USD", 100)
+			return err
+		},
+		"place hold": func() error {
+			_, err := repo.PlaceHold(ctx, walletID, "USD", 100, time.Now().Add(time.Hour))
+			return err
+		},
+		"capture hold": func() error {
+			_, err := repo.CaptureHold(ctx, hold.ID.String(), 0, time.Now())
+			return err
+		},
+		"convert": func() error {
+			_, err := repo.Convert(ctx, walletID, "USD", "EUR", 100, 90, "0.9")
+			return err
+		},
+	}
+
+	for name, op := range ops {
+		t.Run(name, func(t *testing.T) {
+			err := op()
+			if !errors.Is(err, appErr.ErrWalletFrozen) {
+				t.Fatalf("expected ErrWalletFrozen, got %v", err)
+			}
+
+			e, _ := appErr.As(err)
+			if e.Details["walletId"] != walletID {
+				t.Errorf("unexpected details: %v", e.Details)
+			}
+		})
+	}
+
+	expectBalance(t, repo, walletID, "USD", 1000, 100)
+	expectBalance(t, repo, otherID, "USD", 1000, 0)
+	if _, err := repo.GetWallet(ctx, walletID, "EUR"); !errors.Is(err, appErr.ErrWalletNotFound) {
+		t.Errorf("expected no EUR balance, got %v", err)
+	}
+
+	if _, err := repo.ReleaseHold(ctx, hold.ID.String()); err != nil {
+		t.Errorf("releasing a hold of a frozen wallet: %v", err)
+	}
+
+	if err := repo.UnfreezeWallet(ctx, walletID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	deposit(t, repo, walletID, "USD", 100)
+}
+
+// testFreezeWaitsForOperations freezes a wallet under load: once
+// FreezeWallet returns, no operation on the wallet may commit.
+func testFreezeWaitsForOperations(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	walletID := uuid.NewString()
+	deposit(t, repo, walletID, "USD", 1000)
+
+	const workers = 8
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				case <-time.After(time.Millisecond):
+				}
+
+				amount := int64(1)
+				if i%2 == 1 {
+					amount = -1
+				}
+
+				err := repo.UpdateBalance(ctx, walletID, "USD", amount, 0)
+				if errors.Is(err, appErr.ErrWalletFrozen) {
+					return
+				}
+				if err != nil && !errors.Is(err, appErr.ErrConcurrentUpdate) {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+			}
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	_, err := repo.FreezeWallet(ctx, walletID, "")
+	if err != nil {
+		close(stop)
+		wg.Wait()
+		t.Fatalf("unexpected error: %v", err)
+	}
+	frozen := getWallet(t, repo, walletID, "USD")
+	close(stop)
+	wg.Wait()
+
+	if after := getWallet(t, repo, walletID, "USD"); after != frozen {
+		t.Errorf("the wallet changed after it was frozen: %+v, then %+v", frozen, after)
+	}
+}
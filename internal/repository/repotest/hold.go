@@ -0,0 +1,159 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+)
+
+func testHolds(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	walletID := uuid.NewString()
+
+	deposit(t, repo, walletID, "USD", 1000)
+
+	hold, err := repo.PlaceHold(ctx, walletID, "USD", 400, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hold.Status != model.HoldActive || hold.Amount != 400 || hold.WalletID.String() != walletID || hold.Currency != "USD" {
+		t.Errorf("unexpected hold: %+v", hold)
+	}
+	if wallet := getWallet(t, repo, walletID, "USD"); wallet.Held != 400 || wallet.Version != 2 {
+		t.Errorf("expected 400 held at version 2, got %+v", wallet)
+	}
+
+	_, err = repo.PlaceHold(ctx, walletID, "USD", 601, time.Now().Add(time.Hour))
+	expectErr(t, err, appErr.ErrInsufficientFunds)
+
+	err = repo.UpdateBalance(ctx, walletID, "USD", -601, 0)
+	expectErr(t, err, appErr.ErrInsufficientFunds)
+
+	captured, err := repo.CaptureHold(ctx, hold.ID.String(), 250, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if captured.Status != model.HoldCaptured || captured.Captured != 250 || captured.ID != hold.ID {
+		t.Errorf("unexpected hold: %+v", captured)
+	}
+	expectBalance(t, repo, walletID, "USD", 750, 0)
+
+	if got, err := repo.GetHold(ctx, hold.ID.String()); err != nil || got.Status != model.HoldCaptured {
+		t.Errorf("expected the captured hold, got %+v (%v)", got, err)
+	}
+
+	txs := listTransactions(t, repo, walletID)
+	if txs[0].Type != model.OpCapture || txs[0].Amount != -250 || txs[0].BalanceAfter != 750 {
+		t.Errorf("unexpected transaction: %+v", txs[0])
+	}
+
+	sequenceEvents(t, repo)
+	events, err := repo.WalletEventsSince(ctx, walletID, 1, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(events) != 1 || events[0].Type != model.OpCapture || events[0].Reference != hold.ID.String() {
+		t.Errorf("expected a single capture event, got %+v", events)
+	}
+
+	_, err = repo.CaptureHold(ctx, hold.ID.String(), 0, time.Now())
+	expectErr(t, err, appErr.ErrHoldNotActive)
+}
+
+func testHoldErrors(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	walletID := uuid.NewString()
+
+	deposit(t, repo, walletID, "USD", 1000)
+
+	_, err := repo.PlaceHold(ctx, uuid.NewString(), "USD", 10, time.Now().Add(time.Hour))
+	expectErr(t, err, appErr.ErrWalletNotFound)
+
+	_, err = repo.PlaceHold(ctx, walletID, "EUR", 10, time.Now().Add(time.Hour))
+	expectErr(t, err, appErr.ErrCurrencyMismatch)
+
+	hold, err := repo.PlaceHold(ctx, walletID, "USD", 100, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = repo.CaptureHold(ctx, hold.ID.String(), 101, time.Now())
+	expectErr(t, err, appErr.ErrCaptureExceedsHold)
+
+	_, err = repo.CaptureHold(ctx, hold.ID.String(), 0, time.Now().Add(2*time.Hour))
+	expectErr(t, err, appErr.ErrHoldNotActive)
+
+	_, err = repo.CaptureHold(ctx, uuid.NewString(), 0, time.Now())
+	expectErr(t, err, appErr.ErrHoldNotFound)
+
+	_, err = repo.GetHold(ctx, uuid.NewString())
+	expectErr(t, err, appErr.ErrHoldNotFound)
+
+	released, err := repo.ReleaseHold(ctx, hold.ID.String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if released.Status != model.HoldReleased || released.Captured != 0 {
+		t.Errorf("unexpected hold: %+v", released)
+	}
+	expectBalance(t, repo, walletID, "USD", 1000, 0)
+
+	_, err = repo.ReleaseHold(ctx, hold.ID.String())
+	expectErr(t, err, appErr.ErrHoldNotActive)
+
+	sequenceEvents(t, repo)
+	if seq, err := repo.LastEventSequence(ctx, walletID); err != nil || seq != 1 {
+		t.Errorf("expected a release to write no event, got sequence %d (%v)", seq, err)
+	}
+}
+
+func testExpireHolds(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	walletID := uuid.NewString()
+	now := time.Now()
+
+	deposit(t, repo, walletID, "USD", 1000)
+
+	var holds []model.Hold
+	for i, amount := range []int64{100, 200, 300} {
+		hold, err := repo.PlaceHold(ctx, walletID, "USD", amount, now.Add(time.Duration(3-i)*time.Hour))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		holds = append(holds, hold)
+	}
+
+	expired, err := repo.ExpireHolds(ctx, now.Add(150*time.Minute), 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(expired) != 2 || expired[0].ID != holds[2].ID || expired[1].ID != holds[1].ID {
+		t.Fatalf("expected the two earliest holds in expiry order, got %+v", expired)
+	}
+	for _, hold := range expired {
+		if hold.Status != model.HoldExpired {
+			t.Errorf("expected an expired hold, got %+v", hold)
+		}
+	}
+	expectBalance(t, repo, walletID, "USD", 1000, 100)
+
+	sequenceEvents(t, repo)
+	events, err := repo.WalletEventsSince(ctx, walletID, 1, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(events) != 2 || events[0].Type != model.EventHoldExpired || events[0].Amount != 300 ||
+		events[0].BalanceAfter != 1000 || events[0].Reference != holds[2].ID.String() {
+		t.Errorf("expected an event per expiry, got %+v", events)
+	}
+
+	expired, err = repo.ExpireHolds(ctx, now.Add(4*time.Hour), 0)
+	if err != nil || len(expired) != 0 {
+		t.Errorf("expected no holds beyond the limit, got %+v (%v)", expired, err)
+	}
+}
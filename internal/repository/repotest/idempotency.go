@@ -0,0 +1,52 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func testIdempotency(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+
+	rec, err := repo.ReserveIdempotencyKey(ctx, "key-1", "hash-1", past)
+	if err != nil || rec != nil {
+		t.Fatalf("expected a fresh key, got %+v (%v)", rec, err)
+	}
+
+	rec, err = repo.ReserveIdempotencyKey(ctx, "key-1", "hash-2", past)
+	if err != nil || rec == nil || rec.RequestHash != "hash-1" || rec.StatusCode != 0 {
+		t.Fatalf("expected the reservation in flight, got %+v (%v)", rec, err)
+	}
+
+	if err := repo.SaveIdempotencyResponse(ctx, "key-1", 201, []byte(`{"ok":true}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rec, err = repo.ReserveIdempotencyKey(ctx, "key-1", "hash-1", past)
+	if err != nil || rec == nil || rec.StatusCode != 201 || string(rec.Response) != `{"ok":true}` {
+		t.Fatalf("expected the stored response, got %+v (%v)", rec, err)
+	}
+
+	// An expired key is taken over by the new request.
+	rec, err = repo.ReserveIdempotencyKey(ctx, "key-1", "hash-3", future)
+	if err != nil || rec != nil {
+		t.Fatalf("expected the expired key to be fresh, got %+v (%v)", rec, err)
+	}
+	rec, err = repo.ReserveIdempotencyKey(ctx, "key-1", "hash-1", past)
+	if err != nil || rec == nil || rec.RequestHash != "hash-3" || rec.StatusCode != 0 {
+		t.Fatalf("expected the new reservation, got %+v (%v)", rec, err)
+	}
+
+	if _, err := repo.ReserveIdempotencyKey(ctx, "key-2", "hash-1", past); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if n, err := repo.DeleteExpiredIdempotencyKeys(ctx, past); err != nil || n != 0 {
+		t.Errorf("expected nothing to delete, got %d (%v)", n, err)
+	}
+	if n, err := repo.DeleteExpiredIdempotencyKeys(ctx, future); err != nil || n != 2 {
+		t.Errorf("expected 2 keys deleted, got %d (%v)", n, err)
+	}
+}
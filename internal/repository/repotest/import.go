@@ -0,0 +1,100 @@
+package repotest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+)
+
+func testImports(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	data := []byte("wallet_id,operation_type,amount\n")
+
+	job, err := repo.CreateImport(ctx, model.ImportCSV, data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if job.Status != model.ImportPending || job.Format != model.ImportCSV || job.LastLine != 0 {
+		t.Errorf("unexpected job: %+v", job)
+	}
+	id := job.ID.String()
+
+	if got, err := repo.ImportData(ctx, id); err != nil || string(got) != string(data) {
+		t.Errorf("expected the uploaded data, got %q (%v)", got, err)
+	}
+
+	other, err := repo.CreateImport(ctx, model.ImportJSONL, []byte("{}\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, step := range []struct {
+		line    int
+		lineErr *model.ImportLineError
+		want    bool
+	}{
+		{1, nil, true},
+		{1, nil, false},
+		{2, &model.ImportLineError{Code: appErr.CodeInsufficientFunds, Detail: "insufficient funds"}, true},
+	} {
+		advanced, err := repo.AdvanceImport(ctx, id, step.line, step.lineErr)
+		if err != nil || advanced != step.want {
+			t.Errorf("line %d: expected %v, got %v (%v)", step.line, step.want, advanced, err)
+		}
+	}
+
+	// A line rolled back with its operation is not done.
+	boom := errors.New("boom")
+	err = repo.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := repo.AdvanceImport(ctx, id, 3, nil); err != nil {
+			return err
+		}
+		return boom
+	})
+	if err != boom {
+		t.Fatalf("expected the error of fn, got %v", err)
+	}
+
+	job, err = repo.GetImport(ctx, id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if job.LastLine != 2 || job.Succeeded != 1 || job.Failed != 1 {
+		t.Errorf("unexpected progress: %+v", job)
+	}
+
+	lineErrs, err := repo.ImportErrors(ctx, id)
+	if err != nil || len(lineErrs) != 1 || lineErrs[0].Line != 2 || lineErrs[0].Code != appErr.CodeInsufficientFunds {
+		t.Errorf("expected the error of line 2, got %+v (%v)", lineErrs, err)
+	}
+
+	if err := repo.SetImportStatus(ctx, other.ID.String(), model.ImportRunning, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if jobs, _ := repo.UnfinishedImports(ctx); len(jobs) != 2 || jobs[0].ID != job.ID || jobs[1].Status != model.ImportRunning {
+		t.Errorf("expected both jobs oldest first, got %+v", jobs)
+	}
+
+	if err := repo.SetImportStatus(ctx, id, model.ImportFailed, "bad file"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if job, _ := repo.GetImport(ctx, id); job.Status != model.ImportFailed || job.Error != "bad file" {
+		t.Errorf("unexpected job: %+v", job)
+	}
+	if jobs, _ := repo.UnfinishedImports(ctx); len(jobs) != 1 || jobs[0].ID != other.ID {
+		t.Errorf("expected only the other job, got %+v", jobs)
+	}
+
+	missing := uuid.NewString()
+	_, err = repo.GetImport(ctx, missing)
+	expectErr(t, err, appErr.ErrImportNotFound)
+	_, err = repo.ImportData(ctx, missing)
+	expectErr(t, err, appErr.ErrImportNotFound)
+	_, err = repo.AdvanceImport(ctx, missing, 1, nil)
+	expectErr(t, err, appErr.ErrImportNotFound)
+}
@@ -0,0 +1,105 @@
+// Package repotest is the conformance suite of the wallet repositories.
+// Every implementation the service can run on, Postgres, SQLite or
+// in-memory, has to pass it.
+package repotest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Hlompy/Wallet/internal/model"
+	"github.com/Hlompy/Wallet/internal/outbox"
+	"github.com/Hlompy/Wallet/internal/service"
+	"github.com/Hlompy/Wallet/internal/webhook"
+)
+
+// Repository is everything the application needs from its storage.
+type Repository interface {
+	service.WalletRepository
+	outbox.Store
+	outbox.SequenceStore
+	webhook.Queue
+	webhook.Store
+}
+
+// Run runs the suite. newRepo is called once per test and must return an
+// empty repository; the tests do not run in parallel.
+func Run(t *testing.T, newRepo func(t *testing.T) Repository) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repo Repository)
+	}{
+		{"UpdateBalance", testUpdateBalance},
+		{"UpdateBalanceErrors", testUpdateBalanceErrors},
+		{"ListTransactions", testListTransactions},
+		{"Transfer", testTransfer},
+		{"TrialBalance", testTrialBalance},
+		{"WithinTx", testWithinTx},
+		{"ConcurrentUpdates", testConcurrentUpdates},
+		{"Holds", testHolds},
+		{"HoldErrors", testHoldErrors},
+		{"ExpireHolds", testExpireHolds},
+		{"Convert", testConvert},
+		{"Rates", testRates},
+		{"Idempotency", testIdempotency},
+		{"WalletEvents", testWalletEvents},
+		{"Outbox", testOutbox},
+		{"Imports", testImports},
+		{"Webhooks", testWebhooks},
+		{"Freezes", testFreezes},
+		{"FrozenWallet", testFrozenWallet},
+		{"FreezeWaitsForOperations", testFreezeWaitsForOperations},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newRepo(t))
+		})
+	}
+}
+
+func deposit(t *testing.T, repo Repository, walletID, currency string, amount int64) {
+	t.Helper()
+
+	if err := repo.UpdateBalance(context.Background(), walletID, currency, amount, 0); err != nil {
+		t.Fatalf("failed to update %s by %d: %v", walletID, amount, err)
+	}
+}
+
+func getWallet(t *testing.T, repo Repository, walletID, currency string) model.Wallet {
+	t.Helper()
+
+	wallet, err := repo.GetWallet(context.Background(), walletID, currency)
+	if err != nil {
+		t.Fatalf("failed to get %s %s: %v", walletID, currency, err)
+	}
+	return wallet
+}
+
+func listTransactions(t *testing.T, repo Repository, walletID string) []model.Transaction {
+	t.Helper()
+
+	txs, err := repo.ListTransactions(context.Background(), walletID, model.TransactionFilter{Limit: 100})
+	if err != nil {
+		t.Fatalf("failed to list transactions: %v", err)
+	}
+	return txs
+}
+
+func expectErr(t *testing.T, err, want error) {
+	t.Helper()
+
+	if !errors.Is(err, want) {
+		t.Errorf("expected %v, got %v", want, err)
+	}
+}
+
+func expectBalance(t *testing.T, repo Repository, walletID, currency string, balance, held int64) {
+	t.Helper()
+
+	wallet := getWallet(t, repo, walletID, currency)
+	if wallet.Balance != balance || wallet.Held != held {
+		t.Errorf("expected %s balance %d held %d, got %d held %d", currency, balance, held, wallet.Balance, wallet.Held)
+	}
+}
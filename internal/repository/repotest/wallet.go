@@ -0,0 +1,346 @@
+package repotest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+)
+
+func testUpdateBalance(t *testing.T, repo Repository) {
+	walletID := uuid.NewString()
+
+	deposit(t, repo, walletID, "USD", 1000)
+	if wallet := getWallet(t, repo, walletID, "USD"); wallet.Balance != 1000 || wallet.Version != 1 {
+		t.Errorf("expected a new balance of 1000 at version 1, got %+v", wallet)
+	}
+
+	deposit(t, repo, walletID, "USD", -300)
+	deposit(t, repo, walletID, "EUR", 50)
+
+	wallets, err := repo.ListWallets(context.Background(), walletID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(wallets) != 2 ||
+		wallets[0].Currency != "EUR" || wallets[0].Balance != 50 || wallets[0].Version != 1 ||
+		wallets[1].Currency != "USD" || wallets[1].Balance != 700 || wallets[1].Version != 2 {
+		t.Errorf("unexpected wallets: %+v", wallets)
+	}
+	if wallets[0].ID.String() != walletID {
+		t.Errorf("expected wallet %s, got %s", walletID, wallets[0].ID)
+	}
+
+	txs := listTransactions(t, repo, walletID)
+	want := []model.Transaction{
+		{Currency: "EUR", Type: model.OpDeposit, Amount: 50, BalanceAfter: 50},
+		{Currency: "USD", Type: model.OpWithdraw, Amount: -300, BalanceAfter: 700},
+		{Currency: "USD", Type: model.OpDeposit, Amount: 1000, BalanceAfter: 1000},
+	}
+	if len(txs) != len(want) {
+		t.Fatalf("expected %d transactions, got %+v", len(want), txs)
+	}
+	for i, tx := range txs {
+		if tx.Currency != want[i].Currency || tx.Type != want[i].Type || tx.Amount != want[i].Amount || tx.BalanceAfter != want[i].BalanceAfter {
+			t.Errorf("transaction %d: expected %+v, got %+v", i, want[i], tx)
+		}
+	}
+	if txs[0].ID <= txs[1].ID || txs[1].ID <= txs[2].ID {
+		t.Errorf("expected transactions newest first, got ids %d, %d, %d", txs[0].ID, txs[1].ID, txs[2].ID)
+	}
+}
+
+func testUpdateBalanceErrors(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	walletID := uuid.NewString()
+
+	deposit(t, repo, walletID, "USD", 100)
+
+	tests := []struct {
+		name      string
+		walletID  string
+		currency  string
+		amount    int64
+		ifVersion int64
+		want      error
+	}{
+		{"withdraw from missing wallet", uuid.NewString(), "USD", -10, 0, appErr.ErrWalletNotFound},
+		{"withdraw other currency", walletID, "EUR", -10, 0, appErr.ErrCurrencyMismatch},
+		{"version of missing balance", walletID, "EUR", 10, 1, appErr.ErrVersionMismatch},
+		{"stale version", walletID, "USD", 10, 2, appErr.ErrVersionMismatch},
+		{"insufficient funds", walletID, "USD", -101, 0, appErr.ErrInsufficientFunds},
+	}
+
+	for _, tt := range tests {
+		err := repo.UpdateBalance(ctx, tt.walletID, tt.currency, tt.amount, tt.ifVersion)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	err := repo.UpdateBalance(ctx, walletID, "USD", -150, 0)
+	if e, ok := appErr.As(err); !ok || e.Details["available"] != int64(100) || e.Details["requested"] != int64(150) {
+		t.Errorf("expected the available balance in the details, got %v", err)
+	}
+
+	if err := repo.UpdateBalance(ctx, walletID, "USD", -40, 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wallet := getWallet(t, repo, walletID, "USD")
+	if wallet.Balance != 60 || wallet.Version != 2 {
+		t.Errorf("expected 60 at version 2, got %+v", wallet)
+	}
+	if txs := listTransactions(t, repo, walletID); len(txs) != 2 {
+		t.Errorf("expected refused operations to leave no transactions, got %+v", txs)
+	}
+}
+
+func testListTransactions(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	walletID := uuid.NewString()
+
+	deposit(t, repo, walletID, "USD", 100)
+	deposit(t, repo, walletID, "USD", 200)
+	deposit(t, repo, walletID, "EUR", 50)
+	deposit(t, repo, walletID, "USD", -30)
+
+	all := listTransactions(t, repo, walletID)
+	if len(all) != 4 {
+		t.Fatalf("expected 4 transactions, got %+v", all)
+	}
+
+	tests := []struct {
+		name    string
+		filter  model.TransactionFilter
+		amounts []int64
+	}{
+		{"currency", model.TransactionFilter{Currency: "USD", Limit: 10}, []int64{-30, 200, 100}},
+		{"type", model.TransactionFilter{Type: model.OpDeposit, Limit: 10}, []int64{50, 200, 100}},
+		{"limit", model.TransactionFilter{Limit: 2}, []int64{-30, 50}},
+		{"before id", model.TransactionFilter{BeforeID: all[1].ID, Limit: 10}, []int64{200, 100}},
+		{"from", model.TransactionFilter{From: time.Now().Add(time.Hour), Limit: 10}, nil},
+		{"to", model.TransactionFilter{To: time.Now().Add(time.Hour), Currency: "EUR", Limit: 10}, []int64{50}},
+	}
+
+	for _, tt := range tests {
+		txs, err := repo.ListTransactions(ctx, walletID, tt.filter)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+
+		var amounts []int64
+		for _, tx := range txs {
+			amounts = append(amounts, tx.Amount)
+		}
+		if !slices.Equal(amounts, tt.amounts) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.amounts, amounts)
+		}
+	}
+}
+
+func testTransfer(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	from, to := uuid.NewString(), uuid.NewString()
+
+	deposit(t, repo, from, "USD", 1000)
+	deposit(t, repo, to, "USD", 100)
+
+	res, err := repo.Transfer(ctx, from, to, "USD", 300)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.FromBalance != 700 || res.ToBalance != 400 || res.Currency != "USD" {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	expectBalance(t, repo, from, "USD", 700, 0)
+	expectBalance(t, repo, to, "USD", 400, 0)
+
+	for _, leg := range []struct {
+		walletID     string
+		counterparty string
+		op           string
+		amount       int64
+	}{
+		{from, to, model.OpTransferOut, -300},
+		{to, from, model.OpTransferIn, 300},
+	} {
+		if txs := listTransactions(t, repo, leg.walletID); txs[0].Type != leg.op || txs[0].Amount != leg.amount {
+			t.Errorf("expected a %s of %d, got %+v", leg.op, leg.amount, txs[0])
+		}
+
+		sequenceEvents(t, repo)
+		events, err := repo.WalletEventsSince(ctx, leg.walletID, 1, 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(events) != 1 || events[0].Type != leg.op || events[0].Reference != leg.counterparty {
+			t.Errorf("expected a %s event referencing %s, got %+v", leg.op, leg.counterparty, events)
+		}
+	}
+
+	_, err = repo.Transfer(ctx, from, uuid.NewString(), "USD", 10)
+	expectErr(t, err, appErr.ErrWalletNotFound)
+
+	_, err = repo.Transfer(ctx, from, to, "EUR", 10)
+	expectErr(t, err, appErr.ErrCurrencyMismatch)
+
+	_, err = repo.Transfer(ctx, from, to, "USD", 701)
+	expectErr(t, err, appErr.ErrInsufficientFunds)
+
+	expectBalance(t, repo, from, "USD", 700, 0)
+	expectBalance(t, repo, to, "USD", 400, 0)
+}
+
+func testTrialBalance(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	a, b := uuid.NewString(), uuid.NewString()
+
+	deposit(t, repo, a, "USD", 1000)
+	deposit(t, repo, a, "USD", -200)
+	deposit(t, repo, b, "USD", 50)
+	if _, err := repo.Transfer(ctx, a, b, "USD", 300); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	balances, err := repo.TrialBalance(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]model.AccountBalance{
+		model.AccountCashIn:  {Debit: 1050},
+		model.AccountCashOut: {Credit: 200},
+		model.AccountWallets: {Debit: 500, Credit: 1350},
+	}
+	if len(balances) != len(want) {
+		t.Fatalf("expected %d accounts, got %+v", len(want), balances)
+	}
+	for _, got := range balances {
+		w, ok := want[got.Account]
+		if !ok || got.Currency != "USD" || got.Debit != w.Debit || got.Credit != w.Credit {
+			t.Errorf("unexpected account %+v", got)
+		}
+	}
+}
+
+func testWithinTx(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	committed, rolledBack := uuid.NewString(), uuid.NewString()
+
+	err := repo.WithinTx(ctx, func(ctx context.Context) error {
+		if err := repo.UpdateBalance(ctx, committed, "USD", 100, 0); err != nil {
+			return err
+		}
+
+		// A refused operation leaves the transaction usable.
+		err := repo.UpdateBalance(ctx, committed, "USD", -500, 0)
+		if !errors.Is(err, appErr.ErrInsufficientFunds) {
+			t.Errorf("expected insufficient funds, got %v", err)
+		}
+
+		return repo.UpdateBalance(ctx, committed, "USD", 50, 0)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if wallet := getWallet(t, repo, committed, "USD"); wallet.Balance != 150 || wallet.Version != 2 {
+		t.Errorf("expected 150 at version 2, got %+v", wallet)
+	}
+
+	boom := errors.New("boom")
+	err = repo.WithinTx(ctx, func(ctx context.Context) error {
+		if err := repo.UpdateBalance(ctx, rolledBack, "USD", 100, 0); err != nil {
+			return err
+		}
+		if err := repo.UpdateBalance(ctx, committed, "USD", -100, 0); err != nil {
+			return err
+		}
+		return boom
+	})
+	if err != boom {
+		t.Fatalf("expected the error of fn, got %v", err)
+	}
+
+	_, err = repo.GetWallet(ctx, rolledBack, "USD")
+	expectErr(t, err, appErr.ErrWalletNotFound)
+	expectBalance(t, repo, committed, "USD", 150, 0)
+
+	sequenceEvents(t, repo)
+	if seq, err := repo.LastEventSequence(ctx, rolledBack); err != nil || seq != 0 {
+		t.Errorf("expected no events, got %d (%v)", seq, err)
+	}
+	if txs := listTransactions(t, repo, committed); len(txs) != 2 {
+		t.Errorf("expected 2 transactions, got %+v", txs)
+	}
+}
+
+func testConcurrentUpdates(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	a, b := uuid.NewString(), uuid.NewString()
+
+	deposit(t, repo, a, "USD", 1000)
+	deposit(t, repo, b, "USD", 1000)
+
+	const workers, rounds = 10, 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*rounds*2)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				from, to := a, b
+				if (w+i)%2 == 1 {
+					from, to = b, a
+				}
+				if _, err := repo.Transfer(ctx, from, to, "USD", 1); err != nil {
+					errs <- err
+				}
+				if err := repo.UpdateBalance(ctx, a, "USD", 1, 0); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	walletA, walletB := getWallet(t, repo, a, "USD"), getWallet(t, repo, b, "USD")
+	if walletA.Balance+walletB.Balance != 2000+workers*rounds {
+		t.Errorf("expected a total of %d, got %d + %d", 2000+workers*rounds, walletA.Balance, walletB.Balance)
+	}
+	if walletA.Version != 1+2*workers*rounds {
+		t.Errorf("expected version %d, got %d", 1+2*workers*rounds, walletA.Version)
+	}
+
+	sequenceEvents(t, repo)
+	if seq, _ := repo.LastEventSequence(ctx, a); seq != walletA.Version {
+		t.Errorf("expected an event per change, got sequence %d", seq)
+	}
+
+	// Sequences follow the order the changes committed in: every event
+	// starts from the balance the one before it left.
+	events, err := repo.WalletEventsSince(ctx, a, 0, 1000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 1; i < len(events); i++ {
+		if events[i].Sequence != events[i-1].Sequence+1 || events[i].BalanceAfter != events[i-1].BalanceAfter+events[i].Amount {
+			t.Errorf("event %+v does not follow %+v", events[i], events[i-1])
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+)
+
+func testWebhooks(t *testing.T, repo Repository) {
+	ctx := context.Background()
+
+	first, err := repo.CreateWebhook(ctx, "https://first.example", "secret-1", []string{model.WebhookBalanceChanged})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := repo.CreateWebhook(ctx, "https://second.example", "secret-2", []string{model.WebhookTransferCompleted, model.WebhookBalanceChanged})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := repo.GetWebhook(ctx, second.ID.String())
+	if err != nil || !got.Active || got.URL != "https://second.example" || got.Secret != "secret-2" || len(got.Events) != 2 {
+		t.Errorf("unexpected webhook: %+v (%v)", got, err)
+	}
+
+	_, err = repo.GetWebhook(ctx, uuid.NewString())
+	expectErr(t, err, appErr.ErrWebhookNotFound)
+	expectErr(t, repo.DeactivateWebhook(ctx, uuid.NewString()), appErr.ErrWebhookNotFound)
+
+	for _, step := range []struct {
+		eventType string
+		want      int64
+	}{
+		{model.WebhookBalanceChanged, 2},
+		{model.WebhookTransferCompleted, 1},
+		{model.WebhookHoldExpired, 0},
+	} {
+		n, err := repo.EnqueueWebhookDeliveries(ctx, step.eventType, []byte(`{}`))
+		if err != nil || n != step.want {
+			t.Errorf("%s: expected %d deliveries, got %d (%v)", step.eventType, step.want, n, err)
+		}
+	}
+
+	if err := repo.DeactivateWebhook(ctx, first.ID.String()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n, _ := repo.EnqueueWebhookDeliveries(ctx, model.WebhookBalanceChanged, []byte(`{}`)); n != 1 {
+		t.Errorf("expected a delivery to the active webhook only, got %d", n)
+	}
+
+	// Deliveries to the deactivated webhook stay pending but are not sent.
+	now := time.Now().Add(time.Minute)
+	claimed := claim(t, repo, now)
+	if len(claimed) != 3 {
+		t.Fatalf("expected 3 deliveries, got %+v", claimed)
+	}
+	for _, d := range claimed {
+		if d.WebhookID != second.ID || d.URL != second.URL || d.Secret != "secret-2" || d.Status != model.DeliveryPending {
+			t.Errorf("unexpected delivery: %+v", d)
+		}
+	}
+	if again := claim(t, repo, now); len(again) != 0 {
+		t.Errorf("expected claimed deliveries to be held, got %+v", again)
+	}
+
+	if err := repo.MarkWebhookDelivered(ctx, claimed[0].ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := repo.FailWebhookDelivery(ctx, claimed[1].ID, model.DeliveryDead, now, "HTTP 500"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if all := deliveries(t, repo, ""); len(all) != 4 || all[0].ID <= all[1].ID {
+		t.Errorf("expected 4 deliveries newest first, got %+v", all)
+	}
+	if delivered := deliveries(t, repo, model.DeliveryDelivered); len(delivered) != 1 || delivered[0].Attempts != 1 {
+		t.Errorf("expected the delivered one, got %+v", delivered)
+	}
+	dead := deliveries(t, repo, model.DeliveryDead)
+	if len(dead) != 1 || dead[0].ID != claimed[1].ID || dead[0].LastError != "HTTP 500" {
+		t.Errorf("expected the dead one, got %+v", dead)
+	}
+
+	replayed, err := repo.ReplayWebhookDelivery(ctx, claimed[1].ID)
+	if err != nil || replayed.Status != model.DeliveryPending || replayed.Attempts != 0 || replayed.LastError != "" {
+		t.Errorf("unexpected replay: %+v (%v)", replayed, err)
+	}
+	if due := claim(t, repo, now); len(due) != 1 || due[0].ID != claimed[1].ID {
+		t.Errorf("expected the replayed delivery to be due, got %+v", due)
+	}
+
+	_, err = repo.ReplayWebhookDelivery(ctx, 1<<40)
+	expectErr(t, err, appErr.ErrDeliveryNotFound)
+}
+
+func claim(t *testing.T, repo Repository, now time.Time) []model.WebhookDelivery {
+	t.Helper()
+
+	claimed, err := repo.ClaimWebhookDeliveries(context.Background(), now, now.Add(time.Hour), 10)
+	if err != nil {
+		t.Fatalf("failed to claim deliveries: %v", err)
+	}
+	return claimed
+}
+
+func deliveries(t *testing.T, repo Repository, status string) []model.WebhookDelivery {
+	t.Helper()
+
+	list, err := repo.WebhookDeliveries(context.Background(), status, 10)
+	if err != nil {
+		t.Fatalf("failed to list deliveries: %v", err)
+	}
+	return list
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"math/rand"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const maxRetryDelay = time.Second
+
+// txRetries counts transaction retries by the SQLSTATE that caused them,
+// plus "exhausted" for transactions that failed after the last retry.
+// It is published on /debug/vars.
+var txRetries = expvar.NewMap("tx_retries")
+
+// retryable reports whether err is a transient conflict that Postgres
+// expects the client to resolve by running the transaction again.
+func retryable(err error) (pq.ErrorCode, bool) {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return "", false
+	}
+
+	switch pqErr.Code {
+	case "40001", "40P01": // serialization_failure, deadlock_detected
+		return pqErr.Code, true
+	}
+	return "", false
+}
+
+// backoff waits before retry attempt (0-based): the delay doubles with
+// every attempt and is jittered so that the transactions that collided do
+// not collide again.
+func (r *WalletRepository) backoff(ctx context.Context, attempt int) error {
+	d := r.retryDelay << attempt
+	if d <= 0 || d > maxRetryDelay {
+		d = maxRetryDelay
+	}
+	d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
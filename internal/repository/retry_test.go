@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/lib/pq"
+)
+
+func retryCount(key string) int64 {
+	if v, ok := txRetries.Get(key).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func TestInTx_RetriesSerializationFailure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db, WithRetry(3, time.Microsecond))
+
+	walletID := "550e8400-e29b-41d4-a716-446655440000"
+	before := retryCount("serialization_failure")
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE holds`).WillReturnError(&pq.Error{Code: "40001"})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE holds`).WillReturnError(&pq.Error{Code: "40P01"})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE holds`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	runs := 0
+	err = repo.WithinTx(context.Background(), func(ctx context.Context) error {
+		runs++
+		_, err := repo.conn(ctx).ExecContext(ctx, `UPDATE holds SET status = 'EXPIRED' WHERE wallet_id = $1`, walletID)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if runs != 3 {
+		t.Errorf("expected 3 runs, got %d", runs)
+	}
+	if n := retryCount("serialization_failure") - before; n != 1 {
+		t.Errorf("expected 1 serialization_failure retry, got %d", n)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestInTx_GivesUpAfterMaxRetries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db, WithRetry(1, time.Microsecond))
+
+	before := retryCount("exhausted")
+
+	for i := 0; i < 2; i++ {
+		mock.ExpectBegin()
+		mock.ExpectCommit().WillReturnError(&pq.Error{Code: "40001"})
+	}
+
+	err = repo.WithinTx(context.Background(), func(ctx context.Context) error { return nil })
+	if !errors.Is(err, appErr.ErrConcurrentUpdate) {
+		t.Errorf("expected ErrConcurrentUpdate, got %v", err)
+	}
+
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		t.Errorf("expected the Postgres error as the cause, got %v", err)
+	}
+
+	if n := retryCount("exhausted") - before; n != 1 {
+		t.Errorf("expected exhausted to grow by 1, got %d", n)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestInTx_DoesNotRetryOtherErrors(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db, WithRetry(3, time.Microsecond))
+
+	uniqueViolation := &pq.Error{Code: "23505"}
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	err = repo.WithinTx(context.Background(), func(ctx context.Context) error { return uniqueViolation })
+	if err != uniqueViolation {
+		t.Errorf("expected the original error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestInTx_JoinedTransactionIsNotRetried(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db, WithRetry(1, time.Microsecond))
+
+	walletID := "550e8400-e29b-41d4-a716-446655440000"
+
+	// The inner UpdateBalance fails once; only the outer transaction,
+	// which owns the BEGIN, runs again.
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+		WillReturnError(&pq.Error{Code: "40P01"})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+		WillReturnRows(walletRow(walletID, 0, 0))
+	expectNotFrozen(mock, walletID)
+	mock.ExpectRollback()
+
+	runs := 0
+	err = repo.WithinTx(context.Background(), func(ctx context.Context) error {
+		runs++
+		return repo.UpdateBalance(ctx, walletID, testCurrency, -100, 0)
+	})
+	if !errors.Is(err, appErr.ErrInsufficientFunds) {
+		t.Errorf("expected ErrInsufficientFunds, got %v", err)
+	}
+	if runs != 2 {
+		t.Errorf("expected 2 runs, got %d", runs)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
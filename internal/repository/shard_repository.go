@@ -0,0 +1,326 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"math/rand"
+
+	"github.com/Hlompy/Wallet/internal/model"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// shardedWalletQuery reads a hot wallet with its shards added up. The
+// version is summed as well, so it still grows with every change.
+const shardedWalletQuery = `SELECT w.id, w.currency, w.balance + COALESCE(SUM(s.balance), 0), w.held,
+		w.version + COALESCE(SUM(s.version), 0)
+	FROM wallets w LEFT JOIN wallet_shards s ON s.wallet_id = w.id AND s.currency = w.currency`
+
+func (r *WalletRepository) isSharded(walletID string) bool {
+	if len(r.hotWallets) == 0 {
+		return false
+	}
+
+	id, err := uuid.Parse(walletID)
+	if err != nil {
+		return false
+	}
+	return r.hotWallets[id.String()]
+}
+
+// updateShardedBalance is UpdateBalance for a hot wallet. Deposits go to
+// a random shard; withdrawals drain shards in shard order and fall back
+// to the wallet row only for what the shards cannot cover.
+func (r *WalletRepository) updateShardedBalance(
+	ctx context.Context,
+	walletID string,
+	currency string,
+	amount int64,
+) error {
+
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		if amount > 0 {
+			return depositToShard(ctx, tx, walletID, currency, rand.Intn(r.shards), amount)
+		}
+		return withdrawFromShards(ctx, tx, walletID, currency, -amount)
+	})
+}
+
+func depositToShard(
+	ctx context.Context,
+	tx *sql.Tx,
+	walletID string,
+	currency string,
+	shard int,
+	amount int64,
+) error {
+
+	// Locking the shard is enough to queue up with FreezeWallet; the
+	// wallet row stays unlocked.
+	_, err := tx.ExecContext(
+		ctx,
+		`SELECT 1 FROM wallet_shards WHERE wallet_id = $1 AND currency = $2 AND shard = $3 FOR UPDATE`,
+		walletID,
+		currency,
+		shard,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := checkNotFrozen(ctx, tx, walletID); err != nil {
+		return err
+	}
+
+	// Reads start from the wallet row, so it has to exist even while the
+	// whole balance sits in shards.
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO wallets (id, currency, balance) VALUES ($1, $2, 0) ON CONFLICT DO NOTHING`,
+		walletID,
+		currency,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO wallet_shards (wallet_id, currency, shard, balance) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (wallet_id, currency, shard) DO UPDATE SET balance = wallet_shards.balance + EXCLUDED.balance`,
+		walletID,
+		currency,
+		shard,
+		amount,
+	)
+	if err != nil {
+		return err
+	}
+
+	return recordShardedOperation(ctx, tx, walletID, currency, amount)
+}
+
+// withdrawFromShards locks shards one by one until they cover amount.
+// Shards are always locked in shard order and before the wallet row, the
+// same order foldShards uses, so the two cannot deadlock.
+func withdrawFromShards(
+	ctx context.Context,
+	tx *sql.Tx,
+	walletID string,
+	currency string,
+	amount int64,
+) error {
+
+	shards, err := nonEmptyShards(ctx, tx, walletID, currency)
+	if err != nil {
+		return err
+	}
+
+	taken := make(map[int]int64, len(shards))
+	remaining := amount
+	for _, shard := range shards {
+		if remaining == 0 {
+			break
+		}
+
+		var balance int64
+		err := tx.QueryRowContext(
+			ctx,
+			`SELECT balance FROM wallet_shards WHERE wallet_id = $1 AND currency = $2 AND shard = $3 FOR UPDATE`,
+			walletID,
+			currency,
+			shard,
+		).Scan(&balance)
+		if err != nil {
+			return err
+		}
+
+		if n := min(balance, remaining); n > 0 {
+			taken[shard] = n
+			remaining -= n
+		}
+	}
+
+	// A freeze is checked under the locks the withdrawal takes: the
+	// shards, and the wallet row when they do not cover it.
+	if remaining == 0 {
+		if err := checkNotFrozen(ctx, tx, walletID); err != nil {
+			return err
+		}
+	} else {
+		wallet, err := lockWalletRow(ctx, tx, walletID, currency)
+		if err == sql.ErrNoRows {
+			return walletMissing(ctx, tx, walletID)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := checkNotFrozen(ctx, tx, walletID); err != nil {
+			return err
+		}
+
+		if wallet.Available() < remaining {
+			wallet.Balance += amount - remaining
+			return insufficientFunds(wallet, amount)
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`UPDATE wallets SET balance = balance - $1 WHERE id = $2 AND currency = $3`,
+			remaining,
+			walletID,
+			currency,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, shard := range shards {
+		n, ok := taken[shard]
+		if !ok {
+			continue
+		}
+
+		_, err := tx.ExecContext(
+			ctx,
+			`UPDATE wallet_shards SET balance = balance - $1 WHERE wallet_id = $2 AND currency = $3 AND shard = $4`,
+			n,
+			walletID,
+			currency,
+			shard,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return recordShardedOperation(ctx, tx, walletID, currency, -amount)
+}
+
+func nonEmptyShards(ctx context.Context, tx *sql.Tx, walletID, currency string) ([]int, error) {
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT shard FROM wallet_shards WHERE wallet_id = $1 AND currency = $2 AND balance > 0 ORDER BY shard`,
+		walletID,
+		currency,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shards []int
+	for rows.Next() {
+		var shard int
+		if err := rows.Scan(&shard); err != nil {
+			return nil, err
+		}
+		shards = append(shards, shard)
+	}
+
+	return shards, rows.Err()
+}
+
+// recordShardedOperation records the operation with the balance this
+// transaction sees afterwards. Other shards change concurrently, so for a
+// hot wallet balance_after is a snapshot rather than a running total.
+func recordShardedOperation(ctx context.Context, tx *sql.Tx, walletID, currency string, amount int64) error {
+	var balance int64
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT w.balance + COALESCE((SELECT SUM(s.balance) FROM wallet_shards s WHERE s.wallet_id = w.id AND s.currency = w.currency), 0)
+		FROM wallets w WHERE w.id = $1 AND w.currency = $2`,
+		walletID,
+		currency,
+	).Scan(&balance)
+	if err != nil {
+		return err
+	}
+
+	return recordOperation(ctx, tx, walletID, currency, amount, balance)
+}
+
+// foldShards locks a hot wallet's shards and then its row, and moves the
+// shard balances onto the row. Operations that lock the wallet row
+// (transfers, holds, conversions) then see and may rewrite the whole
+// balance.
+func foldShards(ctx context.Context, tx *sql.Tx, walletID, currency string) (model.Wallet, error) {
+	var balance, versions int64
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT COALESCE(SUM(balance), 0), COALESCE(SUM(version), 0) FROM (
+			SELECT balance, version FROM wallet_shards WHERE wallet_id = $1 AND currency = $2 ORDER BY shard FOR UPDATE
+		) s`,
+		walletID,
+		currency,
+	).Scan(&balance, &versions)
+	if err != nil {
+		return model.Wallet{}, err
+	}
+
+	wallet, err := lockWalletRow(ctx, tx, walletID, currency)
+	if err != nil {
+		return model.Wallet{}, err
+	}
+
+	// The version clients were given includes the shards.
+	wallet.Version += versions
+	if balance == 0 {
+		return wallet, nil
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`UPDATE wallet_shards SET balance = 0 WHERE wallet_id = $1 AND currency = $2 AND balance > 0`,
+		walletID,
+		currency,
+	)
+	if err != nil {
+		return model.Wallet{}, err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`UPDATE wallets SET balance = balance + $1 WHERE id = $2 AND currency = $3`,
+		balance,
+		walletID,
+		currency,
+	)
+	if err != nil {
+		return model.Wallet{}, err
+	}
+
+	wallet.Balance += balance
+	return wallet, nil
+}
+
+// FoldShards moves the shards of wallets that are no longer sharded back
+// onto their wallet rows, carrying the shard versions over so the version
+// never goes backwards. It returns the number of balances folded.
+func (r *WalletRepository) FoldShards(ctx context.Context) (int64, error) {
+	hot := make([]string, 0, len(r.hotWallets))
+	for id := range r.hotWallets {
+		hot = append(hot, id)
+	}
+
+	res, err := r.conn(ctx).ExecContext(
+		ctx,
+		`WITH folded AS (
+			DELETE FROM wallet_shards WHERE wallet_id <> ALL($1::uuid[])
+			RETURNING wallet_id, currency, balance, version
+		), sums AS (
+			SELECT wallet_id, currency, SUM(balance) AS balance, SUM(version) AS version
+			FROM folded GROUP BY wallet_id, currency
+		)
+		UPDATE wallets w SET balance = w.balance + sums.balance, version = w.version + sums.version
+		FROM sums WHERE w.id = sums.wallet_id AND w.currency = sums.currency`,
+		pq.Array(hot),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
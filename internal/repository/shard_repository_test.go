@@ -0,0 +1,293 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+	"github.com/lib/pq"
+)
+
+const testHotWalletID = "550e8400-e29b-41d4-a716-446655440000"
+
+func expectShardedBalance(mock sqlmock.Sqlmock, balance int64) {
+	mock.ExpectQuery(`SELECT w.balance \+ COALESCE\(\(SELECT SUM\(s.balance\) FROM wallet_shards s`).
+		WithArgs(testHotWalletID, testCurrency).
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(balance))
+}
+
+func expectLockShard(mock sqlmock.Sqlmock, shard int, balance int64) {
+	mock.ExpectQuery(`SELECT balance FROM wallet_shards WHERE wallet_id = \$1 AND currency = \$2 AND shard = \$3 FOR UPDATE`).
+		WithArgs(testHotWalletID, testCurrency, shard).
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(balance))
+}
+
+func expectTakeFromShard(mock sqlmock.Sqlmock, shard int, amount int64) {
+	mock.ExpectExec(`UPDATE wallet_shards SET balance = balance - \$1 WHERE wallet_id = \$2 AND currency = \$3 AND shard = \$4`).
+		WithArgs(amount, testHotWalletID, testCurrency, shard).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func expectNonEmptyShards(mock sqlmock.Sqlmock, shards ...int) {
+	rows := sqlmock.NewRows([]string{"shard"})
+	for _, s := range shards {
+		rows.AddRow(s)
+	}
+	mock.ExpectQuery(`SELECT shard FROM wallet_shards WHERE wallet_id = \$1 AND currency = \$2 AND balance > 0 ORDER BY shard`).
+		WithArgs(testHotWalletID, testCurrency).
+		WillReturnRows(rows)
+}
+
+func TestUpdateBalanceSharded_Deposit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db, WithShardedWallets(8, testHotWalletID))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT 1 FROM wallet_shards WHERE wallet_id = \$1 AND currency = \$2 AND shard = \$3 FOR UPDATE`).
+		WithArgs(testHotWalletID, testCurrency, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectNotFrozen(mock, testHotWalletID)
+	mock.ExpectExec(`INSERT INTO wallets \(id, currency, balance\) VALUES \(\$1, \$2, 0\) ON CONFLICT DO NOTHING`).
+		WithArgs(testHotWalletID, testCurrency).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO wallet_shards \(wallet_id, currency, shard, balance\) VALUES \(\$1, \$2, \$3, \$4\)\s+ON CONFLICT`).
+		WithArgs(testHotWalletID, testCurrency, sqlmock.AnyArg(), int64(500)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectShardedBalance(mock, 1500)
+	expectJournal(mock, "DEPOSIT", 1,
+		model.JournalEntry{Account: "system:cash_in", Currency: testCurrency, Amount: 500},
+		model.JournalEntry{Account: "wallet:" + testHotWalletID, Currency: testCurrency, Amount: -500},
+	)
+	mock.ExpectExec(`INSERT INTO transactions`).
+		WithArgs(int64(1), testHotWalletID, testCurrency, "DEPOSIT", int64(500), int64(1500)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectEvent(mock, testHotWalletID, testCurrency, "DEPOSIT", int64(500), int64(1500), "")
+	mock.ExpectCommit()
+
+	if err := repo.UpdateBalance(context.Background(), testHotWalletID, testCurrency, 500, 0); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestUpdateBalanceSharded_WithdrawAcrossShards(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db, WithShardedWallets(8, testHotWalletID))
+
+	mock.ExpectBegin()
+	expectNonEmptyShards(mock, 1, 4, 6)
+	expectLockShard(mock, 1, 100)
+	expectLockShard(mock, 4, 300)
+	expectNotFrozen(mock, testHotWalletID)
+	expectTakeFromShard(mock, 1, 100)
+	expectTakeFromShard(mock, 4, 150)
+	expectShardedBalance(mock, 900)
+	expectJournal(mock, "WITHDRAW", 2,
+		model.JournalEntry{Account: "wallet:" + testHotWalletID, Currency: testCurrency, Amount: 250},
+		model.JournalEntry{Account: "system:cash_out", Currency: testCurrency, Amount: -250},
+	)
+	mock.ExpectExec(`INSERT INTO transactions`).
+		WithArgs(int64(2), testHotWalletID, testCurrency, "WITHDRAW", int64(-250), int64(900)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectEvent(mock, testHotWalletID, testCurrency, "WITHDRAW", int64(-250), int64(900), "")
+	mock.ExpectCommit()
+
+	if err := repo.UpdateBalance(context.Background(), testHotWalletID, testCurrency, -250, 0); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestUpdateBalanceSharded_WithdrawFromWalletRow(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db, WithShardedWallets(8, testHotWalletID))
+
+	mock.ExpectBegin()
+	expectNonEmptyShards(mock, 2)
+	expectLockShard(mock, 2, 100)
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+		WithArgs(testHotWalletID, testCurrency).
+		WillReturnRows(walletRow(testHotWalletID, 500, 200))
+	expectNotFrozen(mock, testHotWalletID)
+	mock.ExpectExec(`UPDATE wallets SET balance = balance - \$1 WHERE id = \$2 AND currency = \$3`).
+		WithArgs(int64(300), testHotWalletID, testCurrency).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectTakeFromShard(mock, 2, 100)
+	expectShardedBalance(mock, 200)
+	expectJournal(mock, "WITHDRAW", 3,
+		model.JournalEntry{Account: "wallet:" + testHotWalletID, Currency: testCurrency, Amount: 400},
+		model.JournalEntry{Account: "system:cash_out", Currency: testCurrency, Amount: -400},
+	)
+	mock.ExpectExec(`INSERT INTO transactions`).
+		WithArgs(int64(3), testHotWalletID, testCurrency, "WITHDRAW", int64(-400), int64(200)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectEvent(mock, testHotWalletID, testCurrency, "WITHDRAW", int64(-400), int64(200), "")
+	mock.ExpectCommit()
+
+	if err := repo.UpdateBalance(context.Background(), testHotWalletID, testCurrency, -400, 0); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestUpdateBalanceSharded_InsufficientFunds(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db, WithShardedWallets(8, testHotWalletID))
+
+	mock.ExpectBegin()
+	expectNonEmptyShards(mock, 0)
+	expectLockShard(mock, 0, 100)
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+		WithArgs(testHotWalletID, testCurrency).
+		WillReturnRows(walletRow(testHotWalletID, 500, 450))
+	expectNotFrozen(mock, testHotWalletID)
+	mock.ExpectRollback()
+
+	err = repo.UpdateBalance(context.Background(), testHotWalletID, testCurrency, -200, 0)
+	if !errors.Is(err, appErr.ErrInsufficientFunds) {
+		t.Fatalf("expected ErrInsufficientFunds, got %v", err)
+	}
+
+	e, _ := appErr.As(err)
+	if e.Details["balance"] != int64(600) || e.Details["available"] != int64(150) {
+		t.Errorf("unexpected details: %v", e.Details)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestUpdateBalanceSharded_IfVersionFoldsShards(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db, WithShardedWallets(8, testHotWalletID), WithCompareAndSet(3))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT COALESCE\(SUM\(balance\), 0\), COALESCE\(SUM\(version\), 0\) FROM \(\s+SELECT balance, version FROM wallet_shards WHERE wallet_id = \$1 AND currency = \$2 ORDER BY shard FOR UPDATE`).
+		WithArgs(testHotWalletID, testCurrency).
+		WillReturnRows(sqlmock.NewRows([]string{"balance", "version"}).AddRow(int64(400), int64(5)))
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+		WithArgs(testHotWalletID, testCurrency).
+		WillReturnRows(walletRow(testHotWalletID, 100, 0))
+	mock.ExpectExec(`UPDATE wallet_shards SET balance = 0 WHERE wallet_id = \$1 AND currency = \$2 AND balance > 0`).
+		WithArgs(testHotWalletID, testCurrency).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(`UPDATE wallets SET balance = balance \+ \$1 WHERE id = \$2 AND currency = \$3`).
+		WithArgs(int64(400), testHotWalletID, testCurrency).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectNotFrozen(mock, testHotWalletID)
+	mock.ExpectExec(`UPDATE wallets SET balance = \$1 WHERE id = \$2 AND currency = \$3$`).
+		WithArgs(int64(450), testHotWalletID, testCurrency).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectJournal(mock, "WITHDRAW", 4,
+		model.JournalEntry{Account: "wallet:" + testHotWalletID, Currency: testCurrency, Amount: 50},
+		model.JournalEntry{Account: "system:cash_out", Currency: testCurrency, Amount: -50},
+	)
+	mock.ExpectExec(`INSERT INTO transactions`).
+		WithArgs(int64(4), testHotWalletID, testCurrency, "WITHDRAW", int64(-50), int64(450)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectEvent(mock, testHotWalletID, testCurrency, "WITHDRAW", int64(-50), int64(450), "")
+	mock.ExpectCommit()
+
+	// The version a client saw is the wallet row's plus the shards'.
+	if err := repo.UpdateBalance(context.Background(), testHotWalletID, testCurrency, -50, testVersion+5); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestGetWallet_Sharded(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db, WithShardedWallets(8, testHotWalletID))
+
+	// Wallet ids are matched in canonical form.
+	mock.ExpectQuery(`SELECT w.id, w.currency, w.balance \+ COALESCE\(SUM\(s.balance\), 0\), w.held,\s+w.version \+ COALESCE\(SUM\(s.version\), 0\)\s+FROM wallets w LEFT JOIN wallet_shards s .* WHERE w.id = \$1 AND w.currency = \$2 GROUP BY w.id, w.currency`).
+		WithArgs("550E8400-E29B-41D4-A716-446655440000", testCurrency).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "currency", "balance", "held", "version"}).
+			AddRow(testHotWalletID, testCurrency, int64(1200), int64(100), int64(31)))
+
+	wallet, err := repo.GetWallet(context.Background(), "550E8400-E29B-41D4-A716-446655440000", testCurrency)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if wallet.Balance != 1200 || wallet.Available() != 1100 || wallet.Version != 31 {
+		t.Errorf("unexpected wallet: %+v", wallet)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestFoldShards(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db, WithShardedWallets(8, testHotWalletID))
+
+	mock.ExpectExec(`WITH folded AS \(\s+DELETE FROM wallet_shards WHERE wallet_id <> ALL\(\$1::uuid\[\]\)`).
+		WithArgs(pq.Array([]string{testHotWalletID})).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	n, err := repo.FoldShards(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if n != 2 {
+		t.Errorf("expected 2 folded balances, got %d", n)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
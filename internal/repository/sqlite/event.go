@@ -0,0 +1,201 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+// recordEvent writes an outbox event, normally that of a ledger row;
+// reference links it to the other wallet of a transfer or to a hold. The
+// wallet is notified once the transaction commits.
+func recordEvent(
+	ctx context.Context,
+	tx *txn,
+	walletID string,
+	currency string,
+	eventType string,
+	amount int64,
+	balanceAfter int64,
+	reference string,
+) error {
+
+	var sequence int64
+	err := tx.QueryRowContext(
+		ctx,
+		`INSERT INTO wallet_event_sequences (wallet_id, sequence) VALUES ($1, 1)
+		ON CONFLICT (wallet_id) DO UPDATE SET sequence = sequence + 1
+		RETURNING sequence`,
+		walletID,
+	).Scan(&sequence)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO wallet_events (wallet_id, sequence, currency, type, amount, balance_after, reference)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		walletID,
+		sequence,
+		currency,
+		eventType,
+		amount,
+		balanceAfter,
+		reference,
+	)
+	if err != nil {
+		return err
+	}
+
+	tx.notified = append(tx.notified, walletID)
+	return nil
+}
+
+// SequenceEvents has nothing to number: transactions hold the database's
+// write lock, so recordEvent numbers events as they are written without
+// holding up anyone.
+func (r *Repository) SequenceEvents(ctx context.Context, limit int) (int, error) {
+	return 0, nil
+}
+
+// LockEventRelay always succeeds: a relay runs in a transaction, and so
+// holds the database's write lock, which no other relay can take.
+func (r *Repository) LockEventRelay(ctx context.Context) (bool, error) {
+	return true, nil
+}
+
+// PendingEvents returns up to limit undelivered events in commit order
+// per wallet, leaving out wallets whose oldest event waits for a retry
+// after now.
+func (r *Repository) PendingEvents(ctx context.Context, now time.Time, limit int) ([]model.WalletEvent, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`SELECT id, wallet_id, sequence, currency, type, amount, balance_after, reference, attempts, created_at
+		FROM wallet_events e
+		WHERE delivered_at IS NULL AND NOT EXISTS (
+			SELECT 1 FROM wallet_events b
+			WHERE b.wallet_id = e.wallet_id AND b.delivered_at IS NULL AND b.next_attempt_at > $1
+		)
+		ORDER BY id
+		LIMIT $2`,
+		timestamp(now),
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanEvents(rows)
+}
+
+// WalletEventsSince returns up to limit events of the wallet with a
+// sequence after the given one, in sequence order. Events deleted after
+// delivery are not returned, which leaves a gap before the first one.
+func (r *Repository) WalletEventsSince(ctx context.Context, walletID string, after int64, limit int) ([]model.WalletEvent, error) {
+	walletID, err := parseID(walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`SELECT id, wallet_id, sequence, currency, type, amount, balance_after, reference, attempts, created_at
+		FROM wallet_events
+		WHERE wallet_id = $1 AND sequence > $2
+		ORDER BY sequence
+		LIMIT $3`,
+		walletID,
+		after,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanEvents(rows)
+}
+
+// LastEventSequence returns the sequence of the wallet's latest event, 0
+// if it has none.
+func (r *Repository) LastEventSequence(ctx context.Context, walletID string) (int64, error) {
+	walletID, err := parseID(walletID)
+	if err != nil {
+		return 0, err
+	}
+
+	var sequence int64
+	err = r.conn(ctx).QueryRowContext(
+		ctx,
+		`SELECT sequence FROM wallet_event_sequences WHERE wallet_id = $1`,
+		walletID,
+	).Scan(&sequence)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+
+	return sequence, err
+}
+
+func (r *Repository) MarkEventsDelivered(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	list, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.conn(ctx).ExecContext(
+		ctx,
+		`UPDATE wallet_events SET delivered_at = $1 WHERE id IN (SELECT value FROM json_each($2))`,
+		timestamp(time.Now()),
+		string(list),
+	)
+	return err
+}
+
+// RetryEvent records a failed delivery; the event is due again at at.
+func (r *Repository) RetryEvent(ctx context.Context, id int64, at time.Time, msg string) error {
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		`UPDATE wallet_events SET attempts = attempts + 1, next_attempt_at = $1, last_error = $2 WHERE id = $3`,
+		timestamp(at),
+		msg,
+		id,
+	)
+	return err
+}
+
+func (r *Repository) DeleteDeliveredEvents(ctx context.Context, deliveredBefore time.Time) (int64, error) {
+	res, err := r.conn(ctx).ExecContext(
+		ctx,
+		`DELETE FROM wallet_events WHERE delivered_at < $1`,
+		timestamp(deliveredBefore),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func scanEvents(rows *sql.Rows) ([]model.WalletEvent, error) {
+	defer rows.Close()
+
+	var events []model.WalletEvent
+	for rows.Next() {
+		var e model.WalletEvent
+		err := rows.Scan(&e.ID, &e.WalletID, &e.Sequence, &e.Currency, &e.Type, &e.Amount, &e.BalanceAfter, &e.Reference, &e.Attempts, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
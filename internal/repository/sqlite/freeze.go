@@ -0,0 +1,88 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+const freezeColumns = `wallet_id, reason, frozen_at`
+
+// FreezeWallet freezes the wallet. Freezing a frozen wallet again only
+// replaces the reason.
+func (r *Repository) FreezeWallet(ctx context.Context, walletID, reason string) (model.WalletFreeze, error) {
+	walletID, err := parseID(walletID)
+	if err != nil {
+		return model.WalletFreeze{}, err
+	}
+
+	var f model.WalletFreeze
+	err = r.conn(ctx).QueryRowContext(
+		ctx,
+		`INSERT INTO wallet_freezes (wallet_id, reason) VALUES ($1, $2)
+		ON CONFLICT (wallet_id) DO UPDATE SET reason = excluded.reason
+		RETURNING `+freezeColumns,
+		walletID,
+		reason,
+	).Scan(&f.WalletID, &f.Reason, &f.FrozenAt)
+
+	return f, err
+}
+
+// UnfreezeWallet lifts the wallet's freeze, if it has one.
+func (r *Repository) UnfreezeWallet(ctx context.Context, walletID string) error {
+	walletID, err := parseID(walletID)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.conn(ctx).ExecContext(ctx, `DELETE FROM wallet_freezes WHERE wallet_id = $1`, walletID)
+	return err
+}
+
+// GetWalletFreeze returns the wallet's freeze, or nil when it is not
+// frozen.
+func (r *Repository) GetWalletFreeze(ctx context.Context, walletID string) (*model.WalletFreeze, error) {
+	walletID, err := parseID(walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	var f model.WalletFreeze
+	err = r.conn(ctx).QueryRowContext(
+		ctx,
+		`SELECT `+freezeColumns+` FROM wallet_freezes WHERE wallet_id = $1`,
+		walletID,
+	).Scan(&f.WalletID, &f.Reason, &f.FrozenAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &f, nil
+}
+
+// checkNotFrozen refuses an operation on a frozen wallet. The transaction
+// holds the write lock, so no freeze can commit before it does. The reason
+// of the freeze is kept from the client.
+func checkNotFrozen(ctx context.Context, tx *txn, walletID string) error {
+	var frozen bool
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT EXISTS (SELECT 1 FROM wallet_freezes WHERE wallet_id = $1)`,
+		walletID,
+	).Scan(&frozen)
+	if err != nil {
+		return err
+	}
+
+	if frozen {
+		return appErr.ErrWalletFrozen.WithDetails(map[string]any{"walletId": walletID})
+	}
+	return nil
+}
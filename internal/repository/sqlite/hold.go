@@ -0,0 +1,311 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+)
+
+const holdColumns = `id, wallet_id, currency, amount, captured, status, expires_at, created_at`
+
+// PlaceHold reserves amount of the wallet's currency balance until
+// expiresAt. The balance is untouched; only the available balance shrinks.
+func (r *Repository) PlaceHold(
+	ctx context.Context,
+	walletID string,
+	currency string,
+	amount int64,
+	expiresAt time.Time,
+) (model.Hold, error) {
+
+	walletID, err := parseID(walletID)
+	if err != nil {
+		return model.Hold{}, err
+	}
+
+	var hold model.Hold
+	err = r.inTx(ctx, func(tx *txn) error {
+		wallet, err := lockWallet(ctx, tx, walletID, currency)
+		if err == sql.ErrNoRows {
+			return walletMissing(ctx, tx, walletID)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := checkNotFrozen(ctx, tx, walletID); err != nil {
+			return err
+		}
+
+		if wallet.Available() < amount {
+			return insufficientFunds(wallet, amount)
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`UPDATE wallets SET held = held + $1 WHERE id = $2 AND currency = $3`,
+			amount,
+			walletID,
+			currency,
+		)
+		if err != nil {
+			return err
+		}
+
+		hold, err = scanHold(tx.QueryRowContext(
+			ctx,
+			`INSERT INTO holds (id, wallet_id, currency, amount, status, expires_at) VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING `+holdColumns,
+			uuid.NewString(),
+			walletID,
+			currency,
+			amount,
+			model.HoldActive,
+			timestamp(expiresAt),
+		))
+		return err
+	})
+	if err != nil {
+		return model.Hold{}, err
+	}
+
+	return hold, nil
+}
+
+// CaptureHold withdraws amount (the whole hold when amount is 0) from the
+// wallet and releases whatever part of the hold was not captured.
+func (r *Repository) CaptureHold(
+	ctx context.Context,
+	holdID string,
+	amount int64,
+	now time.Time,
+) (model.Hold, error) {
+
+	holdID, err := parseID(holdID)
+	if err != nil {
+		return model.Hold{}, err
+	}
+
+	var hold model.Hold
+	err = r.inTx(ctx, func(tx *txn) error {
+		wallet, h, err := lockHold(ctx, tx, holdID)
+		if err != nil {
+			return err
+		}
+
+		if err := checkNotFrozen(ctx, tx, wallet.ID.String()); err != nil {
+			return err
+		}
+
+		if h.Status != model.HoldActive || !h.ExpiresAt.After(now) {
+			return appErr.ErrHoldNotActive
+		}
+
+		if amount == 0 {
+			amount = h.Amount
+		}
+		if amount > h.Amount {
+			return appErr.ErrCaptureExceedsHold
+		}
+
+		walletID := wallet.ID.String()
+		newBalance := wallet.Balance - amount
+
+		_, err = tx.ExecContext(
+			ctx,
+			`UPDATE wallets SET balance = $1, held = held - $2 WHERE id = $3 AND currency = $4`,
+			newBalance,
+			h.Amount,
+			walletID,
+			h.Currency,
+		)
+		if err != nil {
+			return err
+		}
+
+		journalID, err := postJournal(ctx, tx, model.OpCapture, []model.JournalEntry{
+			{Account: model.WalletAccount(walletID), Currency: h.Currency, Amount: amount},
+			{Account: model.AccountCashOut, Currency: h.Currency, Amount: -amount},
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := insertTransaction(ctx, tx, journalID, walletID, h.Currency, model.OpCapture, -amount, newBalance, holdID); err != nil {
+			return err
+		}
+
+		hold, err = finishHold(ctx, tx, holdID, model.HoldCaptured, amount)
+		return err
+	})
+	if err != nil {
+		return model.Hold{}, err
+	}
+
+	return hold, nil
+}
+
+func (r *Repository) ReleaseHold(ctx context.Context, holdID string) (model.Hold, error) {
+	holdID, err := parseID(holdID)
+	if err != nil {
+		return model.Hold{}, err
+	}
+
+	var hold model.Hold
+	err = r.inTx(ctx, func(tx *txn) error {
+		var err error
+		hold, err = releaseHold(ctx, tx, holdID, model.HoldReleased)
+		return err
+	})
+	if err != nil {
+		return model.Hold{}, err
+	}
+
+	return hold, nil
+}
+
+// ExpireHolds releases up to limit active holds whose expiry is not after
+// now, each in its own transaction, and returns the holds it expired.
+// Holds captured or released concurrently are skipped.
+func (r *Repository) ExpireHolds(ctx context.Context, now time.Time, limit int) ([]model.Hold, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT id FROM holds WHERE status = $1 AND expires_at <= $2 ORDER BY expires_at LIMIT $3`,
+		model.HoldActive,
+		timestamp(now),
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var expired []model.Hold
+	for _, id := range ids {
+		var hold model.Hold
+		err := r.inTx(ctx, func(tx *txn) error {
+			var err error
+			hold, err = releaseHold(ctx, tx, id, model.HoldExpired)
+			return err
+		})
+		if errors.Is(err, appErr.ErrHoldNotActive) {
+			continue
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired = append(expired, hold)
+	}
+
+	return expired, nil
+}
+
+func (r *Repository) GetHold(ctx context.Context, holdID string) (model.Hold, error) {
+	holdID, err := parseID(holdID)
+	if err != nil {
+		return model.Hold{}, err
+	}
+
+	hold, err := scanHold(r.conn(ctx).QueryRowContext(
+		ctx,
+		`SELECT `+holdColumns+` FROM holds WHERE id = $1`,
+		holdID,
+	))
+	if err == sql.ErrNoRows {
+		return model.Hold{}, appErr.ErrHoldNotFound
+	}
+
+	return hold, err
+}
+
+func releaseHold(ctx context.Context, tx *txn, holdID, status string) (model.Hold, error) {
+	wallet, h, err := lockHold(ctx, tx, holdID)
+	if err != nil {
+		return model.Hold{}, err
+	}
+
+	if h.Status != model.HoldActive {
+		return model.Hold{}, appErr.ErrHoldNotActive
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`UPDATE wallets SET held = held - $1 WHERE id = $2 AND currency = $3`,
+		h.Amount,
+		wallet.ID.String(),
+		h.Currency,
+	)
+	if err != nil {
+		return model.Hold{}, err
+	}
+
+	// An expiry leaves the balance alone but still gets an event, for
+	// integrators to learn that the reserved amount is free again.
+	if status == model.HoldExpired {
+		err := recordEvent(ctx, tx, wallet.ID.String(), h.Currency, model.EventHoldExpired, h.Amount, wallet.Balance, holdID)
+		if err != nil {
+			return model.Hold{}, err
+		}
+	}
+
+	return finishHold(ctx, tx, holdID, status, 0)
+}
+
+// lockHold reads the hold and its wallet.
+func lockHold(ctx context.Context, tx *txn, holdID string) (model.Wallet, model.Hold, error) {
+	hold, err := scanHold(tx.QueryRowContext(
+		ctx,
+		`SELECT `+holdColumns+` FROM holds WHERE id = $1`,
+		holdID,
+	))
+	if err == sql.ErrNoRows {
+		return model.Wallet{}, model.Hold{}, appErr.ErrHoldNotFound
+	}
+	if err != nil {
+		return model.Wallet{}, model.Hold{}, err
+	}
+
+	wallet, err := lockWallet(ctx, tx, hold.WalletID.String(), hold.Currency)
+	if err != nil {
+		return model.Wallet{}, model.Hold{}, err
+	}
+
+	return wallet, hold, nil
+}
+
+func finishHold(ctx context.Context, tx *txn, holdID, status string, captured int64) (model.Hold, error) {
+	return scanHold(tx.QueryRowContext(
+		ctx,
+		`UPDATE holds SET status = $1, captured = $2, updated_at = $3 WHERE id = $4
+		RETURNING `+holdColumns,
+		status,
+		captured,
+		timestamp(time.Now()),
+		holdID,
+	))
+}
+
+func scanHold(row *sql.Row) (model.Hold, error) {
+	var h model.Hold
+	err := row.Scan(&h.ID, &h.WalletID, &h.Currency, &h.Amount, &h.Captured, &h.Status, &h.ExpiresAt, &h.CreatedAt)
+	return h, err
+}
@@ -0,0 +1,103 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+// ReserveIdempotencyKey claims key for the current request. It returns nil
+// when the key is fresh and the caller should go on to execute the request,
+// or the previously stored record when the key has already been used.
+// Keys created before expiredBefore are discarded and treated as fresh.
+//
+// Callers should run it inside WithinTx together with the operation itself:
+// a concurrent request with the same key then waits for the write lock
+// until the first one commits and sees its stored response.
+func (r *Repository) ReserveIdempotencyKey(
+	ctx context.Context,
+	key string,
+	requestHash string,
+	expiredBefore time.Time,
+) (*model.IdempotencyRecord, error) {
+
+	q := r.conn(ctx)
+
+	_, err := q.ExecContext(
+		ctx,
+		`DELETE FROM idempotency_keys WHERE key = $1 AND created_at < $2`,
+		key,
+		timestamp(expiredBefore),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := q.ExecContext(
+		ctx,
+		`INSERT INTO idempotency_keys (key, request_hash) VALUES ($1, $2) ON CONFLICT (key) DO NOTHING`,
+		key,
+		requestHash,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if inserted == 1 {
+		return nil, nil
+	}
+
+	rec := model.IdempotencyRecord{Key: key}
+	var status sql.NullInt64
+	err = q.QueryRowContext(
+		ctx,
+		`SELECT request_hash, status_code, response FROM idempotency_keys WHERE key = $1`,
+		key,
+	).Scan(&rec.RequestHash, &status, &rec.Response)
+	if err != nil {
+		return nil, err
+	}
+	rec.StatusCode = int(status.Int64)
+
+	return &rec, nil
+}
+
+func (r *Repository) SaveIdempotencyResponse(
+	ctx context.Context,
+	key string,
+	statusCode int,
+	response []byte,
+) error {
+
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		`UPDATE idempotency_keys SET status_code = $1, response = $2 WHERE key = $3`,
+		statusCode,
+		response,
+		key,
+	)
+	return err
+}
+
+func (r *Repository) DeleteExpiredIdempotencyKeys(
+	ctx context.Context,
+	expiredBefore time.Time,
+) (int64, error) {
+
+	res, err := r.conn(ctx).ExecContext(
+		ctx,
+		`DELETE FROM idempotency_keys WHERE created_at < $1`,
+		timestamp(expiredBefore),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
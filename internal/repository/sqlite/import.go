@@ -0,0 +1,217 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+)
+
+const importColumns = `id, format, status, last_line, succeeded, failed, error, created_at, updated_at`
+
+func (r *Repository) CreateImport(ctx context.Context, format string, data []byte) (model.ImportJob, error) {
+	return scanImport(r.conn(ctx).QueryRowContext(
+		ctx,
+		`INSERT INTO import_jobs (id, format, data, status) VALUES ($1, $2, $3, $4) RETURNING `+importColumns,
+		uuid.NewString(),
+		format,
+		data,
+		model.ImportPending,
+	))
+}
+
+func (r *Repository) GetImport(ctx context.Context, id string) (model.ImportJob, error) {
+	id, err := parseID(id)
+	if err != nil {
+		return model.ImportJob{}, err
+	}
+
+	job, err := scanImport(r.conn(ctx).QueryRowContext(
+		ctx,
+		`SELECT `+importColumns+` FROM import_jobs WHERE id = $1`,
+		id,
+	))
+	if err == sql.ErrNoRows {
+		return model.ImportJob{}, appErr.ErrImportNotFound
+	}
+
+	return job, err
+}
+
+func (r *Repository) ImportData(ctx context.Context, id string) ([]byte, error) {
+	id, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	err = r.conn(ctx).QueryRowContext(ctx, `SELECT data FROM import_jobs WHERE id = $1`, id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, appErr.ErrImportNotFound
+	}
+
+	return data, err
+}
+
+// UnfinishedImports returns the jobs that are queued or were interrupted,
+// oldest first.
+func (r *Repository) UnfinishedImports(ctx context.Context) ([]model.ImportJob, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`SELECT `+importColumns+` FROM import_jobs WHERE status IN ($1, $2) ORDER BY created_at, rowid`,
+		model.ImportPending,
+		model.ImportRunning,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []model.ImportJob
+	for rows.Next() {
+		job, err := scanImport(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// AdvanceImport marks line of the job as done, failed with lineErr when it
+// is not nil. Within a transaction the write lock also keeps a second
+// runner of the same job from applying the line. It returns false when
+// the line was already done.
+func (r *Repository) AdvanceImport(
+	ctx context.Context,
+	id string,
+	line int,
+	lineErr *model.ImportLineError,
+) (bool, error) {
+
+	id, err := parseID(id)
+	if err != nil {
+		return false, err
+	}
+
+	var advanced bool
+	err = r.inTx(ctx, func(tx *txn) error {
+		var lastLine int
+		err := tx.QueryRowContext(ctx, `SELECT last_line FROM import_jobs WHERE id = $1`, id).Scan(&lastLine)
+		if err == sql.ErrNoRows {
+			return appErr.ErrImportNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		advanced = lastLine < line
+		if !advanced {
+			return nil
+		}
+
+		if lineErr == nil {
+			_, err = tx.ExecContext(
+				ctx,
+				`UPDATE import_jobs SET last_line = $1, succeeded = succeeded + 1, updated_at = $2 WHERE id = $3`,
+				line,
+				timestamp(time.Now()),
+				id,
+			)
+			return err
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`INSERT INTO import_errors (job_id, line, code, detail) VALUES ($1, $2, $3, $4)`,
+			id,
+			line,
+			lineErr.Code,
+			lineErr.Detail,
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`UPDATE import_jobs SET last_line = $1, failed = failed + 1, updated_at = $2 WHERE id = $3`,
+			line,
+			timestamp(time.Now()),
+			id,
+		)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return advanced, nil
+}
+
+// SetImportStatus moves the job to status; msg says why a job failed.
+func (r *Repository) SetImportStatus(ctx context.Context, id, status, msg string) error {
+	id, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.conn(ctx).ExecContext(
+		ctx,
+		`UPDATE import_jobs SET status = $1, error = $2, updated_at = $3 WHERE id = $4`,
+		status,
+		msg,
+		timestamp(time.Now()),
+		id,
+	)
+	return err
+}
+
+func (r *Repository) ImportErrors(ctx context.Context, id string) ([]model.ImportLineError, error) {
+	id, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`SELECT line, code, detail FROM import_errors WHERE job_id = $1 ORDER BY line`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lineErrs []model.ImportLineError
+	for rows.Next() {
+		var e model.ImportLineError
+		if err := rows.Scan(&e.Line, &e.Code, &e.Detail); err != nil {
+			return nil, err
+		}
+		lineErrs = append(lineErrs, e)
+	}
+
+	return lineErrs, rows.Err()
+}
+
+func scanImport(row interface{ Scan(dest ...any) error }) (model.ImportJob, error) {
+	var job model.ImportJob
+	err := row.Scan(
+		&job.ID,
+		&job.Format,
+		&job.Status,
+		&job.LastLine,
+		&job.Succeeded,
+		&job.Failed,
+		&job.Error,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	)
+	return job, err
+}
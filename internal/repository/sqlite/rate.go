@@ -0,0 +1,89 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+// SaveRates stores rates in one statement. A rate for a pair and
+// effective-from time that already exists is overwritten.
+func (r *Repository) SaveRates(ctx context.Context, rates []model.ExchangeRate) error {
+	if len(rates) == 0 {
+		return nil
+	}
+
+	values := make([]string, 0, len(rates))
+	args := make([]any, 0, len(rates)*4)
+	for _, rate := range rates {
+		n := len(args)
+		values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4))
+		args = append(args, rate.Base, rate.Quote, rate.Rate, timestamp(rate.EffectiveFrom))
+	}
+
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		`INSERT INTO exchange_rates (base, quote, rate, effective_from) VALUES `+strings.Join(values, ", ")+`
+		ON CONFLICT (base, quote, effective_from) DO UPDATE SET rate = excluded.rate`,
+		args...,
+	)
+	return err
+}
+
+// GetRate returns the base/quote rate in effect at the given time.
+func (r *Repository) GetRate(ctx context.Context, base, quote string, at time.Time) (model.ExchangeRate, error) {
+	rate := model.ExchangeRate{Base: base, Quote: quote}
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		`SELECT rate, effective_from FROM exchange_rates
+		WHERE base = $1 AND quote = $2 AND effective_from <= $3
+		ORDER BY effective_from DESC LIMIT 1`,
+		base,
+		quote,
+		timestamp(at),
+	).Scan(&rate.Rate, &rate.EffectiveFrom)
+
+	if err == sql.ErrNoRows {
+		return model.ExchangeRate{}, appErr.ErrRateNotFound
+	}
+	if err != nil {
+		return model.ExchangeRate{}, err
+	}
+
+	return rate, nil
+}
+
+// ListRates returns, for every pair, the rate in effect at the given time.
+func (r *Repository) ListRates(ctx context.Context, at time.Time) ([]model.ExchangeRate, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`SELECT base, quote, rate, effective_from FROM (
+			SELECT base, quote, rate, effective_from,
+				ROW_NUMBER() OVER (PARTITION BY base, quote ORDER BY effective_from DESC) AS n
+			FROM exchange_rates WHERE effective_from <= $1
+		)
+		WHERE n = 1
+		ORDER BY base, quote`,
+		timestamp(at),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []model.ExchangeRate
+	for rows.Next() {
+		var rate model.ExchangeRate
+		if err := rows.Scan(&rate.Base, &rate.Quote, &rate.Rate, &rate.EffectiveFrom); err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+
+	return rates, rows.Err()
+}
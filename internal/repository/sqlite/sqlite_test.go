@@ -0,0 +1,95 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/Hlompy/Wallet/internal/db"
+	"github.com/Hlompy/Wallet/internal/repository/repotest"
+
+	"github.com/google/uuid"
+)
+
+// testDB opens a new database file and applies the SQLite migrations.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	database, err := db.New("sqlite", filepath.Join(t.TempDir(), "wallet.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	if err := db.Migrate(database, "sqlite"); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	return database
+}
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repository {
+		return New(testDB(t))
+	})
+}
+
+func TestWithNotify_OnCommitOnly(t *testing.T) {
+	ctx := context.Background()
+	walletID := uuid.NewString()
+
+	var notified []string
+	repo := New(testDB(t), WithNotify(func(id string) { notified = append(notified, id) }))
+
+	err := repo.WithinTx(ctx, func(ctx context.Context) error {
+		if err := repo.UpdateBalance(ctx, walletID, "USD", 100, 0); err != nil {
+			return err
+		}
+		if len(notified) != 0 {
+			t.Errorf("expected no notification before commit, got %v", notified)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(notified) != 1 || notified[0] != walletID {
+		t.Errorf("expected %s notified once, got %v", walletID, notified)
+	}
+
+	notified = nil
+	_ = repo.WithinTx(ctx, func(ctx context.Context) error {
+		if err := repo.UpdateBalance(ctx, walletID, "USD", 100, 0); err != nil {
+			return err
+		}
+		return errors.New("boom")
+	})
+	if len(notified) != 0 {
+		t.Errorf("expected no notification after a rollback, got %v", notified)
+	}
+	if wallet, _ := repo.GetWallet(ctx, walletID, "USD"); wallet.Balance != 100 {
+		t.Errorf("expected the rolled back deposit to be gone, got %d", wallet.Balance)
+	}
+}
+
+func TestUpdateBalance_CanonicalID(t *testing.T) {
+	ctx := context.Background()
+	repo := New(testDB(t))
+
+	id := uuid.New()
+	braced := "{" + id.String() + "}"
+	if err := repo.UpdateBalance(ctx, braced, "USD", 100, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wallet, err := repo.GetWallet(ctx, id.String(), "USD")
+	if err != nil || wallet.ID != id || wallet.Balance != 100 {
+		t.Errorf("expected the wallet under its canonical id, got %+v (%v)", wallet, err)
+	}
+
+	if err := repo.UpdateBalance(ctx, "not-a-uuid", "USD", 100, 0); err == nil {
+		t.Error("expected an error")
+	}
+}
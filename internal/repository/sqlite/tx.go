@@ -0,0 +1,107 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+)
+
+// sqliteBusy is the SQLITE_BUSY result code: the write lock stayed taken
+// for the whole busy timeout.
+const sqliteBusy = 5
+
+type txKey struct{}
+
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// txn is a transaction on a connection of its own, and the wallets to
+// notify once it commits.
+type txn struct {
+	*sql.Conn
+	notified []string
+}
+
+// WithinTx runs fn in a single database transaction. Repository calls made
+// with the context passed to fn join that transaction instead of opening
+// their own, so several operations commit or roll back together.
+func (r *Repository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.inTx(ctx, func(tx *txn) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// inTx runs fn in the transaction carried by ctx or, failing that, in a
+// new one. Transactions begin IMMEDIATE: they take the database's write
+// lock up front, which serializes them the way row locks serialize
+// Postgres transactions on the same wallet, and leaves no lock to upgrade
+// and so no deadlock to retry.
+func (r *Repository) inTx(ctx context.Context, fn func(tx *txn) error) error {
+	if tx, ok := ctx.Value(txKey{}).(*txn); ok {
+		return fn(tx)
+	}
+
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+		if busy(err) {
+			return appErr.ErrConcurrentUpdate.Wrap(err)
+		}
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			rollback(ctx, conn)
+		}
+	}()
+
+	tx := &txn{Conn: conn}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if _, err := conn.ExecContext(ctx, `COMMIT`); err != nil {
+		return err
+	}
+	committed = true
+
+	if r.notify != nil {
+		for _, walletID := range tx.notified {
+			r.notify(walletID)
+		}
+	}
+	return nil
+}
+
+func (r *Repository) conn(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txKey{}).(*txn); ok {
+		return tx
+	}
+	return r.db
+}
+
+// rollback ends the transaction on conn even when ctx is what failed it.
+// A connection that cannot roll back is dropped rather than handed out
+// again in the middle of a transaction.
+func rollback(ctx context.Context, conn *sql.Conn) {
+	if _, err := conn.ExecContext(context.WithoutCancel(ctx), `ROLLBACK`); err != nil {
+		conn.Raw(func(any) error { return driver.ErrBadConn })
+	}
+}
+
+func busy(err error) bool {
+	var sqliteErr interface{ Code() int }
+	return errors.As(err, &sqliteErr) && sqliteErr.Code()&0xff == sqliteBusy
+}
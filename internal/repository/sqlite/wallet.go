@@ -0,0 +1,362 @@
+// Package sqlite keeps wallets in a SQLite database, for deployments
+// without a Postgres server. It has the semantics of the Postgres
+// repository and the same methods, minus the options that only make sense
+// with concurrent writers: a SQLite database has one writer at a time.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+)
+
+const walletColumns = `id, currency, balance, held, version`
+
+// timeFormat is how timestamps are stored: UTC with a fixed number of
+// fractional digits, so that comparing them as text compares the times.
+const timeFormat = "2006-01-02 15:04:05.000000"
+
+type Repository struct {
+	db     *sql.DB
+	notify func(walletID string)
+}
+
+type Option func(*Repository)
+
+// WithNotify calls fn with the wallet id once a transaction writing events
+// of the wallet commits, as Postgres notifies repository.EventChannel.
+func WithNotify(fn func(walletID string)) Option {
+	return func(r *Repository) {
+		r.notify = fn
+	}
+}
+
+func New(db *sql.DB, opts ...Option) *Repository {
+	r := &Repository{db: db}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// UpdateBalance applies a deposit (amount > 0) or withdrawal (amount < 0)
+// to the wallet's balance in currency. A deposit in a currency the wallet
+// does not hold yet opens a new balance for it. A non-zero ifVersion is
+// the version the balance must still have.
+func (r *Repository) UpdateBalance(
+	ctx context.Context,
+	walletID string,
+	currency string,
+	amount int64,
+	ifVersion int64,
+) error {
+
+	walletID, err := parseID(walletID)
+	if err != nil {
+		return err
+	}
+
+	return r.inTx(ctx, func(tx *txn) error {
+		if err := checkNotFrozen(ctx, tx, walletID); err != nil {
+			return err
+		}
+
+		wallet, err := lockWallet(ctx, tx, walletID, currency)
+
+		if err != nil {
+			if err == sql.ErrNoRows {
+				if ifVersion != 0 {
+					return appErr.ErrVersionMismatch
+				}
+				if amount < 0 {
+					return walletMissing(ctx, tx, walletID)
+				}
+
+				_, err = tx.ExecContext(
+					ctx,
+					`INSERT INTO wallets (id, currency, balance) VALUES ($1, $2, $3)`,
+					walletID,
+					currency,
+					amount,
+				)
+				if err != nil {
+					return err
+				}
+
+				return recordOperation(ctx, tx, walletID, currency, amount, amount)
+			}
+			return err
+		}
+
+		if ifVersion != 0 && wallet.Version != ifVersion {
+			return appErr.ErrVersionMismatch
+		}
+
+		// Held funds stay on the balance but cannot be withdrawn.
+		newBalance := wallet.Balance + amount
+		if newBalance < wallet.Held {
+			return insufficientFunds(wallet, -amount)
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`UPDATE wallets SET balance = $1 WHERE id = $2 AND currency = $3`,
+			newBalance,
+			walletID,
+			currency,
+		)
+		if err != nil {
+			return err
+		}
+
+		return recordOperation(ctx, tx, walletID, currency, amount, newBalance)
+	})
+}
+
+func (r *Repository) GetWallet(
+	ctx context.Context,
+	walletID string,
+	currency string,
+) (model.Wallet, error) {
+
+	walletID, err := parseID(walletID)
+	if err != nil {
+		return model.Wallet{}, err
+	}
+
+	var wallet model.Wallet
+	err = r.conn(ctx).QueryRowContext(
+		ctx,
+		`SELECT `+walletColumns+` FROM wallets WHERE id = $1 AND currency = $2`,
+		walletID,
+		currency,
+	).Scan(&wallet.ID, &wallet.Currency, &wallet.Balance, &wallet.Held, &wallet.Version)
+
+	if err == sql.ErrNoRows {
+		return model.Wallet{}, appErr.ErrWalletNotFound
+	}
+
+	return wallet, err
+}
+
+// ListWallets returns every currency balance of the wallet.
+func (r *Repository) ListWallets(ctx context.Context, walletID string) ([]model.Wallet, error) {
+	walletID, err := parseID(walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`SELECT `+walletColumns+` FROM wallets WHERE id = $1 ORDER BY currency`,
+		walletID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var wallets []model.Wallet
+	for rows.Next() {
+		var w model.Wallet
+		if err := rows.Scan(&w.ID, &w.Currency, &w.Balance, &w.Held, &w.Version); err != nil {
+			return nil, err
+		}
+		wallets = append(wallets, w)
+	}
+
+	return wallets, rows.Err()
+}
+
+// lockWallet reads the wallet row. The transaction already holds the
+// database's write lock, so nobody can change the row before it commits.
+// A missing wallet is reported as sql.ErrNoRows so callers can decide
+// whether to create it.
+func lockWallet(ctx context.Context, tx *txn, walletID, currency string) (model.Wallet, error) {
+	var wallet model.Wallet
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT `+walletColumns+` FROM wallets WHERE id = $1 AND currency = $2`,
+		walletID,
+		currency,
+	).Scan(&wallet.ID, &wallet.Currency, &wallet.Balance, &wallet.Held, &wallet.Version)
+
+	return wallet, err
+}
+
+// walletMissing explains a lockWallet miss: either the wallet does not
+// exist at all or it only holds other currencies.
+func walletMissing(ctx context.Context, tx *txn, walletID string) error {
+	var exists bool
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT EXISTS (SELECT 1 FROM wallets WHERE id = $1)`,
+		walletID,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return appErr.ErrCurrencyMismatch
+	}
+	return appErr.ErrWalletNotFound
+}
+
+// insufficientFunds tells the client how much it could have taken.
+func insufficientFunds(wallet model.Wallet, requested int64) error {
+	return appErr.ErrInsufficientFunds.WithDetails(map[string]any{
+		"currency":  wallet.Currency,
+		"balance":   wallet.Balance,
+		"available": wallet.Available(),
+		"requested": requested,
+	})
+}
+
+func (r *Repository) ListTransactions(
+	ctx context.Context,
+	walletID string,
+	filter model.TransactionFilter,
+) ([]model.Transaction, error) {
+
+	walletID, err := parseID(walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT id, wallet_id, currency, type, amount, balance_after, COALESCE(rate, ''), created_at
+		FROM transactions WHERE wallet_id = $1`
+	args := []any{walletID}
+
+	if filter.Currency != "" {
+		args = append(args, filter.Currency)
+		query += fmt.Sprintf(" AND currency = $%d", len(args))
+	}
+	if filter.Type != "" {
+		args = append(args, filter.Type)
+		query += fmt.Sprintf(" AND type = $%d", len(args))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, timestamp(filter.From))
+		query += fmt.Sprintf(" AND created_at >= $%d", len(args))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, timestamp(filter.To))
+		query += fmt.Sprintf(" AND created_at < $%d", len(args))
+	}
+	if filter.BeforeID > 0 {
+		args = append(args, filter.BeforeID)
+		query += fmt.Sprintf(" AND id < $%d", len(args))
+	}
+
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var txs []model.Transaction
+	for rows.Next() {
+		var t model.Transaction
+		if err := rows.Scan(
+			&t.ID,
+			&t.WalletID,
+			&t.Currency,
+			&t.Type,
+			&t.Amount,
+			&t.BalanceAfter,
+			&t.Rate,
+			&t.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		txs = append(txs, t)
+	}
+
+	return txs, rows.Err()
+}
+
+// recordOperation posts the journal for a deposit (amount > 0) or
+// withdrawal (amount < 0) and the matching ledger row.
+func recordOperation(
+	ctx context.Context,
+	tx *txn,
+	walletID string,
+	currency string,
+	amount int64,
+	balanceAfter int64,
+) error {
+
+	op := model.OpDeposit
+	entries := []model.JournalEntry{
+		{Account: model.AccountCashIn, Currency: currency, Amount: amount},
+		{Account: model.WalletAccount(walletID), Currency: currency, Amount: -amount},
+	}
+	if amount < 0 {
+		op = model.OpWithdraw
+		entries = []model.JournalEntry{
+			{Account: model.WalletAccount(walletID), Currency: currency, Amount: -amount},
+			{Account: model.AccountCashOut, Currency: currency, Amount: amount},
+		}
+	}
+
+	journalID, err := postJournal(ctx, tx, op, entries)
+	if err != nil {
+		return err
+	}
+
+	return insertTransaction(ctx, tx, journalID, walletID, currency, op, amount, balanceAfter, "")
+}
+
+// insertTransaction writes a ledger row together with its outbox event.
+func insertTransaction(
+	ctx context.Context,
+	tx *txn,
+	journalID int64,
+	walletID string,
+	currency string,
+	opType string,
+	amount int64,
+	balanceAfter int64,
+	reference string,
+) error {
+
+	_, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO transactions (journal_id, wallet_id, currency, type, amount, balance_after) VALUES ($1, $2, $3, $4, $5, $6)`,
+		journalID,
+		walletID,
+		currency,
+		opType,
+		amount,
+		balanceAfter,
+	)
+	if err != nil {
+		return err
+	}
+
+	return recordEvent(ctx, tx, walletID, currency, opType, amount, balanceAfter, reference)
+}
+
+// parseID reads an id the way Postgres reads a UUID column and returns it
+// in the canonical form the tables keep.
+func parseID(s string) (string, error) {
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid input syntax for type uuid: %q", s)
+	}
+	return id.String(), nil
+}
+
+func timestamp(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
@@ -0,0 +1,273 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+)
+
+const webhookColumns = `id, url, secret, events, active, created_at`
+
+const deliveryColumns = `id, webhook_id, event_type, payload, status, attempts, next_attempt_at, last_error, created_at, updated_at`
+
+func (r *Repository) CreateWebhook(ctx context.Context, url, secret string, events []string) (model.Webhook, error) {
+	list, err := json.Marshal(events)
+	if err != nil {
+		return model.Webhook{}, err
+	}
+
+	return scanWebhook(r.conn(ctx).QueryRowContext(
+		ctx,
+		`INSERT INTO webhooks (id, url, secret, events) VALUES ($1, $2, $3, $4) RETURNING `+webhookColumns,
+		uuid.NewString(),
+		url,
+		secret,
+		string(list),
+	))
+}
+
+func (r *Repository) GetWebhook(ctx context.Context, id string) (model.Webhook, error) {
+	id, err := parseID(id)
+	if err != nil {
+		return model.Webhook{}, err
+	}
+
+	webhook, err := scanWebhook(r.conn(ctx).QueryRowContext(
+		ctx,
+		`SELECT `+webhookColumns+` FROM webhooks WHERE id = $1`,
+		id,
+	))
+	if err == sql.ErrNoRows {
+		return model.Webhook{}, appErr.ErrWebhookNotFound
+	}
+
+	return webhook, err
+}
+
+// DeactivateWebhook stops new and pending deliveries to the webhook; the
+// deliveries already made are kept.
+func (r *Repository) DeactivateWebhook(ctx context.Context, id string) error {
+	id, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	res, err := r.conn(ctx).ExecContext(ctx, `UPDATE webhooks SET active = 0 WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return appErr.ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// EnqueueWebhookDeliveries queues payload for every active webhook
+// subscribed to eventType and returns how many deliveries it queued.
+func (r *Repository) EnqueueWebhookDeliveries(ctx context.Context, eventType string, payload []byte) (int64, error) {
+	res, err := r.conn(ctx).ExecContext(
+		ctx,
+		`INSERT INTO webhook_deliveries (webhook_id, event_type, payload)
+		SELECT id, $1, $2 FROM webhooks
+		WHERE active AND EXISTS (SELECT 1 FROM json_each(events) WHERE value = $1)`,
+		eventType,
+		payload,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// ClaimWebhookDeliveries takes up to limit pending deliveries due by now,
+// with the URL and secret of their webhook, and holds them until until:
+// they become due again then, unless marked delivered or failed first.
+// The claim runs in a transaction, so several dispatchers never take the
+// same delivery.
+func (r *Repository) ClaimWebhookDeliveries(ctx context.Context, now, until time.Time, limit int) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	err := r.inTx(ctx, func(tx *txn) error {
+		deliveries = nil
+
+		rows, err := tx.QueryContext(
+			ctx,
+			`SELECT d.id, d.webhook_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at, d.last_error, d.created_at, d.updated_at, w.url, w.secret
+			FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+			WHERE d.status = $1 AND d.next_attempt_at <= $2 AND w.active
+			ORDER BY d.next_attempt_at, d.id
+			LIMIT $3`,
+			model.DeliveryPending,
+			timestamp(now),
+			limit,
+		)
+		if err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			var d model.WebhookDelivery
+			err := rows.Scan(
+				&d.ID,
+				&d.WebhookID,
+				&d.EventType,
+				&d.Payload,
+				&d.Status,
+				&d.Attempts,
+				&d.NextAttemptAt,
+				&d.LastError,
+				&d.CreatedAt,
+				&d.UpdatedAt,
+				&d.URL,
+				&d.Secret,
+			)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			deliveries = append(deliveries, d)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		placeholders := make([]string, len(deliveries))
+		args := []any{timestamp(until)}
+		for i := range deliveries {
+			deliveries[i].NextAttemptAt = until
+			placeholders[i] = fmt.Sprintf("$%d", i+2)
+			args = append(args, deliveries[i].ID)
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`UPDATE webhook_deliveries SET next_attempt_at = $1 WHERE id IN (`+strings.Join(placeholders, ", ")+`)`,
+			args...,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (r *Repository) MarkWebhookDelivered(ctx context.Context, id int64) error {
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		`UPDATE webhook_deliveries SET status = $1, attempts = attempts + 1, last_error = '', updated_at = $2 WHERE id = $3`,
+		model.DeliveryDelivered,
+		timestamp(time.Now()),
+		id,
+	)
+	return err
+}
+
+// FailWebhookDelivery records a failed attempt. The delivery moves to
+// status: PENDING to be tried again at at, or DEAD to stop.
+func (r *Repository) FailWebhookDelivery(ctx context.Context, id int64, status string, at time.Time, msg string) error {
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		`UPDATE webhook_deliveries SET status = $1, attempts = attempts + 1, next_attempt_at = $2, last_error = $3, updated_at = $4
+		WHERE id = $5`,
+		status,
+		timestamp(at),
+		msg,
+		timestamp(time.Now()),
+		id,
+	)
+	return err
+}
+
+// WebhookDeliveries returns up to limit deliveries in status, or in any
+// status when it is empty, newest first.
+func (r *Repository) WebhookDeliveries(ctx context.Context, status string, limit int) ([]model.WebhookDelivery, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE $1 = '' OR status = $1 ORDER BY id DESC LIMIT $2`,
+		status,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []model.WebhookDelivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// ReplayWebhookDelivery queues the delivery again from scratch, whatever
+// its status.
+func (r *Repository) ReplayWebhookDelivery(ctx context.Context, id int64) (model.WebhookDelivery, error) {
+	now := timestamp(time.Now())
+	d, err := scanDelivery(r.conn(ctx).QueryRowContext(
+		ctx,
+		`UPDATE webhook_deliveries SET status = $1, attempts = 0, next_attempt_at = $2, last_error = '', updated_at = $2
+		WHERE id = $3
+		RETURNING `+deliveryColumns,
+		model.DeliveryPending,
+		now,
+		id,
+	))
+	if err == sql.ErrNoRows {
+		return model.WebhookDelivery{}, appErr.ErrDeliveryNotFound
+	}
+
+	return d, err
+}
+
+func scanWebhook(row interface{ Scan(dest ...any) error }) (model.Webhook, error) {
+	var w model.Webhook
+	var events string
+	err := row.Scan(&w.ID, &w.URL, &w.Secret, &events, &w.Active, &w.CreatedAt)
+	if err != nil {
+		return model.Webhook{}, err
+	}
+	return w, json.Unmarshal([]byte(events), &w.Events)
+}
+
+func scanDelivery(row interface{ Scan(dest ...any) error }) (model.WebhookDelivery, error) {
+	var d model.WebhookDelivery
+	err := row.Scan(
+		&d.ID,
+		&d.WebhookID,
+		&d.EventType,
+		&d.Payload,
+		&d.Status,
+		&d.Attempts,
+		&d.NextAttemptAt,
+		&d.LastError,
+		&d.CreatedAt,
+		&d.UpdatedAt,
+	)
+	return d, err
+}
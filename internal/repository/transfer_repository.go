@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+// Transfer moves amount from one wallet to another in a single
+// transaction. Both wallets must hold currency. The rows are locked in
+// ascending id order, so concurrent transfers in opposite directions queue
+// up instead of deadlocking.
+func (r *WalletRepository) Transfer(
+	ctx context.Context,
+	fromID string,
+	toID string,
+	currency string,
+	amount int64,
+) (model.TransferResult, error) {
+
+	result := model.TransferResult{
+		FromWalletID: fromID,
+		ToWalletID:   toID,
+		Currency:     currency,
+	}
+
+	fromID, toID = canonicalID(fromID), canonicalID(toID)
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		lockOrder := []string{fromID, toID}
+		if toID < fromID {
+			lockOrder = []string{toID, fromID}
+		}
+
+		wallets := make(map[string]model.Wallet, 2)
+		for _, id := range lockOrder {
+			wallet, err := r.lockWallet(ctx, tx, id, currency)
+			if err == sql.ErrNoRows {
+				return walletMissing(ctx, tx, id)
+			}
+			if err != nil {
+				return err
+			}
+			wallets[id] = wallet
+		}
+
+		for _, id := range []string{fromID, toID} {
+			if err := checkNotFrozen(ctx, tx, id); err != nil {
+				return err
+			}
+		}
+
+		result.FromBalance = wallets[fromID].Balance - amount
+		result.ToBalance = wallets[toID].Balance + amount
+
+		if result.FromBalance < wallets[fromID].Held {
+			return insufficientFunds(wallets[fromID], amount)
+		}
+
+		journalID, err := postJournal(ctx, tx, model.OpTransfer, []model.JournalEntry{
+			{Account: model.WalletAccount(fromID), Currency: currency, Amount: amount},
+			{Account: model.WalletAccount(toID), Currency: currency, Amount: -amount},
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, leg := range []struct {
+			id           string
+			counterparty string
+			op           string
+			amount       int64
+			balance      int64
+		}{
+			{fromID, toID, model.OpTransferOut, -amount, result.FromBalance},
+			{toID, fromID, model.OpTransferIn, amount, result.ToBalance},
+		} {
+			_, err := tx.ExecContext(
+				ctx,
+				`UPDATE wallets SET balance = $1 WHERE id = $2 AND currency = $3`,
+				leg.balance,
+				leg.id,
+				currency,
+			)
+			if err != nil {
+				return err
+			}
+
+			if err := insertTransaction(ctx, tx, journalID, leg.id, currency, leg.op, leg.amount, leg.balance, leg.counterparty); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return model.TransferResult{}, err
+	}
+
+	return result, nil
+}
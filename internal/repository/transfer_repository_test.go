@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+func TestTransfer_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	fromID := "550e8400-e29b-41d4-a716-446655440000"
+	toID := "11111111-1111-1111-1111-111111111111"
+
+	mock.ExpectBegin()
+	// toID sorts first, so it is locked first.
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+		WithArgs(toID, testCurrency).
+		WillReturnRows(walletRow(toID, int64(200), 0))
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+		WithArgs(fromID, testCurrency).
+		WillReturnRows(walletRow(fromID, int64(1000), 0))
+	expectNotFrozen(mock, fromID)
+	expectNotFrozen(mock, toID)
+	expectJournal(mock, "TRANSFER", 9,
+		model.JournalEntry{Account: "wallet:" + fromID, Currency: testCurrency, Amount: 300},
+		model.JournalEntry{Account: "wallet:" + toID, Currency: testCurrency, Amount: -300},
+	)
+	mock.ExpectExec(`UPDATE wallets SET balance = \$1 WHERE id = \$2 AND currency = \$3`).
+		WithArgs(int64(700), fromID, testCurrency).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO transactions`).
+		WithArgs(int64(9), fromID, testCurrency, "TRANSFER_OUT", int64(-300), int64(700)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectEvent(mock, fromID, testCurrency, "TRANSFER_OUT", int64(-300), int64(700), toID)
+	mock.ExpectExec(`UPDATE wallets SET balance = \$1 WHERE id = \$2 AND currency = \$3`).
+		WithArgs(int64(500), toID, testCurrency).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO transactions`).
+		WithArgs(int64(9), toID, testCurrency, "TRANSFER_IN", int64(300), int64(500)).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	expectEvent(mock, toID, testCurrency, "TRANSFER_IN", int64(300), int64(500), fromID)
+	mock.ExpectCommit()
+
+	res, err := repo.Transfer(context.Background(), fromID, toID, testCurrency, 300)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if res.FromBalance != 700 || res.ToBalance != 500 {
+		t.Errorf("unexpected result: %+v", res)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestTransfer_LockOrderIsDeterministic(t *testing.T) {
+	a := "11111111-1111-1111-1111-111111111111"
+	b := "550e8400-e29b-41d4-a716-446655440000"
+
+	for _, dir := range [][2]string{{a, b}, {b, a}} {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock: %v", err)
+		}
+
+		repo := New(db)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+			WithArgs(a, testCurrency).
+			WillReturnRows(walletRow(a, int64(0), 0))
+		mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+			WithArgs(b, testCurrency).
+			WillReturnRows(walletRow(b, int64(0), 0))
+		expectNotFrozen(mock, dir[0])
+		expectNotFrozen(mock, dir[1])
+		mock.ExpectRollback()
+
+		_, err = repo.Transfer(context.Background(), dir[0], dir[1], testCurrency, 100)
+		if !errors.Is(err, appErr.ErrInsufficientFunds) {
+			t.Errorf("expected ErrInsufficientFunds, got %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unfulfilled expectations for %s -> %s: %v", dir[0], dir[1], err)
+		}
+
+		db.Close()
+	}
+}
+
+func TestTransfer_WalletNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	fromID := "11111111-1111-1111-1111-111111111111"
+	toID := "550e8400-e29b-41d4-a716-446655440000"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+		WithArgs(fromID, testCurrency).
+		WillReturnRows(walletRow(fromID, int64(1000), 0))
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+		WithArgs(toID, testCurrency).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "currency", "balance", "held", "version"}))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM wallets WHERE id = \$1\)`).
+		WithArgs(toID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectRollback()
+
+	_, err = repo.Transfer(context.Background(), fromID, toID, testCurrency, 100)
+	if err != appErr.ErrWalletNotFound {
+		t.Errorf("expected ErrWalletNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+)
+
+type txKey struct{}
+
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// WithinTx runs fn in a single database transaction. Repository calls made
+// with the context passed to fn join that transaction instead of opening
+// their own, so several operations commit or roll back together.
+func (r *WalletRepository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// inTx runs fn in the transaction carried by ctx or, failing that, in a
+// new one. A new transaction that hits a serialization failure or a
+// deadlock is run again from the start, so fn must not keep state between
+// calls; a joined one leaves the retry to whoever opened it.
+func (r *WalletRepository) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(tx)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := r.runTx(ctx, fn)
+
+		code, ok := retryable(err)
+		if !ok {
+			return err
+		}
+		if attempt == r.maxRetries {
+			txRetries.Add("exhausted", 1)
+			return appErr.ErrConcurrentUpdate.Wrap(err)
+		}
+		txRetries.Add(code.Name(), 1)
+
+		if err := r.backoff(ctx, attempt); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *WalletRepository) runTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{
+		Isolation: r.isolation,
+	})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *WalletRepository) conn(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return r.db
+}
@@ -3,93 +3,401 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"time"
 
 	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+	"github.com/google/uuid"
 )
 
+const walletColumns = `id, currency, balance, held, version`
+
 type WalletRepository struct {
 	db *sql.DB
+
+	isolation  sql.IsolationLevel
+	maxRetries int
+	retryDelay time.Duration
+
+	// casRetries > 0 switches UpdateBalance to compare-and-set updates.
+	casRetries int
+
+	// hotWallets keep their balances split across shards rows.
+	shards     int
+	hotWallets map[string]bool
+}
+
+type Option func(*WalletRepository)
+
+// WithIsolation sets the isolation level of the transactions the
+// repository opens.
+func WithIsolation(level sql.IsolationLevel) Option {
+	return func(r *WalletRepository) {
+		r.isolation = level
+	}
+}
+
+// WithRetry sets how many times a transaction is rerun after a
+// serialization failure or deadlock, and the delay before the first rerun.
+func WithRetry(maxRetries int, baseDelay time.Duration) Option {
+	return func(r *WalletRepository) {
+		r.maxRetries = maxRetries
+		r.retryDelay = baseDelay
+	}
+}
+
+// WithCompareAndSet makes UpdateBalance read the balance without a row
+// lock and write it back only if its version is unchanged, rereading up
+// to retries times when another writer got there first. The rereads rely
+// on READ COMMITTED, the default isolation: under a serializable one a
+// reread sees the same snapshot.
+func WithCompareAndSet(retries int) Option {
+	return func(r *WalletRepository) {
+		r.casRetries = retries
+	}
+}
+
+// WithShardedWallets splits the balances of the given wallets across
+// shards rows. Deposits to them land on a random shard instead of
+// queueing on the wallet row.
+func WithShardedWallets(shards int, walletIDs ...string) Option {
+	return func(r *WalletRepository) {
+		r.shards = shards
+		r.hotWallets = make(map[string]bool, len(walletIDs))
+		for _, id := range walletIDs {
+			r.hotWallets[id] = true
+		}
+	}
 }
 
-func New(db *sql.DB) *WalletRepository {
-	return &WalletRepository{db: db}
+func New(db *sql.DB, opts ...Option) *WalletRepository {
+	r := &WalletRepository{
+		db:         db,
+		isolation:  sql.LevelReadCommitted,
+		maxRetries: 3,
+		retryDelay: 5 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
+// UpdateBalance applies a deposit (amount > 0) or withdrawal (amount < 0)
+// to the wallet's balance in currency. A deposit in a currency the wallet
+// does not hold yet opens a new balance for it. A non-zero ifVersion is
+// the version the balance must still have.
 func (r *WalletRepository) UpdateBalance(
 	ctx context.Context,
 	walletID string,
+	currency string,
 	amount int64,
+	ifVersion int64,
 ) error {
 
-	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{
-		Isolation: sql.LevelReadCommitted,
-	})
-	if err != nil {
-		return err
+	walletID = canonicalID(walletID)
+	if r.isSharded(walletID) {
+		// A version check needs the whole balance, which only the
+		// locking path below sees.
+		if ifVersion == 0 {
+			return r.updateShardedBalance(ctx, walletID, currency, amount)
+		}
+	} else if r.casRetries > 0 {
+		return r.updateBalanceCAS(ctx, walletID, currency, amount, ifVersion)
 	}
-	defer tx.Rollback()
 
-	var balance int64
-	err = tx.QueryRowContext(
-		ctx,
-		`SELECT balance FROM wallets WHERE id = $1 FOR UPDATE`,
-		walletID,
-	).Scan(&balance)
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		wallet, err := r.lockWallet(ctx, tx, walletID, currency)
+		missing := err == sql.ErrNoRows
+		if err != nil && !missing {
+			return err
+		}
 
-	if err != nil {
-		if err == sql.ErrNoRows {
+		if err := checkNotFrozen(ctx, tx, walletID); err != nil {
+			return err
+		}
+
+		if missing {
+			if ifVersion != 0 {
+				return appErr.ErrVersionMismatch
+			}
 			if amount < 0 {
-				return appErr.ErrWalletNotFound
+				return walletMissing(ctx, tx, walletID)
 			}
 
 			_, err = tx.ExecContext(
 				ctx,
-				`INSERT INTO wallets (id, balance) VALUES ($1, $2)`,
+				`INSERT INTO wallets (id, currency, balance) VALUES ($1, $2, $3)`,
 				walletID,
+				currency,
 				amount,
 			)
 			if err != nil {
 				return err
 			}
 
-			return tx.Commit()
+			return recordOperation(ctx, tx, walletID, currency, amount, amount)
 		}
-		return err
+
+		if ifVersion != 0 && wallet.Version != ifVersion {
+			return appErr.ErrVersionMismatch
+		}
+
+		// Held funds stay on the balance but cannot be withdrawn.
+		newBalance := wallet.Balance + amount
+		if newBalance < wallet.Held {
+			return insufficientFunds(wallet, -amount)
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`UPDATE wallets SET balance = $1 WHERE id = $2 AND currency = $3`,
+			newBalance,
+			walletID,
+			currency,
+		)
+		if err != nil {
+			return err
+		}
+
+		return recordOperation(ctx, tx, walletID, currency, amount, newBalance)
+	})
+}
+
+func (r *WalletRepository) GetWallet(
+	ctx context.Context,
+	walletID string,
+	currency string,
+) (model.Wallet, error) {
+
+	query := `SELECT ` + walletColumns + ` FROM wallets WHERE id = $1 AND currency = $2`
+	if r.isSharded(walletID) {
+		query = shardedWalletQuery + ` WHERE w.id = $1 AND w.currency = $2 GROUP BY w.id, w.currency`
+	}
+
+	var wallet model.Wallet
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		query,
+		walletID,
+		currency,
+	).Scan(&wallet.ID, &wallet.Currency, &wallet.Balance, &wallet.Held, &wallet.Version)
+
+	if err == sql.ErrNoRows {
+		return model.Wallet{}, appErr.ErrWalletNotFound
+	}
+
+	return wallet, err
+}
+
+// ListWallets returns every currency balance of the wallet.
+func (r *WalletRepository) ListWallets(ctx context.Context, walletID string) ([]model.Wallet, error) {
+	query := `SELECT ` + walletColumns + ` FROM wallets WHERE id = $1 ORDER BY currency`
+	if r.isSharded(walletID) {
+		query = shardedWalletQuery + ` WHERE w.id = $1 GROUP BY w.id, w.currency ORDER BY w.currency`
+	}
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, walletID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var wallets []model.Wallet
+	for rows.Next() {
+		var w model.Wallet
+		if err := rows.Scan(&w.ID, &w.Currency, &w.Balance, &w.Held, &w.Version); err != nil {
+			return nil, err
+		}
+		wallets = append(wallets, w)
 	}
 
-	newBalance := balance + amount
-	if newBalance < 0 {
-		return appErr.ErrInsufficientFunds
+	return wallets, rows.Err()
+}
+
+// lockWallet reads the wallet row under FOR UPDATE. A missing wallet is
+// reported as sql.ErrNoRows so callers can decide whether to create it.
+// The shards of a hot wallet are folded into the row first, so the
+// returned balance is the whole balance and callers may overwrite it.
+func (r *WalletRepository) lockWallet(ctx context.Context, tx *sql.Tx, walletID, currency string) (model.Wallet, error) {
+	if r.isSharded(walletID) {
+		return foldShards(ctx, tx, walletID, currency)
 	}
+	return lockWalletRow(ctx, tx, walletID, currency)
+}
 
-	_, err = tx.ExecContext(
+func lockWalletRow(ctx context.Context, tx *sql.Tx, walletID, currency string) (model.Wallet, error) {
+	var wallet model.Wallet
+	err := tx.QueryRowContext(
 		ctx,
-		`UPDATE wallets SET balance = $1 WHERE id = $2`,
-		newBalance,
+		`SELECT `+walletColumns+` FROM wallets WHERE id = $1 AND currency = $2 FOR UPDATE`,
 		walletID,
-	)
+		currency,
+	).Scan(&wallet.ID, &wallet.Currency, &wallet.Balance, &wallet.Held, &wallet.Version)
+
+	return wallet, err
+}
+
+// walletMissing explains a lockWallet miss: either the wallet does not
+// exist at all or it only holds other currencies.
+func walletMissing(ctx context.Context, tx *sql.Tx, walletID string) error {
+	var exists bool
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT EXISTS (SELECT 1 FROM wallets WHERE id = $1)`,
+		walletID,
+	).Scan(&exists)
 	if err != nil {
 		return err
 	}
 
-	return tx.Commit()
+	if exists {
+		return appErr.ErrCurrencyMismatch
+	}
+	return appErr.ErrWalletNotFound
+}
+
+// insufficientFunds tells the client how much it could have taken.
+func insufficientFunds(wallet model.Wallet, requested int64) error {
+	return appErr.ErrInsufficientFunds.WithDetails(map[string]any{
+		"currency":  wallet.Currency,
+		"balance":   wallet.Balance,
+		"available": wallet.Available(),
+		"requested": requested,
+	})
 }
 
-func (r *WalletRepository) GetBalance(
+func (r *WalletRepository) ListTransactions(
 	ctx context.Context,
 	walletID string,
-) (int64, error) {
+	filter model.TransactionFilter,
+) ([]model.Transaction, error) {
 
-	var balance int64
-	err := r.db.QueryRowContext(
+	query := `SELECT id, wallet_id, currency, type, amount, balance_after, COALESCE(rate::text, ''), created_at
+		FROM transactions WHERE wallet_id = $1`
+	args := []any{walletID}
+
+	if filter.Currency != "" {
+		args = append(args, filter.Currency)
+		query += fmt.Sprintf(" AND currency = $%d", len(args))
+	}
+	if filter.Type != "" {
+		args = append(args, filter.Type)
+		query += fmt.Sprintf(" AND type = $%d", len(args))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		query += fmt.Sprintf(" AND created_at >= $%d", len(args))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		query += fmt.Sprintf(" AND created_at < $%d", len(args))
+	}
+	if filter.BeforeID > 0 {
+		args = append(args, filter.BeforeID)
+		query += fmt.Sprintf(" AND id < $%d", len(args))
+	}
+
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var txs []model.Transaction
+	for rows.Next() {
+		var t model.Transaction
+		if err := rows.Scan(
+			&t.ID,
+			&t.WalletID,
+			&t.Currency,
+			&t.Type,
+			&t.Amount,
+			&t.BalanceAfter,
+			&t.Rate,
+			&t.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		txs = append(txs, t)
+	}
+
+	return txs, rows.Err()
+}
+
+// recordOperation posts the journal for a deposit (amount > 0) or
+// withdrawal (amount < 0) and the matching ledger row.
+func recordOperation(
+	ctx context.Context,
+	tx *sql.Tx,
+	walletID string,
+	currency string,
+	amount int64,
+	balanceAfter int64,
+) error {
+
+	op := model.OpDeposit
+	entries := []model.JournalEntry{
+		{Account: model.AccountCashIn, Currency: currency, Amount: amount},
+		{Account: model.WalletAccount(walletID), Currency: currency, Amount: -amount},
+	}
+	if amount < 0 {
+		op = model.OpWithdraw
+		entries = []model.JournalEntry{
+			{Account: model.WalletAccount(walletID), Currency: currency, Amount: -amount},
+			{Account: model.AccountCashOut, Currency: currency, Amount: amount},
+		}
+	}
+
+	journalID, err := postJournal(ctx, tx, op, entries)
+	if err != nil {
+		return err
+	}
+
+	return insertTransaction(ctx, tx, journalID, walletID, currency, op, amount, balanceAfter, "")
+}
+
+// insertTransaction writes a ledger row together with its outbox event.
+func insertTransaction(
+	ctx context.Context,
+	tx *sql.Tx,
+	journalID int64,
+	walletID string,
+	currency string,
+	opType string,
+	amount int64,
+	balanceAfter int64,
+	reference string,
+) error {
+
+	_, err := tx.ExecContext(
 		ctx,
-		`SELECT balance FROM wallets WHERE id = $1`,
+		`INSERT INTO transactions (journal_id, wallet_id, currency, type, amount, balance_after) VALUES ($1, $2, $3, $4, $5, $6)`,
+		journalID,
 		walletID,
-	).Scan(&balance)
-
-	if err == sql.ErrNoRows {
-		return 0, appErr.ErrWalletNotFound
+		currency,
+		opType,
+		amount,
+		balanceAfter,
+	)
+	if err != nil {
+		return err
 	}
 
-	return balance, err
+	return recordEvent(ctx, tx, walletID, currency, opType, amount, balanceAfter, reference)
+}
+
+// canonicalID returns a wallet id in the form wallet_id::text gives, the
+// one journal accounts are keyed by, whatever form of the UUID the caller
+// passed. An id that is no UUID is returned as is, for the database to
+// reject.
+func canonicalID(id string) string {
+	if u, err := uuid.Parse(id); err == nil {
+		return u.String()
+	}
+	return id
 }
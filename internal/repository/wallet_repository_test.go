@@ -3,12 +3,24 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
 )
 
+const (
+	testCurrency = "USD"
+	testVersion  = int64(7)
+)
+
+func walletRow(id string, balance, held int64) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "currency", "balance", "held", "version"}).AddRow(id, testCurrency, balance, held, testVersion)
+}
+
 func TestUpdateBalance_CreateWallet(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -22,15 +34,24 @@ func TestUpdateBalance_CreateWallet(t *testing.T) {
 	amount := int64(1000)
 
 	mock.ExpectBegin()
-	mock.ExpectQuery(`SELECT balance FROM wallets WHERE id = \$1 FOR UPDATE`).
-		WithArgs(walletID).
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+		WithArgs(walletID, testCurrency).
 		WillReturnError(sql.ErrNoRows)
-	mock.ExpectExec(`INSERT INTO wallets \(id, balance\) VALUES \(\$1, \$2\)`).
-		WithArgs(walletID, amount).
+	expectNotFrozen(mock, walletID)
+	mock.ExpectExec(`INSERT INTO wallets \(id, currency, balance\) VALUES \(\$1, \$2, \$3\)`).
+		WithArgs(walletID, testCurrency, amount).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectJournal(mock, "DEPOSIT", 7,
+		model.JournalEntry{Account: "system:cash_in", Currency: testCurrency, Amount: amount},
+		model.JournalEntry{Account: "wallet:" + walletID, Currency: testCurrency, Amount: -amount},
+	)
+	mock.ExpectExec(`INSERT INTO transactions \(journal_id, wallet_id, currency, type, amount, balance_after\)`).
+		WithArgs(int64(7), walletID, testCurrency, "DEPOSIT", amount, amount).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectEvent(mock, walletID, testCurrency, "DEPOSIT", amount, amount, "")
 	mock.ExpectCommit()
 
-	err = repo.UpdateBalance(context.Background(), walletID, amount)
+	err = repo.UpdateBalance(context.Background(), walletID, testCurrency, amount, 0)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
@@ -53,12 +74,16 @@ func TestUpdateBalance_CreateWallet_WithNegativeAmount(t *testing.T) {
 	amount := int64(-500)
 
 	mock.ExpectBegin()
-	mock.ExpectQuery(`SELECT balance FROM wallets WHERE id = \$1 FOR UPDATE`).
-		WithArgs(walletID).
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+		WithArgs(walletID, testCurrency).
 		WillReturnError(sql.ErrNoRows)
+	expectNotFrozen(mock, walletID)
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM wallets WHERE id = \$1\)`).
+		WithArgs(walletID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
 	mock.ExpectRollback()
 
-	err = repo.UpdateBalance(context.Background(), walletID, amount)
+	err = repo.UpdateBalance(context.Background(), walletID, testCurrency, amount, 0)
 	if err != appErr.ErrWalletNotFound {
 		t.Errorf("expected ErrWalletNotFound, got %v", err)
 	}
@@ -68,6 +93,37 @@ func TestUpdateBalance_CreateWallet_WithNegativeAmount(t *testing.T) {
 	}
 }
 
+func TestUpdateBalance_WithdrawOtherCurrency(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	walletID := "550e8400-e29b-41d4-a716-446655440000"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+		WithArgs(walletID, "EUR").
+		WillReturnError(sql.ErrNoRows)
+	expectNotFrozen(mock, walletID)
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM wallets WHERE id = \$1\)`).
+		WithArgs(walletID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	err = repo.UpdateBalance(context.Background(), walletID, "EUR", -500, 0)
+	if err != appErr.ErrCurrencyMismatch {
+		t.Errorf("expected ErrCurrencyMismatch, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
 func TestUpdateBalance_Deposit(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -83,15 +139,66 @@ func TestUpdateBalance_Deposit(t *testing.T) {
 	newBalance := currentBalance + amount
 
 	mock.ExpectBegin()
-	mock.ExpectQuery(`SELECT balance FROM wallets WHERE id = \$1 FOR UPDATE`).
-		WithArgs(walletID).
-		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(currentBalance))
-	mock.ExpectExec(`UPDATE wallets SET balance = \$1 WHERE id = \$2`).
-		WithArgs(newBalance, walletID).
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+		WithArgs(walletID, testCurrency).
+		WillReturnRows(walletRow(walletID, currentBalance, 0))
+	expectNotFrozen(mock, walletID)
+	mock.ExpectExec(`UPDATE wallets SET balance = \$1 WHERE id = \$2 AND currency = \$3`).
+		WithArgs(newBalance, walletID, testCurrency).
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectJournal(mock, "DEPOSIT", 7,
+		model.JournalEntry{Account: "system:cash_in", Currency: testCurrency, Amount: amount},
+		model.JournalEntry{Account: "wallet:" + walletID, Currency: testCurrency, Amount: -amount},
+	)
+	mock.ExpectExec(`INSERT INTO transactions \(journal_id, wallet_id, currency, type, amount, balance_after\)`).
+		WithArgs(int64(7), walletID, testCurrency, "DEPOSIT", amount, newBalance).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectEvent(mock, walletID, testCurrency, "DEPOSIT", amount, newBalance, "")
 	mock.ExpectCommit()
 
-	err = repo.UpdateBalance(context.Background(), walletID, amount)
+	err = repo.UpdateBalance(context.Background(), walletID, testCurrency, amount, 0)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+// An id in another form of the same UUID posts to the wallet's one
+// journal account, the one its transfers use.
+func TestUpdateBalance_CanonicalID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	walletID := "550e8400-e29b-41d4-a716-446655440000"
+	amount := int64(500)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+		WithArgs(walletID, testCurrency).
+		WillReturnRows(walletRow(walletID, 1000, 0))
+	expectNotFrozen(mock, walletID)
+	mock.ExpectExec(`UPDATE wallets SET balance = \$1 WHERE id = \$2 AND currency = \$3`).
+		WithArgs(int64(1500), walletID, testCurrency).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectJournal(mock, "DEPOSIT", 7,
+		model.JournalEntry{Account: "system:cash_in", Currency: testCurrency, Amount: amount},
+		model.JournalEntry{Account: "wallet:" + walletID, Currency: testCurrency, Amount: -amount},
+	)
+	mock.ExpectExec(`INSERT INTO transactions \(journal_id, wallet_id, currency, type, amount, balance_after\)`).
+		WithArgs(int64(7), walletID, testCurrency, "DEPOSIT", amount, int64(1500)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectEvent(mock, walletID, testCurrency, "DEPOSIT", amount, int64(1500), "")
+	mock.ExpectCommit()
+
+	err = repo.UpdateBalance(context.Background(), "{550E8400-E29B-41D4-A716-446655440000}", testCurrency, amount, 0)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
@@ -116,15 +223,24 @@ func TestUpdateBalance_Withdraw_Success(t *testing.T) {
 	newBalance := currentBalance + amount
 
 	mock.ExpectBegin()
-	mock.ExpectQuery(`SELECT balance FROM wallets WHERE id = \$1 FOR UPDATE`).
-		WithArgs(walletID).
-		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(currentBalance))
-	mock.ExpectExec(`UPDATE wallets SET balance = \$1 WHERE id = \$2`).
-		WithArgs(newBalance, walletID).
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+		WithArgs(walletID, testCurrency).
+		WillReturnRows(walletRow(walletID, currentBalance, 0))
+	expectNotFrozen(mock, walletID)
+	mock.ExpectExec(`UPDATE wallets SET balance = \$1 WHERE id = \$2 AND currency = \$3`).
+		WithArgs(newBalance, walletID, testCurrency).
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectJournal(mock, "WITHDRAW", 7,
+		model.JournalEntry{Account: "wallet:" + walletID, Currency: testCurrency, Amount: -amount},
+		model.JournalEntry{Account: "system:cash_out", Currency: testCurrency, Amount: amount},
+	)
+	mock.ExpectExec(`INSERT INTO transactions \(journal_id, wallet_id, currency, type, amount, balance_after\)`).
+		WithArgs(int64(7), walletID, testCurrency, "WITHDRAW", amount, newBalance).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectEvent(mock, walletID, testCurrency, "WITHDRAW", amount, newBalance, "")
 	mock.ExpectCommit()
 
-	err = repo.UpdateBalance(context.Background(), walletID, amount)
+	err = repo.UpdateBalance(context.Background(), walletID, testCurrency, amount, 0)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
@@ -148,13 +264,47 @@ func TestUpdateBalance_InsufficientFunds(t *testing.T) {
 	amount := int64(-500)
 
 	mock.ExpectBegin()
-	mock.ExpectQuery(`SELECT balance FROM wallets WHERE id = \$1 FOR UPDATE`).
-		WithArgs(walletID).
-		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(currentBalance))
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+		WithArgs(walletID, testCurrency).
+		WillReturnRows(walletRow(walletID, currentBalance, 0))
+	expectNotFrozen(mock, walletID)
+	mock.ExpectRollback()
+
+	err = repo.UpdateBalance(context.Background(), walletID, testCurrency, amount, 0)
+	if !errors.Is(err, appErr.ErrInsufficientFunds) {
+		t.Errorf("expected ErrInsufficientFunds, got %v", err)
+	}
+
+	domainErr, ok := appErr.As(err)
+	if !ok || domainErr.Details["available"] != currentBalance || domainErr.Details["requested"] != int64(500) {
+		t.Errorf("expected available and requested amounts in details, got %+v", domainErr)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestUpdateBalance_WithdrawRespectsHolds(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	walletID := "550e8400-e29b-41d4-a716-446655440000"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+		WithArgs(walletID, testCurrency).
+		WillReturnRows(walletRow(walletID, 1000, 800))
+	expectNotFrozen(mock, walletID)
 	mock.ExpectRollback()
 
-	err = repo.UpdateBalance(context.Background(), walletID, amount)
-	if err != appErr.ErrInsufficientFunds {
+	err = repo.UpdateBalance(context.Background(), walletID, testCurrency, -300, 0)
+	if !errors.Is(err, appErr.ErrInsufficientFunds) {
 		t.Errorf("expected ErrInsufficientFunds, got %v", err)
 	}
 
@@ -163,6 +313,89 @@ func TestUpdateBalance_InsufficientFunds(t *testing.T) {
 	}
 }
 
+func TestUpdateBalance_IfVersion(t *testing.T) {
+	walletID := "550e8400-e29b-41d4-a716-446655440000"
+
+	t.Run("mismatch", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+			WithArgs(walletID, testCurrency).
+			WillReturnRows(walletRow(walletID, 1000, 0))
+		expectNotFrozen(mock, walletID)
+		mock.ExpectRollback()
+
+		err = New(db).UpdateBalance(context.Background(), walletID, testCurrency, -100, testVersion-1)
+		if !errors.Is(err, appErr.ErrVersionMismatch) {
+			t.Errorf("expected ErrVersionMismatch, got %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unfulfilled expectations: %v", err)
+		}
+	})
+
+	t.Run("no balance yet", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets`).
+			WillReturnError(sql.ErrNoRows)
+		expectNotFrozen(mock, walletID)
+		mock.ExpectRollback()
+
+		err = New(db).UpdateBalance(context.Background(), walletID, testCurrency, 100, 1)
+		if !errors.Is(err, appErr.ErrVersionMismatch) {
+			t.Errorf("expected ErrVersionMismatch, got %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unfulfilled expectations: %v", err)
+		}
+	})
+
+	t.Run("match", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create mock: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets`).
+			WillReturnRows(walletRow(walletID, 1000, 0))
+		expectNotFrozen(mock, walletID)
+		mock.ExpectExec(`UPDATE wallets SET balance = \$1 WHERE id = \$2 AND currency = \$3`).
+			WithArgs(int64(900), walletID, testCurrency).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectJournal(mock, "WITHDRAW", 1,
+			model.JournalEntry{Account: "wallet:" + walletID, Currency: testCurrency, Amount: 100},
+			model.JournalEntry{Account: "system:cash_out", Currency: testCurrency, Amount: -100},
+		)
+		mock.ExpectExec(`INSERT INTO transactions`).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		expectEvent(mock)
+		mock.ExpectCommit()
+
+		if err := New(db).UpdateBalance(context.Background(), walletID, testCurrency, -100, testVersion); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unfulfilled expectations: %v", err)
+		}
+	})
+}
+
 func TestUpdateBalance_BeginTxError(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -174,7 +407,7 @@ func TestUpdateBalance_BeginTxError(t *testing.T) {
 
 	mock.ExpectBegin().WillReturnError(sql.ErrConnDone)
 
-	err = repo.UpdateBalance(context.Background(), "test-wallet", 100)
+	err = repo.UpdateBalance(context.Background(), "test-wallet", testCurrency, 100, 0)
 	if err == nil {
 		t.Error("expected error, got nil")
 	}
@@ -184,7 +417,7 @@ func TestUpdateBalance_BeginTxError(t *testing.T) {
 	}
 }
 
-func TestGetBalance_Success(t *testing.T) {
+func TestGetWallet_Success(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("failed to create mock: %v", err)
@@ -196,17 +429,23 @@ func TestGetBalance_Success(t *testing.T) {
 	walletID := "550e8400-e29b-41d4-a716-446655440000"
 	expectedBalance := int64(5000)
 
-	mock.ExpectQuery(`SELECT balance FROM wallets WHERE id = \$1`).
-		WithArgs(walletID).
-		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(expectedBalance))
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2`).
+		WithArgs(walletID, testCurrency).
+		WillReturnRows(walletRow(walletID, expectedBalance, 1000))
 
-	balance, err := repo.GetBalance(context.Background(), walletID)
+	wallet, err := repo.GetWallet(context.Background(), walletID, testCurrency)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
 
-	if balance != expectedBalance {
-		t.Errorf("expected balance %d, got %d", expectedBalance, balance)
+	if wallet.Balance != expectedBalance {
+		t.Errorf("expected balance %d, got %d", expectedBalance, wallet.Balance)
+	}
+	if wallet.Held != 1000 {
+		t.Errorf("expected held 1000, got %d", wallet.Held)
+	}
+	if wallet.Version != testVersion {
+		t.Errorf("expected version %d, got %d", testVersion, wallet.Version)
 	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -214,7 +453,7 @@ func TestGetBalance_Success(t *testing.T) {
 	}
 }
 
-func TestGetBalance_WalletNotFound(t *testing.T) {
+func TestGetWallet_WalletNotFound(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("failed to create mock: %v", err)
@@ -225,17 +464,17 @@ func TestGetBalance_WalletNotFound(t *testing.T) {
 
 	walletID := "550e8400-e29b-41d4-a716-446655440000"
 
-	mock.ExpectQuery(`SELECT balance FROM wallets WHERE id = \$1`).
-		WithArgs(walletID).
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2`).
+		WithArgs(walletID, testCurrency).
 		WillReturnError(sql.ErrNoRows)
 
-	balance, err := repo.GetBalance(context.Background(), walletID)
+	wallet, err := repo.GetWallet(context.Background(), walletID, testCurrency)
 	if err != appErr.ErrWalletNotFound {
 		t.Errorf("expected ErrWalletNotFound, got %v", err)
 	}
 
-	if balance != 0 {
-		t.Errorf("expected balance 0, got %d", balance)
+	if wallet.Balance != 0 {
+		t.Errorf("expected balance 0, got %d", wallet.Balance)
 	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -243,7 +482,7 @@ func TestGetBalance_WalletNotFound(t *testing.T) {
 	}
 }
 
-func TestGetBalance_QueryError(t *testing.T) {
+func TestGetWallet_QueryError(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("failed to create mock: %v", err)
@@ -254,11 +493,149 @@ func TestGetBalance_QueryError(t *testing.T) {
 
 	walletID := "550e8400-e29b-41d4-a716-446655440000"
 
-	mock.ExpectQuery(`SELECT balance FROM wallets WHERE id = \$1`).
-		WithArgs(walletID).
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2`).
+		WithArgs(walletID, testCurrency).
+		WillReturnError(sql.ErrConnDone)
+
+	_, err = repo.GetWallet(context.Background(), walletID, testCurrency)
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestUpdateBalance_LedgerInsertError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	walletID := "550e8400-e29b-41d4-a716-446655440000"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, currency, balance, held, version FROM wallets WHERE id = \$1 AND currency = \$2 FOR UPDATE`).
+		WithArgs(walletID, testCurrency).
+		WillReturnRows(walletRow(walletID, int64(1000), 0))
+	expectNotFrozen(mock, walletID)
+	mock.ExpectExec(`UPDATE wallets SET balance = \$1 WHERE id = \$2 AND currency = \$3`).
+		WithArgs(int64(1500), walletID, testCurrency).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectJournal(mock, "DEPOSIT", 7,
+		model.JournalEntry{Account: "system:cash_in", Currency: testCurrency, Amount: 500},
+		model.JournalEntry{Account: "wallet:" + walletID, Currency: testCurrency, Amount: -500},
+	)
+	mock.ExpectExec(`INSERT INTO transactions`).
+		WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	err = repo.UpdateBalance(context.Background(), walletID, testCurrency, 500, 0)
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestListTransactions_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	walletID := "550e8400-e29b-41d4-a716-446655440000"
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT id, wallet_id, currency, type, amount, balance_after, COALESCE\(rate::text, ''\), created_at\s+FROM transactions WHERE wallet_id = \$1 ORDER BY id DESC LIMIT \$2`).
+		WithArgs(walletID, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "wallet_id", "currency", "type", "amount", "balance_after", "rate", "created_at"}).
+			AddRow(int64(2), walletID, "EUR", "CONVERT_IN", int64(270), int64(270), "0.9", now).
+			AddRow(int64(1), walletID, testCurrency, "DEPOSIT", int64(1000), int64(1000), "", now))
+
+	txs, err := repo.ListTransactions(context.Background(), walletID, model.TransactionFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(txs) != 2 {
+		t.Fatalf("expected 2 transactions, got %d", len(txs))
+	}
+	if txs[0].ID != 2 || txs[0].Currency != "EUR" || txs[0].Type != "CONVERT_IN" || txs[0].Amount != 270 || txs[0].Rate != "0.9" {
+		t.Errorf("unexpected first transaction: %+v", txs[0])
+	}
+	if txs[1].Rate != "" {
+		t.Errorf("expected no rate on a deposit, got %q", txs[1].Rate)
+	}
+	if txs[1].WalletID.String() != walletID {
+		t.Errorf("expected walletID %s, got %s", walletID, txs[1].WalletID)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestListTransactions_WithFilter(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	walletID := "550e8400-e29b-41d4-a716-446655440000"
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`WHERE wallet_id = \$1 AND currency = \$2 AND type = \$3 AND created_at >= \$4 AND created_at < \$5 AND id < \$6 ORDER BY id DESC LIMIT \$7`).
+		WithArgs(walletID, testCurrency, "DEPOSIT", from, to, int64(42), 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "wallet_id", "currency", "type", "amount", "balance_after", "rate", "created_at"}))
+
+	txs, err := repo.ListTransactions(context.Background(), walletID, model.TransactionFilter{
+		Currency: testCurrency,
+		Type:     "DEPOSIT",
+		From:     from,
+		To:       to,
+		BeforeID: 42,
+		Limit:    5,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(txs) != 0 {
+		t.Errorf("expected no transactions, got %d", len(txs))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestListTransactions_QueryError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	mock.ExpectQuery(`SELECT id, wallet_id, currency, type, amount, balance_after, COALESCE\(rate::text, ''\), created_at`).
 		WillReturnError(sql.ErrConnDone)
 
-	_, err = repo.GetBalance(context.Background(), walletID)
+	_, err = repo.ListTransactions(context.Background(), "550e8400-e29b-41d4-a716-446655440000", model.TransactionFilter{Limit: 10})
 	if err == nil {
 		t.Error("expected error, got nil")
 	}
@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/lib/pq"
+)
+
+const webhookColumns = `id, url, secret, events, active, created_at`
+
+const deliveryColumns = `id, webhook_id, event_type, payload, status, attempts, next_attempt_at, last_error, created_at, updated_at`
+
+func (r *WalletRepository) CreateWebhook(ctx context.Context, url, secret string, events []string) (model.Webhook, error) {
+	return scanWebhook(r.conn(ctx).QueryRowContext(
+		ctx,
+		`INSERT INTO webhooks (url, secret, events) VALUES ($1, $2, $3) RETURNING `+webhookColumns,
+		url,
+		secret,
+		pq.Array(events),
+	))
+}
+
+func (r *WalletRepository) GetWebhook(ctx context.Context, id string) (model.Webhook, error) {
+	webhook, err := scanWebhook(r.conn(ctx).QueryRowContext(
+		ctx,
+		`SELECT `+webhookColumns+` FROM webhooks WHERE id = $1`,
+		id,
+	))
+	if err == sql.ErrNoRows {
+		return model.Webhook{}, appErr.ErrWebhookNotFound
+	}
+
+	return webhook, err
+}
+
+// DeactivateWebhook stops new and pending deliveries to the webhook; the
+// deliveries already made are kept.
+func (r *WalletRepository) DeactivateWebhook(ctx context.Context, id string) error {
+	res, err := r.conn(ctx).ExecContext(ctx, `UPDATE webhooks SET active = false WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return appErr.ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// EnqueueWebhookDeliveries queues payload for every active webhook
+// subscribed to eventType and returns how many deliveries it queued.
+func (r *WalletRepository) EnqueueWebhookDeliveries(ctx context.Context, eventType string, payload []byte) (int64, error) {
+	res, err := r.conn(ctx).ExecContext(
+		ctx,
+		`INSERT INTO webhook_deliveries (webhook_id, event_type, payload)
+		SELECT id, $1, $2 FROM webhooks WHERE active AND $1 = ANY(events)`,
+		eventType,
+		payload,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// ClaimWebhookDeliveries takes up to limit pending deliveries due by now,
+// with the URL and secret of their webhook, and holds them until until:
+// they become due again then, unless marked delivered or failed first.
+// Deliveries claimed by another dispatcher are skipped, so several can
+// run side by side.
+func (r *WalletRepository) ClaimWebhookDeliveries(ctx context.Context, now, until time.Time, limit int) ([]model.WebhookDelivery, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`WITH due AS (
+			SELECT d.id FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+			WHERE d.status = $1 AND d.next_attempt_at <= $2 AND w.active
+			ORDER BY d.next_attempt_at, d.id
+			LIMIT $3
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d SET next_attempt_at = $4
+		FROM due, webhooks w
+		WHERE d.id = due.id AND w.id = d.webhook_id
+		RETURNING d.id, d.webhook_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at, d.last_error, d.created_at, d.updated_at, w.url, w.secret`,
+		model.DeliveryPending,
+		now,
+		limit,
+		until,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []model.WebhookDelivery
+	for rows.Next() {
+		var d model.WebhookDelivery
+		err := rows.Scan(
+			&d.ID,
+			&d.WebhookID,
+			&d.EventType,
+			&d.Payload,
+			&d.Status,
+			&d.Attempts,
+			&d.NextAttemptAt,
+			&d.LastError,
+			&d.CreatedAt,
+			&d.UpdatedAt,
+			&d.URL,
+			&d.Secret,
+		)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func (r *WalletRepository) MarkWebhookDelivered(ctx context.Context, id int64) error {
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		`UPDATE webhook_deliveries SET status = $1, attempts = attempts + 1, last_error = '', updated_at = now() WHERE id = $2`,
+		model.DeliveryDelivered,
+		id,
+	)
+	return err
+}
+
+// FailWebhookDelivery records a failed attempt. The delivery moves to
+// status: PENDING to be tried again at at, or DEAD to stop.
+func (r *WalletRepository) FailWebhookDelivery(ctx context.Context, id int64, status string, at time.Time, msg string) error {
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		`UPDATE webhook_deliveries SET status = $1, attempts = attempts + 1, next_attempt_at = $2, last_error = $3, updated_at = now()
+		WHERE id = $4`,
+		status,
+		at,
+		msg,
+		id,
+	)
+	return err
+}
+
+// WebhookDeliveries returns up to limit deliveries in status, or in any
+// status when it is empty, newest first.
+func (r *WalletRepository) WebhookDeliveries(ctx context.Context, status string, limit int) ([]model.WebhookDelivery, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE $1 = '' OR status = $1 ORDER BY id DESC LIMIT $2`,
+		status,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []model.WebhookDelivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// ReplayWebhookDelivery queues the delivery again from scratch, whatever
+// its status.
+func (r *WalletRepository) ReplayWebhookDelivery(ctx context.Context, id int64) (model.WebhookDelivery, error) {
+	d, err := scanDelivery(r.conn(ctx).QueryRowContext(
+		ctx,
+		`UPDATE webhook_deliveries SET status = $1, attempts = 0, next_attempt_at = now(), last_error = '', updated_at = now()
+		WHERE id = $2
+		RETURNING `+deliveryColumns,
+		model.DeliveryPending,
+		id,
+	))
+	if err == sql.ErrNoRows {
+		return model.WebhookDelivery{}, appErr.ErrDeliveryNotFound
+	}
+
+	return d, err
+}
+
+func scanWebhook(row interface{ Scan(dest ...any) error }) (model.Webhook, error) {
+	var w model.Webhook
+	err := row.Scan(&w.ID, &w.URL, &w.Secret, pq.Array(&w.Events), &w.Active, &w.CreatedAt)
+	return w, err
+}
+
+func scanDelivery(row interface{ Scan(dest ...any) error }) (model.WebhookDelivery, error) {
+	var d model.WebhookDelivery
+	err := row.Scan(
+		&d.ID,
+		&d.WebhookID,
+		&d.EventType,
+		&d.Payload,
+		&d.Status,
+		&d.Attempts,
+		&d.NextAttemptAt,
+		&d.LastError,
+		&d.CreatedAt,
+		&d.UpdatedAt,
+	)
+	return d, err
+}
@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+const testWebhookID = "6a1e4c2d-3b5f-4e7a-9c8d-0f1e2d3c4b5a"
+
+func TestCreateWebhook(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+	events := []string{model.WebhookBalanceChanged, model.WebhookHoldExpired}
+
+	mock.ExpectQuery(`INSERT INTO webhooks \(url, secret, events\) VALUES \(\$1, \$2, \$3\) RETURNING id, url, secret, events, active, created_at`).
+		WithArgs("https://example.com/hooks", "whsec_test", pq.Array(events)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "url", "secret", "events", "active", "created_at"}).
+			AddRow(testWebhookID, "https://example.com/hooks", "whsec_test", "{balance.changed,hold.expired}", true, time.Now()))
+
+	webhook, err := repo.CreateWebhook(context.Background(), "https://example.com/hooks", "whsec_test", events)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if webhook.ID.String() != testWebhookID || len(webhook.Events) != 2 || webhook.Events[1] != model.WebhookHoldExpired {
+		t.Errorf("unexpected webhook: %+v", webhook)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestEnqueueWebhookDeliveries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+	payload := []byte(`{"eventId":1}`)
+
+	mock.ExpectExec(`INSERT INTO webhook_deliveries \(webhook_id, event_type, payload\)\s+SELECT id, \$1, \$2 FROM webhooks WHERE active AND \$1 = ANY\(events\)`).
+		WithArgs(model.WebhookBalanceChanged, payload).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	n, err := repo.EnqueueWebhookDeliveries(context.Background(), model.WebhookBalanceChanged, payload)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 deliveries, got %d", n)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestClaimWebhookDeliveries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+	now := time.Now()
+	until := now.Add(20 * time.Second)
+
+	mock.ExpectQuery(`(?s)WITH due AS \(.*FOR UPDATE OF d SKIP LOCKED\s+\)\s+UPDATE webhook_deliveries d SET next_attempt_at = \$4`).
+		WithArgs(model.DeliveryPending, now, 20, until).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "webhook_id", "event_type", "payload", "status", "attempts", "next_attempt_at", "last_error", "created_at", "updated_at", "url", "secret"}).
+			AddRow(int64(5), testWebhookID, model.WebhookBalanceChanged, []byte(`{}`), model.DeliveryPending, 2, until, "timeout", now, now, "https://example.com/hooks", "whsec_test"))
+
+	deliveries, err := repo.ClaimWebhookDeliveries(context.Background(), now, until, 20)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(deliveries) != 1 || deliveries[0].Attempts != 2 || deliveries[0].URL != "https://example.com/hooks" || deliveries[0].Secret != "whsec_test" {
+		t.Errorf("unexpected deliveries: %+v", deliveries)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestReplayWebhookDelivery_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	mock.ExpectQuery(`UPDATE webhook_deliveries SET status = \$1, attempts = 0`).
+		WithArgs(model.DeliveryPending, int64(42)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err = repo.ReplayWebhookDelivery(context.Background(), 42)
+	if !errors.Is(err, appErr.ErrDeliveryNotFound) {
+		t.Errorf("expected ErrDeliveryNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDeactivateWebhook_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := New(db)
+
+	mock.ExpectExec(`UPDATE webhooks SET active = false WHERE id = \$1`).
+		WithArgs(testWebhookID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.DeactivateWebhook(context.Background(), testWebhookID)
+	if !errors.Is(err, appErr.ErrWebhookNotFound) {
+		t.Errorf("expected ErrWebhookNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
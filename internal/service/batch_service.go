@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"maps"
+	"sort"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+// WithBatchLimit caps the number of operations ProcessBatch accepts.
+func WithBatchLimit(maxItems int) Option {
+	return func(s *WalletService) {
+		s.maxBatchItems = maxItems
+	}
+}
+
+// ProcessBatch applies deposits and withdrawals to many wallets. In
+// atomic mode they share one transaction and the first failure, reported
+// with the index of its operation, undoes them all. Otherwise every
+// operation commits on its own and its failure is reported in its result.
+//
+// Operations run ordered by wallet and currency, keeping their relative
+// order within a balance, so concurrent batches lock balances in the same
+// order. Results come back in request order.
+func (s *WalletService) ProcessBatch(
+	ctx context.Context,
+	ops []model.BatchOperation,
+	atomic bool,
+) ([]model.BatchResult, error) {
+
+	if len(ops) == 0 {
+		return nil, appErr.ErrInvalidOperation
+	}
+	if len(ops) > s.maxBatchItems {
+		return nil, appErr.ErrBatchTooLarge
+	}
+
+	results := make([]model.BatchResult, len(ops))
+	apply := func(ctx context.Context, i int) error {
+		currency, amount, err := s.operation(ops[i].Currency, ops[i].Type, ops[i].Amount)
+		if err != nil {
+			return err
+		}
+
+		if err := s.repo.UpdateBalance(ctx, ops[i].WalletID, currency, amount, 0); err != nil {
+			return err
+		}
+
+		results[i].Wallet, err = s.repo.GetWallet(ctx, ops[i].WalletID, currency)
+		return err
+	}
+
+	order := s.lockOrder(ops)
+
+	if atomic {
+		err := s.repo.WithinTx(ctx, func(ctx context.Context) error {
+			for _, i := range order {
+				if err := apply(ctx, i); err != nil {
+					return withIndex(err, i)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return results, nil
+	}
+
+	for _, i := range order {
+		results[i].Err = s.repo.WithinTx(ctx, func(ctx context.Context) error {
+			return apply(ctx, i)
+		})
+	}
+
+	return results, nil
+}
+
+// lockOrder returns the indexes of ops sorted by wallet and currency.
+func (s *WalletService) lockOrder(ops []model.BatchOperation) []int {
+	order := make([]int, len(ops))
+	keys := make([]string, len(ops))
+	for i, op := range ops {
+		order[i] = i
+		keys[i] = op.WalletID + "/" + op.Currency
+		if currency, err := s.currency(op.Currency); err == nil {
+			keys[i] = op.WalletID + "/" + currency
+		}
+	}
+
+	sort.SliceStable(order, func(a, b int) bool {
+		return keys[order[a]] < keys[order[b]]
+	})
+	return order
+}
+
+// withIndex adds the index of the failed operation to a domain error.
+func withIndex(err error, i int) error {
+	e, ok := appErr.As(err)
+	if !ok {
+		return err
+	}
+
+	details := map[string]any{"index": i}
+	maps.Copy(details, e.Details)
+	return e.WithDetails(details)
+}
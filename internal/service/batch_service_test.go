@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+const (
+	batchWalletA = "11111111-1111-1111-1111-111111111111"
+	batchWalletB = "22222222-2222-2222-2222-222222222222"
+)
+
+func TestProcessBatch_LockOrder(t *testing.T) {
+	var applied []string
+	mockRepo := &MockWalletRepository{
+		UpdateBalanceFunc: func(ctx context.Context, walletID, currency string, amount, ifVersion int64) error {
+			applied = append(applied, walletID+"/"+currency)
+			return nil
+		},
+		GetWalletFunc: func(ctx context.Context, walletID, currency string) (model.Wallet, error) {
+			return model.Wallet{Currency: currency, Balance: int64(len(applied))}, nil
+		},
+	}
+
+	svc := New(mockRepo)
+
+	results, err := svc.ProcessBatch(context.Background(), []model.BatchOperation{
+		{WalletID: batchWalletB, Type: model.OpDeposit, Amount: 1},
+		{WalletID: batchWalletA, Currency: "usd", Type: model.OpDeposit, Amount: 1},
+		{WalletID: batchWalletA, Type: model.OpDeposit, Amount: 1},
+		{WalletID: batchWalletB, Type: model.OpWithdraw, Amount: 1},
+	}, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{batchWalletA + "/RUB", batchWalletA + "/USD", batchWalletB + "/RUB", batchWalletB + "/RUB"}
+	for i := range want {
+		if applied[i] != want[i] {
+			t.Fatalf("expected order %v, got %v", want, applied)
+		}
+	}
+
+	// Results follow the request, not the order of execution.
+	if results[0].Wallet.Balance != 3 || results[1].Wallet.Balance != 2 || results[2].Wallet.Balance != 1 || results[3].Wallet.Balance != 4 {
+		t.Errorf("unexpected results: %+v", results)
+	}
+}
+
+func TestProcessBatch_AtomicFailureReportsIndex(t *testing.T) {
+	txs := 0
+	mockRepo := &MockWalletRepository{
+		WithinTxFunc: func(ctx context.Context, fn func(ctx context.Context) error) error {
+			txs++
+			return fn(ctx)
+		},
+		UpdateBalanceFunc: func(ctx context.Context, walletID, currency string, amount, ifVersion int64) error {
+			if walletID == batchWalletB {
+				return appErr.ErrInsufficientFunds.WithDetails(map[string]any{"available": int64(5)})
+			}
+			return nil
+		},
+	}
+
+	svc := New(mockRepo)
+
+	_, err := svc.ProcessBatch(context.Background(), []model.BatchOperation{
+		{WalletID: batchWalletB, Type: model.OpWithdraw, Amount: 10},
+		{WalletID: batchWalletA, Type: model.OpDeposit, Amount: 10},
+	}, true)
+	if !errors.Is(err, appErr.ErrInsufficientFunds) {
+		t.Fatalf("expected ErrInsufficientFunds, got %v", err)
+	}
+
+	e, _ := appErr.As(err)
+	if e.Details["index"] != 0 || e.Details["available"] != int64(5) {
+		t.Errorf("unexpected details: %v", e.Details)
+	}
+	if txs != 1 {
+		t.Errorf("expected one transaction, got %d", txs)
+	}
+}
+
+func TestProcessBatch_NonAtomicReportsEachItem(t *testing.T) {
+	txs := 0
+	mockRepo := &MockWalletRepository{
+		WithinTxFunc: func(ctx context.Context, fn func(ctx context.Context) error) error {
+			txs++
+			return fn(ctx)
+		},
+		UpdateBalanceFunc: func(ctx context.Context, walletID, currency string, amount, ifVersion int64) error {
+			if walletID == batchWalletB {
+				return appErr.ErrWalletNotFound
+			}
+			return nil
+		},
+		GetWalletFunc: func(ctx context.Context, walletID, currency string) (model.Wallet, error) {
+			return model.Wallet{Currency: currency, Balance: 100}, nil
+		},
+	}
+
+	svc := New(mockRepo)
+
+	results, err := svc.ProcessBatch(context.Background(), []model.BatchOperation{
+		{WalletID: batchWalletB, Type: model.OpWithdraw, Amount: 10},
+		{WalletID: batchWalletA, Type: "REFUND", Amount: 10},
+		{WalletID: batchWalletA, Type: model.OpDeposit, Amount: 10},
+	}, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if results[0].Err != appErr.ErrWalletNotFound {
+		t.Errorf("expected ErrWalletNotFound for item 0, got %v", results[0].Err)
+	}
+	if results[1].Err != appErr.ErrInvalidOperation {
+		t.Errorf("expected ErrInvalidOperation for item 1, got %v", results[1].Err)
+	}
+	if results[2].Err != nil || results[2].Wallet.Balance != 100 {
+		t.Errorf("unexpected item 2: %+v", results[2])
+	}
+	if txs != 3 {
+		t.Errorf("expected a transaction per item, got %d", txs)
+	}
+}
+
+func TestProcessBatch_TooLarge(t *testing.T) {
+	svc := New(&MockWalletRepository{}, WithBatchLimit(1))
+
+	_, err := svc.ProcessBatch(context.Background(), []model.BatchOperation{
+		{WalletID: batchWalletA, Type: model.OpDeposit, Amount: 1},
+		{WalletID: batchWalletB, Type: model.OpDeposit, Amount: 1},
+	}, false)
+	if err != appErr.ErrBatchTooLarge {
+		t.Errorf("expected ErrBatchTooLarge, got %v", err)
+	}
+}
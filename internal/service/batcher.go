@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+)
+
+// txScopeKey marks a context that already runs inside a transaction
+// opened by the service, such as an idempotent request.
+type txScopeKey struct{}
+
+func inTransaction(ctx context.Context) bool {
+	return ctx.Value(txScopeKey{}) != nil
+}
+
+// WithBatching makes Process collect operations on the same wallet for up
+// to window (or until maxSize of them are waiting) and apply them in one
+// database transaction, in the order they arrived.
+func WithBatching(window time.Duration, maxSize int) Option {
+	return func(s *WalletService) {
+		s.batcher = &batcher{
+			repo:    s.repo,
+			window:  window,
+			maxSize: maxSize,
+			pending: make(map[string]*batch),
+		}
+	}
+}
+
+type balanceOp struct {
+	ctx       context.Context
+	walletID  string
+	currency  string
+	amount    int64
+	ifVersion int64
+
+	err  error
+	done chan error
+}
+
+type batch struct {
+	ops []*balanceOp
+}
+
+type batcher struct {
+	repo    WalletRepository
+	window  time.Duration
+	maxSize int
+
+	mu      sync.Mutex
+	pending map[string]*batch
+}
+
+// submit queues op behind the other operations on its wallet and waits
+// for the batch to commit. The caller always gets the real outcome: it
+// waits even if its context is cancelled, and an operation whose caller
+// has already gone is skipped rather than applied.
+func (b *batcher) submit(op *balanceOp) error {
+	op.done = make(chan error, 1)
+
+	b.mu.Lock()
+	bt, ok := b.pending[op.walletID]
+	if !ok {
+		bt = &batch{}
+		b.pending[op.walletID] = bt
+		time.AfterFunc(b.window, func() { b.flush(op.walletID, bt) })
+	}
+	bt.ops = append(bt.ops, op)
+	full := len(bt.ops) >= b.maxSize
+	b.mu.Unlock()
+
+	if full {
+		go b.flush(op.walletID, bt)
+	}
+
+	return <-op.done
+}
+
+// flush runs bt unless the size limit or the timer already did.
+func (b *batcher) flush(walletID string, bt *batch) {
+	b.mu.Lock()
+	if b.pending[walletID] != bt {
+		b.mu.Unlock()
+		return
+	}
+	delete(b.pending, walletID)
+	b.mu.Unlock()
+
+	b.run(bt.ops)
+}
+
+// run applies ops in one transaction. A domain error (insufficient funds,
+// a stale version) fails only its own operation; any other error rolls
+// the transaction back and fails the whole batch.
+func (b *batcher) run(ops []*balanceOp) {
+	err := b.repo.WithinTx(context.Background(), func(ctx context.Context) error {
+		for _, op := range ops {
+			if op.err = op.ctx.Err(); op.err != nil {
+				continue
+			}
+
+			err := b.repo.UpdateBalance(ctx, op.walletID, op.currency, op.amount, op.ifVersion)
+			if _, ok := appErr.As(err); ok {
+				op.err = err
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	for _, op := range ops {
+		if err != nil {
+			op.done <- err
+			continue
+		}
+		op.done <- op.err
+	}
+}
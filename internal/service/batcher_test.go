@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+const testBatchWalletID = "550e8400-e29b-41d4-a716-446655440000"
+
+// processConcurrently runs one Process call per amount (negative amounts
+// withdraw) and returns their errors in the same order.
+func processConcurrently(svc *WalletService, amounts ...int64) []error {
+	errs := make([]error, len(amounts))
+
+	var wg sync.WaitGroup
+	for i, amount := range amounts {
+		op := model.OpDeposit
+		if amount < 0 {
+			op, amount = model.OpWithdraw, -amount
+		}
+
+		wg.Add(1)
+		go func(i int, op string, amount int64) {
+			defer wg.Done()
+			errs[i] = svc.Process(context.Background(), testBatchWalletID, "", op, amount, 0)
+		}(i, op, amount)
+	}
+	wg.Wait()
+
+	return errs
+}
+
+func TestProcess_BatchesConcurrentOps(t *testing.T) {
+	var txs atomic.Int32
+	var mu sync.Mutex
+	var applied []int64
+
+	mockRepo := &MockWalletRepository{
+		WithinTxFunc: func(ctx context.Context, fn func(ctx context.Context) error) error {
+			txs.Add(1)
+			return fn(ctx)
+		},
+		UpdateBalanceFunc: func(ctx context.Context, walletID, currency string, amount, ifVersion int64) error {
+			if amount < -500 {
+				return appErr.ErrInsufficientFunds
+			}
+			mu.Lock()
+			applied = append(applied, amount)
+			mu.Unlock()
+			return nil
+		},
+	}
+
+	// The batch is flushed by size, long before the window ends.
+	svc := New(mockRepo, WithBatching(time.Minute, 3))
+
+	errs := processConcurrently(svc, 100, -1000, 200)
+
+	if n := txs.Load(); n != 1 {
+		t.Errorf("expected one transaction, got %d", n)
+	}
+
+	failed := 0
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		failed++
+		if !errors.Is(err, appErr.ErrInsufficientFunds) {
+			t.Errorf("expected ErrInsufficientFunds, got %v", err)
+		}
+	}
+	if failed != 1 {
+		t.Errorf("expected exactly one failed operation, got %v", errs)
+	}
+
+	if len(applied) != 2 {
+		t.Errorf("expected two applied operations, got %v", applied)
+	}
+}
+
+func TestProcess_BatchFlushesAfterWindow(t *testing.T) {
+	var txs atomic.Int32
+
+	mockRepo := &MockWalletRepository{
+		WithinTxFunc: func(ctx context.Context, fn func(ctx context.Context) error) error {
+			txs.Add(1)
+			return fn(ctx)
+		},
+	}
+
+	svc := New(mockRepo, WithBatching(5*time.Millisecond, 100))
+
+	start := time.Now()
+	if err := svc.Process(context.Background(), testBatchWalletID, "", model.OpDeposit, 100, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 5*time.Millisecond {
+		t.Errorf("batch ran after %v, before its window", elapsed)
+	}
+	if n := txs.Load(); n != 1 {
+		t.Errorf("expected one transaction, got %d", n)
+	}
+}
+
+func TestProcess_BatchFailureFailsAllOps(t *testing.T) {
+	dbErr := errors.New("connection reset")
+
+	mockRepo := &MockWalletRepository{
+		UpdateBalanceFunc: func(ctx context.Context, walletID, currency string, amount, ifVersion int64) error {
+			if amount == 200 {
+				return dbErr
+			}
+			return nil
+		},
+	}
+
+	svc := New(mockRepo, WithBatching(time.Minute, 3))
+
+	for i, err := range processConcurrently(svc, 100, 200, 300) {
+		if err != dbErr {
+			t.Errorf("op %d: expected the transaction error, got %v", i, err)
+		}
+	}
+}
+
+func TestProcess_BatchSkipsCancelledOps(t *testing.T) {
+	var applied atomic.Int32
+
+	mockRepo := &MockWalletRepository{
+		UpdateBalanceFunc: func(ctx context.Context, walletID, currency string, amount, ifVersion int64) error {
+			applied.Add(1)
+			return nil
+		},
+	}
+
+	svc := New(mockRepo, WithBatching(5*time.Millisecond, 100))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := svc.Process(ctx, testBatchWalletID, "", model.OpDeposit, 100, 0)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if n := applied.Load(); n != 0 {
+		t.Errorf("cancelled operation was applied %d times", n)
+	}
+}
+
+func TestProcess_IdempotentBypassesBatcher(t *testing.T) {
+	var txs atomic.Int32
+
+	mockRepo := &MockWalletRepository{
+		WithinTxFunc: func(ctx context.Context, fn func(ctx context.Context) error) error {
+			txs.Add(1)
+			return fn(ctx)
+		},
+	}
+
+	svc := New(mockRepo, WithBatching(time.Minute, 100))
+
+	// With a one-minute window, a batched call would block the test.
+	_, _, _, err := svc.Idempotent(context.Background(), "key-1", "hash-1", func(ctx context.Context) (int, []byte, error) {
+		return 200, nil, svc.Process(ctx, testBatchWalletID, "", model.OpDeposit, 100, 0)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if n := txs.Load(); n != 1 {
+		t.Errorf("expected only the idempotency transaction, got %d", n)
+	}
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"math/big"
+	"strings"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+// rateDecimals is how many decimal places of a rate are stored. The
+// converted amount itself is computed from the exact applied rate.
+const rateDecimals = 10
+
+// WithConversionSpread sets the spread, in basis points, taken off the
+// table rate on every conversion.
+func WithConversionSpread(bps int) Option {
+	return func(s *WalletService) {
+		s.spreadBps = bps
+	}
+}
+
+// Convert sells amount of the wallet's from balance for the to currency at
+// the table rate in effect now minus the spread. The converted amount is
+// rounded down to the target currency's minor unit.
+func (s *WalletService) Convert(
+	ctx context.Context,
+	walletID string,
+	from string,
+	to string,
+	amount int64,
+) (model.ConversionResult, error) {
+
+	if amount <= 0 {
+		return model.ConversionResult{}, appErr.ErrInvalidOperation
+	}
+
+	fromCur, err := parseCurrency(from)
+	if err != nil {
+		return model.ConversionResult{}, err
+	}
+	toCur, err := parseCurrency(to)
+	if err != nil {
+		return model.ConversionResult{}, err
+	}
+	if fromCur.Code == toCur.Code {
+		return model.ConversionResult{}, appErr.ErrSameCurrency
+	}
+
+	rate, err := s.repo.GetRate(ctx, fromCur.Code, toCur.Code, time.Now())
+	if err != nil {
+		return model.ConversionResult{}, err
+	}
+
+	applied, ok := new(big.Rat).SetString(rate.Rate)
+	if !ok {
+		return model.ConversionResult{}, appErr.ErrInvalidRate
+	}
+	applied.Mul(applied, big.NewRat(int64(10000-s.spreadBps), 10000))
+
+	converted := convertAmount(amount, applied, fromCur.MinorUnits, toCur.MinorUnits)
+	if converted <= 0 {
+		return model.ConversionResult{}, appErr.ErrAmountTooSmall
+	}
+
+	return s.repo.Convert(ctx, walletID, fromCur.Code, toCur.Code, amount, converted, formatRate(applied))
+}
+
+// UpdateRates validates and stores rates. A zero EffectiveFrom means the
+// rate applies from now on.
+func (s *WalletService) UpdateRates(ctx context.Context, rates []model.ExchangeRate) error {
+	now := time.Now()
+
+	normalized := make([]model.ExchangeRate, 0, len(rates))
+	for _, r := range rates {
+		base, err := parseCurrency(r.Base)
+		if err != nil {
+			return err
+		}
+		quote, err := parseCurrency(r.Quote)
+		if err != nil {
+			return err
+		}
+		if base.Code == quote.Code {
+			return appErr.ErrSameCurrency
+		}
+
+		rate, ok := new(big.Rat).SetString(strings.TrimSpace(r.Rate))
+		if !ok || rate.Sign() <= 0 || formatRate(rate) == "0" {
+			return appErr.ErrInvalidRate
+		}
+
+		effectiveFrom := r.EffectiveFrom
+		if effectiveFrom.IsZero() {
+			effectiveFrom = now
+		}
+
+		normalized = append(normalized, model.ExchangeRate{
+			Base:          base.Code,
+			Quote:         quote.Code,
+			Rate:          formatRate(rate),
+			EffectiveFrom: effectiveFrom,
+		})
+	}
+
+	return s.repo.SaveRates(ctx, normalized)
+}
+
+// Rates returns the rate currently in effect for every pair.
+func (s *WalletService) Rates(ctx context.Context) ([]model.ExchangeRate, error) {
+	return s.repo.ListRates(ctx, time.Now())
+}
+
+// ParseRatesCSV reads rates in the "base,quote,rate,effective_from" format
+// with a header line. effective_from is RFC 3339 and may be left empty.
+func ParseRatesCSV(r io.Reader) ([]model.ExchangeRate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 4
+	reader.TrimLeadingSpace = true
+
+	if _, err := reader.Read(); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var rates []model.ExchangeRate
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rates, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		rate := model.ExchangeRate{
+			Base:  record[0],
+			Quote: record[1],
+			Rate:  record[2],
+		}
+		if record[3] != "" {
+			rate.EffectiveFrom, err = time.Parse(time.RFC3339, record[3])
+			if err != nil {
+				line, _ := reader.FieldPos(3)
+				return nil, &csv.ParseError{StartLine: line, Line: line, Column: 4, Err: err}
+			}
+		}
+		rates = append(rates, rate)
+	}
+}
+
+// convertAmount turns amount minor units of one currency into minor units
+// of another at rate (major units of the target per major unit of the
+// source), rounding down.
+func convertAmount(amount int64, rate *big.Rat, fromUnits, toUnits int) int64 {
+	v := new(big.Rat).SetInt64(amount)
+	v.Mul(v, rate)
+	v.Mul(v, new(big.Rat).SetFrac(pow10(toUnits), pow10(fromUnits)))
+
+	q := new(big.Int).Quo(v.Num(), v.Denom())
+	if !q.IsInt64() {
+		return 0
+	}
+	return q.Int64()
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+func formatRate(rate *big.Rat) string {
+	s := rate.FloatString(rateDecimals)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
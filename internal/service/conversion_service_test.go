@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+func rateRepo(rate string) *MockWalletRepository {
+	return &MockWalletRepository{
+		GetRateFunc: func(ctx context.Context, base, quote string, at time.Time) (model.ExchangeRate, error) {
+			return model.ExchangeRate{Base: base, Quote: quote, Rate: rate}, nil
+		},
+	}
+}
+
+func TestConvert_Amounts(t *testing.T) {
+	tests := []struct {
+		name      string
+		from, to  string
+		amount    int64
+		rate      string
+		spreadBps int
+		wantTo    int64
+		wantRate  string
+	}{
+		{"same exponent", "USD", "EUR", 1000, "0.9", 0, 900, "0.9"},
+		{"rounds down", "USD", "EUR", 333, "0.9", 0, 299, "0.9"},
+		{"with spread", "USD", "EUR", 10000, "0.9", 50, 8955, "0.8955"},
+		{"to zero-decimal currency", "USD", "JPY", 1050, "150.25", 0, 1577, "150.25"},
+		{"from zero-decimal currency", "JPY", "USD", 1500, "0.0066", 0, 990, "0.0066"},
+		{"to three-decimal currency", "EUR", "KWD", 100, "0.33", 0, 330, "0.33"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := rateRepo(tt.rate)
+			repo.ConvertFunc = func(ctx context.Context, walletID, fromCurrency, toCurrency string, fromAmount, toAmount int64, rate string) (model.ConversionResult, error) {
+				if fromCurrency != tt.from || toCurrency != tt.to || fromAmount != tt.amount {
+					t.Errorf("unexpected conversion %s %d -> %s", fromCurrency, fromAmount, toCurrency)
+				}
+				if toAmount != tt.wantTo {
+					t.Errorf("expected %d, got %d", tt.wantTo, toAmount)
+				}
+				if rate != tt.wantRate {
+					t.Errorf("expected rate %s, got %s", tt.wantRate, rate)
+				}
+				return model.ConversionResult{ToAmount: toAmount, Rate: rate}, nil
+			}
+
+			svc := New(repo, WithConversionSpread(tt.spreadBps))
+
+			if _, err := svc.Convert(context.Background(), "wallet-1", strings.ToLower(tt.from), tt.to, tt.amount); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestConvert_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		amount   int64
+		rate     string
+		want     error
+	}{
+		{"zero amount", "USD", "EUR", 0, "0.9", appErr.ErrInvalidOperation},
+		{"unknown currency", "USD", "XXX", 100, "0.9", appErr.ErrInvalidCurrency},
+		{"missing currency", "", "EUR", 100, "0.9", appErr.ErrInvalidCurrency},
+		{"same currency", "USD", "usd", 100, "1", appErr.ErrSameCurrency},
+		{"too small", "USD", "JPY", 1, "0.5", appErr.ErrAmountTooSmall},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := rateRepo(tt.rate)
+			repo.ConvertFunc = func(ctx context.Context, walletID, fromCurrency, toCurrency string, fromAmount, toAmount int64, rate string) (model.ConversionResult, error) {
+				t.Error("repository should not be called")
+				return model.ConversionResult{}, nil
+			}
+
+			_, err := New(repo).Convert(context.Background(), "wallet-1", tt.from, tt.to, tt.amount)
+			if err != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestConvert_RateNotFound(t *testing.T) {
+	svc := New(&MockWalletRepository{})
+
+	_, err := svc.Convert(context.Background(), "wallet-1", "USD", "EUR", 100)
+	if err != appErr.ErrRateNotFound {
+		t.Errorf("expected ErrRateNotFound, got %v", err)
+	}
+}
+
+func TestUpdateRates(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var saved []model.ExchangeRate
+	svc := New(&MockWalletRepository{
+		SaveRatesFunc: func(ctx context.Context, rates []model.ExchangeRate) error {
+			saved = rates
+			return nil
+		},
+	})
+
+	err := svc.UpdateRates(context.Background(), []model.ExchangeRate{
+		{Base: "usd", Quote: "eur", Rate: "0.90", EffectiveFrom: from},
+		{Base: "EUR", Quote: "USD", Rate: " 1.1 "},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(saved) != 2 {
+		t.Fatalf("expected 2 saved rates, got %d", len(saved))
+	}
+	if saved[0] != (model.ExchangeRate{Base: "USD", Quote: "EUR", Rate: "0.9", EffectiveFrom: from}) {
+		t.Errorf("unexpected first rate: %+v", saved[0])
+	}
+	if saved[1].Rate != "1.1" || saved[1].EffectiveFrom.IsZero() {
+		t.Errorf("unexpected second rate: %+v", saved[1])
+	}
+}
+
+func TestUpdateRates_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		rate model.ExchangeRate
+		want error
+	}{
+		{"unknown base", model.ExchangeRate{Base: "XXX", Quote: "EUR", Rate: "1"}, appErr.ErrInvalidCurrency},
+		{"missing quote", model.ExchangeRate{Base: "USD", Rate: "1"}, appErr.ErrInvalidCurrency},
+		{"same currency", model.ExchangeRate{Base: "USD", Quote: "USD", Rate: "1"}, appErr.ErrSameCurrency},
+		{"not a number", model.ExchangeRate{Base: "USD", Quote: "EUR", Rate: "abc"}, appErr.ErrInvalidRate},
+		{"zero", model.ExchangeRate{Base: "USD", Quote: "EUR", Rate: "0"}, appErr.ErrInvalidRate},
+		{"negative", model.ExchangeRate{Base: "USD", Quote: "EUR", Rate: "-0.9"}, appErr.ErrInvalidRate},
+		{"below precision", model.ExchangeRate{Base: "USD", Quote: "EUR", Rate: "0.00000000001"}, appErr.ErrInvalidRate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := New(&MockWalletRepository{
+				SaveRatesFunc: func(ctx context.Context, rates []model.ExchangeRate) error {
+					t.Error("repository should not be called")
+					return nil
+				},
+			})
+
+			err := svc.UpdateRates(context.Background(), []model.ExchangeRate{tt.rate})
+			if err != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestParseRatesCSV(t *testing.T) {
+	in := `base,quote,rate,effective_from
+USD,EUR,0.9,2026-01-01T00:00:00Z
+EUR,USD,1.1,
+`
+
+	rates, err := ParseRatesCSV(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(rates) != 2 {
+		t.Fatalf("expected 2 rates, got %d", len(rates))
+	}
+	if rates[0].Base != "USD" || rates[0].Quote != "EUR" || rates[0].Rate != "0.9" ||
+		!rates[0].EffectiveFrom.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected first rate: %+v", rates[0])
+	}
+	if !rates[1].EffectiveFrom.IsZero() {
+		t.Errorf("expected zero effective_from, got %v", rates[1].EffectiveFrom)
+	}
+}
+
+func TestParseRatesCSV_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{"bad date", "base,quote,rate,effective_from\nUSD,EUR,0.9,yesterday\n"},
+		{"missing field", "base,quote,rate,effective_from\nUSD,EUR,0.9\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseRatesCSV(strings.NewReader(tt.in)); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+// WalletSnapshot returns the wallet's balances in every currency and the
+// sequence of the latest event they reflect, the point a client without
+// history starts following WalletEvents from.
+func (s *WalletService) WalletSnapshot(ctx context.Context, walletID string) (int64, []model.Wallet, error) {
+	// The sequence is read first: a change committing in between shows in
+	// the balances and comes again as an event, whose balanceAfter only
+	// repeats what the client already has.
+	sequence, err := s.repo.LastEventSequence(ctx, walletID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	wallets, err := s.repo.ListWallets(ctx, walletID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(wallets) == 0 {
+		return 0, nil, appErr.ErrWalletNotFound
+	}
+
+	return sequence, wallets, nil
+}
+
+// WalletEvents returns the next page of the wallet's events after the
+// given sequence.
+func (s *WalletService) WalletEvents(ctx context.Context, walletID string, after int64) ([]model.WalletEvent, error) {
+	return s.repo.WalletEventsSince(ctx, walletID, after, s.maxPageSize)
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+func TestWalletSnapshot_ReadsSequenceFirst(t *testing.T) {
+	var calls []string
+	svc := New(&MockWalletRepository{
+		LastEventSequenceFunc: func(ctx context.Context, walletID string) (int64, error) {
+			calls = append(calls, "sequence")
+			return 17, nil
+		},
+		ListWalletsFunc: func(ctx context.Context, walletID string) ([]model.Wallet, error) {
+			calls = append(calls, "wallets")
+			return []model.Wallet{{Currency: "USD", Balance: 1500}}, nil
+		},
+	})
+
+	sequence, wallets, err := svc.WalletSnapshot(context.Background(), "550e8400-e29b-41d4-a716-446655440000")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if sequence != 17 || len(wallets) != 1 || wallets[0].Balance != 1500 {
+		t.Errorf("unexpected snapshot: %d %+v", sequence, wallets)
+	}
+	if len(calls) != 2 || calls[0] != "sequence" {
+		t.Errorf("the sequence must be read before the balances, got %v", calls)
+	}
+}
+
+func TestWalletSnapshot_UnknownWallet(t *testing.T) {
+	svc := New(&MockWalletRepository{
+		ListWalletsFunc: func(ctx context.Context, walletID string) ([]model.Wallet, error) {
+			return nil, nil
+		},
+	})
+
+	_, _, err := svc.WalletSnapshot(context.Background(), "550e8400-e29b-41d4-a716-446655440000")
+	if !errors.Is(err, appErr.ErrWalletNotFound) {
+		t.Errorf("expected ErrWalletNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+// FreezeWallet stops deposits, withdrawals, transfers, holds, captures and
+// conversions on every balance of the wallet until UnfreezeWallet. Reading
+// the wallet and releasing its holds still work. The repository checks for
+// the freeze in the transaction of each operation: one that has already
+// locked the wallet's balance completes and the freeze waits for it, any
+// other is refused.
+func (s *WalletService) FreezeWallet(ctx context.Context, walletID, reason string) (model.WalletFreeze, error) {
+	if err := s.walletExists(ctx, walletID); err != nil {
+		return model.WalletFreeze{}, err
+	}
+
+	return s.repo.FreezeWallet(ctx, walletID, reason)
+}
+
+func (s *WalletService) UnfreezeWallet(ctx context.Context, walletID string) error {
+	if err := s.walletExists(ctx, walletID); err != nil {
+		return err
+	}
+
+	return s.repo.UnfreezeWallet(ctx, walletID)
+}
+
+// WalletFreeze returns the wallet's freeze, or nil when it is not frozen.
+func (s *WalletService) WalletFreeze(ctx context.Context, walletID string) (*model.WalletFreeze, error) {
+	if err := s.walletExists(ctx, walletID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetWalletFreeze(ctx, walletID)
+}
+
+func (s *WalletService) walletExists(ctx context.Context, walletID string) error {
+	wallets, err := s.repo.ListWallets(ctx, walletID)
+	if err != nil {
+		return err
+	}
+	if len(wallets) == 0 {
+		return appErr.ErrWalletNotFound
+	}
+	return nil
+}
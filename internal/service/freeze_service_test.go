@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+)
+
+const frozenID = "550e8400-e29b-41d4-a716-446655440000"
+
+func TestFreezeWallet(t *testing.T) {
+	var frozen, unfrozen string
+	svc := New(&MockWalletRepository{
+		FreezeWalletFunc: func(ctx context.Context, walletID, reason string) (model.WalletFreeze, error) {
+			frozen = walletID + " " + reason
+			return model.WalletFreeze{WalletID: uuid.MustParse(walletID), Reason: reason}, nil
+		},
+		UnfreezeWalletFunc: func(ctx context.Context, walletID string) error {
+			unfrozen = walletID
+			return nil
+		},
+	})
+
+	freeze, err := svc.FreezeWallet(context.Background(), frozenID, "fraud")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if frozen != frozenID+" fraud" || freeze.Reason != "fraud" {
+		t.Errorf("unexpected freeze %q: %+v", frozen, freeze)
+	}
+
+	if err := svc.UnfreezeWallet(context.Background(), frozenID); err != nil || unfrozen != frozenID {
+		t.Errorf("unexpected unfreeze of %q: %v", unfrozen, err)
+	}
+}
+
+func TestFreezeWallet_UnknownWallet(t *testing.T) {
+	svc := New(&MockWalletRepository{
+		ListWalletsFunc: func(ctx context.Context, walletID string) ([]model.Wallet, error) {
+			return nil, nil
+		},
+		FreezeWalletFunc: func(ctx context.Context, walletID, reason string) (model.WalletFreeze, error) {
+			t.Error("an unknown wallet must not be frozen")
+			return model.WalletFreeze{}, nil
+		},
+	})
+
+	if _, err := svc.FreezeWallet(context.Background(), frozenID, ""); !errors.Is(err, appErr.ErrWalletNotFound) {
+		t.Errorf("expected ErrWalletNotFound, got %v", err)
+	}
+	if err := svc.UnfreezeWallet(context.Background(), frozenID); !errors.Is(err, appErr.ErrWalletNotFound) {
+		t.Errorf("expected ErrWalletNotFound, got %v", err)
+	}
+	if _, err := svc.WalletFreeze(context.Background(), frozenID); !errors.Is(err, appErr.ErrWalletNotFound) {
+		t.Errorf("expected ErrWalletNotFound, got %v", err)
+	}
+}
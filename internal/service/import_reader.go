@@ -0,0 +1,168 @@
+package service
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// maxImportLine bounds a single JSONL line.
+const maxImportLine = 1 << 20
+
+// importRow is one operation of an import file. A row that cannot be
+// parsed carries err instead and is reported as a failed line.
+type importRow struct {
+	line     int
+	walletID string
+	currency string
+	op       string
+	amount   int64
+	err      error
+}
+
+type importReader interface {
+	// next returns the following row, or io.EOF after the last one. Any
+	// other error means the rest of the file cannot be read.
+	next() (importRow, error)
+}
+
+// newImportReader checks the file header and returns a reader of its
+// rows. CSV files start with a header naming the walletId, operationType
+// and amount columns, plus an optional currency column, in any order.
+// JSONL files hold one walletRequest-like object per line.
+func newImportReader(format string, r io.Reader) (importReader, error) {
+	switch format {
+	case model.ImportCSV:
+		return newCSVImportReader(r)
+	case model.ImportJSONL:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxImportLine)
+		return &jsonlImportReader{scanner: scanner}, nil
+	default:
+		return nil, appErr.ErrInvalidImport.WithDetails(map[string]any{"format": format})
+	}
+}
+
+type csvImportReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+func newCSVImportReader(r io.Reader) (*csvImportReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, appErr.ErrInvalidImport.WithDetails(map[string]any{"reason": "empty file"})
+	}
+	if err != nil {
+		return nil, appErr.ErrInvalidImport.WithDetails(map[string]any{"reason": err.Error()})
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"walletid", "operationtype", "amount"} {
+		if _, ok := columns[name]; !ok {
+			return nil, appErr.ErrInvalidImport.WithDetails(map[string]any{"reason": "missing column " + name})
+		}
+	}
+
+	return &csvImportReader{reader: reader, columns: columns}, nil
+}
+
+func (c *csvImportReader) next() (importRow, error) {
+	record, err := c.reader.Read()
+	if errors.Is(err, io.EOF) {
+		return importRow{}, io.EOF
+	}
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return importRow{line: parseErr.StartLine, err: invalidRow(parseErr.Err.Error())}, nil
+	}
+	if err != nil {
+		return importRow{}, err
+	}
+
+	line, _ := c.reader.FieldPos(0)
+	field := func(name string) string {
+		i, ok := c.columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	amount, err := strconv.ParseInt(field("amount"), 10, 64)
+	if err != nil {
+		return importRow{line: line, err: invalidRow("invalid amount")}, nil
+	}
+
+	return parseRow(line, field("walletid"), field("currency"), field("operationtype"), amount), nil
+}
+
+type jsonlImportReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (j *jsonlImportReader) next() (importRow, error) {
+	for j.scanner.Scan() {
+		j.line++
+
+		text := strings.TrimSpace(j.scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var req struct {
+			WalletID string `json:"walletId"`
+			Currency string `json:"currency"`
+			OpType   string `json:"operationType"`
+			Amount   int64  `json:"amount"`
+		}
+		if err := json.Unmarshal([]byte(text), &req); err != nil {
+			return importRow{line: j.line, err: invalidRow("invalid json")}, nil
+		}
+
+		return parseRow(j.line, req.WalletID, req.Currency, req.OpType, req.Amount), nil
+	}
+
+	if err := j.scanner.Err(); err != nil {
+		return importRow{}, err
+	}
+	return importRow{}, io.EOF
+}
+
+func parseRow(line int, walletID, currency, op string, amount int64) importRow {
+	id, err := uuid.Parse(walletID)
+	if err != nil {
+		return importRow{line: line, err: invalidRow("invalid walletId")}
+	}
+
+	return importRow{
+		line:     line,
+		walletID: id.String(),
+		currency: currency,
+		op:       op,
+		amount:   amount,
+	}
+}
+
+func invalidRow(detail string) error {
+	return appErr.New(appErr.CodeValidationFailed, http.StatusBadRequest, detail)
+}
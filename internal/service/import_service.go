@@ -0,0 +1,160 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+// CreateImport stores an import file and queues it. The file is checked
+// up front only as a whole (format, CSV header); bad lines are reported
+// when the job runs.
+func (s *WalletService) CreateImport(ctx context.Context, format string, data []byte) (model.ImportJob, error) {
+	format = strings.ToLower(format)
+	if _, err := newImportReader(format, bytes.NewReader(data)); err != nil {
+		return model.ImportJob{}, err
+	}
+
+	job, err := s.repo.CreateImport(ctx, format, data)
+	if err != nil {
+		return model.ImportJob{}, err
+	}
+
+	select {
+	case s.importQueued <- struct{}{}:
+	default:
+	}
+
+	return job, nil
+}
+
+// ImportQueued receives a value whenever a new import job is created.
+func (s *WalletService) ImportQueued() <-chan struct{} {
+	return s.importQueued
+}
+
+func (s *WalletService) Import(ctx context.Context, id string) (model.ImportJob, error) {
+	return s.repo.GetImport(ctx, id)
+}
+
+// ImportErrors returns the failed lines of a job, in file order.
+func (s *WalletService) ImportErrors(ctx context.Context, id string) ([]model.ImportLineError, error) {
+	if _, err := s.repo.GetImport(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return s.repo.ImportErrors(ctx, id)
+}
+
+// RunImports runs every queued or interrupted job, oldest first.
+func (s *WalletService) RunImports(ctx context.Context) error {
+	jobs, err := s.repo.UnfinishedImports(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		if _, err := s.RunImport(ctx, job.ID.String()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RunImport applies the job's lines through Process, starting after the
+// last line already done. Each line commits together with the job's
+// progress, so a job stopped at any point (a crash, a database error)
+// resumes without applying a line twice. A line the service refuses is
+// recorded as failed and the job moves on; an error of the service itself
+// stops the job, which stays RUNNING to be resumed later.
+func (s *WalletService) RunImport(ctx context.Context, id string) (model.ImportJob, error) {
+	job, err := s.repo.GetImport(ctx, id)
+	if err != nil {
+		return model.ImportJob{}, err
+	}
+	if job.Status != model.ImportPending && job.Status != model.ImportRunning {
+		return job, nil
+	}
+
+	data, err := s.repo.ImportData(ctx, id)
+	if err != nil {
+		return model.ImportJob{}, err
+	}
+
+	rows, err := newImportReader(job.Format, bytes.NewReader(data))
+	if err != nil {
+		return s.finishImport(ctx, id, model.ImportFailed, err.Error())
+	}
+
+	if err := s.repo.SetImportStatus(ctx, id, model.ImportRunning, ""); err != nil {
+		return model.ImportJob{}, err
+	}
+
+	for {
+		row, err := rows.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return s.finishImport(ctx, id, model.ImportFailed, err.Error())
+		}
+
+		if row.line <= job.LastLine {
+			continue
+		}
+
+		if err := s.importRow(ctx, id, row); err != nil {
+			return model.ImportJob{}, err
+		}
+	}
+
+	return s.finishImport(ctx, id, model.ImportCompleted, "")
+}
+
+func (s *WalletService) finishImport(ctx context.Context, id, status, msg string) (model.ImportJob, error) {
+	if err := s.repo.SetImportStatus(ctx, id, status, msg); err != nil {
+		return model.ImportJob{}, err
+	}
+
+	return s.repo.GetImport(ctx, id)
+}
+
+func (s *WalletService) importRow(ctx context.Context, jobID string, row importRow) error {
+	lineErr := row.err
+
+	if lineErr == nil {
+		err := s.repo.WithinTx(ctx, func(ctx context.Context) error {
+			ctx = context.WithValue(ctx, txScopeKey{}, true)
+
+			// The job row is locked before any wallet, here and in every
+			// other runner of the job.
+			advanced, err := s.repo.AdvanceImport(ctx, jobID, row.line, nil)
+			if err != nil || !advanced {
+				return err
+			}
+
+			return s.Process(ctx, row.walletID, row.currency, row.op, row.amount, 0)
+		})
+
+		// Contention is worth another try when the job resumes, unlike a
+		// refused operation.
+		if _, ok := appErr.As(err); !ok || errors.Is(err, appErr.ErrConcurrentUpdate) {
+			return err
+		}
+		lineErr = err
+	}
+
+	e, _ := appErr.As(lineErr)
+	_, err := s.repo.AdvanceImport(ctx, jobID, row.line, &model.ImportLineError{
+		Line:   row.line,
+		Code:   e.Code,
+		Detail: e.Message,
+	})
+	return err
+}
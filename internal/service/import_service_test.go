@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+const (
+	testImportID       = "3f1c2b7a-8e4d-4c5b-9a6f-0e1d2c3b4a59"
+	testImportWalletID = "550e8400-e29b-41d4-a716-446655440000"
+)
+
+// importRepo returns a repository holding one import job with data, and
+// the log of lines it was asked to advance ("line:code", code empty on
+// success) and of status changes.
+func importRepo(job model.ImportJob, data string) (*MockWalletRepository, *[]string, *[]string) {
+	var advanced, statuses []string
+
+	repo := &MockWalletRepository{
+		GetImportFunc: func(ctx context.Context, id string) (model.ImportJob, error) {
+			return job, nil
+		},
+		ImportDataFunc: func(ctx context.Context, id string) ([]byte, error) {
+			return []byte(data), nil
+		},
+		AdvanceImportFunc: func(ctx context.Context, id string, line int, lineErr *model.ImportLineError) (bool, error) {
+			code := ""
+			if lineErr != nil {
+				code = lineErr.Code
+			}
+			advanced = append(advanced, fmt.Sprintf("%d:%s", line, code))
+			return true, nil
+		},
+		SetImportStatusFunc: func(ctx context.Context, id, status, msg string) error {
+			statuses = append(statuses, status)
+			return nil
+		},
+	}
+
+	return repo, &advanced, &statuses
+}
+
+func TestRunImport_RecordsFailedLines(t *testing.T) {
+	data := "walletId,operationType,amount,currency\n" +
+		testImportWalletID + ",DEPOSIT,100,\n" +
+		testImportWalletID + ",WITHDRAW,500,usd\n" +
+		"not-a-uuid,DEPOSIT,1,\n" +
+		testImportWalletID + ",DEPOSIT,abc,\n"
+
+	repo, advanced, statuses := importRepo(model.ImportJob{Format: model.ImportCSV, Status: model.ImportPending}, data)
+
+	var applied []string
+	repo.UpdateBalanceFunc = func(ctx context.Context, walletID, currency string, amount, ifVersion int64) error {
+		if amount < 0 {
+			return appErr.ErrInsufficientFunds
+		}
+		applied = append(applied, fmt.Sprintf("%s/%d", currency, amount))
+		return nil
+	}
+
+	svc := New(repo)
+
+	if _, err := svc.RunImport(context.Background(), testImportID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A refused line is advanced again, as failed, once its transaction
+	// has rolled back.
+	want := []string{"2:", "3:", "3:INSUFFICIENT_FUNDS", "4:VALIDATION_FAILED", "5:VALIDATION_FAILED"}
+	if fmt.Sprint(*advanced) != fmt.Sprint(want) {
+		t.Errorf("expected advanced lines %v, got %v", want, *advanced)
+	}
+
+	if len(applied) != 1 || applied[0] != "RUB/100" {
+		t.Errorf("unexpected applied operations: %v", applied)
+	}
+
+	if fmt.Sprint(*statuses) != fmt.Sprint([]string{model.ImportRunning, model.ImportCompleted}) {
+		t.Errorf("unexpected status changes: %v", *statuses)
+	}
+}
+
+func TestRunImport_ResumesAfterLastLine(t *testing.T) {
+	data := `{"walletId":"` + testImportWalletID + `","operationType":"DEPOSIT","amount":1}` + "\n" +
+		"\n" +
+		`{"walletId":"` + testImportWalletID + `","operationType":"DEPOSIT","amount":3}` + "\n"
+
+	repo, advanced, _ := importRepo(model.ImportJob{Format: model.ImportJSONL, Status: model.ImportRunning, LastLine: 1}, data)
+
+	var applied []int64
+	repo.UpdateBalanceFunc = func(ctx context.Context, walletID, currency string, amount, ifVersion int64) error {
+		applied = append(applied, amount)
+		return nil
+	}
+
+	svc := New(repo)
+
+	if _, err := svc.RunImport(context.Background(), testImportID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(applied) != 1 || applied[0] != 3 {
+		t.Errorf("expected only the line after LastLine to apply, got %v", applied)
+	}
+	if fmt.Sprint(*advanced) != "[3:]" {
+		t.Errorf("unexpected advanced lines: %v", *advanced)
+	}
+}
+
+func TestRunImport_StopsOnServiceError(t *testing.T) {
+	dbErr := errors.New("connection reset")
+
+	data := "walletId,operationType,amount\n" +
+		testImportWalletID + ",DEPOSIT,100\n" +
+		testImportWalletID + ",DEPOSIT,200\n"
+
+	repo, advanced, statuses := importRepo(model.ImportJob{Format: model.ImportCSV, Status: model.ImportPending}, data)
+	repo.UpdateBalanceFunc = func(ctx context.Context, walletID, currency string, amount, ifVersion int64) error {
+		return dbErr
+	}
+
+	svc := New(repo)
+
+	if _, err := svc.RunImport(context.Background(), testImportID); err != dbErr {
+		t.Fatalf("expected the database error, got %v", err)
+	}
+
+	if len(*advanced) != 1 {
+		t.Errorf("expected the job to stop at its first line, got %v", *advanced)
+	}
+	if fmt.Sprint(*statuses) != fmt.Sprint([]string{model.ImportRunning}) {
+		t.Errorf("expected the job to stay running, got %v", *statuses)
+	}
+}
+
+func TestCreateImport_ChecksFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		data   string
+		want   error
+	}{
+		{"csv", "CSV", "walletId,operationType,amount\n", nil},
+		{"jsonl", "jsonl", "", nil},
+		{"missing column", "csv", "walletId,amount\n", appErr.ErrInvalidImport},
+		{"empty csv", "csv", "", appErr.ErrInvalidImport},
+		{"unknown format", "xml", "<operations/>", appErr.ErrInvalidImport},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			created := false
+			svc := New(&MockWalletRepository{
+				CreateImportFunc: func(ctx context.Context, format string, data []byte) (model.ImportJob, error) {
+					created = true
+					return model.ImportJob{Format: format}, nil
+				},
+			})
+
+			job, err := svc.CreateImport(context.Background(), tt.format, []byte(tt.data))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+
+			if created != (tt.want == nil) {
+				t.Errorf("job created = %v", created)
+			}
+			if tt.want == nil && job.Format != model.ImportCSV && job.Format != model.ImportJSONL {
+				t.Errorf("format not normalized: %q", job.Format)
+			}
+		})
+	}
+}
@@ -2,44 +2,433 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
 
 	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
 )
 
 type WalletRepository interface {
-	UpdateBalance(ctx context.Context, walletID string, amount int64) error
-	GetBalance(ctx context.Context, walletID string) (int64, error)
+	UpdateBalance(ctx context.Context, walletID, currency string, amount, ifVersion int64) error
+	GetWallet(ctx context.Context, walletID, currency string) (model.Wallet, error)
+	ListWallets(ctx context.Context, walletID string) ([]model.Wallet, error)
+	ListTransactions(ctx context.Context, walletID string, filter model.TransactionFilter) ([]model.Transaction, error)
+	Transfer(ctx context.Context, fromID, toID, currency string, amount int64) (model.TransferResult, error)
+	TrialBalance(ctx context.Context) ([]model.AccountBalance, error)
+
+	FreezeWallet(ctx context.Context, walletID, reason string) (model.WalletFreeze, error)
+	UnfreezeWallet(ctx context.Context, walletID string) error
+	GetWalletFreeze(ctx context.Context, walletID string) (*model.WalletFreeze, error)
+
+	PlaceHold(ctx context.Context, walletID, currency string, amount int64, expiresAt time.Time) (model.Hold, error)
+	CaptureHold(ctx context.Context, holdID string, amount int64, now time.Time) (model.Hold, error)
+	ReleaseHold(ctx context.Context, holdID string) (model.Hold, error)
+	ExpireHolds(ctx context.Context, now time.Time, limit int) ([]model.Hold, error)
+	GetHold(ctx context.Context, holdID string) (model.Hold, error)
+
+	Convert(ctx context.Context, walletID, fromCurrency, toCurrency string, fromAmount, toAmount int64, rate string) (model.ConversionResult, error)
+	SaveRates(ctx context.Context, rates []model.ExchangeRate) error
+	GetRate(ctx context.Context, base, quote string, at time.Time) (model.ExchangeRate, error)
+	ListRates(ctx context.Context, at time.Time) ([]model.ExchangeRate, error)
+
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+	ReserveIdempotencyKey(ctx context.Context, key, requestHash string, expiredBefore time.Time) (*model.IdempotencyRecord, error)
+	SaveIdempotencyResponse(ctx context.Context, key string, statusCode int, response []byte) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context, expiredBefore time.Time) (int64, error)
+
+	WalletEventsSince(ctx context.Context, walletID string, after int64, limit int) ([]model.WalletEvent, error)
+	LastEventSequence(ctx context.Context, walletID string) (int64, error)
+
+	CreateImport(ctx context.Context, format string, data []byte) (model.ImportJob, error)
+	GetImport(ctx context.Context, id string) (model.ImportJob, error)
+	ImportData(ctx context.Context, id string) ([]byte, error)
+	UnfinishedImports(ctx context.Context) ([]model.ImportJob, error)
+	AdvanceImport(ctx context.Context, id string, line int, lineErr *model.ImportLineError) (bool, error)
+	SetImportStatus(ctx context.Context, id, status, msg string) error
+	ImportErrors(ctx context.Context, id string) ([]model.ImportLineError, error)
+
+	CreateWebhook(ctx context.Context, url, secret string, events []string) (model.Webhook, error)
+	GetWebhook(ctx context.Context, id string) (model.Webhook, error)
+	DeactivateWebhook(ctx context.Context, id string) error
+	WebhookDeliveries(ctx context.Context, status string, limit int) ([]model.WebhookDelivery, error)
+	ReplayWebhookDelivery(ctx context.Context, id int64) (model.WebhookDelivery, error)
 }
 
 type WalletService struct {
 	repo WalletRepository
+
+	defaultCurrency string
+	spreadBps       int
+	defaultPageSize int
+	maxPageSize     int
+	idempotencyTTL  time.Duration
+	defaultHoldTTL  time.Duration
+	maxHoldTTL      time.Duration
+
+	// batcher, when set, groups concurrent Process calls per wallet.
+	batcher *batcher
+
+	maxBatchItems int
+
+	importQueued chan struct{}
+}
+
+type Option func(*WalletService)
+
+// WithDefaultCurrency sets the currency used when a request names none.
+func WithDefaultCurrency(code string) Option {
+	return func(s *WalletService) {
+		s.defaultCurrency = code
+	}
 }
 
-func New(repo WalletRepository) *WalletService {
-	return &WalletService{repo: repo}
+func WithPageSize(def, max int) Option {
+	return func(s *WalletService) {
+		s.defaultPageSize = def
+		s.maxPageSize = max
+	}
 }
 
+func WithIdempotencyTTL(ttl time.Duration) Option {
+	return func(s *WalletService) {
+		s.idempotencyTTL = ttl
+	}
+}
+
+func WithHoldTTL(def, max time.Duration) Option {
+	return func(s *WalletService) {
+		s.defaultHoldTTL = def
+		s.maxHoldTTL = max
+	}
+}
+
+func New(repo WalletRepository, opts ...Option) *WalletService {
+	s := &WalletService{
+		repo:            repo,
+		defaultCurrency: "RUB",
+		defaultPageSize: 20,
+		maxPageSize:     100,
+		idempotencyTTL:  24 * time.Hour,
+		defaultHoldTTL:  15 * time.Minute,
+		maxHoldTTL:      7 * 24 * time.Hour,
+		maxBatchItems:   1000,
+		importQueued:    make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Process deposits or withdraws amount. A non-zero ifVersion is the
+// version the balance must still have for the operation to apply.
 func (s *WalletService) Process(
 	ctx context.Context,
 	walletID string,
+	currency string,
 	op string,
 	amount int64,
+	ifVersion int64,
 ) error {
 
+	currency, amount, err := s.operation(currency, op, amount)
+	if err != nil {
+		return err
+	}
+
+	// An operation inside the caller's transaction has to commit with it,
+	// so it cannot join a batch.
+	if s.batcher == nil || inTransaction(ctx) {
+		return s.repo.UpdateBalance(ctx, walletID, currency, amount, ifVersion)
+	}
+
+	return s.batcher.submit(&balanceOp{
+		ctx:       ctx,
+		walletID:  walletID,
+		currency:  currency,
+		amount:    amount,
+		ifVersion: ifVersion,
+	})
+}
+
+// operation validates a deposit or withdrawal and returns its currency and
+// signed amount.
+func (s *WalletService) operation(currency, op string, amount int64) (string, int64, error) {
 	if amount <= 0 {
-		return appErr.ErrInvalidOperation
+		return "", 0, appErr.ErrInvalidOperation
+	}
+
+	currency, err := s.currency(currency)
+	if err != nil {
+		return "", 0, err
 	}
 
 	switch op {
-	case "DEPOSIT":
-		return s.repo.UpdateBalance(ctx, walletID, amount)
-	case "WITHDRAW":
-		return s.repo.UpdateBalance(ctx, walletID, -amount)
+	case model.OpDeposit:
+		return currency, amount, nil
+	case model.OpWithdraw:
+		return currency, -amount, nil
 	default:
-		return appErr.ErrInvalidOperation
+		return "", 0, appErr.ErrInvalidOperation
+	}
+}
+
+func (s *WalletService) Transfer(
+	ctx context.Context,
+	fromID string,
+	toID string,
+	currency string,
+	amount int64,
+) (model.TransferResult, error) {
+
+	if amount <= 0 {
+		return model.TransferResult{}, appErr.ErrInvalidOperation
+	}
+
+	if fromID == toID {
+		return model.TransferResult{}, appErr.ErrSameWallet
+	}
+
+	currency, err := s.currency(currency)
+	if err != nil {
+		return model.TransferResult{}, err
+	}
+
+	return s.repo.Transfer(ctx, fromID, toID, currency, amount)
+}
+
+func (s *WalletService) TrialBalance(ctx context.Context) ([]model.AccountBalance, error) {
+	return s.repo.TrialBalance(ctx)
+}
+
+func (s *WalletService) Balance(ctx context.Context, walletID, currency string) (model.Wallet, error) {
+	currency, err := s.currency(currency)
+	if err != nil {
+		return model.Wallet{}, err
 	}
+
+	return s.repo.GetWallet(ctx, walletID, currency)
 }
 
-func (s *WalletService) Balance(ctx context.Context, walletID string) (int64, error) {
-	return s.repo.GetBalance(ctx, walletID)
+// PlaceHold reserves amount on the wallet's currency balance for ttl (the
+// configured default when ttl is zero).
+func (s *WalletService) PlaceHold(
+	ctx context.Context,
+	walletID string,
+	currency string,
+	amount int64,
+	ttl time.Duration,
+) (model.Hold, error) {
+
+	if amount <= 0 {
+		return model.Hold{}, appErr.ErrInvalidOperation
+	}
+
+	currency, err := s.currency(currency)
+	if err != nil {
+		return model.Hold{}, err
+	}
+
+	if ttl == 0 {
+		ttl = s.defaultHoldTTL
+	}
+	if ttl < 0 || ttl > s.maxHoldTTL {
+		return model.Hold{}, appErr.ErrInvalidTTL
+	}
+
+	return s.repo.PlaceHold(ctx, walletID, currency, amount, time.Now().Add(ttl))
+}
+
+// CaptureHold withdraws amount from an active hold, or the full hold when
+// amount is zero; the rest of the hold is released.
+func (s *WalletService) CaptureHold(ctx context.Context, holdID string, amount int64) (model.Hold, error) {
+	if amount < 0 {
+		return model.Hold{}, appErr.ErrInvalidOperation
+	}
+
+	return s.repo.CaptureHold(ctx, holdID, amount, time.Now())
+}
+
+func (s *WalletService) ReleaseHold(ctx context.Context, holdID string) (model.Hold, error) {
+	return s.repo.ReleaseHold(ctx, holdID)
+}
+
+func (s *WalletService) Hold(ctx context.Context, holdID string) (model.Hold, error) {
+	return s.repo.GetHold(ctx, holdID)
+}
+
+// ExpireHolds releases every hold that is past its expiry and returns how
+// many it released.
+func (s *WalletService) ExpireHolds(ctx context.Context) (int, error) {
+	const batchSize = 100
+
+	total := 0
+	for {
+		expired, err := s.repo.ExpireHolds(ctx, time.Now(), batchSize)
+		total += len(expired)
+		if err != nil || len(expired) < batchSize {
+			return total, err
+		}
+	}
+}
+
+// History returns one page of the wallet's ledger, newest first, together
+// with an opaque cursor for the next page ("" when there are no more).
+func (s *WalletService) History(
+	ctx context.Context,
+	walletID string,
+	filter model.TransactionFilter,
+	cursor string,
+) ([]model.Transaction, string, error) {
+
+	switch filter.Type {
+	case "", model.OpDeposit, model.OpWithdraw, model.OpTransferOut, model.OpTransferIn, model.OpCapture,
+		model.OpConvertOut, model.OpConvertIn:
+	default:
+		return nil, "", appErr.ErrInvalidOperation
+	}
+
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return nil, "", appErr.ErrInvalidDateRange
+	}
+
+	// No currency means the history of every balance the wallet holds.
+	if filter.Currency != "" {
+		currency, err := s.currency(filter.Currency)
+		if err != nil {
+			return nil, "", err
+		}
+		filter.Currency = currency
+	}
+
+	if cursor != "" {
+		beforeID, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		filter.BeforeID = beforeID
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = s.defaultPageSize
+	}
+	if limit > s.maxPageSize {
+		limit = s.maxPageSize
+	}
+	// A page holds at least one transaction, whatever the options say.
+	limit = max(limit, 1)
+
+	wallets, err := s.repo.ListWallets(ctx, walletID)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(wallets) == 0 {
+		return nil, "", appErr.ErrWalletNotFound
+	}
+
+	// Fetch one extra row to learn whether another page exists.
+	filter.Limit = limit + 1
+	txs, err := s.repo.ListTransactions(ctx, walletID, filter)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(txs) <= limit {
+		return txs, "", nil
+	}
+
+	txs = txs[:limit]
+	return txs, encodeCursor(txs[limit-1].ID), nil
+}
+
+// Idempotent runs fn at most once per key within the retention period.
+// The key reservation, fn and the stored response share one transaction;
+// a repeated key returns the stored response with replayed set, and a
+// repeated key with a different requestHash fails with
+// ErrIdempotencyConflict. An error from fn aborts the transaction without
+// storing anything, so a retry with the same key runs it again.
+func (s *WalletService) Idempotent(
+	ctx context.Context,
+	key string,
+	requestHash string,
+	fn func(ctx context.Context) (int, []byte, error),
+) (statusCode int, response []byte, replayed bool, err error) {
+
+	err = s.repo.WithinTx(ctx, func(ctx context.Context) error {
+		ctx = context.WithValue(ctx, txScopeKey{}, true)
+
+		rec, err := s.repo.ReserveIdempotencyKey(ctx, key, requestHash, time.Now().Add(-s.idempotencyTTL))
+		if err != nil {
+			return err
+		}
+
+		if rec != nil {
+			if rec.RequestHash != requestHash || rec.StatusCode == 0 {
+				return appErr.ErrIdempotencyConflict
+			}
+			statusCode, response, replayed = rec.StatusCode, rec.Response, true
+			return nil
+		}
+
+		statusCode, response, err = fn(ctx)
+		if err != nil {
+			return err
+		}
+
+		return s.repo.SaveIdempotencyResponse(ctx, key, statusCode, response)
+	})
+	if err != nil {
+		return 0, nil, false, err
+	}
+
+	return statusCode, response, replayed, nil
+}
+
+func (s *WalletService) PurgeIdempotencyKeys(ctx context.Context) (int64, error) {
+	return s.repo.DeleteExpiredIdempotencyKeys(ctx, time.Now().Add(-s.idempotencyTTL))
+}
+
+// currency validates an ISO 4217 code, falling back to the default
+// currency when code is empty.
+func (s *WalletService) currency(code string) (string, error) {
+	if code == "" {
+		return s.defaultCurrency, nil
+	}
+
+	c, err := parseCurrency(code)
+	if err != nil {
+		return "", err
+	}
+
+	return c.Code, nil
+}
+
+func parseCurrency(code string) (model.Currency, error) {
+	c, ok := model.LookupCurrency(strings.ToUpper(code))
+	if !ok {
+		return model.Currency{}, appErr.ErrInvalidCurrency
+	}
+	return c, nil
+}
+
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodeCursor(cursor string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, appErr.ErrInvalidCursor
+	}
+
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, appErr.ErrInvalidCursor
+	}
+
+	return id, nil
 }
@@ -2,33 +2,315 @@ package service
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
 	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
 )
 
 type MockWalletRepository struct {
-	UpdateBalanceFunc func(ctx context.Context, walletID string, amount int64) error
-	GetBalanceFunc    func(ctx context.Context, walletID string) (int64, error)
+	UpdateBalanceFunc    func(ctx context.Context, walletID, currency string, amount, ifVersion int64) error
+	GetWalletFunc        func(ctx context.Context, walletID, currency string) (model.Wallet, error)
+	ListWalletsFunc      func(ctx context.Context, walletID string) ([]model.Wallet, error)
+	ListTransactionsFunc func(ctx context.Context, walletID string, filter model.TransactionFilter) ([]model.Transaction, error)
+	TransferFunc         func(ctx context.Context, fromID, toID, currency string, amount int64) (model.TransferResult, error)
+	TrialBalanceFunc     func(ctx context.Context) ([]model.AccountBalance, error)
+
+	FreezeWalletFunc    func(ctx context.Context, walletID, reason string) (model.WalletFreeze, error)
+	UnfreezeWalletFunc  func(ctx context.Context, walletID string) error
+	GetWalletFreezeFunc func(ctx context.Context, walletID string) (*model.WalletFreeze, error)
+
+	PlaceHoldFunc   func(ctx context.Context, walletID, currency string, amount int64, expiresAt time.Time) (model.Hold, error)
+	CaptureHoldFunc func(ctx context.Context, holdID string, amount int64, now time.Time) (model.Hold, error)
+	ReleaseHoldFunc func(ctx context.Context, holdID string) (model.Hold, error)
+	ExpireHoldsFunc func(ctx context.Context, now time.Time, limit int) ([]model.Hold, error)
+	GetHoldFunc     func(ctx context.Context, holdID string) (model.Hold, error)
+
+	ConvertFunc   func(ctx context.Context, walletID, fromCurrency, toCurrency string, fromAmount, toAmount int64, rate string) (model.ConversionResult, error)
+	SaveRatesFunc func(ctx context.Context, rates []model.ExchangeRate) error
+	GetRateFunc   func(ctx context.Context, base, quote string, at time.Time) (model.ExchangeRate, error)
+	ListRatesFunc func(ctx context.Context, at time.Time) ([]model.ExchangeRate, error)
+
+	WithinTxFunc                     func(ctx context.Context, fn func(ctx context.Context) error) error
+	ReserveIdempotencyKeyFunc        func(ctx context.Context, key, requestHash string, expiredBefore time.Time) (*model.IdempotencyRecord, error)
+	SaveIdempotencyResponseFunc      func(ctx context.Context, key string, statusCode int, response []byte) error
+	DeleteExpiredIdempotencyKeysFunc func(ctx context.Context, expiredBefore time.Time) (int64, error)
+
+	WalletEventsSinceFunc func(ctx context.Context, walletID string, after int64, limit int) ([]model.WalletEvent, error)
+	LastEventSequenceFunc func(ctx context.Context, walletID string) (int64, error)
+
+	CreateImportFunc      func(ctx context.Context, format string, data []byte) (model.ImportJob, error)
+	GetImportFunc         func(ctx context.Context, id string) (model.ImportJob, error)
+	ImportDataFunc        func(ctx context.Context, id string) ([]byte, error)
+	UnfinishedImportsFunc func(ctx context.Context) ([]model.ImportJob, error)
+	AdvanceImportFunc     func(ctx context.Context, id string, line int, lineErr *model.ImportLineError) (bool, error)
+	SetImportStatusFunc   func(ctx context.Context, id, status, msg string) error
+	ImportErrorsFunc      func(ctx context.Context, id string) ([]model.ImportLineError, error)
+
+	CreateWebhookFunc         func(ctx context.Context, url, secret string, events []string) (model.Webhook, error)
+	GetWebhookFunc            func(ctx context.Context, id string) (model.Webhook, error)
+	DeactivateWebhookFunc     func(ctx context.Context, id string) error
+	WebhookDeliveriesFunc     func(ctx context.Context, status string, limit int) ([]model.WebhookDelivery, error)
+	ReplayWebhookDeliveryFunc func(ctx context.Context, id int64) (model.WebhookDelivery, error)
 }
 
-func (m *MockWalletRepository) UpdateBalance(ctx context.Context, walletID string, amount int64) error {
+func (m *MockWalletRepository) UpdateBalance(ctx context.Context, walletID, currency string, amount, ifVersion int64) error {
 	if m.UpdateBalanceFunc != nil {
-		return m.UpdateBalanceFunc(ctx, walletID, amount)
+		return m.UpdateBalanceFunc(ctx, walletID, currency, amount, ifVersion)
 	}
 	return nil
 }
 
-func (m *MockWalletRepository) GetBalance(ctx context.Context, walletID string) (int64, error) {
-	if m.GetBalanceFunc != nil {
-		return m.GetBalanceFunc(ctx, walletID)
+func (m *MockWalletRepository) GetWallet(ctx context.Context, walletID, currency string) (model.Wallet, error) {
+	if m.GetWalletFunc != nil {
+		return m.GetWalletFunc(ctx, walletID, currency)
+	}
+	return model.Wallet{}, nil
+}
+
+func (m *MockWalletRepository) ListWallets(ctx context.Context, walletID string) ([]model.Wallet, error) {
+	if m.ListWalletsFunc != nil {
+		return m.ListWalletsFunc(ctx, walletID)
+	}
+	return []model.Wallet{{Currency: "RUB"}}, nil
+}
+
+func (m *MockWalletRepository) ListTransactions(ctx context.Context, walletID string, filter model.TransactionFilter) ([]model.Transaction, error) {
+	if m.ListTransactionsFunc != nil {
+		return m.ListTransactionsFunc(ctx, walletID, filter)
+	}
+	return nil, nil
+}
+
+func (m *MockWalletRepository) Transfer(ctx context.Context, fromID, toID, currency string, amount int64) (model.TransferResult, error) {
+	if m.TransferFunc != nil {
+		return m.TransferFunc(ctx, fromID, toID, currency, amount)
+	}
+	return model.TransferResult{}, nil
+}
+
+func (m *MockWalletRepository) TrialBalance(ctx context.Context) ([]model.AccountBalance, error) {
+	if m.TrialBalanceFunc != nil {
+		return m.TrialBalanceFunc(ctx)
+	}
+	return nil, nil
+}
+
+func (m *MockWalletRepository) FreezeWallet(ctx context.Context, walletID, reason string) (model.WalletFreeze, error) {
+	if m.FreezeWalletFunc != nil {
+		return m.FreezeWalletFunc(ctx, walletID, reason)
+	}
+	return model.WalletFreeze{}, nil
+}
+
+func (m *MockWalletRepository) UnfreezeWallet(ctx context.Context, walletID string) error {
+	if m.UnfreezeWalletFunc != nil {
+		return m.UnfreezeWalletFunc(ctx, walletID)
+	}
+	return nil
+}
+
+func (m *MockWalletRepository) GetWalletFreeze(ctx context.Context, walletID string) (*model.WalletFreeze, error) {
+	if m.GetWalletFreezeFunc != nil {
+		return m.GetWalletFreezeFunc(ctx, walletID)
+	}
+	return nil, nil
+}
+
+func (m *MockWalletRepository) PlaceHold(ctx context.Context, walletID, currency string, amount int64, expiresAt time.Time) (model.Hold, error) {
+	if m.PlaceHoldFunc != nil {
+		return m.PlaceHoldFunc(ctx, walletID, currency, amount, expiresAt)
+	}
+	return model.Hold{}, nil
+}
+
+func (m *MockWalletRepository) CaptureHold(ctx context.Context, holdID string, amount int64, now time.Time) (model.Hold, error) {
+	if m.CaptureHoldFunc != nil {
+		return m.CaptureHoldFunc(ctx, holdID, amount, now)
+	}
+	return model.Hold{}, nil
+}
+
+func (m *MockWalletRepository) ReleaseHold(ctx context.Context, holdID string) (model.Hold, error) {
+	if m.ReleaseHoldFunc != nil {
+		return m.ReleaseHoldFunc(ctx, holdID)
+	}
+	return model.Hold{}, nil
+}
+
+func (m *MockWalletRepository) ExpireHolds(ctx context.Context, now time.Time, limit int) ([]model.Hold, error) {
+	if m.ExpireHoldsFunc != nil {
+		return m.ExpireHoldsFunc(ctx, now, limit)
+	}
+	return nil, nil
+}
+
+func (m *MockWalletRepository) GetHold(ctx context.Context, holdID string) (model.Hold, error) {
+	if m.GetHoldFunc != nil {
+		return m.GetHoldFunc(ctx, holdID)
+	}
+	return model.Hold{}, nil
+}
+
+func (m *MockWalletRepository) Convert(ctx context.Context, walletID, fromCurrency, toCurrency string, fromAmount, toAmount int64, rate string) (model.ConversionResult, error) {
+	if m.ConvertFunc != nil {
+		return m.ConvertFunc(ctx, walletID, fromCurrency, toCurrency, fromAmount, toAmount, rate)
+	}
+	return model.ConversionResult{}, nil
+}
+
+func (m *MockWalletRepository) SaveRates(ctx context.Context, rates []model.ExchangeRate) error {
+	if m.SaveRatesFunc != nil {
+		return m.SaveRatesFunc(ctx, rates)
+	}
+	return nil
+}
+
+func (m *MockWalletRepository) GetRate(ctx context.Context, base, quote string, at time.Time) (model.ExchangeRate, error) {
+	if m.GetRateFunc != nil {
+		return m.GetRateFunc(ctx, base, quote, at)
+	}
+	return model.ExchangeRate{}, appErr.ErrRateNotFound
+}
+
+func (m *MockWalletRepository) ListRates(ctx context.Context, at time.Time) ([]model.ExchangeRate, error) {
+	if m.ListRatesFunc != nil {
+		return m.ListRatesFunc(ctx, at)
+	}
+	return nil, nil
+}
+
+func (m *MockWalletRepository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if m.WithinTxFunc != nil {
+		return m.WithinTxFunc(ctx, fn)
+	}
+	return fn(ctx)
+}
+
+func (m *MockWalletRepository) ReserveIdempotencyKey(ctx context.Context, key, requestHash string, expiredBefore time.Time) (*model.IdempotencyRecord, error) {
+	if m.ReserveIdempotencyKeyFunc != nil {
+		return m.ReserveIdempotencyKeyFunc(ctx, key, requestHash, expiredBefore)
+	}
+	return nil, nil
+}
+
+func (m *MockWalletRepository) SaveIdempotencyResponse(ctx context.Context, key string, statusCode int, response []byte) error {
+	if m.SaveIdempotencyResponseFunc != nil {
+		return m.SaveIdempotencyResponseFunc(ctx, key, statusCode, response)
+	}
+	return nil
+}
+
+func (m *MockWalletRepository) DeleteExpiredIdempotencyKeys(ctx context.Context, expiredBefore time.Time) (int64, error) {
+	if m.DeleteExpiredIdempotencyKeysFunc != nil {
+		return m.DeleteExpiredIdempotencyKeysFunc(ctx, expiredBefore)
 	}
 	return 0, nil
 }
 
+func (m *MockWalletRepository) WalletEventsSince(ctx context.Context, walletID string, after int64, limit int) ([]model.WalletEvent, error) {
+	if m.WalletEventsSinceFunc != nil {
+		return m.WalletEventsSinceFunc(ctx, walletID, after, limit)
+	}
+	return nil, nil
+}
+
+func (m *MockWalletRepository) LastEventSequence(ctx context.Context, walletID string) (int64, error) {
+	if m.LastEventSequenceFunc != nil {
+		return m.LastEventSequenceFunc(ctx, walletID)
+	}
+	return 0, nil
+}
+
+func (m *MockWalletRepository) CreateImport(ctx context.Context, format string, data []byte) (model.ImportJob, error) {
+	if m.CreateImportFunc != nil {
+		return m.CreateImportFunc(ctx, format, data)
+	}
+	return model.ImportJob{Format: format, Status: model.ImportPending}, nil
+}
+
+func (m *MockWalletRepository) GetImport(ctx context.Context, id string) (model.ImportJob, error) {
+	if m.GetImportFunc != nil {
+		return m.GetImportFunc(ctx, id)
+	}
+	return model.ImportJob{}, nil
+}
+
+func (m *MockWalletRepository) ImportData(ctx context.Context, id string) ([]byte, error) {
+	if m.ImportDataFunc != nil {
+		return m.ImportDataFunc(ctx, id)
+	}
+	return nil, nil
+}
+
+func (m *MockWalletRepository) UnfinishedImports(ctx context.Context) ([]model.ImportJob, error) {
+	if m.UnfinishedImportsFunc != nil {
+		return m.UnfinishedImportsFunc(ctx)
+	}
+	return nil, nil
+}
+
+func (m *MockWalletRepository) AdvanceImport(ctx context.Context, id string, line int, lineErr *model.ImportLineError) (bool, error) {
+	if m.AdvanceImportFunc != nil {
+		return m.AdvanceImportFunc(ctx, id, line, lineErr)
+	}
+	return true, nil
+}
+
+func (m *MockWalletRepository) SetImportStatus(ctx context.Context, id, status, msg string) error {
+	if m.SetImportStatusFunc != nil {
+		return m.SetImportStatusFunc(ctx, id, status, msg)
+	}
+	return nil
+}
+
+func (m *MockWalletRepository) ImportErrors(ctx context.Context, id string) ([]model.ImportLineError, error) {
+	if m.ImportErrorsFunc != nil {
+		return m.ImportErrorsFunc(ctx, id)
+	}
+	return nil, nil
+}
+
+func (m *MockWalletRepository) CreateWebhook(ctx context.Context, url, secret string, events []string) (model.Webhook, error) {
+	if m.CreateWebhookFunc != nil {
+		return m.CreateWebhookFunc(ctx, url, secret, events)
+	}
+	return model.Webhook{URL: url, Secret: secret, Events: events, Active: true}, nil
+}
+
+func (m *MockWalletRepository) GetWebhook(ctx context.Context, id string) (model.Webhook, error) {
+	if m.GetWebhookFunc != nil {
+		return m.GetWebhookFunc(ctx, id)
+	}
+	return model.Webhook{}, nil
+}
+
+func (m *MockWalletRepository) DeactivateWebhook(ctx context.Context, id string) error {
+	if m.DeactivateWebhookFunc != nil {
+		return m.DeactivateWebhookFunc(ctx, id)
+	}
+	return nil
+}
+
+func (m *MockWalletRepository) WebhookDeliveries(ctx context.Context, status string, limit int) ([]model.WebhookDelivery, error) {
+	if m.WebhookDeliveriesFunc != nil {
+		return m.WebhookDeliveriesFunc(ctx, status, limit)
+	}
+	return nil, nil
+}
+
+func (m *MockWalletRepository) ReplayWebhookDelivery(ctx context.Context, id int64) (model.WebhookDelivery, error) {
+	if m.ReplayWebhookDeliveryFunc != nil {
+		return m.ReplayWebhookDeliveryFunc(ctx, id)
+	}
+	return model.WebhookDelivery{ID: id, Status: model.DeliveryPending}, nil
+}
+
 func TestProcess_Deposit(t *testing.T) {
 	mockRepo := &MockWalletRepository{
-		UpdateBalanceFunc: func(ctx context.Context, walletID string, amount int64) error {
+		UpdateBalanceFunc: func(ctx context.Context, walletID, currency string, amount, ifVersion int64) error {
 			if amount != 1000 {
 				t.Errorf("expected amount 1000, got %d", amount)
 			}
@@ -38,7 +320,7 @@ func TestProcess_Deposit(t *testing.T) {
 
 	service := New(mockRepo)
 
-	err := service.Process(context.Background(), "test-wallet", "DEPOSIT", 1000)
+	err := service.Process(context.Background(), "test-wallet", "", "DEPOSIT", 1000, 0)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
@@ -46,7 +328,7 @@ func TestProcess_Deposit(t *testing.T) {
 
 func TestProcess_Withdraw(t *testing.T) {
 	mockRepo := &MockWalletRepository{
-		UpdateBalanceFunc: func(ctx context.Context, walletID string, amount int64) error {
+		UpdateBalanceFunc: func(ctx context.Context, walletID, currency string, amount, ifVersion int64) error {
 			if amount != -500 {
 				t.Errorf("expected amount -500, got %d", amount)
 			}
@@ -56,12 +338,28 @@ func TestProcess_Withdraw(t *testing.T) {
 
 	service := New(mockRepo)
 
-	err := service.Process(context.Background(), "test-wallet", "WITHDRAW", 500)
+	err := service.Process(context.Background(), "test-wallet", "", "WITHDRAW", 500, 0)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
 }
 
+func TestProcess_IfVersion(t *testing.T) {
+	mockRepo := &MockWalletRepository{
+		UpdateBalanceFunc: func(ctx context.Context, walletID, currency string, amount, ifVersion int64) error {
+			if ifVersion != 4 {
+				t.Errorf("expected ifVersion 4, got %d", ifVersion)
+			}
+			return appErr.ErrVersionMismatch
+		},
+	}
+
+	err := New(mockRepo).Process(context.Background(), "test-wallet", "", "WITHDRAW", 500, 4)
+	if !errors.Is(err, appErr.ErrVersionMismatch) {
+		t.Errorf("expected ErrVersionMismatch, got %v", err)
+	}
+}
+
 func TestProcess_InvalidOperation(t *testing.T) {
 	service := New(&MockWalletRepository{})
 
@@ -77,7 +375,7 @@ func TestProcess_InvalidOperation(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := service.Process(context.Background(), "test-wallet", tt.op, tt.amount)
+			err := service.Process(context.Background(), "test-wallet", "", tt.op, tt.amount, 0)
 			if err != appErr.ErrInvalidOperation {
 				t.Errorf("expected ErrInvalidOperation, got %v", err)
 			}
@@ -85,56 +383,150 @@ func TestProcess_InvalidOperation(t *testing.T) {
 	}
 }
 
+func TestProcess_Currency(t *testing.T) {
+	tests := []struct {
+		name     string
+		currency string
+		want     string
+		err      error
+	}{
+		{"default", "", "EUR", nil},
+		{"explicit", "USD", "USD", nil},
+		{"lower case", "jpy", "JPY", nil},
+		{"unknown", "ABC", "", appErr.ErrInvalidCurrency},
+		{"not a code", "dollars", "", appErr.ErrInvalidCurrency},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			mockRepo := &MockWalletRepository{
+				UpdateBalanceFunc: func(ctx context.Context, walletID, currency string, amount, ifVersion int64) error {
+					got = currency
+					return nil
+				},
+			}
+
+			service := New(mockRepo, WithDefaultCurrency("EUR"))
+
+			err := service.Process(context.Background(), "test-wallet", tt.currency, "DEPOSIT", 100, 0)
+			if err != tt.err {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+			if got != tt.want {
+				t.Errorf("expected currency %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
 func TestProcess_RepositoryError(t *testing.T) {
 	mockRepo := &MockWalletRepository{
-		UpdateBalanceFunc: func(ctx context.Context, walletID string, amount int64) error {
+		UpdateBalanceFunc: func(ctx context.Context, walletID, currency string, amount, ifVersion int64) error {
 			return appErr.ErrInsufficientFunds
 		},
 	}
 
 	service := New(mockRepo)
 
-	err := service.Process(context.Background(), "test-wallet", "WITHDRAW", 1000)
+	err := service.Process(context.Background(), "test-wallet", "", "WITHDRAW", 1000, 0)
 	if err != appErr.ErrInsufficientFunds {
 		t.Errorf("expected ErrInsufficientFunds, got %v", err)
 	}
 }
 
+func TestTransfer_Success(t *testing.T) {
+	mockRepo := &MockWalletRepository{
+		TransferFunc: func(ctx context.Context, fromID, toID, currency string, amount int64) (model.TransferResult, error) {
+			if fromID != "wallet-a" || toID != "wallet-b" || amount != 300 {
+				t.Errorf("unexpected transfer %s -> %s (%d)", fromID, toID, amount)
+			}
+			return model.TransferResult{FromWalletID: fromID, ToWalletID: toID, FromBalance: 700, ToBalance: 300}, nil
+		},
+	}
+
+	service := New(mockRepo)
+
+	res, err := service.Transfer(context.Background(), "wallet-a", "wallet-b", "", 300)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if res.FromBalance != 700 || res.ToBalance != 300 {
+		t.Errorf("unexpected result: %+v", res)
+	}
+}
+
+func TestTransfer_InvalidInput(t *testing.T) {
+	mockRepo := &MockWalletRepository{
+		TransferFunc: func(ctx context.Context, fromID, toID, currency string, amount int64) (model.TransferResult, error) {
+			t.Error("repository must not be called")
+			return model.TransferResult{}, nil
+		},
+	}
+
+	service := New(mockRepo)
+
+	tests := []struct {
+		name   string
+		from   string
+		to     string
+		amount int64
+		err    error
+	}{
+		{"zero amount", "wallet-a", "wallet-b", 0, appErr.ErrInvalidOperation},
+		{"negative amount", "wallet-a", "wallet-b", -10, appErr.ErrInvalidOperation},
+		{"same wallet", "wallet-a", "wallet-a", 10, appErr.ErrSameWallet},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.Transfer(context.Background(), tt.from, tt.to, "", tt.amount)
+			if err != tt.err {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
 func TestBalance_Success(t *testing.T) {
 	mockRepo := &MockWalletRepository{
-		GetBalanceFunc: func(ctx context.Context, walletID string) (int64, error) {
-			return 5000, nil
+		GetWalletFunc: func(ctx context.Context, walletID, currency string) (model.Wallet, error) {
+			return model.Wallet{Balance: 5000, Held: 1200}, nil
 		},
 	}
 
 	service := New(mockRepo)
 
-	balance, err := service.Balance(context.Background(), "test-wallet")
+	wallet, err := service.Balance(context.Background(), "test-wallet", "")
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
 
-	if balance != 5000 {
-		t.Errorf("expected balance 5000, got %d", balance)
+	if wallet.Balance != 5000 {
+		t.Errorf("expected balance 5000, got %d", wallet.Balance)
+	}
+	if wallet.Available() != 3800 {
+		t.Errorf("expected available balance 3800, got %d", wallet.Available())
 	}
 }
 
 func TestBalance_WalletNotFound(t *testing.T) {
 	mockRepo := &MockWalletRepository{
-		GetBalanceFunc: func(ctx context.Context, walletID string) (int64, error) {
-			return 0, appErr.ErrWalletNotFound
+		GetWalletFunc: func(ctx context.Context, walletID, currency string) (model.Wallet, error) {
+			return model.Wallet{}, appErr.ErrWalletNotFound
 		},
 	}
 
 	service := New(mockRepo)
 
-	balance, err := service.Balance(context.Background(), "test-wallet")
+	wallet, err := service.Balance(context.Background(), "test-wallet", "")
 	if err != appErr.ErrWalletNotFound {
 		t.Errorf("expected ErrWalletNotFound, got %v", err)
 	}
 
-	if balance != 0 {
-		t.Errorf("expected balance 0, got %d", balance)
+	if wallet.Balance != 0 {
+		t.Errorf("expected balance 0, got %d", wallet.Balance)
 	}
 }
 
@@ -142,15 +534,407 @@ func TestBalance_RepositoryError(t *testing.T) {
 	expectedErr := appErr.ErrWalletNotFound
 
 	mockRepo := &MockWalletRepository{
-		GetBalanceFunc: func(ctx context.Context, walletID string) (int64, error) {
-			return 0, expectedErr
+		GetWalletFunc: func(ctx context.Context, walletID, currency string) (model.Wallet, error) {
+			return model.Wallet{}, expectedErr
 		},
 	}
 
 	service := New(mockRepo)
 
-	_, err := service.Balance(context.Background(), "test-wallet")
+	_, err := service.Balance(context.Background(), "test-wallet", "")
 	if err != expectedErr {
 		t.Errorf("expected error %v, got %v", expectedErr, err)
 	}
 }
+
+func TestHistory_Pagination(t *testing.T) {
+	mockRepo := &MockWalletRepository{
+		ListTransactionsFunc: func(ctx context.Context, walletID string, filter model.TransactionFilter) ([]model.Transaction, error) {
+			if filter.Limit != 3 {
+				t.Errorf("expected limit 3, got %d", filter.Limit)
+			}
+
+			var txs []model.Transaction
+			for id := int64(10); id > 0 && len(txs) < filter.Limit; id-- {
+				if filter.BeforeID > 0 && id >= filter.BeforeID {
+					continue
+				}
+				txs = append(txs, model.Transaction{ID: id})
+			}
+			return txs, nil
+		},
+	}
+
+	service := New(mockRepo)
+
+	txs, next, err := service.History(context.Background(), "test-wallet", model.TransactionFilter{Limit: 2}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(txs) != 2 || txs[0].ID != 10 || txs[1].ID != 9 {
+		t.Fatalf("unexpected first page: %+v", txs)
+	}
+	if next == "" {
+		t.Fatal("expected next cursor")
+	}
+
+	txs, _, err = service.History(context.Background(), "test-wallet", model.TransactionFilter{Limit: 2}, next)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(txs) != 2 || txs[0].ID != 8 {
+		t.Errorf("unexpected second page: %+v", txs)
+	}
+}
+
+func TestHistory_LastPage(t *testing.T) {
+	mockRepo := &MockWalletRepository{
+		ListTransactionsFunc: func(ctx context.Context, walletID string, filter model.TransactionFilter) ([]model.Transaction, error) {
+			return []model.Transaction{{ID: 2}, {ID: 1}}, nil
+		},
+	}
+
+	service := New(mockRepo)
+
+	txs, next, err := service.History(context.Background(), "test-wallet", model.TransactionFilter{}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(txs) != 2 {
+		t.Errorf("expected 2 transactions, got %d", len(txs))
+	}
+	if next != "" {
+		t.Errorf("expected empty cursor, got %s", next)
+	}
+}
+
+func TestHistory_PageSizeCap(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested int
+		expected  int
+	}{
+		{"default", 0, 10},
+		{"within cap", 25, 25},
+		{"above cap", 500, 50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockWalletRepository{
+				ListTransactionsFunc: func(ctx context.Context, walletID string, filter model.TransactionFilter) ([]model.Transaction, error) {
+					if filter.Limit != tt.expected+1 {
+						t.Errorf("expected limit %d, got %d", tt.expected+1, filter.Limit)
+					}
+					return nil, nil
+				},
+			}
+
+			service := New(mockRepo, WithPageSize(10, 50))
+
+			_, _, err := service.History(context.Background(), "test-wallet", model.TransactionFilter{Limit: tt.requested}, "")
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestHistory_NoPageSize(t *testing.T) {
+	mockRepo := &MockWalletRepository{
+		ListTransactionsFunc: func(ctx context.Context, walletID string, filter model.TransactionFilter) ([]model.Transaction, error) {
+			return []model.Transaction{{ID: 3}, {ID: 2}}, nil
+		},
+	}
+
+	service := New(mockRepo, WithPageSize(0, 0))
+
+	txs, next, err := service.History(context.Background(), "test-wallet", model.TransactionFilter{}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(txs) != 1 || next == "" {
+		t.Errorf("expected a page of one transaction and a cursor, got %+v, %q", txs, next)
+	}
+}
+
+func TestHistory_InvalidInput(t *testing.T) {
+	service := New(&MockWalletRepository{})
+
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		filter model.TransactionFilter
+		cursor string
+		err    error
+	}{
+		{"unknown type", model.TransactionFilter{Type: "REFUND"}, "", appErr.ErrInvalidOperation},
+		{"inverted range", model.TransactionFilter{From: now, To: now.Add(-time.Hour)}, "", appErr.ErrInvalidDateRange},
+		{"garbage cursor", model.TransactionFilter{}, "!!!", appErr.ErrInvalidCursor},
+		{"non-numeric cursor", model.TransactionFilter{}, "YWJj", appErr.ErrInvalidCursor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := service.History(context.Background(), "test-wallet", tt.filter, tt.cursor)
+			if err != tt.err {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestHistory_WalletNotFound(t *testing.T) {
+	mockRepo := &MockWalletRepository{
+		ListWalletsFunc: func(ctx context.Context, walletID string) ([]model.Wallet, error) {
+			return nil, nil
+		},
+		ListTransactionsFunc: func(ctx context.Context, walletID string, filter model.TransactionFilter) ([]model.Transaction, error) {
+			t.Error("ListTransactions should not be called")
+			return nil, nil
+		},
+	}
+
+	service := New(mockRepo)
+
+	_, _, err := service.History(context.Background(), "test-wallet", model.TransactionFilter{}, "")
+	if err != appErr.ErrWalletNotFound {
+		t.Errorf("expected ErrWalletNotFound, got %v", err)
+	}
+}
+
+func TestIdempotent_FirstRequest(t *testing.T) {
+	var saved bool
+
+	mockRepo := &MockWalletRepository{
+		ReserveIdempotencyKeyFunc: func(ctx context.Context, key, requestHash string, expiredBefore time.Time) (*model.IdempotencyRecord, error) {
+			if key != "key-1" || requestHash != "hash-1" {
+				t.Errorf("unexpected key %s / hash %s", key, requestHash)
+			}
+			if time.Since(expiredBefore) < time.Hour-time.Minute {
+				t.Errorf("expected expiry one hour back, got %v", expiredBefore)
+			}
+			return nil, nil
+		},
+		SaveIdempotencyResponseFunc: func(ctx context.Context, key string, statusCode int, response []byte) error {
+			saved = true
+			if statusCode != 200 || string(response) != "ok" {
+				t.Errorf("unexpected stored response %d %s", statusCode, response)
+			}
+			return nil
+		},
+	}
+
+	service := New(mockRepo, WithIdempotencyTTL(time.Hour))
+
+	calls := 0
+	status, body, replayed, err := service.Idempotent(context.Background(), "key-1", "hash-1", func(ctx context.Context) (int, []byte, error) {
+		calls++
+		return 200, []byte("ok"), nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("expected fn to be called once, got %d", calls)
+	}
+	if status != 200 || string(body) != "ok" || replayed {
+		t.Errorf("unexpected result %d %s replayed=%v", status, body, replayed)
+	}
+	if !saved {
+		t.Error("expected response to be saved")
+	}
+}
+
+func TestIdempotent_Replay(t *testing.T) {
+	mockRepo := &MockWalletRepository{
+		ReserveIdempotencyKeyFunc: func(ctx context.Context, key, requestHash string, expiredBefore time.Time) (*model.IdempotencyRecord, error) {
+			return &model.IdempotencyRecord{Key: key, RequestHash: "hash-1", StatusCode: 400, Response: []byte("insufficient funds")}, nil
+		},
+		SaveIdempotencyResponseFunc: func(ctx context.Context, key string, statusCode int, response []byte) error {
+			t.Error("replay must not store a new response")
+			return nil
+		},
+	}
+
+	service := New(mockRepo)
+
+	status, body, replayed, err := service.Idempotent(context.Background(), "key-1", "hash-1", func(ctx context.Context) (int, []byte, error) {
+		t.Error("replay must not execute the request")
+		return 0, nil, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if status != 400 || string(body) != "insufficient funds" || !replayed {
+		t.Errorf("unexpected result %d %s replayed=%v", status, body, replayed)
+	}
+}
+
+func TestIdempotent_Conflict(t *testing.T) {
+	tests := []struct {
+		name   string
+		record model.IdempotencyRecord
+	}{
+		{"different request", model.IdempotencyRecord{RequestHash: "other", StatusCode: 200}},
+		{"in flight", model.IdempotencyRecord{RequestHash: "hash-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockWalletRepository{
+				ReserveIdempotencyKeyFunc: func(ctx context.Context, key, requestHash string, expiredBefore time.Time) (*model.IdempotencyRecord, error) {
+					return &tt.record, nil
+				},
+			}
+
+			service := New(mockRepo)
+
+			_, _, _, err := service.Idempotent(context.Background(), "key-1", "hash-1", func(ctx context.Context) (int, []byte, error) {
+				t.Error("conflicting request must not execute")
+				return 0, nil, nil
+			})
+			if err != appErr.ErrIdempotencyConflict {
+				t.Errorf("expected ErrIdempotencyConflict, got %v", err)
+			}
+		})
+	}
+}
+
+func TestIdempotent_FuncError(t *testing.T) {
+	mockRepo := &MockWalletRepository{
+		SaveIdempotencyResponseFunc: func(ctx context.Context, key string, statusCode int, response []byte) error {
+			t.Error("failed request must not be stored")
+			return nil
+		},
+	}
+
+	service := New(mockRepo)
+
+	expectedErr := errors.New("db down")
+	_, _, _, err := service.Idempotent(context.Background(), "key-1", "hash-1", func(ctx context.Context) (int, []byte, error) {
+		return 0, nil, expectedErr
+	})
+	if err != expectedErr {
+		t.Errorf("expected %v, got %v", expectedErr, err)
+	}
+}
+
+func TestPurgeIdempotencyKeys(t *testing.T) {
+	mockRepo := &MockWalletRepository{
+		DeleteExpiredIdempotencyKeysFunc: func(ctx context.Context, expiredBefore time.Time) (int64, error) {
+			if time.Since(expiredBefore) < 2*time.Hour-time.Minute {
+				t.Errorf("expected expiry two hours back, got %v", expiredBefore)
+			}
+			return 3, nil
+		},
+	}
+
+	service := New(mockRepo, WithIdempotencyTTL(2*time.Hour))
+
+	n, err := service.PurgeIdempotencyKeys(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 purged keys, got %d", n)
+	}
+}
+
+func TestPlaceHold_TTL(t *testing.T) {
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		want time.Duration
+		err  error
+	}{
+		{"default", 0, 10 * time.Minute, nil},
+		{"explicit", time.Hour, time.Hour, nil},
+		{"negative", -time.Second, 0, appErr.ErrInvalidTTL},
+		{"above max", 48 * time.Hour, 0, appErr.ErrInvalidTTL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockWalletRepository{
+				PlaceHoldFunc: func(ctx context.Context, walletID, currency string, amount int64, expiresAt time.Time) (model.Hold, error) {
+					if d := time.Until(expiresAt); d > tt.want || d < tt.want-time.Minute {
+						t.Errorf("expected expiry in %v, got %v", tt.want, d)
+					}
+					return model.Hold{Amount: amount, Status: model.HoldActive, ExpiresAt: expiresAt}, nil
+				},
+			}
+
+			service := New(mockRepo, WithHoldTTL(10*time.Minute, 24*time.Hour))
+
+			_, err := service.PlaceHold(context.Background(), "test-wallet", "", 100, tt.ttl)
+			if err != tt.err {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestPlaceHold_InvalidAmount(t *testing.T) {
+	service := New(&MockWalletRepository{})
+
+	for _, amount := range []int64{0, -5} {
+		if _, err := service.PlaceHold(context.Background(), "test-wallet", "", amount, 0); err != appErr.ErrInvalidOperation {
+			t.Errorf("amount %d: expected ErrInvalidOperation, got %v", amount, err)
+		}
+	}
+}
+
+func TestCaptureHold(t *testing.T) {
+	mockRepo := &MockWalletRepository{
+		CaptureHoldFunc: func(ctx context.Context, holdID string, amount int64, now time.Time) (model.Hold, error) {
+			if holdID != "hold-1" || amount != 40 {
+				t.Errorf("unexpected capture %s %d", holdID, amount)
+			}
+			return model.Hold{Amount: 100, Captured: amount, Status: model.HoldCaptured}, nil
+		},
+	}
+
+	service := New(mockRepo)
+
+	hold, err := service.CaptureHold(context.Background(), "hold-1", 40)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hold.Status != model.HoldCaptured || hold.Captured != 40 {
+		t.Errorf("unexpected hold: %+v", hold)
+	}
+
+	if _, err := service.CaptureHold(context.Background(), "hold-1", -1); err != appErr.ErrInvalidOperation {
+		t.Errorf("expected ErrInvalidOperation, got %v", err)
+	}
+}
+
+func TestExpireHolds_DrainsBatches(t *testing.T) {
+	calls := 0
+	mockRepo := &MockWalletRepository{
+		ExpireHoldsFunc: func(ctx context.Context, now time.Time, limit int) ([]model.Hold, error) {
+			calls++
+			if calls == 1 {
+				return make([]model.Hold, limit), nil
+			}
+			return make([]model.Hold, 3), nil
+		},
+	}
+
+	service := New(mockRepo)
+
+	n, err := service.ExpireHolds(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 103 {
+		t.Errorf("expected 103 expired holds, got %d", n)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 batches, got %d", calls)
+	}
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"slices"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+	"github.com/Hlompy/Wallet/internal/webhook"
+)
+
+// CreateWebhook registers an endpoint for events, or for every event type
+// when none are given. The returned webhook carries the signing secret,
+// which is not shown again.
+func (s *WalletService) CreateWebhook(ctx context.Context, rawURL string, events []string) (model.Webhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return model.Webhook{}, appErr.ErrInvalidWebhook.WithDetails(map[string]any{"url": rawURL})
+	}
+	// Deliveries carry every wallet's events; they must not be aimed at
+	// the internal network. The dispatcher checks the address it connects
+	// to as well, since a name can resolve anywhere.
+	if !webhook.PublicHost(u.Hostname()) {
+		return model.Webhook{}, appErr.ErrInvalidWebhook.WithDetails(map[string]any{"url": rawURL})
+	}
+
+	if len(events) == 0 {
+		events = model.WebhookEvents
+	}
+
+	var types []string
+	for _, event := range events {
+		if !slices.Contains(model.WebhookEvents, event) {
+			return model.Webhook{}, appErr.ErrInvalidWebhook.WithDetails(map[string]any{"event": event})
+		}
+		if !slices.Contains(types, event) {
+			types = append(types, event)
+		}
+	}
+
+	secret, err := webhookSecret()
+	if err != nil {
+		return model.Webhook{}, err
+	}
+
+	return s.repo.CreateWebhook(ctx, u.String(), secret, types)
+}
+
+func (s *WalletService) Webhook(ctx context.Context, id string) (model.Webhook, error) {
+	return s.repo.GetWebhook(ctx, id)
+}
+
+// DeleteWebhook deactivates the webhook; its delivery history stays.
+func (s *WalletService) DeleteWebhook(ctx context.Context, id string) error {
+	return s.repo.DeactivateWebhook(ctx, id)
+}
+
+// WebhookDeliveries lists the newest deliveries in status, e.g. the dead
+// ones, or in any status when it is empty.
+func (s *WalletService) WebhookDeliveries(ctx context.Context, status string, limit int) ([]model.WebhookDelivery, error) {
+	if limit <= 0 {
+		limit = s.defaultPageSize
+	}
+	if limit > s.maxPageSize {
+		limit = s.maxPageSize
+	}
+
+	return s.repo.WebhookDeliveries(ctx, status, limit)
+}
+
+// ReplayWebhookDelivery sends a delivery again, with a fresh set of
+// attempts. It is how dead deliveries are brought back once the endpoint
+// works again.
+func (s *WalletService) ReplayWebhookDelivery(ctx context.Context, id int64) (model.WebhookDelivery, error) {
+	return s.repo.ReplayWebhookDelivery(ctx, id)
+}
+
+func webhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+func TestCreateWebhook(t *testing.T) {
+	svc := New(&MockWalletRepository{})
+
+	webhook, err := svc.CreateWebhook(context.Background(), "https://example.com/hooks", []string{
+		model.WebhookHoldExpired,
+		model.WebhookBalanceChanged,
+		model.WebhookHoldExpired,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := []string{model.WebhookHoldExpired, model.WebhookBalanceChanged}; !reflect.DeepEqual(webhook.Events, want) {
+		t.Errorf("expected events %v, got %v", want, webhook.Events)
+	}
+	if !strings.HasPrefix(webhook.Secret, "whsec_") || len(webhook.Secret) != len("whsec_")+64 {
+		t.Errorf("unexpected secret %q", webhook.Secret)
+	}
+
+	other, err := svc.CreateWebhook(context.Background(), "http://example.com", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(other.Events, model.WebhookEvents) {
+		t.Errorf("expected every event, got %v", other.Events)
+	}
+	if other.Secret == webhook.Secret {
+		t.Error("webhooks must not share a secret")
+	}
+}
+
+func TestCreateWebhook_Invalid(t *testing.T) {
+	svc := New(&MockWalletRepository{
+		CreateWebhookFunc: func(ctx context.Context, url, secret string, events []string) (model.Webhook, error) {
+			t.Fatal("invalid webhook must not be stored")
+			return model.Webhook{}, nil
+		},
+	})
+
+	tests := []struct {
+		name   string
+		url    string
+		events []string
+	}{
+		{"relative url", "/hooks", nil},
+		{"other scheme", "ftp://example.com/hooks", nil},
+		{"no host", "https://", nil},
+		{"loopback", "http://127.0.0.1:8080/hooks", nil},
+		{"localhost", "http://localhost/hooks", nil},
+		{"private network", "https://10.1.2.3/hooks", nil},
+		{"metadata service", "http://169.254.169.254/latest", nil},
+		{"single-label name", "http://wallet-db/hooks", nil},
+		{"unknown event", "https://example.com/hooks", []string{"wallet.deleted"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.CreateWebhook(context.Background(), tt.url, tt.events)
+			if !errors.Is(err, appErr.ErrInvalidWebhook) {
+				t.Errorf("expected ErrInvalidWebhook, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWebhookDeliveries_ClampsLimit(t *testing.T) {
+	var got []int
+	svc := New(&MockWalletRepository{
+		WebhookDeliveriesFunc: func(ctx context.Context, status string, limit int) ([]model.WebhookDelivery, error) {
+			got = append(got, limit)
+			return nil, nil
+		},
+	}, WithPageSize(20, 100))
+
+	for _, limit := range []int{0, 50, 500} {
+		if _, err := svc.WebhookDeliveries(context.Background(), model.DeliveryDead, limit); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if want := []int{20, 50, 100}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected limits %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,108 @@
+// Package stream tells open event streams when their wallet has new
+// events, from the notifications Postgres sends as those events commit.
+package stream
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Hub wakes the subscribers of a wallet. A wake-up carries no data:
+// subscribers read the events themselves, so a missed or merged wake-up
+// only delays them.
+type Hub struct {
+	mu   sync.Mutex
+	subs map[string]map[chan struct{}]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: make(map[string]map[chan struct{}]struct{})}
+}
+
+// Subscribe returns a channel that receives a value after events of the
+// wallet commit, and a function that ends the subscription.
+func (h *Hub) Subscribe(walletID string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	h.mu.Lock()
+	if h.subs[walletID] == nil {
+		h.subs[walletID] = make(map[chan struct{}]struct{})
+	}
+	h.subs[walletID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		delete(h.subs[walletID], ch)
+		if len(h.subs[walletID]) == 0 {
+			delete(h.subs, walletID)
+		}
+	}
+}
+
+// Publish wakes the subscribers of the wallet.
+func (h *Hub) Publish(walletID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subs[walletID] {
+		wake(ch)
+	}
+}
+
+// PublishAll wakes every subscriber, for when notifications may have been
+// lost.
+func (h *Hub) PublishAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, subs := range h.subs {
+		for ch := range subs {
+			wake(ch)
+		}
+	}
+}
+
+func wake(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// Listen feeds the hub from LISTEN on channel, over its own connection to
+// dsn, until ctx is done. Notifications carry the wallet id.
+func (h *Hub) Listen(ctx context.Context, dsn, channel string) error {
+	listener := pq.NewListener(dsn, 100*time.Millisecond, 10*time.Second, nil)
+	defer listener.Close()
+
+	if err := listener.Listen(channel); err != nil {
+		return err
+	}
+
+	ping := time.NewTicker(time.Minute)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-listener.Notify:
+			// A nil notification follows a reconnect, after which some
+			// may be missing.
+			if n == nil {
+				h.PublishAll()
+				continue
+			}
+			h.Publish(strings.TrimSpace(n.Extra))
+		case <-ping.C:
+			go listener.Ping()
+		}
+	}
+}
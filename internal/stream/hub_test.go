@@ -0,0 +1,62 @@
+package stream
+
+import "testing"
+
+const (
+	testWalletA = "11111111-1111-1111-1111-111111111111"
+	testWalletB = "22222222-2222-2222-2222-222222222222"
+)
+
+func woken(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestHub_PublishWakesWalletSubscribers(t *testing.T) {
+	hub := NewHub()
+
+	a1, cancelA1 := hub.Subscribe(testWalletA)
+	defer cancelA1()
+	a2, cancelA2 := hub.Subscribe(testWalletA)
+	defer cancelA2()
+	b, cancelB := hub.Subscribe(testWalletB)
+	defer cancelB()
+
+	// Wake-ups merge while the subscriber is busy.
+	hub.Publish(testWalletA)
+	hub.Publish(testWalletA)
+
+	if !woken(a1) || !woken(a2) {
+		t.Error("subscribers of the wallet must be woken")
+	}
+	if woken(a1) {
+		t.Error("wake-ups must merge")
+	}
+	if woken(b) {
+		t.Error("subscribers of other wallets must not be woken")
+	}
+
+	hub.PublishAll()
+	if !woken(a1) || !woken(a2) || !woken(b) {
+		t.Error("PublishAll must wake every subscriber")
+	}
+}
+
+func TestHub_Unsubscribe(t *testing.T) {
+	hub := NewHub()
+
+	ch, cancel := hub.Subscribe(testWalletA)
+	cancel()
+
+	hub.Publish(testWalletA)
+	if woken(ch) {
+		t.Error("an ended subscription must not be woken")
+	}
+	if len(hub.subs) != 0 {
+		t.Errorf("expected no subscriptions left, got %v", hub.subs)
+	}
+}
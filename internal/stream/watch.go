@@ -0,0 +1,137 @@
+package stream
+
+import (
+	"context"
+	"time"
+
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+// Source reads the state and the events of a wallet.
+type Source interface {
+	WalletSnapshot(ctx context.Context, walletID string) (int64, []model.Wallet, error)
+	WalletEvents(ctx context.Context, walletID string, after int64) ([]model.WalletEvent, error)
+}
+
+// Subscriber wakes watchers when their wallet has new events.
+type Subscriber interface {
+	Subscribe(walletID string) (<-chan struct{}, func())
+}
+
+// Update is either a snapshot of the wallet's balances or one of its
+// events. Sequence is the last event it reflects.
+type Update struct {
+	Sequence int64
+	Balances []model.Wallet
+	Event    *model.WalletEvent
+}
+
+// Sink receives the updates of a watched wallet.
+type Sink interface {
+	// Open is called once the wallet is found, before any update.
+	Open() error
+	Send(Update) error
+	// Idle is called on every interval of the watch.
+	Idle() error
+}
+
+// Watcher follows wallets for streaming APIs.
+type Watcher struct {
+	source   Source
+	events   Subscriber
+	interval time.Duration
+}
+
+// NewWatcher returns a watcher reading from source. Watches wake up on
+// events' notifications, if events is not nil, and every interval in case
+// a notification was lost.
+func NewWatcher(source Source, events Subscriber, interval time.Duration) *Watcher {
+	return &Watcher{source: source, events: events, interval: interval}
+}
+
+// Watch sends the wallet's updates to sink until ctx is done or the sink
+// fails. When resuming, it continues with the events after the given
+// sequence; otherwise, and when those events were already deleted, it
+// starts with a snapshot.
+//
+// The sink is opened only once the wallet is found, so an error returned
+// before that can still be reported as the answer to the request.
+func (w *Watcher) Watch(ctx context.Context, walletID string, after int64, resume bool, sink Sink) error {
+	// Subscribed before the first read, the watch cannot miss a commit
+	// between reading and waiting.
+	var wake <-chan struct{}
+	if w.events != nil {
+		ch, cancel := w.events.Subscribe(walletID)
+		defer cancel()
+		wake = ch
+	}
+
+	sequence, wallets, err := w.source.WalletSnapshot(ctx, walletID)
+	if err != nil {
+		return err
+	}
+
+	if err := sink.Open(); err != nil {
+		return err
+	}
+
+	// A sequence from the future (another database, a bad client) is
+	// treated like none.
+	if !resume || after > sequence {
+		if err := sink.Send(Update{Sequence: sequence, Balances: wallets}); err != nil {
+			return err
+		}
+		after = sequence
+	}
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		after, err = w.sendEvents(ctx, walletID, after, sink)
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-wake:
+		case <-ticker.C:
+			if err := sink.Idle(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// sendEvents sends the wallet's events after the given sequence and
+// returns the last sequence sent. When the events right after it were
+// already deleted, a fresh snapshot is sent instead.
+func (w *Watcher) sendEvents(ctx context.Context, walletID string, after int64, sink Sink) (int64, error) {
+	for {
+		events, err := w.source.WalletEvents(ctx, walletID, after)
+		if err != nil || len(events) == 0 {
+			return after, err
+		}
+
+		if events[0].Sequence > after+1 {
+			sequence, wallets, err := w.source.WalletSnapshot(ctx, walletID)
+			if err != nil {
+				return after, err
+			}
+			if err := sink.Send(Update{Sequence: sequence, Balances: wallets}); err != nil {
+				return after, err
+			}
+			after = sequence
+			continue
+		}
+
+		for i := range events {
+			if err := sink.Send(Update{Sequence: events[i].Sequence, Event: &events[i]}); err != nil {
+				return after, err
+			}
+			after = events[i].Sequence
+		}
+	}
+}
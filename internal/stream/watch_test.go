@@ -0,0 +1,81 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+type mockSource struct {
+	sequence int64
+	err      error
+	events   []model.WalletEvent
+	cancel   context.CancelFunc
+}
+
+func (m *mockSource) WalletSnapshot(ctx context.Context, walletID string) (int64, []model.Wallet, error) {
+	return m.sequence, []model.Wallet{{Currency: "USD"}}, m.err
+}
+
+// WalletEvents serves the events after the sequence, then ends the watch
+// once there are none.
+func (m *mockSource) WalletEvents(ctx context.Context, walletID string, after int64) ([]model.WalletEvent, error) {
+	var events []model.WalletEvent
+	for _, e := range m.events {
+		if e.Sequence > after {
+			events = append(events, e)
+		}
+	}
+	if len(events) == 0 {
+		m.cancel()
+	}
+	return events, nil
+}
+
+type recordingSink struct {
+	open    bool
+	updates []Update
+}
+
+func (s *recordingSink) Open() error {
+	s.open = true
+	return nil
+}
+
+func (s *recordingSink) Send(u Update) error {
+	s.updates = append(s.updates, u)
+	return nil
+}
+
+func (s *recordingSink) Idle() error {
+	return nil
+}
+
+func TestWatch_ResumeFromFutureSendsSnapshot(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	source := &mockSource{sequence: 5, events: []model.WalletEvent{{Sequence: 5}}, cancel: cancel}
+	sink := &recordingSink{}
+
+	if err := NewWatcher(source, nil, time.Hour).Watch(ctx, testWalletA, 99, true, sink); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(sink.updates) != 1 || sink.updates[0].Balances == nil || sink.updates[0].Sequence != 5 {
+		t.Errorf("expected a single snapshot at 5, got %+v", sink.updates)
+	}
+}
+
+func TestWatch_NotOpenedOnError(t *testing.T) {
+	source := &mockSource{err: errors.New("wallet not found")}
+	sink := &recordingSink{}
+
+	err := NewWatcher(source, nil, time.Hour).Watch(context.Background(), testWalletA, 0, false, sink)
+	if err == nil || sink.open {
+		t.Errorf("expected the error before opening, got %v (open %v)", err, sink.open)
+	}
+}
@@ -0,0 +1,67 @@
+package webhook
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), private in
+// all but name.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// PublicHost reports whether host, a name or an IP address, may be the
+// endpoint of a webhook: localhost and single-label names are refused, as
+// are loopback, private, link-local and other non-public addresses.
+func PublicHost(host string) bool {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return publicAddr(addr)
+	}
+
+	name := strings.TrimSuffix(strings.ToLower(host), ".")
+	if name == "localhost" || strings.HasSuffix(name, ".localhost") {
+		return false
+	}
+	return strings.Contains(name, ".")
+}
+
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
+
+// NewClient returns the client deliveries go through unless WithClient
+// says otherwise. Whatever an endpoint's name resolves to when it is
+// called, the client only connects to public addresses, and it does not
+// follow redirects, so an endpoint cannot point deliveries at the internal
+// network.
+func NewClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !publicAddr(addrPort.Addr()) {
+				return fmt.Errorf("%s is not a public address", addrPort.Addr())
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
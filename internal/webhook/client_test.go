@@ -0,0 +1,85 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+func TestPublicHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"hooks.example.com", true},
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"localhost", false},
+		{"api.localhost", false},
+		{"LOCALHOST.", false},
+		{"db", false},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.0.0.5", false},
+		{"172.16.3.4", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		if got := PublicHost(tt.host); got != tt.want {
+			t.Errorf("PublicHost(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestNewClient_RefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the request must not reach a loopback endpoint")
+	}))
+	defer server.Close()
+
+	store := &MockStore{}
+	store.ClaimWebhookDeliveriesFunc = deliveries(server.URL, model.WebhookDelivery{ID: 3, Secret: "whsec_a"})
+
+	if _, err := NewDispatcher(store).DispatchBatch(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if store.failed[3] != model.DeliveryPending {
+		t.Errorf("expected delivery 3 to fail, got delivered %v failed %v", store.delivered, store.failed)
+	}
+}
+
+func TestNewClient_DoesNotFollowRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hook" {
+			http.Redirect(w, r, "/internal", http.StatusFound)
+			return
+		}
+		t.Error("the redirect must not be followed")
+	}))
+	defer server.Close()
+
+	// The test server is on loopback, so keep the redirect policy but
+	// not the address check.
+	client := NewClient()
+	client.Transport = server.Client().Transport
+
+	resp, err := client.Post(server.URL+"/hook", "application/json", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		t.Errorf("expected the redirect itself, got %s", resp.Status)
+	}
+}
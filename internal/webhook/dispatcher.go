@@ -0,0 +1,182 @@
+// Package webhook delivers wallet events to the HTTP endpoints integrators
+// register, signed with each endpoint's secret.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+type Store interface {
+	ClaimWebhookDeliveries(ctx context.Context, now, until time.Time, limit int) ([]model.WebhookDelivery, error)
+	MarkWebhookDelivered(ctx context.Context, id int64) error
+	FailWebhookDelivery(ctx context.Context, id int64, status string, at time.Time, msg string) error
+}
+
+type Dispatcher struct {
+	store  Store
+	client *http.Client
+
+	batchSize     int
+	timeout       time.Duration
+	maxAttempts   int
+	retryDelay    time.Duration
+	maxRetryDelay time.Duration
+}
+
+type Option func(*Dispatcher)
+
+func WithClient(client *http.Client) Option {
+	return func(d *Dispatcher) {
+		d.client = client
+	}
+}
+
+func WithBatchSize(n int) Option {
+	return func(d *Dispatcher) {
+		d.batchSize = n
+	}
+}
+
+// WithTimeout bounds a single request to an endpoint.
+func WithTimeout(timeout time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.timeout = timeout
+	}
+}
+
+// WithMaxAttempts sets after how many failed attempts a delivery is dead.
+func WithMaxAttempts(n int) Option {
+	return func(d *Dispatcher) {
+		d.maxAttempts = n
+	}
+}
+
+// WithRetryDelay sets the wait before retrying a failed delivery; it
+// doubles with every failure up to max.
+func WithRetryDelay(base, max time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.retryDelay = base
+		d.maxRetryDelay = max
+	}
+}
+
+func NewDispatcher(store Store, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		store:         store,
+		client:        NewClient(),
+		batchSize:     20,
+		timeout:       10 * time.Second,
+		maxAttempts:   8,
+		retryDelay:    10 * time.Second,
+		maxRetryDelay: time.Hour,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// DispatchBatch sends up to one batch of due deliveries, concurrently, and
+// returns how many it sent. A delivery counts as delivered on any 2xx
+// response. Otherwise it is retried with backoff until maxAttempts
+// failures, after which it is dead and waits for a replay.
+func (d *Dispatcher) DispatchBatch(ctx context.Context) (int, error) {
+	now := time.Now()
+
+	// The claim outlives the requests, so a dispatcher that dies midway
+	// only delays its deliveries.
+	deliveries, err := d.store.ClaimWebhookDeliveries(ctx, now, now.Add(2*d.timeout), d.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	errs := make([]error, len(deliveries))
+	var wg sync.WaitGroup
+	for i, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = d.dispatch(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(deliveries), nil
+}
+
+// Drain sends batches until no delivery is due.
+func (d *Dispatcher) Drain(ctx context.Context) error {
+	for {
+		n, err := d.DispatchBatch(ctx)
+		if err != nil || n < d.batchSize {
+			return err
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, delivery model.WebhookDelivery) error {
+	sendErr := d.send(ctx, delivery)
+	if sendErr == nil {
+		return d.store.MarkWebhookDelivered(ctx, delivery.ID)
+	}
+
+	status := model.DeliveryPending
+	if delivery.Attempts+1 >= d.maxAttempts {
+		status = model.DeliveryDead
+	}
+
+	return d.store.FailWebhookDelivery(ctx, delivery.ID, status, time.Now().Add(d.backoff(delivery.Attempts)), sendErr.Error())
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery model.WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+
+	return nil
+}
+
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.retryDelay
+	for i := 0; i < attempts && delay < d.maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, d.maxRetryDelay)
+}
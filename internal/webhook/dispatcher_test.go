@@ -0,0 +1,155 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Hlompy/Wallet/internal/model"
+)
+
+type MockStore struct {
+	ClaimWebhookDeliveriesFunc func(ctx context.Context, now, until time.Time, limit int) ([]model.WebhookDelivery, error)
+
+	mu        sync.Mutex
+	delivered []int64
+	failed    map[int64]string
+	retryAt   map[int64]time.Time
+}
+
+func (m *MockStore) ClaimWebhookDeliveries(ctx context.Context, now, until time.Time, limit int) ([]model.WebhookDelivery, error) {
+	if m.ClaimWebhookDeliveriesFunc != nil {
+		return m.ClaimWebhookDeliveriesFunc(ctx, now, until, limit)
+	}
+	return nil, nil
+}
+
+func (m *MockStore) MarkWebhookDelivered(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.delivered = append(m.delivered, id)
+	return nil
+}
+
+func (m *MockStore) FailWebhookDelivery(ctx context.Context, id int64, status string, at time.Time, msg string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.failed == nil {
+		m.failed = map[int64]string{}
+		m.retryAt = map[int64]time.Time{}
+	}
+	m.failed[id] = status
+	m.retryAt[id] = at
+	return nil
+}
+
+func deliveries(url string, list ...model.WebhookDelivery) func(ctx context.Context, now, until time.Time, limit int) ([]model.WebhookDelivery, error) {
+	return func(ctx context.Context, now, until time.Time, limit int) ([]model.WebhookDelivery, error) {
+		for i := range list {
+			list[i].URL = url
+		}
+		return list, nil
+	}
+}
+
+func TestDispatchBatch_SignsRequests(t *testing.T) {
+	payload := []byte(`{"eventId":7,"type":"balance.changed"}`)
+
+	var got http.Header
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		gotBody, _ = io.ReadAll(r.Body)
+		if err := Verify("whsec_a", r.Header, gotBody, time.Now(), time.Minute); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	store := &MockStore{}
+	store.ClaimWebhookDeliveriesFunc = deliveries(server.URL, model.WebhookDelivery{
+		ID:        7,
+		EventType: model.WebhookBalanceChanged,
+		Payload:   payload,
+		Secret:    "whsec_a",
+	})
+
+	n, err := NewDispatcher(store, WithClient(server.Client())).DispatchBatch(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if n != 1 || len(store.delivered) != 1 || store.delivered[0] != 7 {
+		t.Errorf("expected delivery 7 delivered, got %d %v (failed %v)", n, store.delivered, store.failed)
+	}
+	if string(gotBody) != string(payload) {
+		t.Errorf("unexpected body %s", gotBody)
+	}
+	if got.Get(HeaderEvent) != model.WebhookBalanceChanged || got.Get(HeaderDelivery) != "7" {
+		t.Errorf("unexpected headers %v", got)
+	}
+}
+
+func TestDispatchBatch_RetriesThenDeadLetters(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	store := &MockStore{}
+	store.ClaimWebhookDeliveriesFunc = deliveries(server.URL,
+		model.WebhookDelivery{ID: 1, Attempts: 0},
+		model.WebhookDelivery{ID: 2, Attempts: 2},
+		model.WebhookDelivery{ID: 3, Attempts: 4},
+	)
+
+	dispatcher := NewDispatcher(store, WithClient(server.Client()), WithMaxAttempts(5), WithRetryDelay(time.Minute, 3*time.Minute))
+
+	start := time.Now()
+	if _, err := dispatcher.DispatchBatch(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(store.delivered) != 0 {
+		t.Errorf("nothing should be delivered, got %v", store.delivered)
+	}
+
+	want := map[int64]string{1: model.DeliveryPending, 2: model.DeliveryPending, 3: model.DeliveryDead}
+	for id, status := range want {
+		if store.failed[id] != status {
+			t.Errorf("delivery %d: expected %s, got %q", id, status, store.failed[id])
+		}
+	}
+
+	// Backoff doubles from the base delay up to the cap.
+	if d := store.retryAt[1].Sub(start); d < time.Minute || d > time.Minute+time.Second {
+		t.Errorf("first retry after %v, want 1m", d)
+	}
+	if d := store.retryAt[2].Sub(start); d < 3*time.Minute || d > 3*time.Minute+time.Second {
+		t.Errorf("third retry after %v, want the 3m cap", d)
+	}
+}
+
+func TestDispatchBatch_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	store := &MockStore{}
+	store.ClaimWebhookDeliveriesFunc = deliveries(server.URL, model.WebhookDelivery{ID: 1})
+
+	if _, err := NewDispatcher(store, WithClient(server.Client()), WithTimeout(50*time.Millisecond)).DispatchBatch(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if store.failed[1] != model.DeliveryPending {
+		t.Errorf("a timed out delivery must be retried, got %q", store.failed[1])
+	}
+}
@@ -0,0 +1,53 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	// echo -n '1700000000.{"eventId":1}' | openssl dgst -sha256 -hmac whsec_test
+	want := "v1=25893d5844ab45e4f0ed3af0c9bbb7108c27bf44455bf3a4396ddac56a90de99"
+	if got := Sign("whsec_test", 1700000000, []byte(`{"eventId":1}`)); got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"eventId":1}`)
+
+	header := func(secret string, timestamp int64) http.Header {
+		h := http.Header{}
+		h.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+		h.Set(HeaderSignature, Sign(secret, timestamp, body))
+		return h
+	}
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   error
+	}{
+		{"valid", header("whsec_a", now.Unix()-10), nil},
+		{"other secret", header("whsec_b", now.Unix()), ErrInvalidSignature},
+		{"stale", header("whsec_a", now.Unix()-600), ErrStaleTimestamp},
+		{"no headers", http.Header{}, ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify("whsec_a", tt.header, body, now, 5*time.Minute); !errors.Is(err, tt.want) {
+				t.Errorf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// A signature does not carry over to another body.
+	if err := Verify("whsec_a", header("whsec_a", now.Unix()), []byte(`{"eventId":2}`), now, time.Minute); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for a tampered body, got %v", err)
+	}
+}
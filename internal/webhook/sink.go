@@ -0,0 +1,94 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/Hlompy/Wallet/internal/model"
+	"github.com/Hlompy/Wallet/internal/outbox"
+)
+
+// Payload is the JSON body of a webhook request. EventID and Type
+// together identify it: a request may be repeated, and receivers drop
+// the ones they have seen.
+type Payload struct {
+	EventID   int64     `json:"eventId"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"createdAt"`
+	Data      any       `json:"data"`
+}
+
+type transferCompleted struct {
+	FromWalletID string `json:"fromWalletId"`
+	ToWalletID   string `json:"toWalletId"`
+	Currency     string `json:"currency"`
+	Amount       int64  `json:"amount"`
+}
+
+type holdExpired struct {
+	HoldID   string `json:"holdId"`
+	WalletID string `json:"walletId"`
+	Currency string `json:"currency"`
+	Amount   int64  `json:"amount"`
+}
+
+type Queue interface {
+	EnqueueWebhookDeliveries(ctx context.Context, eventType string, payload []byte) (int64, error)
+}
+
+// Sink is the outbox sink that turns wallet events into webhook
+// deliveries. Run by the relay, it queues them in the relay's
+// transaction, so an event is queued exactly when it is marked delivered.
+type Sink struct {
+	queue Queue
+}
+
+func NewSink(queue Queue) *Sink {
+	return &Sink{queue: queue}
+}
+
+func (s *Sink) Deliver(ctx context.Context, event model.WalletEvent) error {
+	for _, p := range payloads(event) {
+		body, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+
+		if _, err := s.queue.EnqueueWebhookDeliveries(ctx, p.Type, body); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// payloads maps a wallet event to webhook events. Every ledger row is a
+// balance change; the debit of a transfer also completes the transfer.
+func payloads(event model.WalletEvent) []Payload {
+	payload := func(eventType string, data any) Payload {
+		return Payload{EventID: event.ID, Type: eventType, CreatedAt: event.CreatedAt, Data: data}
+	}
+
+	if event.Type == model.EventHoldExpired {
+		return []Payload{payload(model.WebhookHoldExpired, holdExpired{
+			HoldID:   event.Reference,
+			WalletID: event.WalletID.String(),
+			Currency: event.Currency,
+			Amount:   event.Amount,
+		})}
+	}
+
+	p := []Payload{payload(model.WebhookBalanceChanged, outbox.NewMessage(event))}
+
+	if event.Type == model.OpTransferOut {
+		p = append(p, payload(model.WebhookTransferCompleted, transferCompleted{
+			FromWalletID: event.WalletID.String(),
+			ToWalletID:   event.Reference,
+			Currency:     event.Currency,
+			Amount:       -event.Amount,
+		}))
+	}
+
+	return p
+}
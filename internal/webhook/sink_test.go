@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Hlompy/Wallet/internal/model"
+
+	"github.com/google/uuid"
+)
+
+type queueFunc func(ctx context.Context, eventType string, payload []byte) (int64, error)
+
+func (f queueFunc) EnqueueWebhookDeliveries(ctx context.Context, eventType string, payload []byte) (int64, error) {
+	return f(ctx, eventType, payload)
+}
+
+var (
+	testWalletA = uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	testWalletB = uuid.MustParse("22222222-2222-2222-2222-222222222222")
+)
+
+func TestSink_MapsEvents(t *testing.T) {
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		event model.WalletEvent
+		want  map[string]string
+	}{
+		{
+			"deposit",
+			model.WalletEvent{ID: 1, WalletID: testWalletA, Sequence: 1, Currency: "USD", Type: model.OpDeposit, Amount: 500, BalanceAfter: 500, CreatedAt: at},
+			map[string]string{
+				model.WebhookBalanceChanged: `{"eventId":1,"type":"balance.changed","createdAt":"2024-01-01T00:00:00Z","data":{"eventId":1,"walletId":"11111111-1111-1111-1111-111111111111","sequence":1,"type":"DEPOSIT","currency":"USD","amount":500,"balanceAfter":500,"createdAt":"2024-01-01T00:00:00Z"}}`,
+			},
+		},
+		{
+			"transfer debit",
+			model.WalletEvent{ID: 2, WalletID: testWalletA, Sequence: 2, Currency: "USD", Type: model.OpTransferOut, Amount: -200, BalanceAfter: 300, Reference: testWalletB.String(), CreatedAt: at},
+			map[string]string{
+				model.WebhookBalanceChanged:    `{"eventId":2,"type":"balance.changed","createdAt":"2024-01-01T00:00:00Z","data":{"eventId":2,"walletId":"11111111-1111-1111-1111-111111111111","sequence":2,"type":"TRANSFER_OUT","currency":"USD","amount":-200,"balanceAfter":300,"reference":"22222222-2222-2222-2222-222222222222","createdAt":"2024-01-01T00:00:00Z"}}`,
+				model.WebhookTransferCompleted: `{"eventId":2,"type":"transfer.completed","createdAt":"2024-01-01T00:00:00Z","data":{"fromWalletId":"11111111-1111-1111-1111-111111111111","toWalletId":"22222222-2222-2222-2222-222222222222","currency":"USD","amount":200}}`,
+			},
+		},
+		{
+			"hold expiry",
+			model.WalletEvent{ID: 3, WalletID: testWalletA, Sequence: 3, Currency: "USD", Type: model.EventHoldExpired, Amount: 100, BalanceAfter: 300, Reference: "9b2f6a3c-1d4e-4f5a-8b6c-7d8e9f0a1b2c", CreatedAt: at},
+			map[string]string{
+				model.WebhookHoldExpired: `{"eventId":3,"type":"hold.expired","createdAt":"2024-01-01T00:00:00Z","data":{"holdId":"9b2f6a3c-1d4e-4f5a-8b6c-7d8e9f0a1b2c","walletId":"11111111-1111-1111-1111-111111111111","currency":"USD","amount":100}}`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := map[string]string{}
+			sink := NewSink(queueFunc(func(ctx context.Context, eventType string, payload []byte) (int64, error) {
+				if !json.Valid(payload) {
+					t.Fatalf("invalid payload %s", payload)
+				}
+				got[eventType] = string(payload)
+				return 1, nil
+			}))
+
+			if err := sink.Deliver(context.Background(), tt.event); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d payloads, got %v", len(tt.want), got)
+			}
+			for eventType, want := range tt.want {
+				if got[eventType] != want {
+					t.Errorf("%s payload:\n got %s\nwant %s", eventType, got[eventType], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS transactions (
+    id BIGSERIAL PRIMARY KEY,
+    wallet_id UUID NOT NULL,
+    type VARCHAR(32) NOT NULL,
+    amount BIGINT NOT NULL,
+    balance_after BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_transactions_wallet_id ON transactions(wallet_id, id DESC);
+
+CREATE OR REPLACE FUNCTION transactions_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'transactions ledger is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS transactions_immutable ON transactions;
+CREATE TRIGGER transactions_immutable
+    BEFORE UPDATE OR DELETE ON transactions
+    FOR EACH ROW EXECUTE FUNCTION transactions_immutable();
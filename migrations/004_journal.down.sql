@@ -0,0 +1,9 @@
+ALTER TABLE transactions DROP COLUMN IF EXISTS journal_id;
+
+DROP TRIGGER IF EXISTS journal_entries_immutable ON journal_entries;
+DROP TRIGGER IF EXISTS journal_entries_balanced ON journal_entries;
+DROP FUNCTION IF EXISTS journal_entries_immutable();
+DROP FUNCTION IF EXISTS journal_entries_balanced();
+
+DROP TABLE IF EXISTS journal_entries;
+DROP TABLE IF EXISTS journals;
@@ -0,0 +1,66 @@
+CREATE TABLE IF NOT EXISTS journals (
+    id BIGSERIAL PRIMARY KEY,
+    type VARCHAR(32) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS journal_entries (
+    id BIGSERIAL PRIMARY KEY,
+    journal_id BIGINT NOT NULL REFERENCES journals(id),
+    account VARCHAR(64) NOT NULL,
+    amount BIGINT NOT NULL CHECK (amount <> 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_journal_entries_journal_id ON journal_entries(journal_id);
+CREATE INDEX IF NOT EXISTS idx_journal_entries_account ON journal_entries(account);
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS journal_id BIGINT REFERENCES journals(id);
+
+-- Debits are positive, credits negative: every journal must net to zero.
+-- The check is deferred to commit so entries can be inserted one by one.
+CREATE OR REPLACE FUNCTION journal_entries_balanced() RETURNS trigger AS $$
+BEGIN
+    IF (SELECT SUM(amount) FROM journal_entries WHERE journal_id = NEW.journal_id) <> 0 THEN
+        RAISE EXCEPTION 'journal % does not balance', NEW.journal_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS journal_entries_balanced ON journal_entries;
+CREATE CONSTRAINT TRIGGER journal_entries_balanced
+    AFTER INSERT ON journal_entries
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION journal_entries_balanced();
+
+CREATE OR REPLACE FUNCTION journal_entries_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'journal entries are append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS journal_entries_immutable ON journal_entries;
+CREATE TRIGGER journal_entries_immutable
+    BEFORE UPDATE OR DELETE ON journal_entries
+    FOR EACH ROW EXECUTE FUNCTION journal_entries_immutable();
+
+-- Wallets funded before the journal existed get an opening balance entry
+-- so the trial balance covers them as well.
+DO $$
+DECLARE
+    w RECORD;
+    j BIGINT;
+BEGIN
+    FOR w IN
+        SELECT id, balance FROM wallets
+        WHERE balance <> 0
+          AND NOT EXISTS (
+              SELECT 1 FROM journal_entries e WHERE e.account = 'wallet:' || wallets.id::text
+          )
+    LOOP
+        INSERT INTO journals (type) VALUES ('OPENING_BALANCE') RETURNING id INTO j;
+        INSERT INTO journal_entries (journal_id, account, amount) VALUES
+            (j, 'system:opening_balance', w.balance),
+            (j, 'wallet:' || w.id::text, -w.balance);
+    END LOOP;
+END $$;
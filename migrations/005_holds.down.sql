@@ -0,0 +1,5 @@
+-- Active holds are dropped with the table: their funds become available.
+DROP TABLE IF EXISTS holds;
+
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_held_check;
+ALTER TABLE wallets DROP COLUMN IF EXISTS held;
@@ -0,0 +1,22 @@
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS held BIGINT NOT NULL DEFAULT 0;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'wallets_held_check') THEN
+        ALTER TABLE wallets ADD CONSTRAINT wallets_held_check CHECK (held >= 0 AND held <= balance);
+    END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS holds (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    wallet_id UUID NOT NULL,
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    captured BIGINT NOT NULL DEFAULT 0,
+    status VARCHAR(16) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_holds_wallet_id ON holds(wallet_id);
+CREATE INDEX IF NOT EXISTS idx_holds_active_expires_at ON holds(expires_at) WHERE status = 'ACTIVE';
@@ -0,0 +1,30 @@
+-- Without currencies every amount is roubles, so other currencies would
+-- silently turn into roubles. Refuse rather than do that.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM wallets WHERE currency <> 'RUB')
+        OR EXISTS (SELECT 1 FROM transactions WHERE currency <> 'RUB')
+        OR EXISTS (SELECT 1 FROM holds WHERE currency <> 'RUB')
+        OR EXISTS (SELECT 1 FROM journal_entries WHERE currency <> 'RUB') THEN
+        RAISE EXCEPTION 'cannot revert currencies: there are amounts in currencies other than RUB';
+    END IF;
+END $$;
+
+CREATE OR REPLACE FUNCTION journal_entries_balanced() RETURNS trigger AS $$
+BEGIN
+    IF (SELECT SUM(amount) FROM journal_entries WHERE journal_id = NEW.journal_id) <> 0 THEN
+        RAISE EXCEPTION 'journal % does not balance', NEW.journal_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS idx_journal_entries_account_currency;
+
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_pkey;
+ALTER TABLE wallets ADD CONSTRAINT wallets_pkey PRIMARY KEY (id);
+
+ALTER TABLE wallets DROP COLUMN IF EXISTS currency;
+ALTER TABLE transactions DROP COLUMN IF EXISTS currency;
+ALTER TABLE holds DROP COLUMN IF EXISTS currency;
+ALTER TABLE journal_entries DROP COLUMN IF EXISTS currency;
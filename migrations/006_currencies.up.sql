@@ -0,0 +1,37 @@
+-- Existing balances predate currencies and are assumed to be roubles.
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'RUB';
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'RUB';
+ALTER TABLE holds ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'RUB';
+ALTER TABLE journal_entries ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'RUB';
+
+ALTER TABLE wallets ALTER COLUMN currency DROP DEFAULT;
+ALTER TABLE transactions ALTER COLUMN currency DROP DEFAULT;
+ALTER TABLE holds ALTER COLUMN currency DROP DEFAULT;
+ALTER TABLE journal_entries ALTER COLUMN currency DROP DEFAULT;
+
+-- A wallet id holds one balance per currency.
+DO $$
+BEGIN
+    IF (SELECT array_length(conkey, 1) FROM pg_constraint WHERE conname = 'wallets_pkey') = 1 THEN
+        ALTER TABLE wallets DROP CONSTRAINT wallets_pkey;
+        ALTER TABLE wallets ADD CONSTRAINT wallets_pkey PRIMARY KEY (id, currency);
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_journal_entries_account_currency ON journal_entries(account, currency);
+
+-- Amounts in different currencies cannot offset each other, so a journal
+-- has to balance within every currency it touches.
+CREATE OR REPLACE FUNCTION journal_entries_balanced() RETURNS trigger AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM journal_entries
+        WHERE journal_id = NEW.journal_id
+        GROUP BY currency
+        HAVING SUM(amount) <> 0
+    ) THEN
+        RAISE EXCEPTION 'journal % does not balance', NEW.journal_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
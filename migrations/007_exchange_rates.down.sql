@@ -0,0 +1,3 @@
+ALTER TABLE transactions DROP COLUMN IF EXISTS rate;
+
+DROP TABLE IF EXISTS exchange_rates;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    base CHAR(3) NOT NULL,
+    quote CHAR(3) NOT NULL,
+    rate NUMERIC NOT NULL CHECK (rate > 0),
+    effective_from TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (base, quote, effective_from)
+);
+
+-- The rate a CONVERT was executed at, kept on both of its ledger legs.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS rate NUMERIC;
@@ -0,0 +1,18 @@
+-- Every change to a balance row bumps its version, whichever code path
+-- made it. Clients use it for If-Match, the compare-and-set update path
+-- for detecting concurrent writers.
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE FUNCTION wallets_bump_version() RETURNS trigger AS $$
+BEGIN
+    NEW.version := OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS wallets_bump_version ON wallets;
+CREATE TRIGGER wallets_bump_version
+    BEFORE UPDATE ON wallets
+    FOR EACH ROW
+    WHEN (NEW.balance IS DISTINCT FROM OLD.balance OR NEW.held IS DISTINCT FROM OLD.held)
+    EXECUTE FUNCTION wallets_bump_version();
@@ -0,0 +1,18 @@
+-- Shards hold part of the balance; dropping them would lose money. Fold
+-- them first by starting the app with HOT_WALLETS empty.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM wallet_shards WHERE balance <> 0) THEN
+        RAISE EXCEPTION 'cannot drop wallet_shards: shards still hold balances';
+    END IF;
+END $$;
+
+DROP TRIGGER IF EXISTS wallet_shards_bump_version ON wallet_shards;
+DROP TABLE IF EXISTS wallet_shards;
+
+CREATE OR REPLACE FUNCTION wallets_bump_version() RETURNS trigger AS $$
+BEGIN
+    NEW.version := OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
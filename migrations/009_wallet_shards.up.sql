@@ -0,0 +1,28 @@
+-- Hot wallets keep part of their balance in shard rows so concurrent
+-- deposits do not queue on the wallet row. The wallet's balance is the
+-- wallet row plus all of its shards; held funds always stay on the
+-- wallet row.
+CREATE TABLE IF NOT EXISTS wallet_shards (
+    wallet_id UUID NOT NULL,
+    currency CHAR(3) NOT NULL,
+    shard INT NOT NULL,
+    balance BIGINT NOT NULL CHECK (balance >= 0),
+    version BIGINT NOT NULL DEFAULT 1,
+    PRIMARY KEY (wallet_id, currency, shard)
+);
+
+-- Folding shards back into the wallet row carries their versions over,
+-- so an explicitly raised version is kept.
+CREATE OR REPLACE FUNCTION wallets_bump_version() RETURNS trigger AS $$
+BEGIN
+    NEW.version := GREATEST(NEW.version, OLD.version + 1);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS wallet_shards_bump_version ON wallet_shards;
+CREATE TRIGGER wallet_shards_bump_version
+    BEFORE UPDATE ON wallet_shards
+    FOR EACH ROW
+    WHEN (NEW.balance IS DISTINCT FROM OLD.balance)
+    EXECUTE FUNCTION wallets_bump_version();
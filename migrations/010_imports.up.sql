@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS import_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    format VARCHAR(8) NOT NULL,
+    data BYTEA NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    last_line INT NOT NULL DEFAULT 0,
+    succeeded INT NOT NULL DEFAULT 0,
+    failed INT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_import_jobs_unfinished ON import_jobs(created_at) WHERE status IN ('PENDING', 'RUNNING');
+
+CREATE TABLE IF NOT EXISTS import_errors (
+    job_id UUID NOT NULL REFERENCES import_jobs(id) ON DELETE CASCADE,
+    line INT NOT NULL,
+    code VARCHAR(64) NOT NULL,
+    detail TEXT NOT NULL,
+    PRIMARY KEY (job_id, line)
+);
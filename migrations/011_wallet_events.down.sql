@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS wallet_events;
+DROP TABLE IF EXISTS wallet_event_sequences;
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS wallet_event_sequences (
+    wallet_id UUID PRIMARY KEY,
+    sequence BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS wallet_events (
+    id BIGSERIAL PRIMARY KEY,
+    wallet_id UUID NOT NULL,
+    sequence BIGINT NOT NULL,
+    currency CHAR(3) NOT NULL,
+    type VARCHAR(32) NOT NULL,
+    amount BIGINT NOT NULL,
+    balance_after BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    delivered_at TIMESTAMPTZ,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error TEXT NOT NULL DEFAULT '',
+    UNIQUE (wallet_id, sequence)
+);
+
+CREATE INDEX IF NOT EXISTS idx_wallet_events_pending ON wallet_events(id) WHERE delivered_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_wallet_events_pending_wallet ON wallet_events(wallet_id) WHERE delivered_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_wallet_events_delivered_at ON wallet_events(delivered_at) WHERE delivered_at IS NOT NULL;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
+
+ALTER TABLE wallet_events DROP COLUMN IF EXISTS reference;
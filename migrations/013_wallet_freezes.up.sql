@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS wallet_freezes (
+    wallet_id UUID PRIMARY KEY,
+    reason TEXT NOT NULL DEFAULT '',
+    frozen_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
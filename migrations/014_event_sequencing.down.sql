@@ -0,0 +1,12 @@
+-- Unnumbered events cannot take the constraint back; let the app number
+-- them first.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM wallet_events WHERE sequence IS NULL) THEN
+        RAISE EXCEPTION 'cannot restore wallet_events.sequence NOT NULL: events are still unnumbered';
+    END IF;
+END $$;
+
+DROP INDEX IF EXISTS idx_wallet_events_unsequenced;
+
+ALTER TABLE wallet_events ALTER COLUMN sequence SET NOT NULL;
@@ -0,0 +1,5 @@
+-- Events are written without a sequence and numbered after they commit by
+-- a single sequencer, so writers no longer lock a per-wallet counter.
+ALTER TABLE wallet_events ALTER COLUMN sequence DROP NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_wallet_events_unsequenced ON wallet_events(id) WHERE sequence IS NULL;
@@ -0,0 +1,12 @@
+// Package migrations embeds the SQL migrations, so that the binaries carry
+// their schema wherever they run. Every migration is a NNN_name.up.sql
+// file, with an optional NNN_name.down.sql reverting it.
+package migrations
+
+import "embed"
+
+// FS holds the Postgres migrations at its root and the SQLite ones under
+// sqlite/.
+//
+//go:embed *.sql sqlite/*.sql
+var FS embed.FS
@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
+DROP TABLE IF EXISTS wallet_events;
+DROP TABLE IF EXISTS wallet_event_sequences;
+DROP TABLE IF EXISTS import_errors;
+DROP TABLE IF EXISTS import_jobs;
+DROP TABLE IF EXISTS exchange_rates;
+DROP TABLE IF EXISTS holds;
+DROP TABLE IF EXISTS idempotency_keys;
+DROP TABLE IF EXISTS transactions;
+DROP TABLE IF EXISTS journal_entries;
+DROP TABLE IF EXISTS journals;
+DROP TABLE IF EXISTS wallets;
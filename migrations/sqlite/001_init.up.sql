@@ -0,0 +1,187 @@
+-- The SQLite schema mirrors the Postgres one as of its migration 012.
+-- Ids are UUIDs in canonical text form, timestamps UTC text with
+-- microseconds, which compares correctly as text.
+CREATE TABLE IF NOT EXISTS wallets (
+    id TEXT NOT NULL,
+    currency TEXT NOT NULL,
+    balance INTEGER NOT NULL,
+    held INTEGER NOT NULL DEFAULT 0,
+    version INTEGER NOT NULL DEFAULT 1,
+    PRIMARY KEY (id, currency),
+    CHECK (held >= 0 AND held <= balance)
+);
+
+-- Every change to a balance row bumps its version, whichever code path
+-- made it.
+CREATE TRIGGER IF NOT EXISTS wallets_bump_version
+    AFTER UPDATE OF balance, held ON wallets
+    FOR EACH ROW
+    WHEN NEW.balance IS NOT OLD.balance OR NEW.held IS NOT OLD.held
+BEGIN
+    UPDATE wallets SET version = OLD.version + 1 WHERE id = NEW.id AND currency = NEW.currency;
+END;
+
+CREATE TABLE IF NOT EXISTS journals (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    type TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS journal_entries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    journal_id INTEGER NOT NULL REFERENCES journals(id),
+    account TEXT NOT NULL,
+    currency TEXT NOT NULL,
+    amount INTEGER NOT NULL CHECK (amount <> 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_journal_entries_journal_id ON journal_entries(journal_id);
+CREATE INDEX IF NOT EXISTS idx_journal_entries_account_currency ON journal_entries(account, currency);
+
+CREATE TRIGGER IF NOT EXISTS journal_entries_immutable
+    BEFORE UPDATE ON journal_entries
+BEGIN
+    SELECT RAISE(ABORT, 'journal entries are append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS journal_entries_undeletable
+    BEFORE DELETE ON journal_entries
+BEGIN
+    SELECT RAISE(ABORT, 'journal entries are append-only');
+END;
+
+CREATE TABLE IF NOT EXISTS transactions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    journal_id INTEGER REFERENCES journals(id),
+    wallet_id TEXT NOT NULL,
+    currency TEXT NOT NULL,
+    type TEXT NOT NULL,
+    amount INTEGER NOT NULL,
+    balance_after INTEGER NOT NULL,
+    rate TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_transactions_wallet_id ON transactions(wallet_id, id DESC);
+
+CREATE TRIGGER IF NOT EXISTS transactions_immutable
+    BEFORE UPDATE ON transactions
+BEGIN
+    SELECT RAISE(ABORT, 'transactions ledger is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS transactions_undeletable
+    BEFORE DELETE ON transactions
+BEGIN
+    SELECT RAISE(ABORT, 'transactions ledger is append-only');
+END;
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key TEXT PRIMARY KEY,
+    request_hash TEXT NOT NULL,
+    status_code INTEGER,
+    response BLOB,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys(created_at);
+
+CREATE TABLE IF NOT EXISTS holds (
+    id TEXT PRIMARY KEY,
+    wallet_id TEXT NOT NULL,
+    currency TEXT NOT NULL,
+    amount INTEGER NOT NULL CHECK (amount > 0),
+    captured INTEGER NOT NULL DEFAULT 0,
+    status TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now')),
+    updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_holds_wallet_id ON holds(wallet_id);
+CREATE INDEX IF NOT EXISTS idx_holds_active_expires_at ON holds(expires_at) WHERE status = 'ACTIVE';
+
+-- Rates are kept as the decimal text they were given in.
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    base TEXT NOT NULL,
+    quote TEXT NOT NULL,
+    rate TEXT NOT NULL CHECK (CAST(rate AS REAL) > 0),
+    effective_from TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now')),
+    PRIMARY KEY (base, quote, effective_from)
+);
+
+CREATE TABLE IF NOT EXISTS import_jobs (
+    id TEXT PRIMARY KEY,
+    format TEXT NOT NULL,
+    data BLOB NOT NULL,
+    status TEXT NOT NULL,
+    last_line INTEGER NOT NULL DEFAULT 0,
+    succeeded INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now')),
+    updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_import_jobs_unfinished ON import_jobs(created_at) WHERE status IN ('PENDING', 'RUNNING');
+
+CREATE TABLE IF NOT EXISTS import_errors (
+    job_id TEXT NOT NULL REFERENCES import_jobs(id) ON DELETE CASCADE,
+    line INTEGER NOT NULL,
+    code TEXT NOT NULL,
+    detail TEXT NOT NULL,
+    PRIMARY KEY (job_id, line)
+);
+
+CREATE TABLE IF NOT EXISTS wallet_event_sequences (
+    wallet_id TEXT PRIMARY KEY,
+    sequence INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS wallet_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    wallet_id TEXT NOT NULL,
+    sequence INTEGER NOT NULL,
+    currency TEXT NOT NULL,
+    type TEXT NOT NULL,
+    amount INTEGER NOT NULL,
+    balance_after INTEGER NOT NULL,
+    reference TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now')),
+    delivered_at TIMESTAMP,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now')),
+    last_error TEXT NOT NULL DEFAULT '',
+    UNIQUE (wallet_id, sequence)
+);
+
+CREATE INDEX IF NOT EXISTS idx_wallet_events_pending ON wallet_events(id) WHERE delivered_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_wallet_events_pending_wallet ON wallet_events(wallet_id) WHERE delivered_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_wallet_events_delivered_at ON wallet_events(delivered_at) WHERE delivered_at IS NOT NULL;
+
+-- events is a JSON array of event types.
+CREATE TABLE IF NOT EXISTS webhooks (
+    id TEXT PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    events TEXT NOT NULL,
+    active INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    webhook_id TEXT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_type TEXT NOT NULL,
+    payload BLOB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'PENDING',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now')),
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now')),
+    updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_pending ON webhook_deliveries(next_attempt_at) WHERE status = 'PENDING';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_status ON webhook_deliveries(status, id);
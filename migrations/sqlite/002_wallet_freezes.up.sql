@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS wallet_freezes (
+    wallet_id TEXT PRIMARY KEY,
+    reason TEXT NOT NULL DEFAULT '',
+    frozen_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now'))
+);
@@ -0,0 +1,306 @@
+// Package walletclient is a Go client for the wallet REST API.
+//
+// Every write carries an Idempotency-Key and keeps it across retries, so a
+// retried request takes effect at most once. The key is generated per call
+// unless the context carries one from WithIdempotencyKey; passing your own
+// lets a request be retried safely after a restart too.
+//
+// Errors reported by the API are returned as *Error values that match the
+// sentinels of this package under errors.Is:
+//
+//	if errors.Is(err, walletclient.ErrInsufficientFunds) {
+//		...
+//	}
+package walletclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const maxRetryDelay = 5 * time.Second
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+
+	maxRetries int
+	retryDelay time.Duration
+}
+
+type Option func(*Client)
+
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetry sets how many times a request failing transiently (network
+// error, 5xx, 429, CONCURRENT_UPDATE) is retried and the delay before the
+// first retry; the delay doubles with every retry. 0 disables retries.
+func WithRetry(maxRetries int, baseDelay time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.retryDelay = baseDelay
+	}
+}
+
+// New returns a client of the server at baseURL, e.g.
+// "http://localhost:8080".
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		maxRetries: 3,
+		retryDelay: 100 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+type idempotencyKeyContext struct{}
+
+// WithIdempotencyKey makes the write called with the returned context use
+// key instead of a generated one.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContext{}, key)
+}
+
+// Wallet is a balance after a deposit or withdrawal.
+type Wallet struct {
+	WalletID string `json:"walletId"`
+	Currency string `json:"currency"`
+	Balance  int64  `json:"balance"`
+	Version  int64  `json:"version"`
+}
+
+// Balance is a balance of a wallet in one currency. Amounts are in minor
+// units, of which a major unit has 10^MinorUnits.
+type Balance struct {
+	WalletID         string `json:"walletId"`
+	Currency         string `json:"currency"`
+	MinorUnits       int    `json:"minorUnits"`
+	Balance          int64  `json:"balance"`
+	AvailableBalance int64  `json:"availableBalance"`
+	Version          int64  `json:"version"`
+}
+
+type TransferResult struct {
+	FromWalletID string `json:"fromWalletId"`
+	FromBalance  int64  `json:"fromBalance"`
+	ToWalletID   string `json:"toWalletId"`
+	ToBalance    int64  `json:"toBalance"`
+	Currency     string `json:"currency"`
+}
+
+type Transaction struct {
+	ID           int64     `json:"id"`
+	Currency     string    `json:"currency"`
+	Type         string    `json:"type"`
+	Amount       int64     `json:"amount"`
+	BalanceAfter int64     `json:"balanceAfter"`
+	Rate         string    `json:"rate,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// HistoryFilter narrows a history page. Zero values mean "no
+// restriction"; Cursor continues from a previous page's NextCursor.
+type HistoryFilter struct {
+	Currency string
+	Type     string
+	From     time.Time
+	To       time.Time
+	Limit    int
+	Cursor   string
+}
+
+// HistoryPage is a page of operations, newest first. NextCursor is empty
+// on the last page.
+type HistoryPage struct {
+	WalletID     string        `json:"walletId"`
+	Transactions []Transaction `json:"transactions"`
+	NextCursor   string        `json:"nextCursor,omitempty"`
+}
+
+type walletRequest struct {
+	WalletID string `json:"walletId"`
+	Currency string `json:"currency,omitempty"`
+	OpType   string `json:"operationType"`
+	Amount   int64  `json:"amount"`
+}
+
+type transferRequest struct {
+	FromWalletID string `json:"fromWalletId"`
+	ToWalletID   string `json:"toWalletId"`
+	Currency     string `json:"currency,omitempty"`
+	Amount       int64  `json:"amount"`
+}
+
+// Deposit adds amount minor units to the balance of walletID in currency,
+// or in the server's default currency when currency is empty, creating
+// the balance if needed.
+func (c *Client) Deposit(ctx context.Context, walletID, currency string, amount int64) (Wallet, error) {
+	return c.process(ctx, walletID, currency, "DEPOSIT", amount)
+}
+
+// Withdraw takes amount minor units from the balance of walletID in
+// currency, or in the server's default currency when currency is empty.
+func (c *Client) Withdraw(ctx context.Context, walletID, currency string, amount int64) (Wallet, error) {
+	return c.process(ctx, walletID, currency, "WITHDRAW", amount)
+}
+
+func (c *Client) process(ctx context.Context, walletID, currency, op string, amount int64) (Wallet, error) {
+	req := walletRequest{WalletID: walletID, Currency: currency, OpType: op, Amount: amount}
+
+	var wallet Wallet
+	err := c.do(ctx, http.MethodPost, "/api/v1/wallet", nil, req, &wallet)
+	return wallet, err
+}
+
+// Balance returns the balance of walletID in currency, or in the server's
+// default currency when currency is empty.
+func (c *Client) Balance(ctx context.Context, walletID, currency string) (Balance, error) {
+	query := url.Values{}
+	if currency != "" {
+		query.Set("currency", currency)
+	}
+
+	var balance Balance
+	err := c.do(ctx, http.MethodGet, "/api/v1/wallets/"+url.PathEscape(walletID), query, nil, &balance)
+	return balance, err
+}
+
+// Transfer moves amount minor units from fromID to toID.
+func (c *Client) Transfer(ctx context.Context, fromID, toID, currency string, amount int64) (TransferResult, error) {
+	req := transferRequest{FromWalletID: fromID, ToWalletID: toID, Currency: currency, Amount: amount}
+
+	var res TransferResult
+	err := c.do(ctx, http.MethodPost, "/api/v1/transfers", nil, req, &res)
+	return res, err
+}
+
+// History returns a page of the operations of walletID.
+func (c *Client) History(ctx context.Context, walletID string, filter HistoryFilter) (HistoryPage, error) {
+	query := url.Values{}
+	if filter.Currency != "" {
+		query.Set("currency", filter.Currency)
+	}
+	if filter.Type != "" {
+		query.Set("type", filter.Type)
+	}
+	if !filter.From.IsZero() {
+		query.Set("from", filter.From.Format(time.RFC3339))
+	}
+	if !filter.To.IsZero() {
+		query.Set("to", filter.To.Format(time.RFC3339))
+	}
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	if filter.Cursor != "" {
+		query.Set("cursor", filter.Cursor)
+	}
+
+	var page HistoryPage
+	path := "/api/v1/wallets/" + url.PathEscape(walletID) + "/transactions"
+	err := c.do(ctx, http.MethodGet, path, query, nil, &page)
+	return page, err
+}
+
+// do sends the request, with req as its JSON body if not nil, and decodes
+// the response into resp, retrying transient failures.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, req, resp any) error {
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var body []byte
+	if req != nil {
+		var err error
+		if body, err = json.Marshal(req); err != nil {
+			return err
+		}
+	}
+
+	var key string
+	if method != http.MethodGet {
+		key, _ = ctx.Value(idempotencyKeyContext{}).(string)
+		if key == "" {
+			key = uuid.NewString()
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		retry, err := c.send(ctx, method, target, key, body, resp)
+		if !retry || attempt >= c.maxRetries {
+			return err
+		}
+		if err := c.backoff(ctx, attempt); err != nil {
+			return err
+		}
+	}
+}
+
+// send makes one attempt and reports whether a failure is worth retrying.
+func (c *Client) send(ctx context.Context, method, target, key string, body []byte, resp any) (bool, error) {
+	r, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if key != "" {
+		r.Header.Set("Idempotency-Key", key)
+	}
+
+	res, err := c.httpClient.Do(r)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		err := decodeProblem(res)
+		return retryable(res.StatusCode, err), err
+	}
+
+	if resp == nil {
+		return false, nil
+	}
+	return false, json.NewDecoder(res.Body).Decode(resp)
+}
+
+// backoff waits before retry attempt (0-based): the delay doubles with
+// every attempt and is jittered so that clients failing together do not
+// retry together.
+func (c *Client) backoff(ctx context.Context, attempt int) error {
+	d := c.retryDelay << attempt
+	if d <= 0 || d > maxRetryDelay {
+		d = maxRetryDelay
+	}
+	d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
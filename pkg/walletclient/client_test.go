@@ -0,0 +1,320 @@
+package walletclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Hlompy/Wallet/internal/handler"
+	"github.com/Hlompy/Wallet/internal/repository/memory"
+	"github.com/Hlompy/Wallet/internal/service"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	walletA = "11111111-1111-1111-1111-111111111111"
+	walletB = "22222222-2222-2222-2222-222222222222"
+)
+
+// newTestClient serves the real handler over an in-memory repository,
+// behind middleware, and returns a client of it.
+func newTestClient(t *testing.T, middleware ...mux.MiddlewareFunc) *Client {
+	t.Helper()
+	return newServiceClient(t, newService(), middleware...)
+}
+
+func newService() *service.WalletService {
+	return service.New(memory.New(), service.WithDefaultCurrency("RUB"))
+}
+
+// newServiceClient is newTestClient with the handler on svc.
+func newServiceClient(t *testing.T, svc handler.WalletService, middleware ...mux.MiddlewareFunc) *Client {
+	t.Helper()
+
+	h := handler.New(svc)
+
+	r := mux.NewRouter()
+	r.Use(handler.RequestID)
+	r.Use(middleware...)
+	r.HandleFunc("/api/v1/wallet", h.PostWallet).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/transfers", h.PostTransfer).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/wallets/{id}", h.GetBalance).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/wallets/{id}/transactions", h.ListTransactions).Methods(http.MethodGet)
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	return New(srv.URL, WithRetry(2, time.Millisecond))
+}
+
+// recordKeys collects the Idempotency-Key of every request.
+type recordKeys struct {
+	mu   sync.Mutex
+	keys []string
+}
+
+func (rk *recordKeys) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rk.mu.Lock()
+		rk.keys = append(rk.keys, r.Header.Get("Idempotency-Key"))
+		rk.mu.Unlock()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func TestClient_DepositWithdrawBalance(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	wallet, err := c.Deposit(ctx, walletA, "", 1000)
+	if err != nil {
+		t.Fatalf("Deposit: %v", err)
+	}
+	if wallet.WalletID != walletA || wallet.Currency != "RUB" || wallet.Balance != 1000 {
+		t.Errorf("unexpected wallet after deposit: %+v", wallet)
+	}
+
+	wallet, err = c.Withdraw(ctx, walletA, "RUB", 300)
+	if err != nil {
+		t.Fatalf("Withdraw: %v", err)
+	}
+	if wallet.Balance != 700 || wallet.Version != 2 {
+		t.Errorf("unexpected wallet after withdraw: %+v", wallet)
+	}
+
+	balance, err := c.Balance(ctx, walletA, "")
+	if err != nil {
+		t.Fatalf("Balance: %v", err)
+	}
+	want := Balance{WalletID: walletA, Currency: "RUB", MinorUnits: 2, Balance: 700, AvailableBalance: 700, Version: 2}
+	if balance != want {
+		t.Errorf("expected %+v, got %+v", want, balance)
+	}
+}
+
+func TestClient_Transfer(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	for _, id := range []string{walletA, walletB} {
+		if _, err := c.Deposit(ctx, id, "", 500); err != nil {
+			t.Fatalf("Deposit: %v", err)
+		}
+	}
+
+	res, err := c.Transfer(ctx, walletA, walletB, "", 200)
+	if err != nil {
+		t.Fatalf("Transfer: %v", err)
+	}
+	want := TransferResult{FromWalletID: walletA, FromBalance: 300, ToWalletID: walletB, ToBalance: 700, Currency: "RUB"}
+	if res != want {
+		t.Errorf("expected %+v, got %+v", want, res)
+	}
+}
+
+func TestClient_History(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	for _, amount := range []int64{100, 200, 300} {
+		if _, err := c.Deposit(ctx, walletA, "", amount); err != nil {
+			t.Fatalf("Deposit: %v", err)
+		}
+	}
+	if _, err := c.Withdraw(ctx, walletA, "", 50); err != nil {
+		t.Fatalf("Withdraw: %v", err)
+	}
+
+	var amounts []int64
+	filter := HistoryFilter{Type: "DEPOSIT", Limit: 2}
+	for {
+		page, err := c.History(ctx, walletA, filter)
+		if err != nil {
+			t.Fatalf("History: %v", err)
+		}
+		for _, tx := range page.Transactions {
+			amounts = append(amounts, tx.Amount)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+
+	want := []int64{300, 200, 100}
+	if len(amounts) != len(want) {
+		t.Fatalf("expected amounts %v, got %v", want, amounts)
+	}
+	for i := range want {
+		if amounts[i] != want[i] {
+			t.Fatalf("expected amounts %v, got %v", want, amounts)
+		}
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	if _, err := c.Deposit(ctx, walletA, "", 100); err != nil {
+		t.Fatalf("Deposit: %v", err)
+	}
+
+	_, err := c.Withdraw(ctx, walletA, "", 500)
+	if !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("expected ErrInsufficientFunds, got %v", err)
+	}
+	var e *Error
+	if !errors.As(err, &e) || e.Details["available"] != float64(100) {
+		t.Errorf("expected details with available balance, got %+v", e)
+	}
+
+	if _, err := c.Balance(ctx, walletB, ""); !errors.Is(err, ErrWalletNotFound) {
+		t.Errorf("expected ErrWalletNotFound, got %v", err)
+	}
+
+	if _, err := c.Transfer(ctx, walletA, walletA, "", 1); !errors.Is(err, ErrSameWallet) {
+		t.Errorf("expected ErrSameWallet, got %v", err)
+	}
+
+	_, err = c.Deposit(ctx, "not-a-uuid", "", 1)
+	if !errors.As(err, &e) || e.Code != "VALIDATION_FAILED" || e.Status != http.StatusBadRequest {
+		t.Errorf("expected VALIDATION_FAILED, got %v", err)
+	}
+}
+
+func TestClient_RetryKeepsIdempotencyKey(t *testing.T) {
+	keys := &recordKeys{}
+	var lost sync.Once
+
+	// The first deposit reaches the service, but its response is lost.
+	loseFirstResponse := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lose := false
+			lost.Do(func() { lose = true })
+			if lose {
+				next.ServeHTTP(httptest.NewRecorder(), r)
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	c := newTestClient(t, keys.middleware, loseFirstResponse)
+	ctx := context.Background()
+
+	wallet, err := c.Deposit(ctx, walletA, "", 100)
+	if err != nil {
+		t.Fatalf("Deposit: %v", err)
+	}
+	if wallet.Balance != 100 {
+		t.Errorf("expected the deposit applied once, got balance %d", wallet.Balance)
+	}
+
+	if len(keys.keys) != 2 || keys.keys[0] == "" || keys.keys[0] != keys.keys[1] {
+		t.Errorf("expected one key sent twice, got %q", keys.keys)
+	}
+
+	if _, err := c.Deposit(ctx, walletA, "", 100); err != nil {
+		t.Fatalf("Deposit: %v", err)
+	}
+	if keys.keys[2] == keys.keys[0] {
+		t.Error("expected a new key for a new call")
+	}
+}
+
+// contendedService fails the first failures operations with
+// CONCURRENT_UPDATE, as the repository does when its retries run out.
+type contendedService struct {
+	*service.WalletService
+	failures int
+	calls    int
+}
+
+func (s *contendedService) Process(ctx context.Context, walletID, currency, op string, amount, ifVersion int64) error {
+	s.calls++
+	if s.calls <= s.failures {
+		return ErrConcurrentUpdate
+	}
+	return s.WalletService.Process(ctx, walletID, currency, op, amount, ifVersion)
+}
+
+func TestClient_RetriesConcurrentUpdate(t *testing.T) {
+	keys := &recordKeys{}
+	svc := &contendedService{WalletService: newService(), failures: 2}
+	c := newServiceClient(t, svc, keys.middleware)
+
+	wallet, err := c.Deposit(context.Background(), walletA, "", 100)
+	if err != nil {
+		t.Fatalf("Deposit: %v", err)
+	}
+	if wallet.Balance != 100 {
+		t.Errorf("expected balance 100, got %d", wallet.Balance)
+	}
+
+	if svc.calls != 3 {
+		t.Errorf("expected every retry to reach the service, got %d calls", svc.calls)
+	}
+	if len(keys.keys) != 3 || keys.keys[0] != keys.keys[2] {
+		t.Errorf("expected one key sent 3 times, got %q", keys.keys)
+	}
+}
+
+func TestClient_RetryGivesUp(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	unavailable := func(http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			attempts++
+			mu.Unlock()
+			w.WriteHeader(http.StatusServiceUnavailable)
+		})
+	}
+
+	c := newTestClient(t, unavailable)
+
+	if _, err := c.Balance(context.Background(), walletA, ""); err == nil {
+		t.Fatal("expected an error")
+	}
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestClient_NoRetryOnDomainError(t *testing.T) {
+	keys := &recordKeys{}
+	c := newTestClient(t, keys.middleware)
+
+	if _, err := c.Withdraw(context.Background(), walletA, "", 1); !errors.Is(err, ErrWalletNotFound) {
+		t.Fatalf("expected ErrWalletNotFound, got %v", err)
+	}
+	if len(keys.keys) != 1 {
+		t.Errorf("expected 1 attempt, got %d", len(keys.keys))
+	}
+}
+
+func TestWithIdempotencyKey(t *testing.T) {
+	c := newTestClient(t)
+	ctx := WithIdempotencyKey(context.Background(), "payout-42")
+
+	for range 2 {
+		wallet, err := c.Deposit(ctx, walletA, "", 100)
+		if err != nil {
+			t.Fatalf("Deposit: %v", err)
+		}
+		if wallet.Balance != 100 {
+			t.Errorf("expected the replayed deposit, got balance %d", wallet.Balance)
+		}
+	}
+
+	if _, err := c.Deposit(ctx, walletA, "", 200); !errors.Is(err, ErrIdempotencyConflict) {
+		t.Errorf("expected ErrIdempotencyConflict, got %v", err)
+	}
+}
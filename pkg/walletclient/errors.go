@@ -0,0 +1,68 @@
+package walletclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	appErr "github.com/Hlompy/Wallet/internal/errors"
+)
+
+// Error is an error reported by the API. Code is the stable code of the
+// problem document, Details its details; JSON numbers in Details are
+// float64.
+type Error = appErr.Error
+
+// The errors the client methods can return, for errors.Is. An error
+// without a sentinel here, such as VALIDATION_FAILED, is still an *Error
+// with its code.
+var (
+	ErrInsufficientFunds   = appErr.ErrInsufficientFunds
+	ErrWalletNotFound      = appErr.ErrWalletNotFound
+	ErrWalletFrozen        = appErr.ErrWalletFrozen
+	ErrInvalidOperation    = appErr.ErrInvalidOperation
+	ErrInvalidCurrency     = appErr.ErrInvalidCurrency
+	ErrCurrencyMismatch    = appErr.ErrCurrencyMismatch
+	ErrSameWallet          = appErr.ErrSameWallet
+	ErrInvalidCursor       = appErr.ErrInvalidCursor
+	ErrInvalidDateRange    = appErr.ErrInvalidDateRange
+	ErrIdempotencyConflict = appErr.ErrIdempotencyConflict
+	ErrConcurrentUpdate    = appErr.ErrConcurrentUpdate
+)
+
+// maxProblemBytes bounds how much of an error response is read.
+const maxProblemBytes = 64 << 10
+
+type problem struct {
+	Status  int            `json:"status"`
+	Code    string         `json:"code"`
+	Detail  string         `json:"detail"`
+	Details map[string]any `json:"details"`
+}
+
+// decodeProblem turns an error response into the domain error it
+// describes. A response that is not a problem document, e.g. from a
+// proxy, gives a plain error with the status.
+func decodeProblem(res *http.Response) error {
+	var p problem
+	err := json.NewDecoder(io.LimitReader(res.Body, maxProblemBytes)).Decode(&p)
+	if err != nil || p.Code == "" {
+		return fmt.Errorf("walletclient: %s %s: %s", res.Request.Method, res.Request.URL.Path, res.Status)
+	}
+
+	if e, ok := appErr.FromCode(p.Code); ok {
+		return e.WithDetails(p.Details)
+	}
+	return appErr.New(p.Code, res.StatusCode, p.Detail).WithDetails(p.Details)
+}
+
+// retryable reports whether a request that failed with status and err may
+// succeed when sent again.
+func retryable(status int, err error) bool {
+	if errors.Is(err, ErrConcurrentUpdate) {
+		return true
+	}
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
@@ -0,0 +1,62 @@
+package walletclient
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func response(status int, body string) *http.Response {
+	rec := httptest.NewRecorder()
+	rec.WriteHeader(status)
+	rec.WriteString(body)
+
+	res := rec.Result()
+	res.Request = httptest.NewRequest(http.MethodPost, "/api/v1/wallet", nil)
+	return res
+}
+
+func TestDecodeProblem(t *testing.T) {
+	err := decodeProblem(response(http.StatusConflict, `{"status":409,"code":"WALLET_FROZEN","detail":"wallet is frozen","details":{"walletId":"x"}}`))
+	if !errors.Is(err, ErrWalletFrozen) {
+		t.Fatalf("expected ErrWalletFrozen, got %v", err)
+	}
+	if e, _ := err.(*Error); e.Details["walletId"] != "x" {
+		t.Errorf("expected details, got %+v", e)
+	}
+
+	err = decodeProblem(response(http.StatusUnauthorized, `{"status":401,"code":"UNAUTHORIZED","detail":"unauthorized"}`))
+	e, ok := err.(*Error)
+	if !ok || e.Code != "UNAUTHORIZED" || e.Status != http.StatusUnauthorized || e.Message != "unauthorized" {
+		t.Errorf("expected UNAUTHORIZED error, got %#v", err)
+	}
+
+	err = decodeProblem(response(http.StatusBadGateway, `<html>bad gateway</html>`))
+	if _, ok := err.(*Error); ok || !strings.Contains(err.Error(), "502") {
+		t.Errorf("expected a plain error with the status, got %v", err)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		status int
+		err    error
+		want   bool
+	}{
+		{http.StatusInternalServerError, nil, true},
+		{http.StatusServiceUnavailable, nil, true},
+		{http.StatusTooManyRequests, nil, true},
+		{http.StatusConflict, ErrConcurrentUpdate, true},
+		{http.StatusConflict, ErrIdempotencyConflict, false},
+		{http.StatusBadRequest, ErrInsufficientFunds, false},
+		{http.StatusNotFound, ErrWalletNotFound, false},
+	}
+
+	for _, tt := range tests {
+		if got := retryable(tt.status, tt.err); got != tt.want {
+			t.Errorf("retryable(%d, %v) = %v, want %v", tt.status, tt.err, got, tt.want)
+		}
+	}
+}